@@ -24,16 +24,22 @@ import (
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/shell"
+	"github.com/charmbracelet/crush/internal/usage"
 )
 
 type App struct {
 	Sessions    session.Service
 	Messages    message.Service
 	History     history.Service
+	Usage       usage.Service
 	Permissions permission.Service
 
 	CoderAgent agent.Service
 
+	agentCfg          config.Agent
+	agentEventsCancel context.CancelFunc
+
 	LSPClients map[string]*lsp.Client
 
 	clientsMutex sync.RWMutex
@@ -69,6 +75,7 @@ func New(ctx context.Context, conn *sql.DB, cfg *config.Config) (*App, error) {
 		Sessions:    sessions,
 		Messages:    messages,
 		History:     files,
+		Usage:       usage.NewService(q),
 		Permissions: permission.NewPermissionService(cfg.WorkingDir(), skipPermissionsRequests, allowedTools),
 		LSPClients:  make(map[string]*lsp.Client),
 
@@ -169,6 +176,12 @@ func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool
 				return fmt.Errorf("agent processing failed: %w", result.Error)
 			}
 
+			if result.Message.FinishReason() == message.FinishReasonBudgetExceeded {
+				details := result.Message.FinishPart().Details
+				slog.Info("Non-interactive: budget exceeded", "session_id", sess.ID, "reason", details)
+				return fmt.Errorf("%w: %s", agent.ErrBudgetExceeded, details)
+			}
+
 			msgContent := result.Message.Content().String()
 			if len(msgContent) < readBts {
 				slog.Error("Non-interactive: message content is shorter than read bytes", "message_length", len(msgContent), "read_bytes", readBts)
@@ -207,6 +220,8 @@ func (app *App) setupEvents() {
 	setupSubscriber(ctx, app.serviceEventsWG, "permissions", app.Permissions.Subscribe, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "permissions-notifications", app.Permissions.SubscribeNotifications, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "history", app.History.Subscribe, app.events)
+	setupSubscriber(ctx, app.serviceEventsWG, "jobs", shell.BackgroundJobs().Subscribe, app.events)
+	app.killDeletedSessionJobs(ctx)
 	cleanupFunc := func() {
 		cancel()
 		app.serviceEventsWG.Wait()
@@ -214,6 +229,20 @@ func (app *App) setupEvents() {
 	app.cleanupFuncs = append(app.cleanupFuncs, cleanupFunc)
 }
 
+// killDeletedSessionJobs stops the background jobs of sessions when they are
+// deleted.
+func (app *App) killDeletedSessionJobs(ctx context.Context) {
+	app.serviceEventsWG.Add(1)
+	go func() {
+		defer app.serviceEventsWG.Done()
+		for event := range app.Sessions.Subscribe(ctx) {
+			if event.Type == pubsub.DeletedEvent {
+				shell.BackgroundJobs().KillSession(event.Payload.ID)
+			}
+		}
+	}()
+}
+
 func setupSubscriber[T any](
 	ctx context.Context,
 	wg *sync.WaitGroup,
@@ -254,25 +283,63 @@ func (app *App) InitCoderAgent() error {
 	if coderAgentCfg.ID == "" {
 		return fmt.Errorf("coder agent configuration is missing")
 	}
-	var err error
-	app.CoderAgent, err = agent.NewAgent(
+	if err := app.initAgent(coderAgentCfg); err != nil {
+		return err
+	}
+
+	// Add MCP client cleanup to shutdown process
+	app.cleanupFuncs = append(app.cleanupFuncs, agent.CloseMCPClients)
+	return nil
+}
+
+// SwitchAgent replaces the main agent with the configured agent with the
+// given ID.
+func (app *App) SwitchAgent(agentID string) error {
+	agentCfg, ok := app.config.Agents[agentID]
+	if !ok {
+		return fmt.Errorf("agent %q not found in config", agentID)
+	}
+	if app.CoderAgent != nil && app.CoderAgent.IsBusy() {
+		return agent.ErrSessionBusy
+	}
+	return app.initAgent(agentCfg)
+}
+
+// ActiveAgent returns the configuration of the agent currently in use.
+func (app *App) ActiveAgent() config.Agent {
+	return app.agentCfg
+}
+
+func (app *App) initAgent(agentCfg config.Agent) error {
+	coderAgent, err := agent.NewAgent(
 		app.globalCtx,
-		coderAgentCfg,
+		agentCfg,
 		app.Permissions,
 		app.Sessions,
 		app.Messages,
 		app.History,
+		app.Usage,
 		app.LSPClients,
 	)
 	if err != nil {
-		slog.Error("Failed to create coder agent", "err", err)
+		slog.Error("Failed to create agent", "agent", agentCfg.ID, "err", err)
 		return err
 	}
 
-	// Add MCP client cleanup to shutdown process
-	app.cleanupFuncs = append(app.cleanupFuncs, agent.CloseMCPClients)
+	// Stop forwarding events from the agent being replaced, and stop the
+	// agent itself.
+	if app.agentEventsCancel != nil {
+		app.agentEventsCancel()
+	}
+	if app.CoderAgent != nil {
+		app.CoderAgent.Shutdown()
+	}
+	ctx, cancel := context.WithCancel(app.eventsCtx)
+	app.agentEventsCancel = cancel
+	app.CoderAgent = coderAgent
+	app.agentCfg = agentCfg
 
-	setupSubscriber(app.eventsCtx, app.serviceEventsWG, "coderAgent", app.CoderAgent.Subscribe, app.events)
+	setupSubscriber(ctx, app.serviceEventsWG, "coderAgent", app.CoderAgent.Subscribe, app.events)
 	return nil
 }
 
@@ -313,6 +380,9 @@ func (app *App) Shutdown() {
 		app.CoderAgent.CancelAll()
 	}
 
+	// Stop the commands still running in the background.
+	shell.BackgroundJobs().Shutdown()
+
 	for cancel := range app.watcherCancelFuncs.Seq() {
 		cancel()
 	}
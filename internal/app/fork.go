@@ -0,0 +1,75 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// Fork creates a new session holding a copy of the conversation up to and
+// including the given message. The origin session is left untouched, so both
+// can be continued independently.
+func (app *App) Fork(ctx context.Context, sessionID, messageID string) (session.Session, error) {
+	if app.CoderAgent != nil && app.CoderAgent.IsSessionBusy(sessionID) {
+		return session.Session{}, agent.ErrSessionBusy
+	}
+
+	origin, err := app.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	msgs, err := app.Messages.List(ctx, sessionID)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to list messages: %w", err)
+	}
+	idx := slices.IndexFunc(msgs, func(msg message.Message) bool {
+		return msg.ID == messageID
+	})
+	if idx == -1 {
+		return session.Session{}, fmt.Errorf("message %s not found in session %s", messageID, sessionID)
+	}
+
+	// Keep the results of the tool calls made by the fork point, otherwise
+	// the provider would reject the history as incomplete.
+	end := idx + 1
+	for end < len(msgs) && msgs[end].Role == message.Tool {
+		end++
+	}
+
+	fork, err := app.Sessions.CreateForkSession(ctx, origin, messageID)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to create session: %w", err)
+	}
+	copiedIDs := make(map[string]string, end)
+	for _, msg := range msgs[:end] {
+		if part := msg.SummaryPart(); part != nil && part.KeepFromMessageID != "" {
+			msg.Parts = slices.Clone(msg.Parts)
+			for i, p := range msg.Parts {
+				if summary, ok := p.(message.Summary); ok {
+					summary.KeepFromMessageID = copiedIDs[summary.KeepFromMessageID]
+					msg.Parts[i] = summary
+				}
+			}
+		}
+		copied, err := app.Messages.Copy(ctx, fork.ID, msg)
+		if err != nil {
+			return session.Session{}, fmt.Errorf("failed to copy message: %w", err)
+		}
+		copiedIDs[msg.ID] = copied.ID
+		if msg.ID == origin.SummaryMessageID {
+			fork.SummaryMessageID = copied.ID
+		}
+	}
+
+	if fork.SummaryMessageID != "" {
+		fork, err = app.Sessions.Save(ctx, fork)
+		if err != nil {
+			return session.Session{}, fmt.Errorf("failed to save session: %w", err)
+		}
+	}
+	return fork, nil
+}
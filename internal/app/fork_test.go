@@ -0,0 +1,62 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFork(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	conn, err := db.Connect(ctx, t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	q := db.New(conn)
+	app := &App{
+		Sessions: session.NewService(q),
+		Messages: message.NewService(q),
+	}
+
+	origin, err := app.Sessions.Create(ctx, "origin")
+	require.NoError(t, err)
+
+	create := func(role message.MessageRole, parts ...message.ContentPart) message.Message {
+		msg, err := app.Messages.Create(ctx, origin.ID, message.CreateMessageParams{
+			Role:  role,
+			Parts: parts,
+		})
+		require.NoError(t, err)
+		return msg
+	}
+	create(message.User, message.TextContent{Text: "first"})
+	call := create(message.Assistant, message.ToolCall{ID: "call", Name: "ls", Finished: true})
+	create(message.Tool, message.ToolResult{ToolCallID: "call", Content: "files"})
+	create(message.Assistant, message.TextContent{Text: "done"})
+
+	fork, err := app.Fork(ctx, origin.ID, call.ID)
+	require.NoError(t, err)
+	require.Equal(t, origin.ID, fork.ForkedFromSessionID)
+	require.Equal(t, call.ID, fork.ForkedFromMessageID)
+
+	// The tool result answering the fork point comes along.
+	msgs, err := app.Messages.List(ctx, fork.ID)
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+	require.Equal(t, "first", msgs[0].Content().Text)
+	require.Equal(t, "call", msgs[1].ToolCalls()[0].ID)
+	require.Equal(t, "files", msgs[2].ToolResults()[0].Content)
+
+	// The origin is left untouched.
+	msgs, err = app.Messages.List(ctx, origin.ID)
+	require.NoError(t, err)
+	require.Len(t, msgs, 4)
+
+	_, err = app.Fork(ctx, origin.ID, "missing")
+	require.Error(t, err)
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+var ErrNotACheckpoint = errors.New("sessions can only be rewound to a user message")
+
+// Checkpoints returns the user messages of a session. Each of them is a point
+// the session can be rewound to.
+func (app *App) Checkpoints(ctx context.Context, sessionID string) ([]message.Message, error) {
+	msgs, err := app.Messages.List(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(msgs, func(msg message.Message) bool {
+		return msg.Role != message.User
+	}), nil
+}
+
+// Rewind takes a session back to the moment right before the given user
+// message was sent. Files changed since then are restored, files created since
+// then are removed, and the message is deleted together with everything that
+// came after it.
+func (app *App) Rewind(ctx context.Context, sessionID, messageID string) error {
+	return app.truncate(ctx, sessionID, messageID, true)
+}
+
+// Resend replaces the given user message with a new prompt. The message and
+// everything after it are discarded, file changes made since then are undone
+// when restoreFiles is set, and the agent runs again from that point.
+func (app *App) Resend(ctx context.Context, sessionID, messageID, prompt string, attachments []message.Attachment, restoreFiles bool) (<-chan agent.AgentEvent, error) {
+	if app.CoderAgent == nil {
+		return nil, errors.New("coder agent is not initialized")
+	}
+	if err := app.truncate(ctx, sessionID, messageID, restoreFiles); err != nil {
+		return nil, err
+	}
+	return app.CoderAgent.Run(ctx, sessionID, prompt, attachments...)
+}
+
+// truncate deletes the given user message and everything after it, restoring
+// the files changed since then if asked to.
+func (app *App) truncate(ctx context.Context, sessionID, messageID string, restoreFiles bool) error {
+	if app.CoderAgent != nil && app.CoderAgent.IsSessionBusy(sessionID) {
+		return agent.ErrSessionBusy
+	}
+
+	checkpoint, err := app.Messages.Get(ctx, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to get message: %w", err)
+	}
+	if checkpoint.SessionID != sessionID {
+		return fmt.Errorf("message %s not found in session %s", messageID, sessionID)
+	}
+	if checkpoint.Role != message.User {
+		return ErrNotACheckpoint
+	}
+
+	if restoreFiles {
+		msgs, err := app.Messages.List(ctx, sessionID)
+		if err != nil {
+			return fmt.Errorf("failed to list messages: %w", err)
+		}
+		idx := slices.IndexFunc(msgs, func(msg message.Message) bool {
+			return msg.ID == messageID
+		})
+		if idx == -1 {
+			return fmt.Errorf("message %s not found in session %s", messageID, sessionID)
+		}
+		var dropped []string
+		for _, msg := range msgs[idx:] {
+			dropped = append(dropped, msg.ID)
+		}
+		if err := app.History.RestoreSessionFiles(ctx, sessionID, dropped); err != nil {
+			return fmt.Errorf("failed to restore files: %w", err)
+		}
+	}
+
+	sess, err := app.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	deleted, err := app.Messages.Truncate(ctx, sessionID, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to delete messages: %w", err)
+	}
+	if slices.ContainsFunc(deleted, func(msg message.Message) bool {
+		return msg.ID == sess.SummaryMessageID
+	}) {
+		sess.SummaryMessageID = ""
+		if _, err := app.Sessions.Save(ctx, sess); err != nil {
+			return fmt.Errorf("failed to save session: %w", err)
+		}
+	}
+	return nil
+}
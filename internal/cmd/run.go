@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"strings"
 
+	"github.com/charmbracelet/crush/internal/config"
 	"github.com/spf13/cobra"
 )
 
@@ -22,6 +23,12 @@ echo "What is this code doing?" | crush run
 
 # Run with quiet mode (no spinner)
 crush run -q "Generate a README for this project"
+
+# Run with a custom agent defined in crush.json
+crush run --agent reviewer "Review the changes on this branch"
+
+# Stop after 20 turns or once a dollar has been spent
+crush run --max-turns 20 --max-cost 1 "Fix the failing tests"
   `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		quiet, _ := cmd.Flags().GetBool("quiet")
@@ -36,6 +43,14 @@ crush run -q "Generate a README for this project"
 			return fmt.Errorf("no providers configured - please run 'crush' to set up a provider interactively")
 		}
 
+		applyBudgetFlags(cmd, app.Config())
+
+		if agentID, _ := cmd.Flags().GetString("agent"); agentID != "" {
+			if err := app.SwitchAgent(agentID); err != nil {
+				return err
+			}
+		}
+
 		prompt := strings.Join(args, " ")
 
 		prompt, err = MaybePrependStdin(prompt)
@@ -53,6 +68,31 @@ crush run -q "Generate a README for this project"
 	},
 }
 
+// applyBudgetFlags overrides the configured budget with the limits given on
+// the command line.
+func applyBudgetFlags(cmd *cobra.Command, cfg *config.Config) {
+	flags := cmd.Flags()
+	if !flags.Changed("max-turns") && !flags.Changed("max-tokens") && !flags.Changed("max-cost") {
+		return
+	}
+	if cfg.Options.Budget == nil {
+		cfg.Options.Budget = &config.Budget{}
+	}
+	if flags.Changed("max-turns") {
+		cfg.Options.Budget.MaxTurns, _ = flags.GetInt("max-turns")
+	}
+	if flags.Changed("max-tokens") {
+		cfg.Options.Budget.MaxTokens, _ = flags.GetInt64("max-tokens")
+	}
+	if flags.Changed("max-cost") {
+		cfg.Options.Budget.MaxCost, _ = flags.GetFloat64("max-cost")
+	}
+}
+
 func init() {
 	runCmd.Flags().BoolP("quiet", "q", false, "Hide spinner")
+	runCmd.Flags().String("agent", "", "ID of the agent to run the prompt with")
+	runCmd.Flags().Int("max-turns", 0, "Maximum number of agent turns (0 means no limit)")
+	runCmd.Flags().Int64("max-tokens", 0, "Maximum number of tokens to use (0 means no limit)")
+	runCmd.Flags().Float64("max-cost", 0, "Maximum cost in USD to spend (0 means no limit)")
 }
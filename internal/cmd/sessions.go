@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Manage sessions",
+	Long:  `List the sessions of the current project, rewind them to an earlier point or fork them into a new session.`,
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List sessions",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		sessions, err := app.Sessions.List(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to list sessions: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTITLE\tMESSAGES\tUPDATED")
+		for _, s := range sessions {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", s.ID, s.Title, s.MessageCount, time.Unix(s.UpdatedAt, 0).Format(time.DateTime))
+		}
+		return w.Flush()
+	},
+}
+
+var sessionsRewindCmd = &cobra.Command{
+	Use:   "rewind <session> [message]",
+	Short: "Rewind a session to one of its user messages",
+	Long: `Rewind a session to the moment right before the given user message was sent.
+Files changed by the agent since then are restored, files it created are removed,
+and the message is deleted along with everything after it.
+Without a message ID, the messages the session can be rewound to are listed.`,
+	Example: `
+# List the points a session can be rewound to
+crush sessions rewind 5f1c0e4a-...
+
+# Rewind a session
+crush sessions rewind 5f1c0e4a-... 9b7d2c31-...
+  `,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		sessionID := args[0]
+		if len(args) == 1 {
+			checkpoints, err := app.Checkpoints(cmd.Context(), sessionID)
+			if err != nil {
+				return fmt.Errorf("failed to list checkpoints: %w", err)
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "MESSAGE\tCREATED\tPROMPT")
+			for _, msg := range checkpoints {
+				prompt, _, _ := strings.Cut(msg.Content().Text, "\n")
+				if len(prompt) > 60 {
+					prompt = prompt[:57] + "..."
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", msg.ID, time.Unix(msg.CreatedAt, 0).Format(time.DateTime), prompt)
+			}
+			return w.Flush()
+		}
+
+		if err := app.Rewind(cmd.Context(), sessionID, args[1]); err != nil {
+			return err
+		}
+		fmt.Println("Session rewound")
+		return nil
+	},
+}
+
+var sessionsForkCmd = &cobra.Command{
+	Use:   "fork <session> <message>",
+	Short: "Fork a session from one of its messages",
+	Long: `Create a new session holding a copy of the conversation up to and including
+the given message. The original session is left untouched.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		fork, err := app.Fork(cmd.Context(), args[0], args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Println(fork.ID)
+		return nil
+	},
+}
+
+func init() {
+	sessionsCmd.AddCommand(sessionsListCmd)
+	sessionsCmd.AddCommand(sessionsRewindCmd)
+	sessionsCmd.AddCommand(sessionsForkCmd)
+	rootCmd.AddCommand(sessionsCmd)
+}
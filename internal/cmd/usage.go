@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/usage"
+	"github.com/spf13/cobra"
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Report token usage and cost",
+	Long: `Report the tokens and cost of every call made to the providers, agent requests,
+titles and summaries included, totaled by day, project, model or session.
+Sessions include the tasks they started. Other projects can be added to the
+report with --project.`,
+	Example: `
+# Cost per day of the current project
+crush usage
+
+# Cost per model of two projects in August, as CSV
+crush usage --by model --since 2025-08-01 --until 2025-08-31 --format csv --project ~/src/api --project ~/src/web
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		by, _ := cmd.Flags().GetString("by")
+		format, _ := cmd.Flags().GetString("format")
+		projects, _ := cmd.Flags().GetStringArray("project")
+		since, until, err := usagePeriod(cmd)
+		if err != nil {
+			return err
+		}
+
+		if len(projects) == 0 {
+			cwd, err := ResolveCwd(cmd)
+			if err != nil {
+				return err
+			}
+			projects = []string{cwd}
+		}
+
+		var records []usage.Record
+		titles := map[string]string{}
+		for _, project := range projects {
+			projectRecords, err := projectUsage(cmd.Context(), project, since, until, usage.GroupBy(by) == usage.GroupBySession, titles)
+			if err != nil {
+				return err
+			}
+			records = append(records, projectRecords...)
+		}
+
+		totals, err := usage.Totals(records, usage.GroupBy(by))
+		if err != nil {
+			return err
+		}
+		for i := range totals {
+			totals[i].Title = titles[totals[i].Key]
+		}
+
+		switch format {
+		case "table":
+			return writeUsageTable(os.Stdout, totals)
+		case "csv":
+			return writeUsageCSV(os.Stdout, totals)
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(totals)
+		default:
+			return fmt.Errorf("unknown format %q, use one of table, csv or json", format)
+		}
+	},
+}
+
+func init() {
+	usageCmd.Flags().String("by", string(usage.GroupByDay), "Total by day, project, model or session")
+	usageCmd.Flags().String("format", "table", "Output format: table, csv or json")
+	usageCmd.Flags().String("since", "", "First day to report, as YYYY-MM-DD")
+	usageCmd.Flags().String("until", "", "Last day to report, as YYYY-MM-DD")
+	usageCmd.Flags().StringArray("project", nil, "Project directory to report on, can be repeated (default: the current directory)")
+	rootCmd.AddCommand(usageCmd)
+}
+
+// usagePeriod returns the period the report covers, from the start of the
+// first day to the end of the last one.
+func usagePeriod(cmd *cobra.Command) (time.Time, time.Time, error) {
+	since, until := time.Unix(0, 0), time.Now().Add(time.Minute)
+	if value, _ := cmd.Flags().GetString("since"); value != "" {
+		day, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+		if err != nil {
+			return since, until, fmt.Errorf("invalid --since date: %w", err)
+		}
+		since = day
+	}
+	if value, _ := cmd.Flags().GetString("until"); value != "" {
+		day, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+		if err != nil {
+			return since, until, fmt.Errorf("invalid --until date: %w", err)
+		}
+		until = day.AddDate(0, 0, 1)
+	}
+	return since, until, nil
+}
+
+// projectUsage reads the usage records of the project. When bySession is set,
+// records of task sessions are moved to the session that started them, and
+// the titles of the sessions are added to titles.
+func projectUsage(ctx context.Context, project string, since, until time.Time, bySession bool, titles map[string]string) ([]usage.Record, error) {
+	dataDir, err := config.DataDirectory(project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration of %s: %w", project, err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "crush.db")); os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "No Crush data found in %s\n", project)
+		return nil, nil
+	}
+	conn, err := db.Connect(ctx, dataDir)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	q := db.New(conn)
+	records, err := usage.NewService(q).List(ctx, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list usage of %s: %w", project, err)
+	}
+	if !bySession {
+		return records, nil
+	}
+
+	sessions := session.NewService(q)
+	roots := map[string]string{}
+	for i, record := range records {
+		root, ok := roots[record.SessionID]
+		if !ok {
+			root = record.SessionID
+			for {
+				sess, err := sessions.Get(ctx, root)
+				if err != nil {
+					// The session was deleted, its usage is kept.
+					break
+				}
+				if sess.ParentSessionID == "" {
+					titles[root] = sess.Title
+					break
+				}
+				root = sess.ParentSessionID
+			}
+			roots[record.SessionID] = root
+		}
+		records[i].SessionID = root
+	}
+	return records, nil
+}
+
+func writeUsageTable(out io.Writer, totals []usage.Total) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tCALLS\tINPUT\tOUTPUT\tCACHE WRITE\tCACHE READ\tCOST\tAVG LATENCY")
+	var sum usage.Total
+	for _, t := range totals {
+		key := t.Key
+		if t.Title != "" {
+			key = fmt.Sprintf("%s (%s)", t.Title, t.Key)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t$%.2f\t%s\n", key, t.Calls, t.InputTokens, t.OutputTokens, t.CacheCreationTokens, t.CacheReadTokens, t.Cost, time.Duration(t.AvgLatencyMs)*time.Millisecond)
+		sum.Calls += t.Calls
+		sum.InputTokens += t.InputTokens
+		sum.OutputTokens += t.OutputTokens
+		sum.CacheCreationTokens += t.CacheCreationTokens
+		sum.CacheReadTokens += t.CacheReadTokens
+		sum.Cost += t.Cost
+	}
+	fmt.Fprintf(w, "TOTAL\t%d\t%d\t%d\t%d\t%d\t$%.2f\t\n", sum.Calls, sum.InputTokens, sum.OutputTokens, sum.CacheCreationTokens, sum.CacheReadTokens, sum.Cost)
+	return w.Flush()
+}
+
+func writeUsageCSV(out io.Writer, totals []usage.Total) error {
+	w := csv.NewWriter(out)
+	_ = w.Write([]string{"key", "title", "calls", "input_tokens", "output_tokens", "cache_creation_tokens", "cache_read_tokens", "cost", "avg_latency_ms"})
+	for _, t := range totals {
+		_ = w.Write([]string{
+			t.Key,
+			t.Title,
+			strconv.FormatInt(t.Calls, 10),
+			strconv.FormatInt(t.InputTokens, 10),
+			strconv.FormatInt(t.OutputTokens, 10),
+			strconv.FormatInt(t.CacheCreationTokens, 10),
+			strconv.FormatInt(t.CacheReadTokens, 10),
+			strconv.FormatFloat(t.Cost, 'f', 6, 64),
+			strconv.FormatInt(t.AvgLatencyMs, 10),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
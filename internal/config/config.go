@@ -18,11 +18,24 @@ import (
 )
 
 const (
-	appName              = "crush"
-	defaultDataDirectory = ".crush"
-	defaultLogLevel      = "info"
+	appName                = "crush"
+	defaultDataDirectory   = ".crush"
+	defaultLogLevel        = "info"
+	defaultToolConcurrency = 4
+
+	defaultSummarizeThreshold = 0.95
+	defaultSummarizeKeepTurns = 3
+
+	defaultRetryMaxAttempts  = 8
+	defaultRetryBaseDelayMs  = 2000
+	defaultRetryMaxDelayMs   = 60000
+	defaultBreakerThreshold  = 5
+	defaultBreakerCooldownMs = 30000
 )
 
+// 529 is Anthropic's overloaded status.
+var defaultRetryableStatusCodes = []int{429, 500, 502, 503, 504, 529}
+
 var defaultContextPaths = []string{
 	".github/copilot-instructions.md",
 	".cursorrules",
@@ -47,6 +60,9 @@ type SelectedModelType string
 const (
 	SelectedModelTypeLarge SelectedModelType = "large"
 	SelectedModelTypeSmall SelectedModelType = "small"
+	// SelectedModelTypeSummarize is optional, the small model is used for
+	// summarization when it is not set.
+	SelectedModelTypeSummarize SelectedModelType = "summarize"
 )
 
 type SelectedModel struct {
@@ -65,6 +81,10 @@ type SelectedModel struct {
 
 	// Used by anthropic models that can reason to indicate if the model should think.
 	Think bool `json:"think,omitempty" jsonschema:"description=Enable thinking mode for Anthropic models that support reasoning"`
+
+	// Models to switch to, in order, when this one keeps failing. Fallbacks of
+	// fallbacks are ignored.
+	Fallbacks []SelectedModel `json:"fallbacks,omitempty" jsonschema:"description=Models to switch to in order when this model keeps failing or the conversation does not fit its context window"`
 }
 
 type ProviderConfig struct {
@@ -75,7 +95,7 @@ type ProviderConfig struct {
 	// The provider's API endpoint.
 	BaseURL string `json:"base_url,omitempty" jsonschema:"description=Base URL for the provider's API,format=uri,example=https://api.openai.com/v1"`
 	// The provider type, e.g. "openai", "anthropic", etc. if empty it defaults to openai.
-	Type catwalk.Type `json:"type,omitempty" jsonschema:"description=Provider type that determines the API format,enum=openai,enum=anthropic,enum=gemini,enum=azure,enum=vertexai,default=openai"`
+	Type catwalk.Type `json:"type,omitempty" jsonschema:"description=Provider type that determines the API format,enum=openai,enum=anthropic,enum=gemini,enum=azure,enum=vertexai,enum=ollama,enum=replay,default=openai"`
 	// The provider's API key.
 	APIKey string `json:"api_key,omitempty" jsonschema:"description=API key for authentication with the provider,example=$OPENAI_API_KEY"`
 	// Marks the provider as disabled.
@@ -92,8 +112,85 @@ type ProviderConfig struct {
 	// Used to pass extra parameters to the provider.
 	ExtraParams map[string]string `json:"-"`
 
+	// Proxy and TLS settings of the requests to the provider.
+	HTTPConfig
+
+	// Only used by providers of the openai type.
+	ResponsesAPI bool `json:"responses_api,omitempty" jsonschema:"description=Use the Responses API instead of Chat Completions for OpenAI-type providers,default=false"`
+
+	// Prompt caching settings, used by Anthropic models. Caching is off on
+	// Bedrock unless this is set.
+	Cache *PromptCache `json:"cache,omitempty" jsonschema:"description=Where to place prompt cache breakpoints for Anthropic models"`
+
 	// The provider models
 	Models []catwalk.Model `json:"models,omitempty" jsonschema:"description=List of models available from this provider"`
+
+	// Only used by providers of the replay type.
+	Replay *ReplayConfig `json:"replay,omitempty" jsonschema:"description=Record and replay settings for providers of the replay type"`
+}
+
+// TypeReplay is the provider type that records the responses of another
+// provider to a cassette file, or plays recorded responses back without any
+// network access.
+const TypeReplay catwalk.Type = "replay"
+
+type ReplayMode string
+
+const (
+	ReplayModeRecord ReplayMode = "record"
+	ReplayModeReplay ReplayMode = "replay"
+)
+
+type ReplayConfig struct {
+	// Defaults to replay.
+	Mode ReplayMode `json:"mode,omitempty" jsonschema:"description=Whether to record the responses of another provider or play recorded responses back,enum=record,enum=replay,default=replay"`
+	// Relative paths are relative to the working directory.
+	Cassette string `json:"cassette" jsonschema:"required,description=Path of the file responses are recorded to and played back from,example=testdata/session.json"`
+	// The provider whose responses are recorded, only used in record mode.
+	Provider string `json:"provider,omitempty" jsonschema:"description=ID of the provider whose responses are recorded,example=anthropic"`
+}
+
+// CacheBreakpoint is a place in a request where the provider caches
+// everything before it.
+type CacheBreakpoint string
+
+const (
+	// End of the system prompt, before the context files.
+	CacheBreakpointSystemPrompt CacheBreakpoint = "system_prompt"
+	// End of the tool definitions.
+	CacheBreakpointTools CacheBreakpoint = "tools"
+	// End of the context files, or of the system prompt when there are none.
+	CacheBreakpointContextFiles CacheBreakpoint = "context_files"
+	// The most recent messages.
+	CacheBreakpointMessages CacheBreakpoint = "messages"
+)
+
+type PromptCache struct {
+	Disable bool `json:"disable,omitempty" jsonschema:"description=Disable prompt caching for this provider,default=false"`
+	// Defaults to tools, context_files and messages. Providers accept at most
+	// four breakpoints, the messages take what the others leave.
+	Breakpoints []CacheBreakpoint `json:"breakpoints,omitempty" jsonschema:"description=Where to place cache breakpoints,enum=system_prompt,enum=tools,enum=context_files,enum=messages"`
+	// Number of most recent messages that get a breakpoint, defaults to 2.
+	Messages int `json:"messages,omitempty" jsonschema:"description=Number of most recent messages that get a cache breakpoint,default=2,minimum=1,maximum=4"`
+	// Defaults to the provider's, 5 minutes for Anthropic.
+	TTL string `json:"ttl,omitempty" jsonschema:"description=How long cached prompts live,enum=5m,enum=1h,default=5m"`
+}
+
+func (c *PromptCache) validate() error {
+	for _, breakpoint := range c.Breakpoints {
+		switch breakpoint {
+		case CacheBreakpointSystemPrompt, CacheBreakpointTools, CacheBreakpointContextFiles, CacheBreakpointMessages:
+		default:
+			return fmt.Errorf("unknown cache breakpoint %q", breakpoint)
+		}
+	}
+	if c.Messages < 0 || c.Messages > 4 {
+		return fmt.Errorf("cache messages must be between 1 and 4, got %d", c.Messages)
+	}
+	if c.TTL != "" && c.TTL != "5m" && c.TTL != "1h" {
+		return fmt.Errorf("unknown cache ttl %q, use 5m or 1h", c.TTL)
+	}
+	return nil
 }
 
 type MCPType string
@@ -128,6 +225,90 @@ type TUIOptions struct {
 	// Here we can add themes later or any TUI related options
 }
 
+type BudgetScope string
+
+const (
+	BudgetScopeSession BudgetScope = "session"
+	BudgetScopeRun     BudgetScope = "run"
+)
+
+// Budget limits how far the agent may go before it stops on its own. Zero
+// values mean no limit.
+type Budget struct {
+	MaxTurns  int         `json:"max_turns,omitempty" jsonschema:"description=Maximum number of agent iterations for a single prompt,minimum=0,example=25"`
+	MaxTokens int64       `json:"max_tokens,omitempty" jsonschema:"description=Maximum number of tokens that may be used,minimum=0,example=200000"`
+	MaxCost   float64     `json:"max_cost,omitempty" jsonschema:"description=Maximum cost in USD that may be spent,minimum=0,example=1.5"`
+	Scope     BudgetScope `json:"scope,omitempty" jsonschema:"description=Whether token and cost limits apply to the whole session or to a single prompt,enum=session,enum=run,default=session"`
+}
+
+func (b *Budget) IsZero() bool {
+	return b == nil || (b.MaxTurns <= 0 && b.MaxTokens <= 0 && b.MaxCost <= 0)
+}
+
+type QueueMode string
+
+const (
+	// QueueModeAfterRun sends queued prompts one by one once the current run
+	// finishes.
+	QueueModeAfterRun QueueMode = "after_run"
+	// QueueModeSteer hands queued prompts to the agent between tool
+	// iterations of the current run.
+	QueueModeSteer QueueMode = "steer"
+)
+
+type SummarizeStrategy string
+
+const (
+	// SummarizeStrategyFull replaces the whole history with a summary.
+	SummarizeStrategyFull SummarizeStrategy = "full"
+	// SummarizeStrategyDropToolResults first drops the results of old tool
+	// calls, and only summarizes when the context fills up again.
+	SummarizeStrategyDropToolResults SummarizeStrategy = "drop_tool_results"
+	// SummarizeStrategyKeepRecent keeps the last turns verbatim and replaces
+	// the rest with a summary.
+	SummarizeStrategyKeepRecent SummarizeStrategy = "keep_recent"
+)
+
+type Summarize struct {
+	// Fraction of the context window that triggers summarization.
+	Threshold float64           `json:"threshold,omitempty" jsonschema:"description=Fraction of the context window in use that triggers automatic summarization,default=0.95,minimum=0.1,maximum=1,example=0.8"`
+	Strategy  SummarizeStrategy `json:"strategy,omitempty" jsonschema:"description=How the conversation is shortened when summarizing,enum=full,enum=drop_tool_results,enum=keep_recent,default=full"`
+	// Number of most recent turns left untouched by the keep_recent and
+	// drop_tool_results strategies. A turn starts with a user message.
+	KeepTurns int `json:"keep_turns,omitempty" jsonschema:"description=Number of most recent turns kept verbatim by the keep_recent and drop_tool_results strategies,default=3,minimum=1,example=5"`
+}
+
+// Retry controls how failed provider requests are retried, and when a provider
+// that keeps failing stops being called for a while.
+type Retry struct {
+	MaxAttempts          int   `json:"max_attempts,omitempty" jsonschema:"description=Maximum number of attempts for a provider request,default=8,minimum=1,example=5"`
+	BaseDelayMs          int   `json:"base_delay_ms,omitempty" jsonschema:"description=Delay in milliseconds before the first retry; it doubles with each attempt,default=2000,minimum=1,example=1000"`
+	MaxDelayMs           int   `json:"max_delay_ms,omitempty" jsonschema:"description=Maximum delay in milliseconds between two attempts,default=60000,minimum=1,example=30000"`
+	RetryableStatusCodes []int `json:"retryable_status_codes,omitempty" jsonschema:"description=HTTP status codes of the responses that are retried,example=429,example=503"`
+	// The circuit breaker of a provider opens after this many requests in a
+	// row failed, and lets a request through again after the cooldown.
+	BreakerThreshold  int `json:"breaker_threshold,omitempty" jsonschema:"description=Number of failed requests in a row after which a provider is no longer called for a while,default=5,minimum=1,example=3"`
+	BreakerCooldownMs int `json:"breaker_cooldown_ms,omitempty" jsonschema:"description=Time in milliseconds before a provider is called again once it kept failing,default=30000,minimum=1,example=60000"`
+}
+
+// Hooks are shell commands run around the agent's work.
+type Hooks struct {
+	PreToolUse   []Hook `json:"pre_tool_use,omitempty" jsonschema:"description=Hooks run before a tool call; a failing hook blocks the call"`
+	PostToolUse  []Hook `json:"post_tool_use,omitempty" jsonschema:"description=Hooks run after a tool call; their output is appended to the tool result"`
+	SessionStart []Hook `json:"session_start,omitempty" jsonschema:"description=Hooks run when the first prompt of a session is submitted"`
+	PromptSubmit []Hook `json:"prompt_submit,omitempty" jsonschema:"description=Hooks run when a prompt is submitted"`
+	AgentStop    []Hook `json:"agent_stop,omitempty" jsonschema:"description=Hooks run when the agent finishes a run"`
+}
+
+type Hook struct {
+	Command string `json:"command" jsonschema:"required,description=Shell command to run,example=gofmt -l ."`
+	// Only used by tool hooks. When empty the hook matches every tool.
+	Tools []string `json:"tools,omitempty" jsonschema:"description=Tool names the hook applies to (all tools when omitted),example=edit,example=write"`
+	Paths []string `json:"paths,omitempty" jsonschema:"description=Glob patterns the file path of the tool call must match,example=**/*.go"`
+	// Timeout in seconds.
+	Timeout int `json:"timeout,omitempty" jsonschema:"description=Timeout in seconds for the hook command,default=60,minimum=1,example=10"`
+}
+
 type Permissions struct {
 	AllowedTools []string `json:"allowed_tools,omitempty" jsonschema:"description=List of tools that don't require permission prompts,example=bash,example=view"` // Tools that don't require permission prompts
 	SkipRequests bool     `json:"-"`                                                                                                                              // Automatically accept all permissions (YOLO mode)
@@ -140,6 +321,15 @@ type Options struct {
 	DebugLSP             bool        `json:"debug_lsp,omitempty" jsonschema:"description=Enable debug logging for LSP servers,default=false"`
 	DisableAutoSummarize bool        `json:"disable_auto_summarize,omitempty" jsonschema:"description=Disable automatic conversation summarization,default=false"`
 	DataDirectory        string      `json:"data_directory,omitempty" jsonschema:"description=Directory for storing application data (relative to working directory),default=.crush,example=.crush"` // Relative to the cwd
+	ToolConcurrency      int         `json:"tool_concurrency,omitempty" jsonschema:"description=Maximum number of read-only tool calls to run in parallel,default=4,minimum=1,example=8"`
+	Budget               *Budget     `json:"budget,omitempty" jsonschema:"description=Limits on how much work the agent may do before it stops"`
+	QueueMode            QueueMode   `json:"queue_mode,omitempty" jsonschema:"description=How prompts submitted while the agent is busy are delivered,enum=after_run,enum=steer,default=after_run"`
+	Summarize            *Summarize  `json:"summarize,omitempty" jsonschema:"description=When and how the conversation is summarized"`
+	Retry                *Retry      `json:"retry,omitempty" jsonschema:"description=How failed provider requests are retried"`
+	// Used by providers that don't set their own, and by the tools that make
+	// requests.
+	HTTP    *HTTPConfig `json:"http,omitempty" jsonschema:"description=Proxy and TLS settings of requests to providers and of the fetch, download and sourcegraph tools"`
+	Sandbox *Sandbox    `json:"sandbox,omitempty" jsonschema:"description=Sandbox the commands of the bash tool run in (Linux only)"`
 }
 
 type MCPs map[string]MCPConfig
@@ -216,30 +406,33 @@ func (m MCPConfig) ResolvedHeaders() map[string]string {
 }
 
 type Agent struct {
-	ID          string `json:"id,omitempty"`
-	Name        string `json:"name,omitempty"`
-	Description string `json:"description,omitempty"`
+	ID          string `json:"id,omitempty" jsonschema:"description=Unique identifier for the agent,example=reviewer"`
+	Name        string `json:"name,omitempty" jsonschema:"description=Human-readable name for the agent,example=Reviewer"`
+	Description string `json:"description,omitempty" jsonschema:"description=Description of what the agent is for"`
 	// This is the id of the system prompt used by the agent
-	Disabled bool `json:"disabled,omitempty"`
+	Disabled bool `json:"disabled,omitempty" jsonschema:"description=Whether this agent is disabled,default=false"`
+
+	Model SelectedModelType `json:"model,omitempty" jsonschema:"description=The model type to use for this agent,enum=large,enum=small,default=large"`
 
-	Model SelectedModelType `json:"model" jsonschema:"required,description=The model type to use for this agent,enum=large,enum=small,default=large"`
+	// Path to a file holding the system prompt of a user-defined agent.
+	Prompt string `json:"prompt,omitempty" jsonschema:"description=Path to a file containing the system prompt for this agent (relative to working directory),example=.crush/agents/reviewer.md"`
 
 	// The available tools for the agent
 	//  if this is nil, all tools are available
-	AllowedTools []string `json:"allowed_tools,omitempty"`
+	AllowedTools []string `json:"allowed_tools,omitempty" jsonschema:"description=List of tools this agent can use (all tools when omitted),example=view,example=grep"`
 
 	// this tells us which MCPs are available for this agent
-	//  if this is empty all mcps are available
+	//  if this is nil all mcps are available
 	//  the string array is the list of tools from the AllowedMCP the agent has available
 	//  if the string array is nil, all tools from the AllowedMCP are available
-	AllowedMCP map[string][]string `json:"allowed_mcp,omitempty"`
+	AllowedMCP map[string][]string `json:"allowed_mcp,omitempty" jsonschema:"description=MCP servers and their tools this agent can use (all when omitted)"`
 
 	// The list of LSPs that this agent can use
 	//  if this is nil, all LSPs are available
-	AllowedLSP []string `json:"allowed_lsp,omitempty"`
+	AllowedLSP []string `json:"allowed_lsp,omitempty" jsonschema:"description=LSP servers this agent can use (all when omitted)"`
 
 	// Overrides the context paths for this agent
-	ContextPaths []string `json:"context_paths,omitempty"`
+	ContextPaths []string `json:"context_paths,omitempty" jsonschema:"description=Context paths for this agent (defaults to options.context_paths)"`
 }
 
 // Config holds the configuration for crush.
@@ -258,10 +451,12 @@ type Config struct {
 
 	Permissions *Permissions `json:"permissions,omitempty" jsonschema:"description=Permission settings for tool usage"`
 
+	Agents map[string]Agent `json:"agents,omitempty" jsonschema:"description=User-defined agents, keyed by agent ID"`
+
+	Hooks *Hooks `json:"hooks,omitempty" jsonschema:"description=Shell commands run before and after tool calls and on session events"`
+
 	// Internal
 	workingDir string `json:"-"`
-	// TODO: most likely remove this concept when I come back to it
-	Agents map[string]Agent `json:"-"`
 	// TODO: find a better way to do this this should probably not be part of the config
 	resolver       VariableResolver
 	dataConfigDir  string             `json:"-"`
@@ -349,6 +544,10 @@ func (c *Config) Resolve(key string) (string, error) {
 }
 
 func (c *Config) UpdatePreferredModel(modelType SelectedModelType, model SelectedModel) error {
+	// Picking another model keeps the configured fallback chain.
+	if model.Fallbacks == nil {
+		model.Fallbacks = c.Models[modelType].Fallbacks
+	}
 	c.Models[modelType] = model
 	if err := c.SetConfigField(fmt.Sprintf("models.%s", modelType), model); err != nil {
 		return fmt.Errorf("failed to update preferred model: %w", err)
@@ -448,9 +647,47 @@ func (c *Config) SetupAgents() {
 			AllowedLSP: []string{},
 		},
 	}
+
+	// User-defined agents can't replace the built-in ones.
+	for id, agent := range c.Agents {
+		if _, builtin := agents[id]; builtin || agent.Disabled {
+			continue
+		}
+		agent.ID = id
+		if agent.Name == "" {
+			agent.Name = id
+		}
+		if agent.Model == "" {
+			agent.Model = SelectedModelTypeLarge
+		}
+		if agent.ContextPaths == nil {
+			agent.ContextPaths = c.Options.ContextPaths
+		}
+		agents[id] = agent
+	}
 	c.Agents = agents
 }
 
+// IsCustom reports whether the agent was defined by the user rather than
+// being one of the built-in agents.
+func (a Agent) IsCustom() bool {
+	return a.ID != "coder" && a.ID != "task"
+}
+
+// CustomAgents returns the user-defined agents sorted by ID.
+func (c *Config) CustomAgents() []Agent {
+	var agents []Agent
+	for _, agent := range c.Agents {
+		if agent.IsCustom() {
+			agents = append(agents, agent)
+		}
+	}
+	slices.SortFunc(agents, func(a, b Agent) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return agents
+}
+
 func (c *Config) Resolver() VariableResolver {
 	return c.resolver
 }
@@ -481,10 +718,21 @@ func (c *ProviderConfig) TestConnection(resolver VariableResolver) error {
 			baseURL = "https://generativelanguage.googleapis.com"
 		}
 		testURL = baseURL + "/v1beta/models?key=" + url.QueryEscape(apiKey)
+	case TypeOllama:
+		baseURL, _ := resolver.ResolveValue(c.BaseURL)
+		if baseURL == "" {
+			baseURL = defaultOllamaBaseURL
+		}
+		testURL = baseURL + "/api/tags"
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	client := &http.Client{}
+	httpCfg := c.HTTP()
+	transport, err := httpCfg.Transport()
+	if err != nil {
+		return fmt.Errorf("invalid HTTP settings for provider %s: %w", c.ID, err)
+	}
+	client := &http.Client{Transport: transport}
 	req, err := http.NewRequestWithContext(ctx, "GET", testURL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request for provider %s: %w", c.ID, err)
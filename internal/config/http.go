@@ -0,0 +1,98 @@
+package config
+
+import (
+	"cmp"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// HTTPConfig holds the transport settings of requests to providers, and of the
+// requests tools make.
+type HTTPConfig struct {
+	// Defaults to the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment
+	// variables.
+	Proxy string `json:"proxy,omitempty" jsonschema:"description=URL of the proxy requests go through,format=uri,example=http://proxy.internal:3128"`
+	// Trusted on top of the system's certificate authorities.
+	CAFile string `json:"ca_file,omitempty" jsonschema:"description=PEM file with certificate authorities to trust on top of the system ones,example=/etc/ssl/certs/internal-ca.pem"`
+	// Client certificate and key, for gateways that require mutual TLS.
+	ClientCert string `json:"client_cert,omitempty" jsonschema:"description=PEM file with the client certificate for mutual TLS,example=/etc/crush/client.pem"`
+	ClientKey  string `json:"client_key,omitempty" jsonschema:"description=PEM file with the private key of the client certificate,example=/etc/crush/client-key.pem"`
+	// Don't verify the certificates of servers.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty" jsonschema:"description=Skip verification of server certificates,default=false"`
+}
+
+// IsZero reports whether h leaves the default transport as it is.
+func (h *HTTPConfig) IsZero() bool {
+	return h == nil || *h == HTTPConfig{}
+}
+
+// withDefaults fills in the settings h leaves empty from defaults.
+func (h HTTPConfig) withDefaults(defaults *HTTPConfig) HTTPConfig {
+	if defaults == nil {
+		return h
+	}
+	h.Proxy = cmp.Or(h.Proxy, defaults.Proxy)
+	h.CAFile = cmp.Or(h.CAFile, defaults.CAFile)
+	if h.ClientCert == "" && h.ClientKey == "" {
+		h.ClientCert, h.ClientKey = defaults.ClientCert, defaults.ClientKey
+	}
+	h.InsecureSkipVerify = h.InsecureSkipVerify || defaults.InsecureSkipVerify
+	return h
+}
+
+// Transport returns a copy of the default transport with the settings
+// applied.
+func (h *HTTPConfig) Transport() (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if h.IsZero() {
+		return transport, nil
+	}
+
+	if h.Proxy != "" {
+		proxyURL, err := url.Parse(h.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %w", h.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: h.InsecureSkipVerify, //nolint:gosec
+	}
+	if h.CAFile != "" {
+		certs, err := os.ReadFile(h.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(certs) {
+			return nil, fmt.Errorf("no certificates found in %s", h.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if h.ClientCert != "" || h.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(h.ClientCert, h.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// HTTP returns the HTTP settings of the provider, with the global ones for
+// what the provider doesn't set.
+func (c *ProviderConfig) HTTP() HTTPConfig {
+	if cfg := Get(); cfg != nil && cfg.Options != nil {
+		return c.HTTPConfig.withDefaults(cfg.Options.HTTP)
+	}
+	return c.HTTPConfig
+}
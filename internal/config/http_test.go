@@ -0,0 +1,64 @@
+package config
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPConfigTransport(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	get := func(h *HTTPConfig) error {
+		transport, err := h.Transport()
+		require.NoError(t, err)
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	// The test server's certificate is not trusted by default.
+	require.Error(t, get(nil))
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+	require.NoError(t, get(&HTTPConfig{CAFile: caFile}))
+	require.NoError(t, get(&HTTPConfig{InsecureSkipVerify: true}))
+
+	_, err := (&HTTPConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}).Transport()
+	require.Error(t, err)
+	_, err = (&HTTPConfig{ClientCert: caFile}).Transport()
+	require.Error(t, err)
+
+	transport, err := (&HTTPConfig{Proxy: "http://proxy.internal:3128"}).Transport()
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodGet, "https://api.anthropic.com", nil)
+	require.NoError(t, err)
+	proxyURL, err := transport.Proxy(req)
+	require.NoError(t, err)
+	require.Equal(t, "proxy.internal:3128", proxyURL.Host)
+}
+
+func TestHTTPConfigWithDefaults(t *testing.T) {
+	t.Parallel()
+
+	defaults := &HTTPConfig{Proxy: "http://proxy.internal:3128", CAFile: "/etc/ca.pem", ClientCert: "a.pem", ClientKey: "a-key.pem"}
+	require.Equal(t, *defaults, HTTPConfig{}.withDefaults(defaults))
+
+	// The client certificate and its key come together.
+	own := HTTPConfig{ClientCert: "b.pem", ClientKey: "b-key.pem"}
+	require.Equal(t, HTTPConfig{Proxy: defaults.Proxy, CAFile: defaults.CAFile, ClientCert: "b.pem", ClientKey: "b-key.pem"}, own.withDefaults(defaults))
+	require.Equal(t, own, own.withDefaults(nil))
+}
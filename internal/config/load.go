@@ -1,7 +1,9 @@
 package config
 
 import (
+	"cmp"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -37,13 +39,7 @@ func LoadReader(fd io.Reader) (*Config, error) {
 
 // Load loads the configuration from the default paths.
 func Load(workingDir string, debug bool) (*Config, error) {
-	// uses default config paths
-	configPaths := []string{
-		globalConfig(),
-		GlobalConfigData(),
-		filepath.Join(workingDir, fmt.Sprintf("%s.json", appName)),
-		filepath.Join(workingDir, fmt.Sprintf(".%s.json", appName)),
-	}
+	configPaths := defaultConfigPaths(workingDir)
 	cfg, err := loadFromConfigPaths(configPaths)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load config from paths %v: %w", configPaths, err)
@@ -66,7 +62,12 @@ func Load(workingDir string, debug bool) (*Config, error) {
 	// Load known providers, this loads the config from catwalk
 	providers, err := Providers()
 	if err != nil || len(providers) == 0 {
-		return nil, fmt.Errorf("failed to load providers: %w", err)
+		// Machines without access to catwalk can still use the providers
+		// configured by the user, such as local ones.
+		if cfg.Providers.Len() == 0 {
+			return nil, fmt.Errorf("failed to load providers: %w", err)
+		}
+		slog.Warn("Failed to load known providers, only configured providers are available", "error", err)
 	}
 	cfg.knownProviders = providers
 
@@ -90,6 +91,36 @@ func Load(workingDir string, debug bool) (*Config, error) {
 	return cfg, nil
 }
 
+// DataDirectory returns the data directory of the project in workingDir. It
+// only reads the configuration files, unlike Load, so it works for projects
+// without configured providers.
+func DataDirectory(workingDir string) (string, error) {
+	configPaths := defaultConfigPaths(workingDir)
+	cfg, err := loadFromConfigPaths(configPaths)
+	if err != nil {
+		return "", fmt.Errorf("failed to load config from paths %v: %w", configPaths, err)
+	}
+	dataDir := defaultDataDirectory
+	if cfg.Options != nil && cfg.Options.DataDirectory != "" {
+		dataDir = cfg.Options.DataDirectory
+	}
+	if !filepath.IsAbs(dataDir) {
+		dataDir = filepath.Join(workingDir, dataDir)
+	}
+	return dataDir, nil
+}
+
+// defaultConfigPaths returns the configuration files of the project in
+// workingDir, from the least to the most specific.
+func defaultConfigPaths(workingDir string) []string {
+	return []string{
+		globalConfig(),
+		GlobalConfigData(),
+		filepath.Join(workingDir, fmt.Sprintf("%s.json", appName)),
+		filepath.Join(workingDir, fmt.Sprintf(".%s.json", appName)),
+	}
+}
+
 func (c *Config) configureProviders(env env.Env, resolver VariableResolver, knownProviders []catwalk.Provider) error {
 	knownProviderNames := make(map[string]bool)
 	for _, p := range knownProviders {
@@ -155,6 +186,9 @@ func (c *Config) configureProviders(env env.Env, resolver VariableResolver, know
 			ExtraHeaders:       headers,
 			ExtraBody:          config.ExtraBody,
 			ExtraParams:        make(map[string]string),
+			ResponsesAPI:       config.ResponsesAPI,
+			Cache:              config.Cache,
+			HTTPConfig:         config.HTTPConfig,
 			Models:             p.Models,
 		}
 
@@ -189,15 +223,8 @@ func (c *Config) configureProviders(env env.Env, resolver VariableResolver, know
 				}
 				continue
 			}
-			prepared.ExtraParams["region"] = env.Get("AWS_REGION")
-			if prepared.ExtraParams["region"] == "" {
-				prepared.ExtraParams["region"] = env.Get("AWS_DEFAULT_REGION")
-			}
-			for _, model := range p.Models {
-				if !strings.HasPrefix(model.ID, "anthropic.") {
-					return fmt.Errorf("bedrock provider only supports anthropic models for now, found: %s", model.ID)
-				}
-			}
+			prepared.ExtraParams["region"] = cmp.Or(env.Get("AWS_REGION"), env.Get("AWS_DEFAULT_REGION"))
+			prepared.ExtraParams["profile"] = cmp.Or(env.Get("AWS_PROFILE"), env.Get("AWS_DEFAULT_PROFILE"))
 		default:
 			// if the provider api or endpoint are missing we skip them
 			v, err := resolver.ResolveValue(p.APIKey)
@@ -233,7 +260,16 @@ func (c *Config) configureProviders(env env.Env, resolver VariableResolver, know
 			c.Providers.Del(id)
 			continue
 		}
-		if providerConfig.APIKey == "" {
+		if providerConfig.Type == TypeReplay {
+			// Replay providers can record other custom providers, they are
+			// configured once those are.
+			c.Providers.Set(id, providerConfig)
+			continue
+		}
+		if providerConfig.Type == TypeOllama {
+			providerConfig.BaseURL = cmp.Or(providerConfig.BaseURL, defaultOllamaBaseURL)
+			providerConfig.Models = configureOllamaModels(resolver, providerConfig, c.Options.HTTP)
+		} else if providerConfig.APIKey == "" {
 			slog.Warn("Provider is missing API key, this might be OK for local providers", "provider", id)
 		}
 		if providerConfig.BaseURL == "" {
@@ -246,14 +282,14 @@ func (c *Config) configureProviders(env env.Env, resolver VariableResolver, know
 			c.Providers.Del(id)
 			continue
 		}
-		if providerConfig.Type != catwalk.TypeOpenAI && providerConfig.Type != catwalk.TypeAnthropic {
+		if providerConfig.Type != catwalk.TypeOpenAI && providerConfig.Type != catwalk.TypeAnthropic && providerConfig.Type != TypeOllama {
 			slog.Warn("Skipping custom provider because the provider type is not supported", "provider", id, "type", providerConfig.Type)
 			c.Providers.Del(id)
 			continue
 		}
 
 		apiKey, err := resolver.ResolveValue(providerConfig.APIKey)
-		if apiKey == "" || err != nil {
+		if (apiKey == "" || err != nil) && providerConfig.Type != TypeOllama {
 			slog.Warn("Provider is missing API key, this might be OK for local providers", "provider", id)
 		}
 		baseURL, err := resolver.ResolveValue(providerConfig.BaseURL)
@@ -265,6 +301,29 @@ func (c *Config) configureProviders(env env.Env, resolver VariableResolver, know
 
 		c.Providers.Set(id, providerConfig)
 	}
+
+	for id, providerConfig := range c.Providers.Seq2() {
+		if knownProviderNames[id] || providerConfig.Type != TypeReplay {
+			continue
+		}
+		if err := c.configureReplay(&providerConfig); err != nil {
+			slog.Warn("Skipping replay provider", "provider", id, "error", err)
+			c.Providers.Del(id)
+			continue
+		}
+		c.Providers.Set(id, providerConfig)
+	}
+
+	for id, providerConfig := range c.Providers.Seq2() {
+		if providerConfig.Cache == nil {
+			continue
+		}
+		if err := providerConfig.Cache.validate(); err != nil {
+			slog.Warn("Ignoring invalid cache settings, using the defaults", "provider", id, "error", err)
+			providerConfig.Cache = nil
+			c.Providers.Set(id, providerConfig)
+		}
+	}
 	return nil
 }
 
@@ -282,6 +341,45 @@ func (c *Config) setDefaults(workingDir string) {
 	if c.Options.DataDirectory == "" {
 		c.Options.DataDirectory = filepath.Join(workingDir, defaultDataDirectory)
 	}
+	if c.Options.ToolConcurrency <= 0 {
+		c.Options.ToolConcurrency = defaultToolConcurrency
+	}
+	if c.Options.QueueMode == "" {
+		c.Options.QueueMode = QueueModeAfterRun
+	}
+	if c.Options.Summarize == nil {
+		c.Options.Summarize = &Summarize{}
+	}
+	if c.Options.Summarize.Threshold <= 0 || c.Options.Summarize.Threshold > 1 {
+		c.Options.Summarize.Threshold = defaultSummarizeThreshold
+	}
+	if c.Options.Summarize.Strategy == "" {
+		c.Options.Summarize.Strategy = SummarizeStrategyFull
+	}
+	if c.Options.Summarize.KeepTurns <= 0 {
+		c.Options.Summarize.KeepTurns = defaultSummarizeKeepTurns
+	}
+	if c.Options.Retry == nil {
+		c.Options.Retry = &Retry{}
+	}
+	if c.Options.Retry.MaxAttempts <= 0 {
+		c.Options.Retry.MaxAttempts = defaultRetryMaxAttempts
+	}
+	if c.Options.Retry.BaseDelayMs <= 0 {
+		c.Options.Retry.BaseDelayMs = defaultRetryBaseDelayMs
+	}
+	if c.Options.Retry.MaxDelayMs <= 0 {
+		c.Options.Retry.MaxDelayMs = defaultRetryMaxDelayMs
+	}
+	if len(c.Options.Retry.RetryableStatusCodes) == 0 {
+		c.Options.Retry.RetryableStatusCodes = defaultRetryableStatusCodes
+	}
+	if c.Options.Retry.BreakerThreshold <= 0 {
+		c.Options.Retry.BreakerThreshold = defaultBreakerThreshold
+	}
+	if c.Options.Retry.BreakerCooldownMs <= 0 {
+		c.Options.Retry.BreakerCooldownMs = defaultBreakerCooldownMs
+	}
 	if c.Providers == nil {
 		c.Providers = csync.NewMap[string, ProviderConfig]()
 	}
@@ -432,8 +530,72 @@ func (c *Config) configureSelectedModels(knownProviders []catwalk.Provider) erro
 			small.Think = smallModelSelected.Think
 		}
 	}
+	large.Fallbacks = c.configureFallbacks(largeModelSelected.Fallbacks)
+	small.Fallbacks = c.configureFallbacks(smallModelSelected.Fallbacks)
 	c.Models[SelectedModelTypeLarge] = large
 	c.Models[SelectedModelTypeSmall] = small
+
+	if summarize, ok := c.Models[SelectedModelTypeSummarize]; ok {
+		model := c.GetModel(summarize.Provider, summarize.Model)
+		if model == nil {
+			slog.Warn("Summarize model not found, using the small model", "provider", summarize.Provider, "model", summarize.Model)
+			delete(c.Models, SelectedModelTypeSummarize)
+		} else {
+			if summarize.MaxTokens <= 0 {
+				summarize.MaxTokens = model.DefaultMaxTokens
+			}
+			summarize.Fallbacks = c.configureFallbacks(summarize.Fallbacks)
+			c.Models[SelectedModelTypeSummarize] = summarize
+		}
+	}
+	return nil
+}
+
+// configureFallbacks drops the fallback models that are not known and fills in
+// the max tokens of the others.
+func (c *Config) configureFallbacks(fallbacks []SelectedModel) []SelectedModel {
+	var configured []SelectedModel
+	for _, fallback := range fallbacks {
+		model := c.GetModel(fallback.Provider, fallback.Model)
+		if model == nil {
+			slog.Warn("Fallback model not found, ignoring it", "provider", fallback.Provider, "model", fallback.Model)
+			continue
+		}
+		if fallback.MaxTokens <= 0 {
+			fallback.MaxTokens = model.DefaultMaxTokens
+		}
+		fallback.Fallbacks = nil
+		configured = append(configured, fallback)
+	}
+	return configured
+}
+
+// configureReplay validates the settings of a replay provider. When recording,
+// the provider offers the models of the recorded one unless it lists its own.
+func (c *Config) configureReplay(providerConfig *ProviderConfig) error {
+	replay := providerConfig.Replay
+	if replay == nil || replay.Cassette == "" {
+		return errors.New("missing cassette")
+	}
+	if replay.Mode == "" {
+		replay.Mode = ReplayModeReplay
+	}
+	switch replay.Mode {
+	case ReplayModeReplay:
+	case ReplayModeRecord:
+		recorded, ok := c.Providers.Get(replay.Provider)
+		if !ok || recorded.Type == TypeReplay {
+			return fmt.Errorf("provider to record not found: %q", replay.Provider)
+		}
+		if len(providerConfig.Models) == 0 {
+			providerConfig.Models = recorded.Models
+		}
+	default:
+		return fmt.Errorf("unknown replay mode: %q", replay.Mode)
+	}
+	if len(providerConfig.Models) == 0 {
+		return errors.New("no models")
+	}
 	return nil
 }
 
@@ -55,6 +55,26 @@ func TestConfig_setDefaults(t *testing.T) {
 	require.Equal(t, "/tmp", cfg.workingDir)
 }
 
+func TestDataDirectory(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	project := t.TempDir()
+	dataDir, err := DataDirectory(project)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(project, ".crush"), dataDir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(project, "crush.json"), []byte(`{"options": {"data_directory": "data"}}`), 0o644))
+	dataDir, err = DataDirectory(project)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(project, "data"), dataDir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(project, ".crush.json"), []byte(`{"options": {"data_directory": "/var/lib/crush"}}`), 0o644))
+	dataDir, err = DataDirectory(project)
+	require.NoError(t, err)
+	require.Equal(t, "/var/lib/crush", dataDir)
+}
+
 func TestConfig_configureProviders(t *testing.T) {
 	knownProviders := []catwalk.Provider{
 		{
@@ -226,27 +246,34 @@ func TestConfig_configureProvidersBedrockWithoutCredentials(t *testing.T) {
 	require.Equal(t, cfg.Providers.Len(), 0)
 }
 
-func TestConfig_configureProvidersBedrockWithoutUnsupportedModel(t *testing.T) {
+func TestConfig_configureProvidersBedrockWithOtherModelFamilies(t *testing.T) {
 	knownProviders := []catwalk.Provider{
 		{
 			ID:          catwalk.InferenceProviderBedrock,
 			APIKey:      "",
 			APIEndpoint: "",
-			Models: []catwalk.Model{{
-				ID: "some-random-model",
-			}},
+			Models: []catwalk.Model{
+				{ID: "anthropic.claude-sonnet-4-20250514-v1:0"},
+				{ID: "us.meta.llama3-3-70b-instruct-v1:0"},
+			},
 		},
 	}
 
 	cfg := &Config{}
 	cfg.setDefaults("/tmp")
 	env := env.NewFromMap(map[string]string{
-		"AWS_ACCESS_KEY_ID":     "test-key-id",
-		"AWS_SECRET_ACCESS_KEY": "test-secret-key",
+		"AWS_PROFILE": "bedrock",
+		"AWS_REGION":  "eu-west-1",
 	})
 	resolver := NewEnvironmentVariableResolver(env)
 	err := cfg.configureProviders(env, resolver, knownProviders)
-	require.Error(t, err)
+	require.NoError(t, err)
+
+	bedrockProvider, ok := cfg.Providers.Get("bedrock")
+	require.True(t, ok, "Bedrock provider should be present")
+	require.Len(t, bedrockProvider.Models, 2)
+	require.Equal(t, "eu-west-1", bedrockProvider.ExtraParams["region"])
+	require.Equal(t, "bedrock", bedrockProvider.ExtraParams["profile"])
 }
 
 func TestConfig_configureProvidersVertexAIWithCredentials(t *testing.T) {
@@ -666,6 +693,73 @@ func TestConfig_configureProvidersCustomProviderValidation(t *testing.T) {
 		_, exists := cfg.Providers.Get("custom")
 		require.False(t, exists)
 	})
+
+	t.Run("recording replay provider offers the recorded provider's models", func(t *testing.T) {
+		cfg := &Config{
+			Providers: csync.NewMapFrom(map[string]ProviderConfig{
+				"custom": {
+					APIKey:  "test-key",
+					BaseURL: "https://api.custom.com/v1",
+					Type:    catwalk.TypeOpenAI,
+					Models: []catwalk.Model{{
+						ID: "test-model",
+					}},
+				},
+				"recorder": {
+					Type: TypeReplay,
+					Replay: &ReplayConfig{
+						Mode:     ReplayModeRecord,
+						Cassette: "testdata/session.json",
+						Provider: "custom",
+					},
+				},
+			}),
+		}
+		cfg.setDefaults("/tmp")
+
+		env := env.NewFromMap(map[string]string{})
+		resolver := NewEnvironmentVariableResolver(env)
+		err := cfg.configureProviders(env, resolver, []catwalk.Provider{})
+		require.NoError(t, err)
+
+		require.Equal(t, cfg.Providers.Len(), 2)
+		recorder, exists := cfg.Providers.Get("recorder")
+		require.True(t, exists)
+		require.Equal(t, "recorder", recorder.ID)
+		require.Len(t, recorder.Models, 1)
+		require.Equal(t, "test-model", recorder.Models[0].ID)
+	})
+
+	t.Run("replay provider without cassette or models is removed", func(t *testing.T) {
+		cfg := &Config{
+			Providers: csync.NewMapFrom(map[string]ProviderConfig{
+				"no-cassette": {
+					Type:   TypeReplay,
+					Models: []catwalk.Model{{ID: "test-model"}},
+				},
+				"no-models": {
+					Type:   TypeReplay,
+					Replay: &ReplayConfig{Cassette: "testdata/session.json"},
+				},
+				"replay": {
+					Type:   TypeReplay,
+					Replay: &ReplayConfig{Cassette: "testdata/session.json"},
+					Models: []catwalk.Model{{ID: "test-model"}},
+				},
+			}),
+		}
+		cfg.setDefaults("/tmp")
+
+		env := env.NewFromMap(map[string]string{})
+		resolver := NewEnvironmentVariableResolver(env)
+		err := cfg.configureProviders(env, resolver, []catwalk.Provider{})
+		require.NoError(t, err)
+
+		require.Equal(t, cfg.Providers.Len(), 1)
+		replay, exists := cfg.Providers.Get("replay")
+		require.True(t, exists)
+		require.Equal(t, ReplayModeReplay, replay.Replay.Mode)
+	})
 }
 
 func TestConfig_configureProvidersEnhancedCredentialValidation(t *testing.T) {
@@ -1183,4 +1277,148 @@ func TestConfig_configureSelectedModels(t *testing.T) {
 		require.Equal(t, "openai", large.Provider)
 		require.Equal(t, int64(100), large.MaxTokens)
 	})
+
+	t.Run("should keep a known summarize model only", func(t *testing.T) {
+		knownProviders := []catwalk.Provider{
+			{
+				ID:                  "openai",
+				APIKey:              "abc",
+				DefaultLargeModelID: "large-model",
+				DefaultSmallModelID: "small-model",
+				Models: []catwalk.Model{
+					{
+						ID:               "large-model",
+						DefaultMaxTokens: 1000,
+					},
+					{
+						ID:               "small-model",
+						DefaultMaxTokens: 500,
+					},
+				},
+			},
+		}
+
+		cfg := &Config{
+			Models: map[SelectedModelType]SelectedModel{
+				"summarize": {
+					Model:    "large-model",
+					Provider: "openai",
+				},
+			},
+		}
+		cfg.setDefaults("/tmp")
+		env := env.NewFromMap(map[string]string{})
+		resolver := NewEnvironmentVariableResolver(env)
+		err := cfg.configureProviders(env, resolver, knownProviders)
+		require.NoError(t, err)
+
+		err = cfg.configureSelectedModels(knownProviders)
+		require.NoError(t, err)
+		summarize, ok := cfg.Models[SelectedModelTypeSummarize]
+		require.True(t, ok)
+		require.Equal(t, "large-model", summarize.Model)
+		require.Equal(t, int64(1000), summarize.MaxTokens)
+
+		cfg.Models[SelectedModelTypeSummarize] = SelectedModel{Model: "unknown", Provider: "openai"}
+		err = cfg.configureSelectedModels(knownProviders)
+		require.NoError(t, err)
+		require.NotContains(t, cfg.Models, SelectedModelTypeSummarize)
+	})
+
+	t.Run("should keep known fallback models only", func(t *testing.T) {
+		knownProviders := []catwalk.Provider{
+			{
+				ID:                  "openai",
+				APIKey:              "abc",
+				DefaultLargeModelID: "large-model",
+				DefaultSmallModelID: "small-model",
+				Models: []catwalk.Model{
+					{
+						ID:               "large-model",
+						DefaultMaxTokens: 1000,
+					},
+					{
+						ID:               "small-model",
+						DefaultMaxTokens: 500,
+					},
+				},
+			},
+		}
+
+		cfg := &Config{
+			Models: map[SelectedModelType]SelectedModel{
+				"large": {
+					Model:    "large-model",
+					Provider: "openai",
+					Fallbacks: []SelectedModel{
+						{
+							Model:    "unknown",
+							Provider: "openai",
+						},
+						{
+							Model:    "small-model",
+							Provider: "openai",
+							Fallbacks: []SelectedModel{
+								{Model: "large-model", Provider: "openai"},
+							},
+						},
+					},
+				},
+			},
+		}
+		cfg.setDefaults("/tmp")
+		env := env.NewFromMap(map[string]string{})
+		resolver := NewEnvironmentVariableResolver(env)
+		err := cfg.configureProviders(env, resolver, knownProviders)
+		require.NoError(t, err)
+
+		err = cfg.configureSelectedModels(knownProviders)
+		require.NoError(t, err)
+		large := cfg.Models[SelectedModelTypeLarge]
+		require.Len(t, large.Fallbacks, 1)
+		require.Equal(t, "small-model", large.Fallbacks[0].Model)
+		require.Equal(t, int64(500), large.Fallbacks[0].MaxTokens)
+		require.Empty(t, large.Fallbacks[0].Fallbacks)
+		require.Empty(t, cfg.Models[SelectedModelTypeSmall].Fallbacks)
+	})
+}
+
+func TestConfig_SetupAgentsWithCustomAgents(t *testing.T) {
+	data := strings.NewReader(`{
+		"agents": {
+			"reviewer": {"prompt": "reviewer.md", "allowed_tools": ["view", "grep"]},
+			"docs": {"name": "Docs", "model": "small", "context_paths": ["docs/"]},
+			"old": {"disabled": true},
+			"coder": {"prompt": "override.md"}
+		}
+	}`)
+	cfg, err := loadFromReaders([]io.Reader{data})
+	require.NoError(t, err)
+	cfg.setDefaults("/tmp")
+	cfg.SetupAgents()
+
+	require.Len(t, cfg.Agents, 4)
+	require.Empty(t, cfg.Agents["coder"].Prompt)
+
+	reviewer := cfg.Agents["reviewer"]
+	require.Equal(t, "reviewer", reviewer.ID)
+	require.Equal(t, "reviewer", reviewer.Name)
+	require.Equal(t, SelectedModelTypeLarge, reviewer.Model)
+	require.Equal(t, []string{"view", "grep"}, reviewer.AllowedTools)
+	require.Equal(t, cfg.Options.ContextPaths, reviewer.ContextPaths)
+
+	docs := cfg.Agents["docs"]
+	require.Equal(t, "Docs", docs.Name)
+	require.Equal(t, SelectedModelTypeSmall, docs.Model)
+	require.Equal(t, []string{"docs/"}, docs.ContextPaths)
+
+	custom := cfg.CustomAgents()
+	require.Len(t, custom, 2)
+	require.Equal(t, "docs", custom[0].ID)
+	require.Equal(t, "reviewer", custom[1].ID)
+
+	// Setting up the agents again must not change them.
+	cfg.SetupAgents()
+	require.Len(t, cfg.Agents, 4)
+	require.Equal(t, reviewer, cfg.Agents["reviewer"])
 }
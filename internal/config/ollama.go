@@ -0,0 +1,171 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+)
+
+// TypeOllama is the provider type of Ollama and other local inference servers
+// speaking its API. It is not one of catwalk's provider types.
+const TypeOllama catwalk.Type = "ollama"
+
+const (
+	defaultOllamaBaseURL = "http://localhost:11434"
+	// Ollama's own default, used when a model does not report its context
+	// length.
+	defaultOllamaContextWindow = 4096
+	defaultOllamaMaxTokens     = 4096
+)
+
+// How long each request to the server can take, so that slow servers and long
+// model lists don't cut discovery short.
+var ollamaRequestTimeout = 10 * time.Second
+
+type ollamaTagsResponse struct {
+	Models []struct {
+		Name  string `json:"name"`
+		Model string `json:"model"`
+	} `json:"models"`
+}
+
+type ollamaShowResponse struct {
+	ModelInfo    map[string]any `json:"model_info"`
+	Capabilities []string       `json:"capabilities"`
+}
+
+// configureOllamaModels merges the configured models of an Ollama provider
+// with the ones installed on its server, reached with the provider's HTTP
+// settings completed by the global ones. The configured models are used as
+// they are when the server cannot be reached.
+func configureOllamaModels(resolver VariableResolver, providerConfig ProviderConfig, defaults *HTTPConfig) []catwalk.Model {
+	httpConfig := providerConfig.HTTPConfig.withDefaults(defaults)
+	transport, err := httpConfig.Transport()
+	if err != nil {
+		slog.Warn("Invalid HTTP settings for Ollama provider", "provider", providerConfig.ID, "error", err)
+		return providerConfig.Models
+	}
+	baseURL, err := resolver.ResolveValue(providerConfig.BaseURL)
+	if err != nil {
+		slog.Warn("Failed to resolve Ollama base URL", "provider", providerConfig.ID, "error", err)
+		return providerConfig.Models
+	}
+	headers := make(map[string]string, len(providerConfig.ExtraHeaders))
+	for key, value := range providerConfig.ExtraHeaders {
+		if resolved, err := resolver.ResolveValue(value); err == nil {
+			headers[key] = resolved
+		}
+	}
+	client := &http.Client{Transport: transport}
+	discovered, err := discoverOllamaModels(context.Background(), client, strings.TrimSuffix(baseURL, "/"), headers)
+	if err != nil {
+		slog.Warn("Failed to discover Ollama models", "provider", providerConfig.ID, "error", err)
+		return providerConfig.Models
+	}
+	return mergeOllamaModels(providerConfig.Models, discovered)
+}
+
+// discoverOllamaModels lists the models installed on an Ollama server, along
+// with their context windows and capabilities. Models whose details cannot be
+// read are left out.
+func discoverOllamaModels(ctx context.Context, client *http.Client, baseURL string, headers map[string]string) ([]catwalk.Model, error) {
+	var tags ollamaTagsResponse
+	if err := ollamaRequest(ctx, client, http.MethodGet, baseURL+"/api/tags", headers, nil, &tags); err != nil {
+		return nil, fmt.Errorf("failed to list models: %w", err)
+	}
+
+	models := make([]catwalk.Model, 0, len(tags.Models))
+	for _, tag := range tags.Models {
+		id := tag.Model
+		if id == "" {
+			id = tag.Name
+		}
+		model := catwalk.Model{
+			ID:            id,
+			Name:          tag.Name,
+			ContextWindow: defaultOllamaContextWindow,
+		}
+
+		var show ollamaShowResponse
+		if err := ollamaRequest(ctx, client, http.MethodPost, baseURL+"/api/show", headers, map[string]string{"model": id}, &show); err != nil {
+			// One broken model does not hide the others.
+			slog.Warn("Skipping Ollama model", "model", id, "error", err)
+			continue
+		}
+		for key, value := range show.ModelInfo {
+			if length, ok := value.(float64); ok && strings.HasSuffix(key, ".context_length") && length > 0 {
+				model.ContextWindow = int64(length)
+			}
+		}
+		model.DefaultMaxTokens = min(defaultOllamaMaxTokens, model.ContextWindow/2)
+		model.SupportsImages = slices.Contains(show.Capabilities, "vision")
+		model.CanReason = slices.Contains(show.Capabilities, "thinking")
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+func ollamaRequest(ctx context.Context, client *http.Client, method, url string, headers map[string]string, body, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, ollamaRequestTimeout)
+	defer cancel()
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, &reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// mergeOllamaModels completes the configured models with what was discovered
+// on the server, and adds the installed models that are not configured.
+func mergeOllamaModels(configured, discovered []catwalk.Model) []catwalk.Model {
+	merged := slices.Clone(configured)
+	for _, found := range discovered {
+		idx := slices.IndexFunc(merged, func(m catwalk.Model) bool {
+			return m.ID == found.ID
+		})
+		if idx == -1 {
+			merged = append(merged, found)
+			continue
+		}
+		model := &merged[idx]
+		if model.Name == "" {
+			model.Name = found.Name
+		}
+		if model.ContextWindow == 0 {
+			model.ContextWindow = found.ContextWindow
+		}
+		if model.DefaultMaxTokens == 0 {
+			model.DefaultMaxTokens = found.DefaultMaxTokens
+		}
+		model.SupportsImages = model.SupportsImages || found.SupportsImages
+		model.CanReason = model.CanReason || found.CanReason
+	}
+	return merged
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/env"
+	"github.com/stretchr/testify/require"
+)
+
+func newOllamaTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(newOllamaTestHandler(t))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newOllamaTestHandler(t *testing.T) http.Handler {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tags", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"models":[{"name":"qwen3:8b","model":"qwen3:8b"},{"name":"broken:1b","model":"broken:1b"},{"name":"llava:7b","model":"llava:7b"}]}`))
+	})
+	mux.HandleFunc("POST /api/show", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		switch req.Model {
+		case "broken:1b":
+			http.Error(w, "model is corrupted", http.StatusInternalServerError)
+		case "qwen3:8b":
+			_, _ = w.Write([]byte(`{"model_info":{"general.architecture":"qwen3","qwen3.context_length":40960},"capabilities":["completion","tools","thinking"]}`))
+		default:
+			_, _ = w.Write([]byte(`{"model_info":{"general.architecture":"llama"},"capabilities":["completion","vision"]}`))
+		}
+	})
+	return mux
+}
+
+func TestDiscoverOllamaModels(t *testing.T) {
+	t.Parallel()
+
+	server := newOllamaTestServer(t)
+	models, err := discoverOllamaModels(t.Context(), http.DefaultClient, server.URL, nil)
+	require.NoError(t, err)
+	require.Len(t, models, 2)
+
+	require.Equal(t, "qwen3:8b", models[0].ID)
+	require.Equal(t, int64(40960), models[0].ContextWindow)
+	require.Equal(t, int64(4096), models[0].DefaultMaxTokens)
+	require.True(t, models[0].CanReason)
+	require.False(t, models[0].SupportsImages)
+
+	// The model whose details failed to load is skipped.
+	require.Equal(t, "llava:7b", models[1].ID)
+	require.Equal(t, int64(defaultOllamaContextWindow), models[1].ContextWindow)
+	require.True(t, models[1].SupportsImages)
+}
+
+func TestDiscoverOllamaModelsTimeout(t *testing.T) {
+	timeout := ollamaRequestTimeout
+	ollamaRequestTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { ollamaRequestTimeout = timeout })
+
+	// Every request fits in the timeout, all of them together don't.
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tags", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"models":[{"model":"a:1b"},{"model":"b:1b"},{"model":"c:1b"},{"model":"d:1b"}]}`))
+	})
+	mux.HandleFunc("POST /api/show", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(80 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"capabilities":["completion"]}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	models, err := discoverOllamaModels(t.Context(), http.DefaultClient, server.URL, nil)
+	require.NoError(t, err)
+	require.Len(t, models, 4)
+}
+
+func TestConfig_configureProvidersWithOllama(t *testing.T) {
+	server := newOllamaTestServer(t)
+	cfg := &Config{
+		Providers: csync.NewMapFrom(map[string]ProviderConfig{
+			"local": {
+				Type:    TypeOllama,
+				BaseURL: server.URL,
+				Models: []catwalk.Model{
+					{ID: "qwen3:8b", Name: "Qwen", DefaultMaxTokens: 1000},
+				},
+			},
+		}),
+	}
+	cfg.setDefaults("/tmp")
+	env := env.NewFromMap(map[string]string{})
+	resolver := NewEnvironmentVariableResolver(env)
+	err := cfg.configureProviders(env, resolver, nil)
+	require.NoError(t, err)
+
+	provider, ok := cfg.Providers.Get("local")
+	require.True(t, ok)
+	require.Len(t, provider.Models, 2)
+	require.Equal(t, "Qwen", provider.Models[0].Name)
+	require.Equal(t, int64(1000), provider.Models[0].DefaultMaxTokens)
+	require.Equal(t, int64(40960), provider.Models[0].ContextWindow)
+	require.Equal(t, "llava:7b", provider.Models[1].ID)
+}
+
+func TestConfig_configureProvidersWithOllamaHTTP(t *testing.T) {
+	server := httptest.NewTLSServer(newOllamaTestHandler(t))
+	t.Cleanup(server.Close)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+
+	configured := []catwalk.Model{{ID: "qwen3:8b", Name: "Qwen"}}
+	models := func(providerHTTP HTTPConfig, globalHTTP *HTTPConfig) []catwalk.Model {
+		cfg := &Config{
+			Providers: csync.NewMapFrom(map[string]ProviderConfig{
+				"local": {
+					Type:       TypeOllama,
+					BaseURL:    server.URL,
+					Models:     configured,
+					HTTPConfig: providerHTTP,
+				},
+			}),
+			Options: &Options{HTTP: globalHTTP},
+		}
+		cfg.setDefaults("/tmp")
+		env := env.NewFromMap(map[string]string{})
+		require.NoError(t, cfg.configureProviders(env, NewEnvironmentVariableResolver(env), nil))
+		provider, ok := cfg.Providers.Get("local")
+		require.True(t, ok)
+		return provider.Models
+	}
+
+	// The server's certificate is only trusted through the CA file.
+	require.Equal(t, configured, models(HTTPConfig{}, nil))
+	require.Len(t, models(HTTPConfig{CAFile: caFile}, nil), 2)
+	require.Len(t, models(HTTPConfig{}, &HTTPConfig{CAFile: caFile}), 2)
+}
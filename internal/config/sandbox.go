@@ -0,0 +1,17 @@
+package config
+
+// Sandbox confines the commands of the bash tool on Linux. They may only
+// write to the working directory, the temporary directory and the writable
+// paths, and have no network access unless it is allowed.
+type Sandbox struct {
+	Enabled bool `json:"enabled,omitempty" jsonschema:"description=Run the commands of the bash tool in a sandbox (Linux only),default=false"`
+	// Relative paths are relative to the working directory, and ~ is the
+	// home directory.
+	WritablePaths []string `json:"writable_paths,omitempty" jsonschema:"description=Paths commands may write to on top of the working directory and the temporary directory,example=~/.cache/go-build,example=~/.npm"`
+	AllowNetwork  bool     `json:"allow_network,omitempty" jsonschema:"description=Let commands access the network,default=false"`
+	MaxCPUSeconds int      `json:"max_cpu_seconds,omitempty" jsonschema:"description=CPU time in seconds each process may use,default=600,minimum=1,example=300"`
+	MaxMemoryMB   int      `json:"max_memory_mb,omitempty" jsonschema:"description=Memory in MB each process may allocate,default=4096,minimum=1,example=2048"`
+	// The kernel counts every process of the user against this limit, not
+	// only those of the command.
+	MaxProcesses int `json:"max_processes,omitempty" jsonschema:"description=Number of processes your user may run at once before commands fail to start more (all processes of the user count; no limit by default),minimum=1,example=4096"`
+}
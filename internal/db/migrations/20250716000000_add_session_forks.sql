@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add fork origin columns to sessions table
+ALTER TABLE sessions ADD COLUMN forked_from_session_id TEXT;
+ALTER TABLE sessions ADD COLUMN forked_from_message_id TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Remove fork origin columns from sessions table
+ALTER TABLE sessions DROP COLUMN forked_from_message_id;
+ALTER TABLE sessions DROP COLUMN forked_from_session_id;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Tokens of every request of the session, prompt_tokens and completion_tokens
+-- only hold those of the last one
+ALTER TABLE sessions ADD COLUMN total_tokens INTEGER NOT NULL DEFAULT 0 CHECK (total_tokens >= 0);
+
+UPDATE sessions SET total_tokens = COALESCE(
+    (SELECT SUM(input_tokens + output_tokens + cache_creation_tokens + cache_read_tokens) FROM usage WHERE usage.session_id = sessions.id),
+    prompt_tokens + completion_tokens
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE sessions DROP COLUMN total_tokens;
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The assistant message whose tool call recorded the version, so that
+-- rewinding a session undoes the changes of the messages it drops
+ALTER TABLE files ADD COLUMN message_id TEXT;
+-- Whether the file didn't exist at this version, rather than being empty
+ALTER TABLE files ADD COLUMN absent BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE files SET message_id = (
+    SELECT m.id
+    FROM messages m
+    WHERE m.session_id = files.session_id AND m.role = 'assistant' AND m.created_at <= files.created_at
+    ORDER BY m.created_at DESC
+    LIMIT 1
+);
+
+-- Empty first versions used to stand for files that didn't exist
+UPDATE files SET absent = TRUE
+WHERE content = '' AND NOT EXISTS (
+    SELECT 1
+    FROM files earlier
+    WHERE earlier.session_id = files.session_id AND earlier.path = files.path AND earlier.version < files.version
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE files DROP COLUMN absent;
+ALTER TABLE files DROP COLUMN message_id;
+-- +goose StatementEnd
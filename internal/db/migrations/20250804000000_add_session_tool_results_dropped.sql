@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Whether the results of old tool calls are left out of the requests of the
+-- session, until it is summarized
+ALTER TABLE sessions ADD COLUMN tool_results_dropped BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE sessions DROP COLUMN tool_results_dropped;
+-- +goose StatementEnd
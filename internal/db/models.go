@@ -9,13 +9,15 @@ import (
 )
 
 type File struct {
-	ID        string `json:"id"`
-	SessionID string `json:"session_id"`
-	Path      string `json:"path"`
-	Content   string `json:"content"`
-	Version   int64  `json:"version"`
-	CreatedAt int64  `json:"created_at"`
-	UpdatedAt int64  `json:"updated_at"`
+	ID        string         `json:"id"`
+	SessionID string         `json:"session_id"`
+	Path      string         `json:"path"`
+	Content   string         `json:"content"`
+	Version   int64          `json:"version"`
+	CreatedAt int64          `json:"created_at"`
+	UpdatedAt int64          `json:"updated_at"`
+	MessageID sql.NullString `json:"message_id"`
+	Absent    bool           `json:"absent"`
 }
 
 type Message struct {
@@ -31,14 +33,35 @@ type Message struct {
 }
 
 type Session struct {
-	ID               string         `json:"id"`
-	ParentSessionID  sql.NullString `json:"parent_session_id"`
-	Title            string         `json:"title"`
-	MessageCount     int64          `json:"message_count"`
-	PromptTokens     int64          `json:"prompt_tokens"`
-	CompletionTokens int64          `json:"completion_tokens"`
-	Cost             float64        `json:"cost"`
-	UpdatedAt        int64          `json:"updated_at"`
-	CreatedAt        int64          `json:"created_at"`
-	SummaryMessageID sql.NullString `json:"summary_message_id"`
+	ID                  string         `json:"id"`
+	ParentSessionID     sql.NullString `json:"parent_session_id"`
+	Title               string         `json:"title"`
+	MessageCount        int64          `json:"message_count"`
+	PromptTokens        int64          `json:"prompt_tokens"`
+	CompletionTokens    int64          `json:"completion_tokens"`
+	Cost                float64        `json:"cost"`
+	UpdatedAt           int64          `json:"updated_at"`
+	CreatedAt           int64          `json:"created_at"`
+	SummaryMessageID    sql.NullString `json:"summary_message_id"`
+	ForkedFromSessionID sql.NullString `json:"forked_from_session_id"`
+	ForkedFromMessageID sql.NullString `json:"forked_from_message_id"`
+	TotalTokens         int64          `json:"total_tokens"`
+	ToolResultsDropped  bool           `json:"tool_results_dropped"`
+}
+
+type Usage struct {
+	ID                  string         `json:"id"`
+	SessionID           string         `json:"session_id"`
+	MessageID           sql.NullString `json:"message_id"`
+	Kind                string         `json:"kind"`
+	Project             string         `json:"project"`
+	Provider            string         `json:"provider"`
+	Model               string         `json:"model"`
+	InputTokens         int64          `json:"input_tokens"`
+	OutputTokens        int64          `json:"output_tokens"`
+	CacheCreationTokens int64          `json:"cache_creation_tokens"`
+	CacheReadTokens     int64          `json:"cache_read_tokens"`
+	Cost                float64        `json:"cost"`
+	LatencyMs           int64          `json:"latency_ms"`
+	CreatedAt           int64          `json:"created_at"`
 }
@@ -9,9 +9,11 @@ import (
 )
 
 type Querier interface {
+	CopyMessage(ctx context.Context, arg CopyMessageParams) (Message, error)
 	CreateFile(ctx context.Context, arg CreateFileParams) (File, error)
 	CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error)
 	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
+	CreateUsage(ctx context.Context, arg CreateUsageParams) (Usage, error)
 	DeleteFile(ctx context.Context, id string) error
 	DeleteMessage(ctx context.Context, id string) error
 	DeleteSession(ctx context.Context, id string) error
@@ -27,6 +29,8 @@ type Querier interface {
 	ListMessagesBySession(ctx context.Context, sessionID string) ([]Message, error)
 	ListNewFiles(ctx context.Context) ([]File, error)
 	ListSessions(ctx context.Context) ([]Session, error)
+	ListUsage(ctx context.Context, arg ListUsageParams) ([]Usage, error)
+	ListUsageBySession(ctx context.Context, sessionID string) ([]Usage, error)
 	UpdateMessage(ctx context.Context, arg UpdateMessageParams) error
 	UpdateSession(ctx context.Context, arg UpdateSessionParams) (Session, error)
 }
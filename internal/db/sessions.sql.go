@@ -20,6 +20,8 @@ INSERT INTO sessions (
     completion_tokens,
     cost,
     summary_message_id,
+    forked_from_session_id,
+    forked_from_message_id,
     updated_at,
     created_at
 ) VALUES (
@@ -31,19 +33,23 @@ INSERT INTO sessions (
     ?,
     ?,
     null,
+    ?,
+    ?,
     strftime('%s', 'now'),
     strftime('%s', 'now')
-) RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id
+) RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, forked_from_session_id, forked_from_message_id, total_tokens, tool_results_dropped
 `
 
 type CreateSessionParams struct {
-	ID               string         `json:"id"`
-	ParentSessionID  sql.NullString `json:"parent_session_id"`
-	Title            string         `json:"title"`
-	MessageCount     int64          `json:"message_count"`
-	PromptTokens     int64          `json:"prompt_tokens"`
-	CompletionTokens int64          `json:"completion_tokens"`
-	Cost             float64        `json:"cost"`
+	ID                  string         `json:"id"`
+	ParentSessionID     sql.NullString `json:"parent_session_id"`
+	Title               string         `json:"title"`
+	MessageCount        int64          `json:"message_count"`
+	PromptTokens        int64          `json:"prompt_tokens"`
+	CompletionTokens    int64          `json:"completion_tokens"`
+	Cost                float64        `json:"cost"`
+	ForkedFromSessionID sql.NullString `json:"forked_from_session_id"`
+	ForkedFromMessageID sql.NullString `json:"forked_from_message_id"`
 }
 
 func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error) {
@@ -55,6 +61,8 @@ func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (S
 		arg.PromptTokens,
 		arg.CompletionTokens,
 		arg.Cost,
+		arg.ForkedFromSessionID,
+		arg.ForkedFromMessageID,
 	)
 	var i Session
 	err := row.Scan(
@@ -68,6 +76,10 @@ func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (S
 		&i.UpdatedAt,
 		&i.CreatedAt,
 		&i.SummaryMessageID,
+		&i.ForkedFromSessionID,
+		&i.ForkedFromMessageID,
+		&i.TotalTokens,
+		&i.ToolResultsDropped,
 	)
 	return i, err
 }
@@ -83,7 +95,7 @@ func (q *Queries) DeleteSession(ctx context.Context, id string) error {
 }
 
 const getSessionByID = `-- name: GetSessionByID :one
-SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id
+SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, forked_from_session_id, forked_from_message_id, total_tokens, tool_results_dropped
 FROM sessions
 WHERE id = ? LIMIT 1
 `
@@ -102,12 +114,16 @@ func (q *Queries) GetSessionByID(ctx context.Context, id string) (Session, error
 		&i.UpdatedAt,
 		&i.CreatedAt,
 		&i.SummaryMessageID,
+		&i.ForkedFromSessionID,
+		&i.ForkedFromMessageID,
+		&i.TotalTokens,
+		&i.ToolResultsDropped,
 	)
 	return i, err
 }
 
 const listSessions = `-- name: ListSessions :many
-SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id
+SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, forked_from_session_id, forked_from_message_id, total_tokens, tool_results_dropped
 FROM sessions
 WHERE parent_session_id is NULL
 ORDER BY created_at DESC
@@ -133,6 +149,10 @@ func (q *Queries) ListSessions(ctx context.Context) ([]Session, error) {
 			&i.UpdatedAt,
 			&i.CreatedAt,
 			&i.SummaryMessageID,
+			&i.ForkedFromSessionID,
+			&i.ForkedFromMessageID,
+			&i.TotalTokens,
+			&i.ToolResultsDropped,
 		); err != nil {
 			return nil, err
 		}
@@ -154,18 +174,22 @@ SET
     prompt_tokens = ?,
     completion_tokens = ?,
     summary_message_id = ?,
-    cost = ?
+    cost = ?,
+    total_tokens = ?,
+    tool_results_dropped = ?
 WHERE id = ?
-RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id
+RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, forked_from_session_id, forked_from_message_id, total_tokens, tool_results_dropped
 `
 
 type UpdateSessionParams struct {
-	Title            string         `json:"title"`
-	PromptTokens     int64          `json:"prompt_tokens"`
-	CompletionTokens int64          `json:"completion_tokens"`
-	SummaryMessageID sql.NullString `json:"summary_message_id"`
-	Cost             float64        `json:"cost"`
-	ID               string         `json:"id"`
+	Title              string         `json:"title"`
+	PromptTokens       int64          `json:"prompt_tokens"`
+	CompletionTokens   int64          `json:"completion_tokens"`
+	SummaryMessageID   sql.NullString `json:"summary_message_id"`
+	Cost               float64        `json:"cost"`
+	TotalTokens        int64          `json:"total_tokens"`
+	ToolResultsDropped bool           `json:"tool_results_dropped"`
+	ID                 string         `json:"id"`
 }
 
 func (q *Queries) UpdateSession(ctx context.Context, arg UpdateSessionParams) (Session, error) {
@@ -175,6 +199,8 @@ func (q *Queries) UpdateSession(ctx context.Context, arg UpdateSessionParams) (S
 		arg.CompletionTokens,
 		arg.SummaryMessageID,
 		arg.Cost,
+		arg.TotalTokens,
+		arg.ToolResultsDropped,
 		arg.ID,
 	)
 	var i Session
@@ -189,6 +215,10 @@ func (q *Queries) UpdateSession(ctx context.Context, arg UpdateSessionParams) (S
 		&i.UpdatedAt,
 		&i.CreatedAt,
 		&i.SummaryMessageID,
+		&i.ForkedFromSessionID,
+		&i.ForkedFromMessageID,
+		&i.TotalTokens,
+		&i.ToolResultsDropped,
 	)
 	return i, err
 }
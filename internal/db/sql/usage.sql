@@ -0,0 +1,32 @@
+-- name: CreateUsage :one
+INSERT INTO usage (
+    id,
+    session_id,
+    message_id,
+    kind,
+    project,
+    provider,
+    model,
+    input_tokens,
+    output_tokens,
+    cache_creation_tokens,
+    cache_read_tokens,
+    cost,
+    latency_ms,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, strftime('%s', 'now')
+)
+RETURNING *;
+
+-- name: ListUsage :many
+SELECT *
+FROM usage
+WHERE created_at >= sqlc.arg(since) AND created_at < sqlc.arg(until)
+ORDER BY created_at ASC;
+
+-- name: ListUsageBySession :many
+SELECT *
+FROM usage
+WHERE session_id = ?
+ORDER BY created_at ASC;
@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/charmbracelet/crush/internal/db"
@@ -18,8 +21,12 @@ const (
 type File struct {
 	ID        string
 	SessionID string
+	// The assistant message whose tool call recorded the version.
+	MessageID string
 	Path      string
 	Content   string
+	// Absent versions record that the file didn't exist.
+	Absent    bool
 	Version   int64
 	CreatedAt int64
 	UpdatedAt int64
@@ -27,14 +34,16 @@ type File struct {
 
 type Service interface {
 	pubsub.Suscriber[File]
-	Create(ctx context.Context, sessionID, path, content string) (File, error)
-	CreateVersion(ctx context.Context, sessionID, path, content string) (File, error)
+	Create(ctx context.Context, sessionID, messageID, path, content string) (File, error)
+	CreateVersion(ctx context.Context, sessionID, messageID, path, content string) (File, error)
+	CreateAbsent(ctx context.Context, sessionID, messageID, path string) (File, error)
 	Get(ctx context.Context, id string) (File, error)
 	GetByPathAndSession(ctx context.Context, path, sessionID string) (File, error)
 	ListBySession(ctx context.Context, sessionID string) ([]File, error)
 	ListLatestSessionFiles(ctx context.Context, sessionID string) ([]File, error)
 	Delete(ctx context.Context, id string) error
 	DeleteSessionFiles(ctx context.Context, sessionID string) error
+	RestoreSessionFiles(ctx context.Context, sessionID string, messageIDs []string) error
 }
 
 type service struct {
@@ -51,30 +60,50 @@ func NewService(q *db.Queries, db *sql.DB) Service {
 	}
 }
 
-func (s *service) Create(ctx context.Context, sessionID, path, content string) (File, error) {
-	return s.createWithVersion(ctx, sessionID, path, content, InitialVersion)
+func (s *service) Create(ctx context.Context, sessionID, messageID, path, content string) (File, error) {
+	return s.createWithVersion(ctx, fileParams(sessionID, messageID, path, content, false), InitialVersion)
 }
 
-func (s *service) CreateVersion(ctx context.Context, sessionID, path, content string) (File, error) {
+func (s *service) CreateVersion(ctx context.Context, sessionID, messageID, path, content string) (File, error) {
+	return s.createNextVersion(ctx, fileParams(sessionID, messageID, path, content, false))
+}
+
+// CreateAbsent records that the file doesn't exist, before the message
+// creates it or after it deletes it.
+func (s *service) CreateAbsent(ctx context.Context, sessionID, messageID, path string) (File, error) {
+	return s.createNextVersion(ctx, fileParams(sessionID, messageID, path, "", true))
+}
+
+func fileParams(sessionID, messageID, path, content string, absent bool) db.CreateFileParams {
+	return db.CreateFileParams{
+		SessionID: sessionID,
+		MessageID: sql.NullString{String: messageID, Valid: messageID != ""},
+		Path:      path,
+		Content:   content,
+		Absent:    absent,
+	}
+}
+
+func (s *service) createNextVersion(ctx context.Context, params db.CreateFileParams) (File, error) {
 	// Get the latest version for this path
-	files, err := s.q.ListFilesByPath(ctx, path)
+	files, err := s.q.ListFilesByPath(ctx, params.Path)
 	if err != nil {
 		return File{}, err
 	}
 
 	if len(files) == 0 {
 		// No previous versions, create initial
-		return s.Create(ctx, sessionID, path, content)
+		return s.createWithVersion(ctx, params, InitialVersion)
 	}
 
 	// Get the latest version
 	latestFile := files[0] // Files are ordered by version DESC, created_at DESC
 	nextVersion := latestFile.Version + 1
 
-	return s.createWithVersion(ctx, sessionID, path, content, nextVersion)
+	return s.createWithVersion(ctx, params, nextVersion)
 }
 
-func (s *service) createWithVersion(ctx context.Context, sessionID, path, content string, version int64) (File, error) {
+func (s *service) createWithVersion(ctx context.Context, params db.CreateFileParams, version int64) (File, error) {
 	// Maximum number of retries for transaction conflicts
 	const maxRetries = 3
 	var file File
@@ -92,13 +121,9 @@ func (s *service) createWithVersion(ctx context.Context, sessionID, path, conten
 		qtx := s.q.WithTx(tx)
 
 		// Try to create the file within the transaction
-		dbFile, txErr := qtx.CreateFile(ctx, db.CreateFileParams{
-			ID:        uuid.New().String(),
-			SessionID: sessionID,
-			Path:      path,
-			Content:   content,
-			Version:   version,
-		})
+		params.ID = uuid.New().String()
+		params.Version = version
+		dbFile, txErr := qtx.CreateFile(ctx, params)
 		if txErr != nil {
 			// Rollback the transaction
 			tx.Rollback()
@@ -197,12 +222,76 @@ func (s *service) DeleteSessionFiles(ctx context.Context, sessionID string) erro
 	return nil
 }
 
+// RestoreSessionFiles puts every file touched in the session back to the
+// content it had before the given messages changed it and drops the versions
+// they recorded. Files the messages created are removed.
+func (s *service) RestoreSessionFiles(ctx context.Context, sessionID string, messageIDs []string) error {
+	files, err := s.ListBySession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	// Versions are listed oldest first, so the first version of each path is
+	// the content the file had before the session touched it.
+	var paths []string
+	versions := make(map[string][]File)
+	for _, file := range files {
+		if _, ok := versions[file.Path]; !ok {
+			paths = append(paths, file.Path)
+		}
+		versions[file.Path] = append(versions[file.Path], file)
+	}
+
+	for _, path := range paths {
+		pathVersions := versions[path]
+		dropped := slices.IndexFunc(pathVersions, func(file File) bool {
+			return slices.Contains(messageIDs, file.MessageID)
+		})
+		if dropped == -1 {
+			// The messages didn't change it.
+			continue
+		}
+
+		target := pathVersions[0]
+		if dropped > 0 {
+			target = pathVersions[dropped-1]
+		}
+		if err := restoreFile(target); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", path, err)
+		}
+
+		for _, file := range pathVersions[dropped:] {
+			if err := s.Delete(ctx, file.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// restoreFile writes the content of the version to its path, or removes the
+// file when it didn't exist.
+func restoreFile(file File) error {
+	if file.Absent {
+		if err := os.Remove(file.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(file.Path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(file.Path, []byte(file.Content), 0o644)
+}
+
 func (s *service) fromDBItem(item db.File) File {
 	return File{
 		ID:        item.ID,
 		SessionID: item.SessionID,
+		MessageID: item.MessageID.String,
 		Path:      item.Path,
 		Content:   item.Content,
+		Absent:    item.Absent,
 		Version:   item.Version,
 		CreatedAt: item.CreatedAt,
 		UpdatedAt: item.UpdatedAt,
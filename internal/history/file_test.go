@@ -0,0 +1,88 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreSessionFiles(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	conn, err := db.Connect(ctx, t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	q := db.New(conn)
+	_, err = q.CreateSession(ctx, db.CreateSessionParams{ID: "session", Title: "test"})
+	require.NoError(t, err)
+	files := NewService(q, conn)
+
+	dir := t.TempDir()
+	edited := filepath.Join(dir, "edited.go")
+	emptied := filepath.Join(dir, "empty.go")
+	created := filepath.Join(dir, "sub", "created.go")
+
+	// The first message edits an existing file, the second fills an existing
+	// empty file and creates a file, and edits the first one again.
+	require.NoError(t, os.WriteFile(edited, []byte("original"), 0o644))
+	_, err = files.Create(ctx, "session", "first", edited, "original")
+	require.NoError(t, err)
+	_, err = files.CreateVersion(ctx, "session", "first", edited, "changed")
+	require.NoError(t, err)
+	_, err = files.CreateVersion(ctx, "session", "second", edited, "changed again")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(edited, []byte("changed again"), 0o644))
+
+	_, err = files.Create(ctx, "session", "second", emptied, "")
+	require.NoError(t, err)
+	_, err = files.CreateVersion(ctx, "session", "second", emptied, "filled")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(emptied, []byte("filled"), 0o644))
+
+	_, err = files.CreateAbsent(ctx, "session", "second", created)
+	require.NoError(t, err)
+	_, err = files.CreateVersion(ctx, "session", "second", created, "new file")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(created), 0o755))
+	require.NoError(t, os.WriteFile(created, []byte("new file"), 0o644))
+
+	// Restoring without messages keeps everything.
+	require.NoError(t, files.RestoreSessionFiles(ctx, "session", []string{"third"}))
+	versions, err := files.ListBySession(ctx, "session")
+	require.NoError(t, err)
+	require.Len(t, versions, 7)
+
+	// Restoring the second message undoes only its changes.
+	require.NoError(t, files.RestoreSessionFiles(ctx, "session", []string{"second", "third"}))
+
+	content, err := os.ReadFile(edited)
+	require.NoError(t, err)
+	require.Equal(t, "changed", string(content))
+
+	content, err = os.ReadFile(emptied)
+	require.NoError(t, err)
+	require.Empty(t, content)
+
+	_, err = os.Stat(created)
+	require.True(t, os.IsNotExist(err))
+
+	versions, err = files.ListBySession(ctx, "session")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+
+	// Restoring the first message too takes the file back to its original.
+	require.NoError(t, files.RestoreSessionFiles(ctx, "session", []string{"first", "second"}))
+
+	content, err = os.ReadFile(edited)
+	require.NoError(t, err)
+	require.Equal(t, "original", string(content))
+
+	versions, err = files.ListBySession(ctx, "session")
+	require.NoError(t, err)
+	require.Empty(t, versions)
+}
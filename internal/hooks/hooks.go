@@ -0,0 +1,190 @@
+// Package hooks runs the user's shell commands configured to fire around the
+// agent's work, such as before and after tool calls.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/shell"
+)
+
+const defaultTimeout = 60 * time.Second
+
+// Event names a point where hooks fire. It is exposed to hook commands as
+// CRUSH_HOOK_EVENT.
+type Event string
+
+const (
+	EventPreToolUse   Event = "pre_tool_use"
+	EventPostToolUse  Event = "post_tool_use"
+	EventSessionStart Event = "session_start"
+	EventPromptSubmit Event = "prompt_submit"
+	EventAgentStop    Event = "agent_stop"
+)
+
+// SessionEvent is the input written to the stdin of session hooks.
+type SessionEvent struct {
+	SessionID string `json:"session_id"`
+	Prompt    string `json:"prompt,omitempty"`
+}
+
+// Runner runs the configured hooks.
+type Runner struct {
+	hooks      config.Hooks
+	workingDir string
+}
+
+func NewRunner(hooks *config.Hooks, workingDir string) *Runner {
+	r := &Runner{workingDir: workingDir}
+	if hooks != nil {
+		r.hooks = *hooks
+	}
+	return r
+}
+
+// PreToolUse runs the pre-tool hooks matching the call. When one of them
+// fails the call must not run, and the returned message explains why.
+func (r *Runner) PreToolUse(ctx context.Context, sessionID string, call tools.ToolCall) (string, bool) {
+	for _, hook := range r.matching(r.hooks.PreToolUse, call) {
+		stdout, stderr, err := r.run(ctx, hook, EventPreToolUse, sessionID, call.Name, call)
+		if err == nil {
+			continue
+		}
+		reason := strings.TrimSpace(stderr)
+		if reason == "" {
+			reason = strings.TrimSpace(stdout)
+		}
+		if reason == "" {
+			reason = err.Error()
+		}
+		return fmt.Sprintf("Tool call blocked by hook %q: %s", hook.Command, reason), true
+	}
+	return "", false
+}
+
+// PostToolUse runs the post-tool hooks matching the call and appends their
+// output to the response.
+func (r *Runner) PostToolUse(ctx context.Context, sessionID string, call tools.ToolCall, response tools.ToolResponse) tools.ToolResponse {
+	for _, hook := range r.matching(r.hooks.PostToolUse, call) {
+		stdout, stderr, err := r.run(ctx, hook, EventPostToolUse, sessionID, call.Name, call)
+		output := strings.TrimSpace(stdout + stderr)
+		if err != nil && output == "" {
+			output = err.Error()
+		}
+		if output == "" {
+			continue
+		}
+		response.Content += fmt.Sprintf("\n\n<hook command=%q>\n%s\n</hook>", hook.Command, output)
+	}
+	return response
+}
+
+// SessionStart runs the hooks for the first prompt of a session.
+func (r *Runner) SessionStart(ctx context.Context, sessionID string) {
+	r.runAll(ctx, r.hooks.SessionStart, EventSessionStart, SessionEvent{SessionID: sessionID})
+}
+
+// PromptSubmit runs the hooks for a submitted prompt.
+func (r *Runner) PromptSubmit(ctx context.Context, sessionID, prompt string) {
+	r.runAll(ctx, r.hooks.PromptSubmit, EventPromptSubmit, SessionEvent{SessionID: sessionID, Prompt: prompt})
+}
+
+// AgentStop runs the hooks for the end of a run.
+func (r *Runner) AgentStop(ctx context.Context, sessionID string) {
+	r.runAll(ctx, r.hooks.AgentStop, EventAgentStop, SessionEvent{SessionID: sessionID})
+}
+
+func (r *Runner) runAll(ctx context.Context, hooks []config.Hook, event Event, input SessionEvent) {
+	for _, hook := range hooks {
+		if _, stderr, err := r.run(ctx, hook, event, input.SessionID, "", input); err != nil {
+			slog.Warn("Hook failed", "event", event, "command", hook.Command, "error", err, "stderr", stderr)
+		}
+	}
+}
+
+func (r *Runner) run(ctx context.Context, hook config.Hook, event Event, sessionID, toolName string, input any) (string, string, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal hook input: %w", err)
+	}
+
+	timeout := defaultTimeout
+	if hook.Timeout > 0 {
+		timeout = time.Duration(hook.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	env := append(os.Environ(),
+		"CRUSH_HOOK_EVENT="+string(event),
+		"CRUSH_SESSION_ID="+sessionID,
+		"CRUSH_TOOL_NAME="+toolName,
+	)
+	sh := shell.NewShell(&shell.Options{WorkingDir: r.workingDir, Env: env})
+	stdout, stderr, err := sh.ExecWithStdin(ctx, hook.Command, bytes.NewReader(data))
+	slog.Debug("Hook finished", "event", event, "command", hook.Command, "exit_code", shell.ExitCode(err))
+	return stdout, stderr, err
+}
+
+// matching returns the hooks that apply to the tool call.
+func (r *Runner) matching(hooks []config.Hook, call tools.ToolCall) []config.Hook {
+	var matched []config.Hook
+	for _, hook := range hooks {
+		if len(hook.Tools) > 0 && !slices.Contains(hook.Tools, call.Name) {
+			continue
+		}
+		if len(hook.Paths) > 0 && !r.matchesPath(hook.Paths, call) {
+			continue
+		}
+		matched = append(matched, hook)
+	}
+	return matched
+}
+
+func (r *Runner) matchesPath(patterns []string, call tools.ToolCall) bool {
+	path := toolCallPath(call)
+	if path == "" {
+		return false
+	}
+	candidates := []string{filepath.ToSlash(path)}
+	if filepath.IsAbs(path) {
+		if rel, err := filepath.Rel(r.workingDir, path); err == nil {
+			candidates = append(candidates, filepath.ToSlash(rel))
+		}
+	}
+	for _, pattern := range patterns {
+		for _, candidate := range candidates {
+			if ok, _ := doublestar.Match(pattern, candidate); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// toolCallPath returns the file path the tool call works on, if any.
+func toolCallPath(call tools.ToolCall) string {
+	var input struct {
+		FilePath string `json:"file_path"`
+		Path     string `json:"path"`
+	}
+	if err := json.Unmarshal([]byte(call.Input), &input); err != nil {
+		return ""
+	}
+	if input.FilePath != "" {
+		return input.FilePath
+	}
+	return input.Path
+}
@@ -0,0 +1,52 @@
+package hooks
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreToolUse(t *testing.T) {
+	t.Parallel()
+
+	r := NewRunner(&config.Hooks{
+		PreToolUse: []config.Hook{
+			{Command: "echo 'no shell today' >&2; exit 1", Tools: []string{"bash"}},
+			{Command: "exit 1", Tools: []string{"edit"}, Paths: []string{"vendor/**"}},
+		},
+	}, t.TempDir())
+
+	reason, blocked := r.PreToolUse(t.Context(), "session", tools.ToolCall{Name: "bash", Input: `{"command":"ls"}`})
+	require.True(t, blocked)
+	require.Contains(t, reason, "no shell today")
+
+	_, blocked = r.PreToolUse(t.Context(), "session", tools.ToolCall{Name: "edit", Input: `{"file_path":"vendor/lib/a.go"}`})
+	require.True(t, blocked)
+
+	_, blocked = r.PreToolUse(t.Context(), "session", tools.ToolCall{Name: "edit", Input: `{"file_path":"main.go"}`})
+	require.False(t, blocked)
+
+	_, blocked = r.PreToolUse(t.Context(), "session", tools.ToolCall{Name: "view", Input: `{"file_path":"vendor/lib/a.go"}`})
+	require.False(t, blocked)
+}
+
+func TestPostToolUse(t *testing.T) {
+	t.Parallel()
+
+	r := NewRunner(&config.Hooks{
+		PostToolUse: []config.Hook{
+			{Command: `read input; echo "$CRUSH_TOOL_NAME $input"`, Paths: []string{"**/*.go"}},
+		},
+	}, t.TempDir())
+
+	call := tools.ToolCall{ID: "call", Name: "write", Input: `{"file_path":"cmd/main.go"}`}
+	response := r.PostToolUse(t.Context(), "session", call, tools.NewTextResponse("written"))
+	require.Contains(t, response.Content, "written")
+	require.Contains(t, response.Content, `write {"id":"call","name":"write"`)
+
+	call.Input = `{"file_path":"README.md"}`
+	response = r.PostToolUse(t.Context(), "session", call, tools.NewTextResponse("written"))
+	require.Equal(t, "written", response.Content)
+}
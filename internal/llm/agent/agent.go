@@ -7,12 +7,14 @@ import (
 	"log/slog"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/hooks"
 	"github.com/charmbracelet/crush/internal/llm/prompt"
 	"github.com/charmbracelet/crush/internal/llm/provider"
 	"github.com/charmbracelet/crush/internal/llm/tools"
@@ -23,12 +25,16 @@ import (
 	"github.com/charmbracelet/crush/internal/pubsub"
 	"github.com/charmbracelet/crush/internal/session"
 	"github.com/charmbracelet/crush/internal/shell"
+	"github.com/charmbracelet/crush/internal/usage"
 )
 
 // Common errors
 var (
 	ErrRequestCancelled = errors.New("request canceled by user")
 	ErrSessionBusy      = errors.New("session is currently processing another request")
+	ErrBudgetExceeded   = errors.New("budget exceeded")
+	// The request doesn't fit in the context window, even without old turns.
+	ErrContextWindowExceeded = errors.New("context window exceeded")
 )
 
 type AgentEventType string
@@ -37,6 +43,13 @@ const (
 	AgentEventTypeError     AgentEventType = "error"
 	AgentEventTypeResponse  AgentEventType = "response"
 	AgentEventTypeSummarize AgentEventType = "summarize"
+	AgentEventTypePlan      AgentEventType = "plan"
+	AgentEventTypeFallback  AgentEventType = "fallback"
+	AgentEventTypeRetry     AgentEventType = "retry"
+	AgentEventTypeTokens    AgentEventType = "tokens"
+	// The oldest messages of the session were left out of a request to fit
+	// the context window.
+	AgentEventTypeContextTrimmed AgentEventType = "context_trimmed"
 )
 
 type AgentEvent struct {
@@ -48,17 +61,40 @@ type AgentEvent struct {
 	SessionID string
 	Progress  string
 	Done      bool
+
+	// When a plan is submitted for review
+	Plan Plan
+
+	// When switching to a fallback model, Error holds the reason
+	Fallback config.SelectedModel
+
+	// When a failed provider request is about to be retried
+	Retry *provider.RetryInfo
+
+	// Estimated input tokens of the session's next request, or of the
+	// trimmed request
+	Tokens int64
 }
 
 type Service interface {
 	pubsub.Suscriber[AgentEvent]
 	Model() catwalk.Model
 	Run(ctx context.Context, sessionID string, content string, attachments ...message.Attachment) (<-chan AgentEvent, error)
+	Queue(sessionID string, content string, attachments ...message.Attachment)
+	QueuedPrompts(sessionID string) []QueuedPrompt
+	RemoveQueuedPrompt(sessionID string, index int) (QueuedPrompt, bool)
+	ClearQueue(sessionID string)
+	SetPlanMode(sessionID string, enabled bool)
+	IsPlanMode(sessionID string) bool
+	ApprovePlan(ctx context.Context, sessionID string, plan string) (<-chan AgentEvent, error)
+	RejectPlan(sessionID string)
 	Cancel(sessionID string)
 	CancelAll()
+	Shutdown()
 	IsSessionBusy(sessionID string) bool
 	IsBusy() bool
 	Summarize(ctx context.Context, sessionID string) error
+	EstimateTokens(ctx context.Context, sessionID string) (int64, error)
 	UpdateModel() error
 }
 
@@ -67,18 +103,55 @@ type agent struct {
 	agentCfg config.Agent
 	sessions session.Service
 	messages message.Service
+	ledger   usage.Service
 	mcpTools []McpTool
 
-	tools *csync.LazySlice[tools.BaseTool]
+	tools    *csync.LazySlice[tools.BaseTool]
+	planTool tools.BaseTool
 
-	provider   provider.Provider
-	providerID string
+	// The selected model, replaced by UpdateModel.
+	primaryMu sync.RWMutex
+	primary   activeModel
+	// Sessions whose current run switched to a fallback model. The next run
+	// starts over with the selected model.
+	fallbackModels *csync.Map[string, activeModel]
 
 	titleProvider       provider.Provider
+	titleProviderID     string
 	summarizeProvider   provider.Provider
 	summarizeProviderID string
 
 	activeRequests *csync.Map[string, context.CancelFunc]
+	queue          *promptQueue
+	planMode       *csync.Map[string, bool]
+	plans          *csync.Map[string, Plan]
+
+	hooks *hooks.Runner
+}
+
+// readOnlyTools are the tools that never change files or run commands, so
+// several calls to them can safely run at the same time.
+var readOnlyTools = []string{
+	AgentToolName,
+	tools.CallHierarchyToolName,
+	tools.DefinitionToolName,
+	tools.DiagnosticsToolName,
+	tools.FetchToolName,
+	tools.GlobToolName,
+	tools.GrepToolName,
+	tools.HoverToolName,
+	tools.JobListToolName,
+	tools.JobOutputToolName,
+	tools.LSToolName,
+	tools.OutlineToolName,
+	tools.ReferencesToolName,
+	tools.SourcegraphToolName,
+	tools.SymbolsToolName,
+	tools.ViewToolName,
+}
+
+func isReadOnlyTool(name string) bool {
+	return slices.Contains(readOnlyTools, name)
 }
 
 var agentPromptMap = map[string]prompt.PromptID{
@@ -86,6 +159,22 @@ var agentPromptMap = map[string]prompt.PromptID{
 	"task":  prompt.PromptTask,
 }
 
+// agentSystemPrompt resolves the system prompt for the given agent. Built-in
+// agents use their own prompts, user-defined agents read theirs from the
+// configured prompt file and fall back to the coder prompt.
+func agentSystemPrompt(agentCfg config.Agent, providerID string) (string, error) {
+	if promptID, ok := agentPromptMap[agentCfg.ID]; ok {
+		return prompt.GetPrompt(promptID, providerID, config.Get().Options.ContextPaths...), nil
+	}
+	if agentCfg.Prompt != "" {
+		return prompt.CustomPrompt(agentCfg.Prompt, agentCfg.ContextPaths...)
+	}
+	if agentCfg.IsCustom() {
+		return prompt.GetPrompt(prompt.PromptCoder, providerID, agentCfg.ContextPaths...), nil
+	}
+	return prompt.GetPrompt(prompt.PromptDefault, providerID), nil
+}
+
 func NewAgent(
 	ctx context.Context,
 	agentCfg config.Agent,
@@ -94,17 +183,18 @@ func NewAgent(
 	sessions session.Service,
 	messages message.Service,
 	history history.Service,
+	ledger usage.Service,
 	lspClients map[string]*lsp.Client,
 ) (Service, error) {
 	cfg := config.Get()
 
 	var agentTool tools.BaseTool
-	if agentCfg.ID == "coder" {
+	if agentCfg.ID != "task" && (agentCfg.AllowedTools == nil || slices.Contains(agentCfg.AllowedTools, AgentToolName)) {
 		taskAgentCfg := config.Get().Agents["task"]
 		if taskAgentCfg.ID == "" {
 			return nil, fmt.Errorf("task agent not found in config")
 		}
-		taskAgent, err := NewAgent(ctx, taskAgentCfg, permissions, sessions, messages, history, lspClients)
+		taskAgent, err := NewAgent(ctx, taskAgentCfg, permissions, sessions, messages, history, ledger, lspClients)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create task agent: %w", err)
 		}
@@ -122,13 +212,13 @@ func NewAgent(
 		return nil, fmt.Errorf("model not found for agent %s", agentCfg.Name)
 	}
 
-	promptID := agentPromptMap[agentCfg.ID]
-	if promptID == "" {
-		promptID = prompt.PromptDefault
+	systemPrompt, err := agentSystemPrompt(agentCfg, providerCfg.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompt for agent %s: %w", agentCfg.Name, err)
 	}
 	opts := []provider.ProviderClientOption{
 		provider.WithModel(agentCfg.Model),
-		provider.WithSystemMessage(prompt.GetPrompt(promptID, providerCfg.ID, config.Get().Options.ContextPaths...)),
+		provider.WithSystemMessage(systemPrompt),
 	}
 	agentProvider, err := provider.NewProvider(*providerCfg, opts...)
 	if err != nil {
@@ -159,15 +249,17 @@ func NewAgent(
 	if err != nil {
 		return nil, err
 	}
-	summarizeOpts := []provider.ProviderClientOption{
-		provider.WithModel(config.SelectedModelTypeSmall),
-		provider.WithSystemMessage(prompt.GetPrompt(prompt.PromptSummarizer, smallModelProviderCfg.ID)),
-	}
-	summarizeProvider, err := provider.NewProvider(*smallModelProviderCfg, summarizeOpts...)
+	summarizeProvider, summarizeProviderID, err := newSummarizeProvider(cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	// Requests of the fetch, download and sourcegraph tools.
+	transport, err := cfg.Options.HTTP.Transport()
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTTP settings: %w", err)
+	}
+
 	toolFn := func() []tools.BaseTool {
 		slog.Info("Initializing agent tools", "agent", agentCfg.ID)
 		defer func() {
@@ -176,15 +268,19 @@ func NewAgent(
 
 		cwd := cfg.WorkingDir()
 		allTools := []tools.BaseTool{
-			tools.NewBashTool(permissions, cwd),
-			tools.NewDownloadTool(permissions, cwd),
+			tools.NewBashTool(permissions, cwd, bashSandbox(cfg.Options.Sandbox, cwd)),
+			tools.NewJobOutputTool(),
+			tools.NewJobListTool(),
+			tools.NewJobKillTool(),
+			tools.NewDownloadTool(permissions, cwd, transport),
 			tools.NewEditTool(lspClients, permissions, history, cwd),
 			tools.NewMultiEditTool(lspClients, permissions, history, cwd),
-			tools.NewFetchTool(permissions, cwd),
+			tools.NewApplyPatchTool(lspClients, permissions, history, cwd),
+			tools.NewFetchTool(permissions, cwd, transport),
 			tools.NewGlobTool(cwd),
 			tools.NewGrepTool(cwd),
 			tools.NewLsTool(permissions, cwd),
-			tools.NewSourcegraphTool(),
+			tools.NewSourcegraphTool(transport),
 			tools.NewViewTool(lspClients, permissions, cwd),
 			tools.NewWriteTool(lspClients, permissions, history, cwd),
 		}
@@ -192,10 +288,25 @@ func NewAgent(
 		mcpToolsOnce.Do(func() {
 			mcpTools = doGetMCPTools(ctx, permissions, cfg)
 		})
-		allTools = append(allTools, mcpTools...)
+		for _, tool := range mcpTools {
+			if mcpTool, ok := tool.(*McpTool); ok && !mcpToolAllowed(agentCfg, mcpTool) {
+				continue
+			}
+			allTools = append(allTools, tool)
+		}
 
 		if len(lspClients) > 0 {
-			allTools = append(allTools, tools.NewDiagnosticsTool(lspClients))
+			allTools = append(allTools,
+				tools.NewDiagnosticsTool(lspClients),
+				tools.NewDefinitionTool(lspClients, cwd),
+				tools.NewReferencesTool(lspClients, cwd),
+				tools.NewSymbolsTool(lspClients, cwd),
+				tools.NewHoverTool(lspClients, cwd),
+				tools.NewOutlineTool(lspClients, cwd),
+				tools.NewCallHierarchyTool(lspClients, cwd),
+				tools.NewRenameSymbolTool(lspClients, permissions, history, cwd),
+				tools.NewApplyCodeActionTool(lspClients, permissions, history, cwd),
+			)
 		}
 
 		if agentTool != nil {
@@ -215,18 +326,26 @@ func NewAgent(
 		return filteredTools
 	}
 
+	plans := csync.NewMap[string, Plan]()
 	return &agent{
 		Broker:              pubsub.NewBroker[AgentEvent](),
 		agentCfg:            agentCfg,
-		provider:            agentProvider,
-		providerID:          string(providerCfg.ID),
+		primary:             activeModel{provider: agentProvider, providerID: string(providerCfg.ID)},
+		fallbackModels:      csync.NewMap[string, activeModel](),
 		messages:            messages,
 		sessions:            sessions,
+		ledger:              ledger,
 		titleProvider:       titleProvider,
+		titleProviderID:     string(smallModelProviderCfg.ID),
 		summarizeProvider:   summarizeProvider,
-		summarizeProviderID: string(smallModelProviderCfg.ID),
+		summarizeProviderID: summarizeProviderID,
 		activeRequests:      csync.NewMap[string, context.CancelFunc](),
+		queue:               newPromptQueue(),
+		planMode:            csync.NewMap[string, bool](),
+		plans:               plans,
 		tools:               csync.NewLazySlice(toolFn),
+		planTool:            &planTool{plans: plans},
+		hooks:               hooks.NewRunner(cfg.Hooks, cfg.WorkingDir()),
 	}, nil
 }
 
@@ -235,6 +354,10 @@ func (a *agent) Model() catwalk.Model {
 }
 
 func (a *agent) Cancel(sessionID string) {
+	// Prompts queued behind the run are dropped along with it
+	a.queue.drain(sessionID)
+	a.plans.Del(sessionID)
+
 	// Cancel regular requests
 	if cancel, ok := a.activeRequests.Take(sessionID); ok && cancel != nil {
 		slog.Info("Request cancellation initiated", "session_id", sessionID)
@@ -248,6 +371,99 @@ func (a *agent) Cancel(sessionID string) {
 	}
 }
 
+// Queue holds a prompt until the session is free. Prompts are sent in the
+// order they were queued once the current run finishes, or handed to the
+// running agent between tool iterations when the queue mode is "steer". An idle
+// session runs the prompt right away.
+func (a *agent) Queue(sessionID string, content string, attachments ...message.Attachment) {
+	a.queue.push(sessionID, QueuedPrompt{Content: content, Attachments: attachments})
+	if !a.IsSessionBusy(sessionID) {
+		a.runQueued(context.Background(), sessionID)
+	}
+}
+
+// QueuedPrompts returns the prompts waiting for the session, oldest first.
+func (a *agent) QueuedPrompts(sessionID string) []QueuedPrompt {
+	return a.queue.list(sessionID)
+}
+
+// RemoveQueuedPrompt takes the prompt at the given position out of the queue.
+func (a *agent) RemoveQueuedPrompt(sessionID string, index int) (QueuedPrompt, bool) {
+	return a.queue.remove(sessionID, index)
+}
+
+// ClearQueue drops every prompt waiting for the session.
+func (a *agent) ClearQueue(sessionID string) {
+	a.queue.drain(sessionID)
+}
+
+// runQueued starts a run for the next queued prompt of the session, if any.
+func (a *agent) runQueued(ctx context.Context, sessionID string) {
+	next, ok := a.queue.pop(sessionID)
+	if !ok {
+		return
+	}
+	events, err := a.Run(ctx, sessionID, next.Content, next.Attachments...)
+	if err != nil {
+		slog.Warn("Failed to run queued prompt", "session_id", sessionID, "error", err)
+		a.queue.pushFront(sessionID, next)
+		return
+	}
+	// The result is published to subscribers, nobody else reads the events
+	// of queued runs.
+	go func() {
+		for range events {
+		}
+	}()
+}
+
+// SetPlanMode switches the session between planning with read-only tools
+// and working with the full tool set.
+func (a *agent) SetPlanMode(sessionID string, enabled bool) {
+	if enabled {
+		a.planMode.Set(sessionID, true)
+		return
+	}
+	a.planMode.Del(sessionID)
+}
+
+func (a *agent) IsPlanMode(sessionID string) bool {
+	_, ok := a.planMode.Get(sessionID)
+	return ok
+}
+
+// ApprovePlan leaves plan mode and has the agent carry out the given plan
+// with the full tool set.
+func (a *agent) ApprovePlan(ctx context.Context, sessionID string, plan string) (<-chan AgentEvent, error) {
+	if a.IsSessionBusy(sessionID) {
+		return nil, ErrSessionBusy
+	}
+	a.SetPlanMode(sessionID, false)
+	return a.Run(ctx, sessionID, approvedPlanPrompt(plan))
+}
+
+// RejectPlan keeps the session in plan mode and sends the prompts queued
+// while the plan was reviewed.
+func (a *agent) RejectPlan(sessionID string) {
+	if !a.IsSessionBusy(sessionID) {
+		a.runQueued(context.Background(), sessionID)
+	}
+}
+
+// sessionTools returns the tools the agent may use in the session.
+func (a *agent) sessionTools(sessionID string) []tools.BaseTool {
+	if !a.IsPlanMode(sessionID) {
+		return slices.Collect(a.tools.Seq())
+	}
+	available := []tools.BaseTool{a.planTool}
+	for tool := range a.tools.Seq() {
+		if slices.Contains(planModeTools, tool.Name()) {
+			available = append(available, tool)
+		}
+	}
+	return available
+}
+
 func (a *agent) IsBusy() bool {
 	var busy bool
 	for cancelFunc := range a.activeRequests.Seq() {
@@ -271,15 +487,12 @@ func (a *agent) generateTitle(ctx context.Context, sessionID string, content str
 	if a.titleProvider == nil {
 		return nil
 	}
-	session, err := a.sessions.Get(ctx, sessionID)
-	if err != nil {
-		return err
-	}
 	parts := []message.ContentPart{message.TextContent{
 		Text: fmt.Sprintf("Generate a concise title for the following content:\n\n%s", content),
 	}}
 
 	// Use streaming approach like summarization
+	start := time.Now()
 	response := a.titleProvider.StreamResponse(
 		ctx,
 		[]message.Message{
@@ -302,13 +515,17 @@ func (a *agent) generateTitle(ctx context.Context, sessionID string, content str
 	if finalResponse == nil {
 		return fmt.Errorf("no response received from title provider")
 	}
+	cost := a.recordUsage(ctx, usage.KindTitle, sessionID, "", a.titleProviderID, a.titleProvider.Model(), finalResponse.Usage, time.Since(start))
 
-	title := strings.TrimSpace(strings.ReplaceAll(finalResponse.Content, "\n", " "))
-	if title == "" {
-		return nil
+	// The session is read last, the agent updates it in the meantime.
+	session, err := a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	session.Cost += cost
+	if title := strings.TrimSpace(strings.ReplaceAll(finalResponse.Content, "\n", " ")); title != "" {
+		session.Title = title
 	}
-
-	session.Title = title
 	_, err = a.sessions.Save(ctx, session)
 	return err
 }
@@ -345,10 +562,17 @@ func (a *agent) Run(ctx context.Context, sessionID string, content string, attac
 		if result.Error != nil && !errors.Is(result.Error, ErrRequestCancelled) && !errors.Is(result.Error, context.Canceled) {
 			slog.Error(result.Error.Error())
 		}
+		a.fallbackModels.Del(sessionID)
 		slog.Debug("Request completed", "sessionID", sessionID)
 		a.activeRequests.Del(sessionID)
 		cancel()
+		// Canceled runs end too, the hooks are only bound by their timeouts.
+		a.hooks.AgentStop(context.WithoutCancel(ctx), sessionID)
 		a.Publish(pubsub.CreatedEvent, result)
+		// Queued prompts wait for the user to approve or reject a plan.
+		if result.Error == nil && result.Type != AgentEventTypePlan && result.Message.FinishReason() != message.FinishReasonBudgetExceeded {
+			a.runQueued(ctx, sessionID)
+		}
 		events <- result
 		close(events)
 	}()
@@ -363,6 +587,7 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 		return a.err(fmt.Errorf("failed to list messages: %w", err))
 	}
 	if len(msgs) == 0 {
+		a.hooks.SessionStart(ctx, sessionID)
 		go func() {
 			defer log.RecoverPanic("agent.Run", func() {
 				slog.Error("panic while generating title")
@@ -373,23 +598,12 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 			}
 		}()
 	}
+	a.hooks.PromptSubmit(ctx, sessionID, content)
 	session, err := a.sessions.Get(ctx, sessionID)
 	if err != nil {
 		return a.err(fmt.Errorf("failed to get session: %w", err))
 	}
-	if session.SummaryMessageID != "" {
-		summaryMsgInex := -1
-		for i, msg := range msgs {
-			if msg.ID == session.SummaryMessageID {
-				summaryMsgInex = i
-				break
-			}
-		}
-		if summaryMsgInex != -1 {
-			msgs = msgs[summaryMsgInex:]
-			msgs[0].Role = message.User
-		}
-	}
+	msgs = a.sessionHistory(session, msgs)
 
 	userMsg, err := a.createUserMessage(ctx, sessionID, content, attachmentParts)
 	if err != nil {
@@ -398,6 +612,7 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 	// Append the new user message to the conversation history.
 	msgHistory := append(msgs, userMsg)
 
+	budget := newBudgetTracker(cfg.Options.Budget, session)
 	for {
 		// Check for cancellation before each iteration
 		select {
@@ -406,6 +621,9 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 		default:
 			// Continue processing
 		}
+		if reason := budget.exceeded(session); reason != "" {
+			return a.budgetExceeded(sessionID, reason)
+		}
 		agentMessage, toolResults, err := a.streamAndHandleEvents(ctx, sessionID, msgHistory)
 		if err != nil {
 			if errors.Is(err, context.Canceled) {
@@ -419,8 +637,30 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 			slog.Info("Result", "message", agentMessage.FinishReason(), "toolResults", toolResults)
 		}
 		if (agentMessage.FinishReason() == message.FinishReasonToolUse) && toolResults != nil {
+			if plan, ok := a.plans.Take(sessionID); ok {
+				// Wait for the user to review the plan before going on.
+				return AgentEvent{
+					Type:      AgentEventTypePlan,
+					Message:   agentMessage,
+					SessionID: sessionID,
+					Plan:      plan,
+					Done:      true,
+				}
+			}
 			// We are not done, we need to respond with the tool response
 			msgHistory = append(msgHistory, agentMessage, *toolResults)
+			if cfg.Options.QueueMode == config.QueueModeSteer {
+				steering, err := a.steer(ctx, sessionID)
+				if err != nil {
+					return a.err(fmt.Errorf("failed to create user message: %w", err))
+				}
+				msgHistory = append(msgHistory, steering...)
+			}
+			session, err = a.sessions.Get(ctx, sessionID)
+			if err != nil {
+				return a.err(fmt.Errorf("failed to get session: %w", err))
+			}
+			budget.track(session)
 			continue
 		}
 		if agentMessage.FinishReason() == "" {
@@ -437,6 +677,56 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 	}
 }
 
+// budgetExceeded stops the run with an assistant message explaining which
+// limit was reached.
+func (a *agent) budgetExceeded(sessionID, reason string) AgentEvent {
+	slog.Info("Agent budget exceeded", "session_id", sessionID, "reason", reason)
+	active := a.active(sessionID)
+	msg, err := a.messages.Create(context.Background(), sessionID, message.CreateMessageParams{
+		Role: message.Assistant,
+		Parts: []message.ContentPart{
+			message.Finish{
+				Reason:  message.FinishReasonBudgetExceeded,
+				Time:    time.Now().Unix(),
+				Message: "Budget exceeded",
+				Details: reason,
+			},
+		},
+		Model:    active.provider.Model().ID,
+		Provider: active.providerID,
+	})
+	if err != nil {
+		return a.err(fmt.Errorf("failed to create message: %w", err))
+	}
+	return AgentEvent{
+		Type:    AgentEventTypeResponse,
+		Message: msg,
+		Done:    true,
+	}
+}
+
+// steer turns the prompts queued for the session into user messages, so the
+// agent sees them before its next iteration.
+func (a *agent) steer(ctx context.Context, sessionID string) ([]message.Message, error) {
+	var msgs []message.Message
+	supportsImages := a.Model().SupportsImages
+	for _, prompt := range a.queue.drain(sessionID) {
+		var attachmentParts []message.ContentPart
+		for _, attachment := range prompt.Attachments {
+			if !supportsImages {
+				break
+			}
+			attachmentParts = append(attachmentParts, message.BinaryContent{Path: attachment.FilePath, MIMEType: attachment.MimeType, Data: attachment.Content})
+		}
+		msg, err := a.createUserMessage(ctx, sessionID, prompt.Content, attachmentParts)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
 func (a *agent) createUserMessage(ctx context.Context, sessionID, content string, attachmentParts []message.ContentPart) (message.Message, error) {
 	parts := []message.ContentPart{message.TextContent{Text: content}}
 	parts = append(parts, attachmentParts...)
@@ -448,136 +738,86 @@ func (a *agent) createUserMessage(ctx context.Context, sessionID, content string
 
 func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msgHistory []message.Message) (message.Message, *message.Message, error) {
 	ctx = context.WithValue(ctx, tools.SessionIDContextKey, sessionID)
-	eventChan := a.provider.StreamResponse(ctx, msgHistory, slices.Collect(a.tools.Seq()))
-
-	assistantMsg, err := a.messages.Create(ctx, sessionID, message.CreateMessageParams{
-		Role:     message.Assistant,
-		Parts:    []message.ContentPart{},
-		Model:    a.Model().ID,
-		Provider: a.providerID,
-	})
+	availableTools := a.sessionTools(sessionID)
+	if a.IsPlanMode(sessionID) {
+		msgHistory = withPlanModePrompt(msgHistory)
+	}
+	msgHistory, err := a.fitContext(ctx, sessionID, msgHistory, availableTools)
+	if err != nil {
+		return message.Message{}, nil, err
+	}
+	assistantMsg, err := a.streamResponse(ctx, sessionID, msgHistory, availableTools)
+	for err != nil && provider.IsFallbackError(err) {
+		fallback, ok := a.switchToFallback(sessionID, err)
+		if !ok {
+			break
+		}
+		// The failed attempt is replaced by the fallback model's answer.
+		if deleteErr := a.messages.Delete(context.Background(), assistantMsg.ID); deleteErr != nil {
+			slog.Error("Failed to delete failed assistant message", "error", deleteErr)
+		}
+		slog.Info("Switched to fallback model", "provider", fallback.Provider, "model", fallback.Model, "error", err)
+		assistantMsg, err = a.streamResponse(ctx, sessionID, msgHistory, availableTools)
+	}
 	if err != nil {
-		return assistantMsg, nil, fmt.Errorf("failed to create assistant message: %w", err)
+		return assistantMsg, nil, err
 	}
 
 	// Add the session and message ID into the context if needed by tools.
 	ctx = context.WithValue(ctx, tools.MessageIDContextKey, assistantMsg.ID)
 
-	// Process each event in the stream.
-	for event := range eventChan {
-		if processErr := a.processEvent(ctx, sessionID, &assistantMsg, event); processErr != nil {
-			if errors.Is(processErr, context.Canceled) {
-				a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
-			} else {
-				a.finishMessage(ctx, &assistantMsg, message.FinishReasonError, "API Error", processErr.Error())
+	toolCalls := assistantMsg.ToolCalls()
+	toolResults := make([]message.ToolResult, len(toolCalls))
+	concurrency := config.Get().Options.ToolConcurrency
+	for i := 0; i < len(toolCalls); {
+		// Consecutive read-only calls run together; anything that may change
+		// files or run commands runs on its own, in call order.
+		n := 1
+		if isReadOnlyTool(toolCalls[i].Name) {
+			for i+n < len(toolCalls) && isReadOnlyTool(toolCalls[i+n].Name) {
+				n++
 			}
-			return assistantMsg, nil, processErr
 		}
-		if ctx.Err() != nil {
-			a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
-			return assistantMsg, nil, ctx.Err()
-		}
-	}
 
-	toolResults := make([]message.ToolResult, len(assistantMsg.ToolCalls()))
-	toolCalls := assistantMsg.ToolCalls()
-	for i, toolCall := range toolCalls {
-		select {
-		case <-ctx.Done():
+		results, err := a.runTools(ctx, availableTools, toolCalls[i:i+n], concurrency)
+		if err != nil {
 			a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
 			// Make all future tool calls cancelled
-			for j := i; j < len(toolCalls); j++ {
-				toolResults[j] = message.ToolResult{
-					ToolCallID: toolCalls[j].ID,
-					Content:    "Tool execution canceled by user",
-					IsError:    true,
-				}
-			}
-			goto out
-		default:
-			// Continue processing
-			var tool tools.BaseTool
-			for availableTool := range a.tools.Seq() {
-				if availableTool.Info().Name == toolCall.Name {
-					tool = availableTool
-					break
-				}
-			}
+			cancelToolCalls(toolResults, toolCalls, i)
+			break
+		}
 
-			// Tool not found
-			if tool == nil {
-				toolResults[i] = message.ToolResult{
+		denied := false
+		for j, result := range results {
+			toolCall := toolCalls[i+j]
+			if result.err != nil {
+				slog.Error("Tool execution error", "toolCall", toolCall.ID, "error", result.err)
+			}
+			if errors.Is(result.err, permission.ErrorPermissionDenied) {
+				toolResults[i+j] = message.ToolResult{
 					ToolCallID: toolCall.ID,
-					Content:    fmt.Sprintf("Tool not found: %s", toolCall.Name),
+					Content:    "Permission denied",
 					IsError:    true,
 				}
+				denied = true
 				continue
 			}
-
-			// Run tool in goroutine to allow cancellation
-			type toolExecResult struct {
-				response tools.ToolResponse
-				err      error
-			}
-			resultChan := make(chan toolExecResult, 1)
-
-			go func() {
-				response, err := tool.Run(ctx, tools.ToolCall{
-					ID:    toolCall.ID,
-					Name:  toolCall.Name,
-					Input: toolCall.Input,
-				})
-				resultChan <- toolExecResult{response: response, err: err}
-			}()
-
-			var toolResponse tools.ToolResponse
-			var toolErr error
-
-			select {
-			case <-ctx.Done():
-				a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
-				// Mark remaining tool calls as cancelled
-				for j := i; j < len(toolCalls); j++ {
-					toolResults[j] = message.ToolResult{
-						ToolCallID: toolCalls[j].ID,
-						Content:    "Tool execution canceled by user",
-						IsError:    true,
-					}
-				}
-				goto out
-			case result := <-resultChan:
-				toolResponse = result.response
-				toolErr = result.err
-			}
-
-			if toolErr != nil {
-				slog.Error("Tool execution error", "toolCall", toolCall.ID, "error", toolErr)
-				if errors.Is(toolErr, permission.ErrorPermissionDenied) {
-					toolResults[i] = message.ToolResult{
-						ToolCallID: toolCall.ID,
-						Content:    "Permission denied",
-						IsError:    true,
-					}
-					for j := i + 1; j < len(toolCalls); j++ {
-						toolResults[j] = message.ToolResult{
-							ToolCallID: toolCalls[j].ID,
-							Content:    "Tool execution canceled by user",
-							IsError:    true,
-						}
-					}
-					a.finishMessage(ctx, &assistantMsg, message.FinishReasonPermissionDenied, "Permission denied", "")
-					break
-				}
-			}
-			toolResults[i] = message.ToolResult{
+			toolResults[i+j] = message.ToolResult{
 				ToolCallID: toolCall.ID,
-				Content:    toolResponse.Content,
-				Metadata:   toolResponse.Metadata,
-				IsError:    toolResponse.IsError,
+				Content:    result.response.Content,
+				Metadata:   result.response.Metadata,
+				IsError:    result.response.IsError,
 			}
 		}
+		if denied {
+			// The other calls run along with the denied one keep their
+			// results, only the ones after them are canceled.
+			cancelToolCalls(toolResults, toolCalls, i+n)
+			a.finishMessage(ctx, &assistantMsg, message.FinishReasonPermissionDenied, "Permission denied", "")
+			break
+		}
+		i += n
 	}
-out:
 	if len(toolResults) == 0 {
 		return assistantMsg, nil, nil
 	}
@@ -588,7 +828,7 @@ out:
 	msg, err := a.messages.Create(context.Background(), assistantMsg.SessionID, message.CreateMessageParams{
 		Role:     message.Tool,
 		Parts:    parts,
-		Provider: a.providerID,
+		Provider: a.active(sessionID).providerID,
 	})
 	if err != nil {
 		return assistantMsg, nil, fmt.Errorf("failed to create cancelled tool message: %w", err)
@@ -597,12 +837,147 @@ out:
 	return assistantMsg, &msg, err
 }
 
+// streamResponse streams the provider's response to the history into a new
+// assistant message.
+func (a *agent) streamResponse(ctx context.Context, sessionID string, msgHistory []message.Message, availableTools []tools.BaseTool) (message.Message, error) {
+	start := time.Now()
+	active := a.active(sessionID)
+	eventChan := active.provider.StreamResponse(ctx, msgHistory, availableTools)
+
+	assistantMsg, err := a.messages.Create(ctx, sessionID, message.CreateMessageParams{
+		Role:     message.Assistant,
+		Parts:    []message.ContentPart{},
+		Model:    active.provider.Model().ID,
+		Provider: active.providerID,
+	})
+	if err != nil {
+		return assistantMsg, fmt.Errorf("failed to create assistant message: %w", err)
+	}
+	ctx = context.WithValue(ctx, tools.MessageIDContextKey, assistantMsg.ID)
+
+	// Process each event in the stream.
+	for event := range eventChan {
+		if processErr := a.processEvent(ctx, sessionID, &assistantMsg, event, start); processErr != nil {
+			if errors.Is(processErr, context.Canceled) {
+				a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
+			} else {
+				a.finishMessage(ctx, &assistantMsg, message.FinishReasonError, "API Error", processErr.Error())
+			}
+			return assistantMsg, processErr
+		}
+		if ctx.Err() != nil {
+			a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
+			return assistantMsg, ctx.Err()
+		}
+	}
+	return assistantMsg, nil
+}
+
+type toolExecResult struct {
+	response tools.ToolResponse
+	err      error
+}
+
+// runTools runs the given tool calls against the available tools, at most
+// limit of them at a time, and returns their results in call order. If the
+// context is canceled before all calls are done it returns the context error
+// instead.
+func (a *agent) runTools(ctx context.Context, availableTools []tools.BaseTool, toolCalls []message.ToolCall, limit int) ([]toolExecResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	limit = max(limit, 1)
+
+	results := make([]toolExecResult, len(toolCalls))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, toolCall := range toolCalls {
+		tool := findTool(availableTools, toolCall.Name)
+		if tool == nil {
+			results[i] = toolExecResult{
+				response: tools.NewTextErrorResponse(fmt.Sprintf("Tool not found: %s", toolCall.Name)),
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer log.RecoverPanic("agent.runTools", func() {
+				results[i] = toolExecResult{err: fmt.Errorf("panic while running tool %s", toolCall.Name)}
+			})
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i] = toolExecResult{err: ctx.Err()}
+				return
+			}
+			response, err := a.runTool(ctx, tool, tools.ToolCall{
+				ID:    toolCall.ID,
+				Name:  toolCall.Name,
+				Input: toolCall.Input,
+			})
+			results[i] = toolExecResult{response: response, err: err}
+		}()
+	}
+
+	// Wait in a goroutine to allow cancellation
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-done:
+		return results, nil
+	}
+}
+
+// runTool runs the tool call between the pre- and post-tool hooks.
+func (a *agent) runTool(ctx context.Context, tool tools.BaseTool, call tools.ToolCall) (tools.ToolResponse, error) {
+	sessionID, _ := ctx.Value(tools.SessionIDContextKey).(string)
+	if reason, blocked := a.hooks.PreToolUse(ctx, sessionID, call); blocked {
+		return tools.NewTextErrorResponse(reason), nil
+	}
+	response, err := tool.Run(ctx, call)
+	if err != nil {
+		return response, err
+	}
+	return a.hooks.PostToolUse(ctx, sessionID, call, response), nil
+}
+
+func findTool(availableTools []tools.BaseTool, name string) tools.BaseTool {
+	for _, tool := range availableTools {
+		if tool.Info().Name == name {
+			return tool
+		}
+	}
+	return nil
+}
+
+// cancelToolCalls marks every tool call starting at index from as canceled.
+func cancelToolCalls(toolResults []message.ToolResult, toolCalls []message.ToolCall, from int) {
+	for j := from; j < len(toolCalls); j++ {
+		toolResults[j] = message.ToolResult{
+			ToolCallID: toolCalls[j].ID,
+			Content:    "Tool execution canceled by user",
+			IsError:    true,
+		}
+	}
+}
+
 func (a *agent) finishMessage(ctx context.Context, msg *message.Message, finishReason message.FinishReason, message, details string) {
 	msg.AddFinish(finishReason, message, details)
 	_ = a.messages.Update(ctx, *msg)
 }
 
-func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg *message.Message, event provider.ProviderEvent) error {
+// processEvent applies the provider event to the assistant message, whose
+// request was sent at start.
+func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg *message.Message, event provider.ProviderEvent, start time.Time) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -617,6 +992,9 @@ func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg
 	case provider.EventSignatureDelta:
 		assistantMsg.AppendReasoningSignature(event.Signature)
 		return a.messages.Update(ctx, *assistantMsg)
+	case provider.EventReasoningItem:
+		assistantMsg.AddReasoningItem(*event.Reasoning)
+		return a.messages.Update(ctx, *assistantMsg)
 	case provider.EventContentDelta:
 		assistantMsg.FinishThinking()
 		assistantMsg.AppendContent(event.Content)
@@ -633,6 +1011,15 @@ func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg
 		slog.Info("Finished tool call", "toolCall", event.ToolCall)
 		assistantMsg.FinishToolCall(event.ToolCall.ID)
 		return a.messages.Update(ctx, *assistantMsg)
+	case provider.EventRetry:
+		// The retried request starts the answer over.
+		assistantMsg.Parts = nil
+		a.Publish(pubsub.CreatedEvent, AgentEvent{
+			Type:      AgentEventTypeRetry,
+			SessionID: sessionID,
+			Retry:     event.Retry,
+		})
+		return a.messages.Update(ctx, *assistantMsg)
 	case provider.EventError:
 		return event.Error
 	case provider.EventComplete:
@@ -642,31 +1029,37 @@ func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg
 		if err := a.messages.Update(ctx, *assistantMsg); err != nil {
 			return fmt.Errorf("failed to update message: %w", err)
 		}
-		return a.TrackUsage(ctx, sessionID, a.Model(), event.Response.Usage)
+		return a.TrackUsage(ctx, sessionID, assistantMsg.ID, a.active(sessionID).provider.Model(), event.Response.Usage, time.Since(start))
 	}
 
 	return nil
 }
 
-func (a *agent) TrackUsage(ctx context.Context, sessionID string, model catwalk.Model, usage provider.TokenUsage) error {
+// TrackUsage records the call that answered with the message in the usage
+// ledger and adds its cost to the session.
+func (a *agent) TrackUsage(ctx context.Context, sessionID, messageID string, model catwalk.Model, tokens provider.TokenUsage, latency time.Duration) error {
+	kind := usage.KindAgent
+	if a.agentCfg.ID == "task" {
+		kind = usage.KindTask
+	}
+	cost := a.recordUsage(ctx, kind, sessionID, messageID, a.active(sessionID).providerID, model, tokens, latency)
+
 	sess, err := a.sessions.Get(ctx, sessionID)
 	if err != nil {
 		return fmt.Errorf("failed to get session: %w", err)
 	}
-
-	cost := model.CostPer1MInCached/1e6*float64(usage.CacheCreationTokens) +
-		model.CostPer1MOutCached/1e6*float64(usage.CacheReadTokens) +
-		model.CostPer1MIn/1e6*float64(usage.InputTokens) +
-		model.CostPer1MOut/1e6*float64(usage.OutputTokens)
-
 	sess.Cost += cost
-	sess.CompletionTokens = usage.OutputTokens + usage.CacheReadTokens
-	sess.PromptTokens = usage.InputTokens + usage.CacheCreationTokens
+	sess.CompletionTokens = tokens.OutputTokens + tokens.CacheReadTokens
+	sess.PromptTokens = tokens.InputTokens + tokens.CacheCreationTokens
+	sess.TotalTokens += sess.PromptTokens + sess.CompletionTokens
 
 	_, err = a.sessions.Save(ctx, sess)
 	if err != nil {
 		return fmt.Errorf("failed to save session: %w", err)
 	}
+	// The next request starts with this one and its response, which saves
+	// counting them.
+	a.publishTokens(sessionID, sess.PromptTokens+sess.CompletionTokens)
 	return nil
 }
 
@@ -695,6 +1088,43 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 		}
 
 		a.Publish(pubsub.CreatedEvent, event)
+
+		oldSession, err := a.sessions.Get(summarizeCtx, sessionID)
+		if err != nil {
+			event = AgentEvent{
+				Type:  AgentEventTypeError,
+				Error: fmt.Errorf("failed to get session: %w", err),
+				Done:  true,
+			}
+
+			a.Publish(pubsub.CreatedEvent, event)
+			return
+		}
+
+		opts := config.Get().Options.Summarize
+		if opts.Strategy == config.SummarizeStrategyDropToolResults && !oldSession.ToolResultsDropped {
+			// Dropping old tool results comes first, the conversation is only
+			// summarized when that was not enough.
+			oldSession.ToolResultsDropped = true
+			if _, err := a.sessions.Save(summarizeCtx, oldSession); err != nil {
+				event = AgentEvent{
+					Type:  AgentEventTypeError,
+					Error: fmt.Errorf("failed to save session: %w", err),
+					Done:  true,
+				}
+				a.Publish(pubsub.CreatedEvent, event)
+				return
+			}
+			event = AgentEvent{
+				Type:      AgentEventTypeSummarize,
+				SessionID: sessionID,
+				Progress:  "Dropped old tool results",
+				Done:      true,
+			}
+			a.Publish(pubsub.CreatedEvent, event)
+			return
+		}
+
 		// Get all messages from the session
 		msgs, err := a.messages.List(summarizeCtx, sessionID)
 		if err != nil {
@@ -708,6 +1138,15 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 		}
 		summarizeCtx = context.WithValue(summarizeCtx, tools.SessionIDContextKey, sessionID)
 
+		msgs = summarizedHistory(oldSession.SummaryMessageID, msgs)
+		var keepFromMessageID string
+		if opts.Strategy == config.SummarizeStrategyKeepRecent {
+			if start := recentTurnsStart(msgs, opts.KeepTurns); start > 0 {
+				keepFromMessageID = msgs[start].ID
+				msgs = msgs[:start:start]
+			}
+		}
+
 		if len(msgs) == 0 {
 			event = AgentEvent{
 				Type:  AgentEventTypeError,
@@ -744,6 +1183,7 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 		a.Publish(pubsub.CreatedEvent, event)
 
 		// Send the messages to the summarize provider
+		start := time.Now()
 		response := a.summarizeProvider.StreamResponse(
 			summarizeCtx,
 			msgsWithPrompt,
@@ -781,22 +1221,12 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 		}
 
 		a.Publish(pubsub.CreatedEvent, event)
-		oldSession, err := a.sessions.Get(summarizeCtx, sessionID)
-		if err != nil {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to get session: %w", err),
-				Done:  true,
-			}
-
-			a.Publish(pubsub.CreatedEvent, event)
-			return
-		}
 		// Create a message in the new session with the summary
 		msg, err := a.messages.Create(summarizeCtx, oldSession.ID, message.CreateMessageParams{
 			Role: message.Assistant,
 			Parts: []message.ContentPart{
 				message.TextContent{Text: summary},
+				message.Summary{KeepFromMessageID: keepFromMessageID},
 				message.Finish{
 					Reason: message.FinishReasonEndTurn,
 					Time:   time.Now().Unix(),
@@ -816,15 +1246,10 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 			return
 		}
 		oldSession.SummaryMessageID = msg.ID
+		oldSession.ToolResultsDropped = false
 		oldSession.CompletionTokens = finalResponse.Usage.OutputTokens
 		oldSession.PromptTokens = 0
-		model := a.summarizeProvider.Model()
-		usage := finalResponse.Usage
-		cost := model.CostPer1MInCached/1e6*float64(usage.CacheCreationTokens) +
-			model.CostPer1MOutCached/1e6*float64(usage.CacheReadTokens) +
-			model.CostPer1MIn/1e6*float64(usage.InputTokens) +
-			model.CostPer1MOut/1e6*float64(usage.OutputTokens)
-		oldSession.Cost += cost
+		oldSession.Cost += a.recordUsage(summarizeCtx, usage.KindSummarize, oldSession.ID, msg.ID, a.summarizeProviderID, a.summarizeProvider.Model(), finalResponse.Usage, time.Since(start))
 		_, err = a.sessions.Save(summarizeCtx, oldSession)
 		if err != nil {
 			event = AgentEvent{
@@ -867,6 +1292,13 @@ func (a *agent) CancelAll() {
 	}
 }
 
+// Shutdown cancels the requests of the agent and closes the subscriptions to
+// its events, for agents that are replaced.
+func (a *agent) Shutdown() {
+	a.CancelAll()
+	a.Broker.Shutdown()
+}
+
 func (a *agent) UpdateModel() error {
 	cfg := config.Get()
 
@@ -876,22 +1308,25 @@ func (a *agent) UpdateModel() error {
 		return fmt.Errorf("provider for agent %s not found in config", a.agentCfg.Name)
 	}
 
+	a.primaryMu.Lock()
+	defer a.primaryMu.Unlock()
+
 	// Check if provider has changed
-	if string(currentProviderCfg.ID) != a.providerID {
+	if string(currentProviderCfg.ID) != a.primary.providerID {
 		// Provider changed, need to recreate the main provider
 		model := cfg.GetModelByType(a.agentCfg.Model)
 		if model.ID == "" {
 			return fmt.Errorf("model not found for agent %s", a.agentCfg.Name)
 		}
 
-		promptID := agentPromptMap[a.agentCfg.ID]
-		if promptID == "" {
-			promptID = prompt.PromptDefault
+		systemPrompt, err := agentSystemPrompt(a.agentCfg, currentProviderCfg.ID)
+		if err != nil {
+			return fmt.Errorf("failed to load prompt for agent %s: %w", a.agentCfg.Name, err)
 		}
 
 		opts := []provider.ProviderClientOption{
 			provider.WithModel(a.agentCfg.Model),
-			provider.WithSystemMessage(prompt.GetPrompt(promptID, currentProviderCfg.ID, cfg.Options.ContextPaths...)),
+			provider.WithSystemMessage(systemPrompt),
 		}
 
 		newProvider, err := provider.NewProvider(*currentProviderCfg, opts...)
@@ -900,11 +1335,10 @@ func (a *agent) UpdateModel() error {
 		}
 
 		// Update the provider and provider ID
-		a.provider = newProvider
-		a.providerID = string(currentProviderCfg.ID)
+		a.primary = activeModel{provider: newProvider, providerID: string(currentProviderCfg.ID)}
 	}
 
-	// Check if small model provider has changed (affects title and summarize providers)
+	// Check if small model provider has changed (affects the title provider)
 	smallModelCfg := cfg.Models[config.SelectedModelTypeSmall]
 	var smallModelProviderCfg config.ProviderConfig
 
@@ -919,8 +1353,8 @@ func (a *agent) UpdateModel() error {
 		return fmt.Errorf("provider %s not found in config", smallModelCfg.Provider)
 	}
 
-	// Check if summarize provider has changed
-	if string(smallModelProviderCfg.ID) != a.summarizeProviderID {
+	// Check if title provider has changed
+	if string(smallModelProviderCfg.ID) != a.titleProviderID {
 		smallModel := cfg.GetModelByType(config.SelectedModelTypeSmall)
 		if smallModel == nil {
 			return fmt.Errorf("model %s not found in provider %s", smallModelCfg.Model, smallModelProviderCfg.ID)
@@ -938,20 +1372,20 @@ func (a *agent) UpdateModel() error {
 			return fmt.Errorf("failed to create new title provider: %w", err)
 		}
 
-		// Recreate summarize provider
-		summarizeOpts := []provider.ProviderClientOption{
-			provider.WithModel(config.SelectedModelTypeSmall),
-			provider.WithSystemMessage(prompt.GetPrompt(prompt.PromptSummarizer, smallModelProviderCfg.ID)),
-		}
-		newSummarizeProvider, err := provider.NewProvider(smallModelProviderCfg, summarizeOpts...)
+		a.titleProvider = newTitleProvider
+		a.titleProviderID = string(smallModelProviderCfg.ID)
+	}
+
+	// Check if summarize provider has changed, it follows the small model
+	// unless a summarize model is configured
+	summarizeProviderCfg := cfg.GetProviderForModel(summarizeModelType(cfg))
+	if summarizeProviderCfg != nil && string(summarizeProviderCfg.ID) != a.summarizeProviderID {
+		summarizeProvider, summarizeProviderID, err := newSummarizeProvider(cfg)
 		if err != nil {
 			return fmt.Errorf("failed to create new summarize provider: %w", err)
 		}
-
-		// Update the providers and provider ID
-		a.titleProvider = newTitleProvider
-		a.summarizeProvider = newSummarizeProvider
-		a.summarizeProviderID = string(smallModelProviderCfg.ID)
+		a.summarizeProvider = summarizeProvider
+		a.summarizeProviderID = summarizeProviderID
 	}
 
 	return nil
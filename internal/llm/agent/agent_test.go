@@ -0,0 +1,398 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/hooks"
+	"github.com/charmbracelet/crush/internal/llm/provider"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+type sleepTool struct {
+	name    string
+	delay   time.Duration
+	running atomic.Int32
+	peak    atomic.Int32
+}
+
+func (t *sleepTool) Name() string { return t.name }
+
+func (t *sleepTool) Info() tools.ToolInfo { return tools.ToolInfo{Name: t.name} }
+
+func (t *sleepTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolResponse, error) {
+	n := t.running.Add(1)
+	defer t.running.Add(-1)
+	for {
+		peak := t.peak.Load()
+		if n <= peak || t.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	select {
+	case <-time.After(t.delay):
+	case <-ctx.Done():
+		return tools.ToolResponse{}, ctx.Err()
+	}
+	return tools.NewTextResponse(call.Input), nil
+}
+
+// deniedTool is a tool the user never allows to run.
+type deniedTool struct{}
+
+func (deniedTool) Name() string { return tools.ViewToolName }
+
+func (deniedTool) Info() tools.ToolInfo { return tools.ToolInfo{Name: tools.ViewToolName} }
+
+func (deniedTool) Run(context.Context, tools.ToolCall) (tools.ToolResponse, error) {
+	return tools.ToolResponse{}, permission.ErrorPermissionDenied
+}
+
+func newTestAgent(ts ...tools.BaseTool) *agent {
+	return &agent{
+		tools: csync.NewLazySlice(func() []tools.BaseTool { return ts }),
+		hooks: hooks.NewRunner(nil, ""),
+	}
+}
+
+func TestRunTools(t *testing.T) {
+	t.Parallel()
+
+	t.Run("results keep call order", func(t *testing.T) {
+		t.Parallel()
+		tool := &sleepTool{name: tools.ViewToolName, delay: 20 * time.Millisecond}
+		a := newTestAgent(tool)
+
+		calls := []message.ToolCall{
+			{ID: "1", Name: tools.ViewToolName, Input: "a"},
+			{ID: "2", Name: "missing", Input: "b"},
+			{ID: "3", Name: tools.ViewToolName, Input: "c"},
+			{ID: "4", Name: tools.ViewToolName, Input: "d"},
+		}
+		results, err := a.runTools(t.Context(), slices.Collect(a.tools.Seq()), calls, 2)
+		require.NoError(t, err)
+		require.Len(t, results, 4)
+		require.Equal(t, "a", results[0].response.Content)
+		require.True(t, results[1].response.IsError)
+		require.Equal(t, "Tool not found: missing", results[1].response.Content)
+		require.Equal(t, "c", results[2].response.Content)
+		require.Equal(t, "d", results[3].response.Content)
+		require.LessOrEqual(t, tool.peak.Load(), int32(2))
+	})
+
+	t.Run("runs concurrently up to the limit", func(t *testing.T) {
+		t.Parallel()
+		tool := &sleepTool{name: tools.GrepToolName, delay: 50 * time.Millisecond}
+		a := newTestAgent(tool)
+
+		calls := make([]message.ToolCall, 3)
+		for i := range calls {
+			calls[i] = message.ToolCall{ID: string(rune('a' + i)), Name: tools.GrepToolName}
+		}
+		_, err := a.runTools(t.Context(), slices.Collect(a.tools.Seq()), calls, 3)
+		require.NoError(t, err)
+		require.Equal(t, int32(3), tool.peak.Load())
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		t.Parallel()
+		tool := &sleepTool{name: tools.GlobToolName, delay: time.Minute}
+		a := newTestAgent(tool)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		_, err := a.runTools(ctx, slices.Collect(a.tools.Seq()), []message.ToolCall{{ID: "1", Name: tools.GlobToolName}}, 1)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestIsReadOnlyTool(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, isReadOnlyTool(tools.ViewToolName))
+	require.True(t, isReadOnlyTool(AgentToolName))
+	require.True(t, isReadOnlyTool(tools.ReferencesToolName))
+	require.False(t, isReadOnlyTool(tools.BashToolName))
+	require.False(t, isReadOnlyTool(tools.JobKillToolName))
+	require.False(t, isReadOnlyTool(tools.EditToolName))
+	require.False(t, isReadOnlyTool("mcp_server_tool"))
+}
+
+// stubProvider answers requests with err, or with response when err is nil,
+// a greeting by default.
+type stubProvider struct {
+	err      error
+	response *provider.ProviderResponse
+}
+
+func (p *stubProvider) SendMessages(context.Context, []message.Message, []tools.BaseTool) (*provider.ProviderResponse, error) {
+	return nil, errors.New("not supported")
+}
+
+func (p *stubProvider) StreamResponse(context.Context, []message.Message, []tools.BaseTool) <-chan provider.ProviderEvent {
+	eventChan := make(chan provider.ProviderEvent, 2)
+	if p.err != nil {
+		eventChan <- provider.ProviderEvent{Type: provider.EventError, Error: p.err}
+	} else {
+		response := p.response
+		if response == nil {
+			response = &provider.ProviderResponse{Content: "Hello!", FinishReason: message.FinishReasonEndTurn}
+		}
+		eventChan <- provider.ProviderEvent{Type: provider.EventContentDelta, Content: response.Content}
+		eventChan <- provider.ProviderEvent{Type: provider.EventComplete, Response: response}
+	}
+	close(eventChan)
+	return eventChan
+}
+
+func (p *stubProvider) CountTokens(context.Context, []message.Message, []tools.BaseTool) (int64, error) {
+	return 0, nil
+}
+
+func (p *stubProvider) Model() catwalk.Model {
+	return catwalk.Model{ID: "test-model"}
+}
+
+// newRunTestAgent returns an agent that can run prompts, configured with a
+// replay provider whose model falls back to itself.
+func newRunTestAgent(t *testing.T) *agent {
+	dir := t.TempDir()
+	catwalkServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	}))
+	t.Cleanup(catwalkServer.Close)
+	t.Setenv("CATWALK_URL", catwalkServer.URL)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("XDG_DATA_HOME", filepath.Join(dir, "data"))
+
+	// The model falls back to itself, so that its second recorded answer is
+	// played once the first one fails.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "crush.json"), []byte(`{
+  "providers": {
+    "replay": {
+      "type": "replay",
+      "replay": {"cassette": "cassette.json"},
+      "models": [{"id": "test-model", "context_window": 100000, "default_max_tokens": 1000}]
+    }
+  },
+  "models": {
+    "large": {"provider": "replay", "model": "test-model", "fallbacks": [{"provider": "replay", "model": "test-model"}]},
+    "small": {"provider": "replay", "model": "test-model"}
+  }
+}`), 0o644))
+	_, err := config.Init(dir, false)
+	require.NoError(t, err)
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+
+	a := newTestAgent()
+	a.Broker = pubsub.NewBroker[AgentEvent]()
+	a.agentCfg = config.Agent{ID: "coder", Model: config.SelectedModelTypeLarge}
+	a.sessions = session.NewService(q)
+	a.messages = message.NewService(q)
+	a.fallbackModels = csync.NewMap[string, activeModel]()
+	a.activeRequests = csync.NewMap[string, context.CancelFunc]()
+	a.queue = newPromptQueue()
+	a.planMode = csync.NewMap[string, bool]()
+	a.plans = csync.NewMap[string, Plan]()
+	return a
+}
+
+func TestRunReplay(t *testing.T) {
+	a := newRunTestAgent(t)
+	dir := config.Get().WorkingDir()
+
+	run := func(p provider.Provider) AgentEvent {
+		a.primary = activeModel{provider: p, providerID: "replay"}
+		sess, err := a.sessions.Create(t.Context(), "replay")
+		require.NoError(t, err)
+		events, err := a.Run(t.Context(), sess.ID, "Hi")
+		require.NoError(t, err)
+		return <-events
+	}
+
+	// Record a request that ran out of retries, then its successful retry.
+	recording := filepath.Join(dir, "recording.json")
+	stub := &stubProvider{err: fmt.Errorf("%w after 3 attempts: upstream unavailable", provider.ErrMaxRetries)}
+	recorder, err := provider.NewRecordingProvider(stub, recording)
+	require.NoError(t, err)
+	result := run(recorder)
+	require.ErrorIs(t, result.Error, provider.ErrMaxRetries)
+	stub.err = nil
+	result = run(recorder)
+	require.NoError(t, result.Error)
+
+	data, err := os.ReadFile(recording)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cassette.json"), data, 0o644))
+
+	// The replayed failure is still one the agent switches models for.
+	providerCfg, ok := config.Get().Providers.Get("replay")
+	require.True(t, ok)
+	replayer, err := provider.NewProvider(providerCfg, provider.WithModel(config.SelectedModelTypeLarge))
+	require.NoError(t, err)
+	result = run(replayer)
+	require.NoError(t, result.Error)
+	require.Equal(t, "Hello!", result.Message.Content().Text)
+}
+
+func TestRunAgentStopHook(t *testing.T) {
+	a := newRunTestAgent(t)
+	dir := config.Get().WorkingDir()
+	a.hooks = hooks.NewRunner(&config.Hooks{
+		AgentStop: []config.Hook{{Command: "echo $CRUSH_SESSION_ID > stopped"}},
+	}, dir)
+	a.primary = activeModel{provider: &stubProvider{}, providerID: "replay"}
+	sess, err := a.sessions.Create(t.Context(), "stop")
+	require.NoError(t, err)
+
+	// The hooks of a canceled run still run, once the session is free.
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	events, err := a.Run(ctx, sess.ID, "Hi")
+	require.NoError(t, err)
+	result := <-events
+	require.ErrorIs(t, result.Error, context.Canceled)
+	require.False(t, a.IsSessionBusy(sess.ID))
+
+	stopped, err := os.ReadFile(filepath.Join(dir, "stopped"))
+	require.NoError(t, err)
+	require.Equal(t, sess.ID+"\n", string(stopped))
+}
+
+func TestPermissionDeniedToolCalls(t *testing.T) {
+	a := newRunTestAgent(t)
+	grep := &sleepTool{name: tools.GrepToolName, delay: 10 * time.Millisecond}
+	bash := &sleepTool{name: tools.BashToolName}
+	a.tools = csync.NewLazySlice(func() []tools.BaseTool { return []tools.BaseTool{deniedTool{}, grep, bash} })
+	a.primary = activeModel{provider: &stubProvider{response: &provider.ProviderResponse{
+		ToolCalls: []message.ToolCall{
+			{ID: "1", Name: tools.ViewToolName, Input: "a", Finished: true},
+			{ID: "2", Name: tools.GrepToolName, Input: "b", Finished: true},
+			{ID: "3", Name: tools.BashToolName, Input: "c", Finished: true},
+		},
+		FinishReason: message.FinishReasonToolUse,
+	}}, providerID: "replay"}
+	sess, err := a.sessions.Create(t.Context(), "denied")
+	require.NoError(t, err)
+
+	assistantMsg, toolResults, err := a.streamAndHandleEvents(t.Context(), sess.ID, []message.Message{{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "Hi"}},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, message.FinishReasonPermissionDenied, assistantMsg.FinishReason())
+
+	// The grep call ran along with the denied one, the bash call never ran.
+	results := toolResults.ToolResults()
+	require.Len(t, results, 3)
+	require.Equal(t, "Permission denied", results[0].Content)
+	require.Equal(t, "b", results[1].Content)
+	require.False(t, results[1].IsError)
+	require.Equal(t, "Tool execution canceled by user", results[2].Content)
+	require.Zero(t, bash.peak.Load())
+}
+
+func TestShutdown(t *testing.T) {
+	a := newRunTestAgent(t)
+	tool := &sleepTool{name: tools.GrepToolName, delay: time.Minute}
+	a.tools = csync.NewLazySlice(func() []tools.BaseTool { return []tools.BaseTool{tool} })
+	a.primary = activeModel{provider: &stubProvider{response: &provider.ProviderResponse{
+		ToolCalls:    []message.ToolCall{{ID: "1", Name: tools.GrepToolName, Finished: true}},
+		FinishReason: message.FinishReasonToolUse,
+	}}, providerID: "replay"}
+	sess, err := a.sessions.Create(t.Context(), "shutdown")
+	require.NoError(t, err)
+
+	agentEvents := a.Subscribe(t.Context())
+	events, err := a.Run(t.Context(), sess.ID, "Hi")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return tool.running.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	a.Shutdown()
+	require.False(t, a.IsBusy())
+	result := <-events
+	require.Equal(t, message.FinishReasonCanceled, result.Message.FinishReason())
+	for range agentEvents {
+	}
+}
+
+func TestSummarizeDropsToolResults(t *testing.T) {
+	a := newRunTestAgent(t)
+	a.summarizeProvider = &stubProvider{}
+	config.Get().Options.Summarize.Strategy = config.SummarizeStrategyDropToolResults
+	sess, err := a.sessions.Create(t.Context(), "drop")
+	require.NoError(t, err)
+
+	agentEvents := a.Subscribe(t.Context())
+	require.NoError(t, a.Summarize(t.Context(), sess.ID))
+	for event := range agentEvents {
+		if event.Payload.Done {
+			require.NoError(t, event.Payload.Error)
+			require.Equal(t, "Dropped old tool results", event.Payload.Progress)
+			break
+		}
+	}
+
+	// The session keeps leaving the results out after a restart.
+	sess, err = a.sessions.Get(t.Context(), sess.ID)
+	require.NoError(t, err)
+	require.True(t, sess.ToolResultsDropped)
+	msgs := []message.Message{
+		testMessage("u1", message.User),
+		testMessage("t1", message.Tool, message.ToolResult{ToolCallID: "1", Content: "files"}),
+		testMessage("u2", message.User),
+		testMessage("u3", message.User),
+		testMessage("u4", message.User),
+	}
+	history := (&agent{}).sessionHistory(sess, msgs)
+	require.Equal(t, droppedToolResult, history[1].ToolResults()[0].Content)
+}
+
+func TestQueueRunsIdleSession(t *testing.T) {
+	a := newRunTestAgent(t)
+	a.primary = activeModel{provider: &stubProvider{}, providerID: "replay"}
+	sess, err := a.sessions.Create(t.Context(), "queue")
+	require.NoError(t, err)
+
+	agentEvents := a.Subscribe(t.Context())
+	a.Queue(sess.ID, "Hi")
+	var result AgentEvent
+	for event := range agentEvents {
+		if event.Payload.Type == AgentEventTypeResponse {
+			result = event.Payload
+			break
+		}
+	}
+	require.NoError(t, result.Error)
+	require.Equal(t, "Hello!", result.Message.Content().Text)
+
+	// Nobody reads the events of the queued run, its goroutine ends anyway.
+	require.Eventually(t, func() bool {
+		buf := make([]byte, 1<<20)
+		return !strings.Contains(string(buf[:runtime.Stack(buf, true)]), "(*agent).Run.func")
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// budgetTracker keeps track of how much of the configured budget a single run
+// of the agent has used.
+type budgetTracker struct {
+	budget *config.Budget
+	turns  int
+	tokens int64
+	cost   float64
+
+	startCost float64
+}
+
+func newBudgetTracker(budget *config.Budget, sess session.Session) *budgetTracker {
+	return &budgetTracker{
+		budget:    budget,
+		startCost: sess.Cost,
+	}
+}
+
+// track records a finished agent iteration, given the session as it is after
+// the iteration's usage has been saved.
+func (b *budgetTracker) track(sess session.Session) {
+	b.turns++
+	b.tokens += sess.PromptTokens + sess.CompletionTokens
+	b.cost = sess.Cost - b.startCost
+}
+
+// exceeded reports which limit, if any, the run has reached. It returns an
+// empty string while the agent may keep going.
+func (b *budgetTracker) exceeded(sess session.Session) string {
+	if b.budget.IsZero() {
+		return ""
+	}
+	if b.budget.MaxTurns > 0 && b.turns >= b.budget.MaxTurns {
+		return fmt.Sprintf("Reached the limit of %d turns", b.budget.MaxTurns)
+	}
+
+	tokens, cost := sess.TotalTokens, sess.Cost
+	if b.budget.Scope == config.BudgetScopeRun {
+		tokens, cost = b.tokens, b.cost
+	}
+	if b.budget.MaxTokens > 0 && tokens >= b.budget.MaxTokens {
+		return fmt.Sprintf("Used %d tokens, the limit is %d", tokens, b.budget.MaxTokens)
+	}
+	if b.budget.MaxCost > 0 && cost >= b.budget.MaxCost {
+		return fmt.Sprintf("Spent $%.2f, the limit is $%.2f", cost, b.budget.MaxCost)
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudgetTracker(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no budget", func(t *testing.T) {
+		t.Parallel()
+		b := newBudgetTracker(nil, session.Session{})
+		b.track(session.Session{PromptTokens: 1e9, Cost: 1e9})
+		require.Empty(t, b.exceeded(session.Session{PromptTokens: 1e9, Cost: 1e9}))
+	})
+
+	t.Run("max turns", func(t *testing.T) {
+		t.Parallel()
+		b := newBudgetTracker(&config.Budget{MaxTurns: 2}, session.Session{})
+		b.track(session.Session{})
+		require.Empty(t, b.exceeded(session.Session{}))
+		b.track(session.Session{})
+		require.Contains(t, b.exceeded(session.Session{}), "2 turns")
+	})
+
+	t.Run("session cost", func(t *testing.T) {
+		t.Parallel()
+		sess := session.Session{Cost: 0.9}
+		b := newBudgetTracker(&config.Budget{MaxCost: 1}, sess)
+		require.Empty(t, b.exceeded(sess))
+		sess.Cost = 1.2
+		b.track(sess)
+		require.Contains(t, b.exceeded(sess), "$1.20")
+	})
+
+	t.Run("session tokens add up across requests", func(t *testing.T) {
+		t.Parallel()
+		sess := session.Session{PromptTokens: 400, TotalTokens: 700}
+		b := newBudgetTracker(&config.Budget{MaxTokens: 1000}, sess)
+		require.Empty(t, b.exceeded(sess))
+
+		// The last request alone is under the limit, the session is not.
+		sess.PromptTokens = 350
+		sess.TotalTokens += 350
+		b.track(sess)
+		require.Contains(t, b.exceeded(sess), "1050 tokens")
+	})
+
+	t.Run("run scope ignores earlier usage", func(t *testing.T) {
+		t.Parallel()
+		sess := session.Session{Cost: 5, PromptTokens: 900}
+		b := newBudgetTracker(&config.Budget{MaxCost: 1, MaxTokens: 1000, Scope: config.BudgetScopeRun}, sess)
+		require.Empty(t, b.exceeded(sess))
+
+		sess.Cost = 5.5
+		sess.PromptTokens = 600
+		b.track(sess)
+		require.Empty(t, b.exceeded(sess))
+
+		sess.Cost = 5.8
+		sess.PromptTokens = 700
+		b.track(sess)
+		require.Contains(t, b.exceeded(sess), "1300 tokens")
+	})
+}
@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/provider"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/pubsub"
+)
+
+// contextLimit returns the number of input tokens a request of the session
+// can take: the context window of its model, less the room left for the
+// response. It returns 0 when the context window is unknown.
+func (a *agent) contextLimit(sessionID string) int64 {
+	active := a.active(sessionID)
+	model := active.provider.Model()
+	if model.ContextWindow <= 0 {
+		return 0
+	}
+	maxTokens := model.DefaultMaxTokens
+	if configured := config.Get().Models[a.agentCfg.Model].MaxTokens; configured > 0 && active.fallbacks == 0 {
+		maxTokens = configured
+	}
+	return model.ContextWindow - maxTokens
+}
+
+// fitContext makes sure a request with the history fits in the context
+// window, instead of letting the provider reject it. The results of old tool
+// calls are left out first, then the oldest turns, keeping the summary of the
+// conversation. The session's stored history is left as it is.
+//
+// Counting tokens may take a request to the provider, so the request is
+// counted once, what is left out is estimated, and the trimmed request is
+// counted once more.
+func (a *agent) fitContext(ctx context.Context, sessionID string, msgs []message.Message, availableTools []tools.BaseTool) ([]message.Message, error) {
+	counter := a.active(sessionID).provider
+	tokens, err := counter.CountTokens(ctx, msgs, availableTools)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tokens: %w", err)
+	}
+	limit := a.contextLimit(sessionID)
+	if limit <= 0 || tokens <= limit {
+		a.publishTokens(sessionID, tokens)
+		return msgs, nil
+	}
+
+	trimmed := dropToolResults(msgs, config.Get().Options.Summarize.KeepTurns)
+	trimmed, _, err = trimToFit(msgs, trimmed, tokens, limit)
+	if err != nil {
+		return nil, err
+	}
+	counted, err := counter.CountTokens(ctx, trimmed, availableTools)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tokens: %w", err)
+	}
+	if counted > limit {
+		if trimmed, counted, err = trimToFit(trimmed, trimmed, counted, limit); err != nil {
+			return nil, err
+		}
+	}
+
+	slog.Info("Request exceeded the context window, trimmed the history", "session_id", sessionID, "tokens", tokens, "limit", limit, "messages", len(msgs), "kept", len(trimmed))
+	a.Publish(pubsub.CreatedEvent, AgentEvent{
+		Type:      AgentEventTypeContextTrimmed,
+		SessionID: sessionID,
+		Tokens:    counted,
+	})
+	a.publishTokens(sessionID, counted)
+	return trimmed, nil
+}
+
+// trimToFit leaves the oldest turns out of trimmed, a trimmed copy of msgs,
+// until the request is estimated to fit in the limit, given that it takes
+// tokens with msgs. It returns the estimated tokens of the request.
+func trimToFit(msgs, trimmed []message.Message, tokens, limit int64) ([]message.Message, int64, error) {
+	// What was left out is estimated on the low side, so that the request
+	// isn't trimmed more than needed.
+	overhead := tokens - provider.EstimateTokens(msgs)
+	estimate := overhead + provider.EstimateTokens(trimmed)
+	for estimate > limit {
+		var ok bool
+		if trimmed, ok = dropOldestTurn(trimmed); !ok {
+			return nil, 0, fmt.Errorf("%w: the request takes about %d tokens, the model accepts %d", ErrContextWindowExceeded, estimate, limit)
+		}
+		estimate = overhead + provider.EstimateTokens(trimmed)
+	}
+	return trimmed, estimate, nil
+}
+
+// dropOldestTurn returns msgs without their oldest turn, keeping the summary
+// they start with. It reports false when only one turn is left.
+func dropOldestTurn(msgs []message.Message) ([]message.Message, bool) {
+	keep := 0
+	if len(msgs) > 0 && msgs[0].SummaryPart() != nil {
+		keep = 1
+	}
+	for i := keep + 1; i < len(msgs); i++ {
+		if msgs[i].Role == message.User {
+			return append(slices.Clone(msgs[:keep]), msgs[i:]...), true
+		}
+	}
+	return msgs, false
+}
+
+// EstimateTokens returns the number of input tokens the next request of the
+// session would take, without a new prompt.
+func (a *agent) EstimateTokens(ctx context.Context, sessionID string) (int64, error) {
+	sess, err := a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get session: %w", err)
+	}
+	msgs, err := a.messages.List(ctx, sessionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list messages: %w", err)
+	}
+	msgs = a.sessionHistory(sess, msgs)
+	if a.IsPlanMode(sessionID) {
+		msgs = withPlanModePrompt(msgs)
+	}
+	return a.active(sessionID).provider.CountTokens(ctx, msgs, a.sessionTools(sessionID))
+}
+
+func (a *agent) publishTokens(sessionID string, tokens int64) {
+	a.Publish(pubsub.CreatedEvent, AgentEvent{
+		Type:      AgentEventTypeTokens,
+		SessionID: sessionID,
+		Tokens:    tokens,
+	})
+}
@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDropOldestTurn(t *testing.T) {
+	t.Parallel()
+
+	msgs := []message.Message{
+		testMessage("s1", message.User, message.Summary{}),
+		testMessage("u1", message.User),
+		testMessage("a1", message.Assistant),
+		testMessage("t1", message.Tool),
+		testMessage("a2", message.Assistant),
+		testMessage("u2", message.User),
+		testMessage("a3", message.Assistant),
+	}
+
+	// The summary is kept in front of the remaining turns.
+	trimmed, ok := dropOldestTurn(msgs)
+	require.True(t, ok)
+	require.Equal(t, []string{"s1", "u2", "a3"}, messageIDs(trimmed))
+	require.Len(t, msgs, 7)
+
+	_, ok = dropOldestTurn(trimmed)
+	require.False(t, ok)
+
+	trimmed, ok = dropOldestTurn(msgs[1:])
+	require.True(t, ok)
+	require.Equal(t, []string{"u2", "a3"}, messageIDs(trimmed))
+
+	_, ok = dropOldestTurn(nil)
+	require.False(t, ok)
+}
+
+func TestTrimToFit(t *testing.T) {
+	t.Parallel()
+
+	// Each message takes about 104 tokens.
+	text := message.TextContent{Text: strings.Repeat("a", 400)}
+	msgs := []message.Message{
+		testMessage("u1", message.User, text),
+		testMessage("a1", message.Assistant, text),
+		testMessage("u2", message.User, text),
+		testMessage("a2", message.Assistant, text),
+		testMessage("u3", message.User, text),
+		testMessage("a3", message.Assistant, text),
+	}
+
+	// The request takes 376 tokens on top of the messages.
+	trimmed, tokens, err := trimToFit(msgs, msgs, 1000, 800)
+	require.NoError(t, err)
+	require.Equal(t, []string{"u2", "a2", "u3", "a3"}, messageIDs(trimmed))
+	require.Equal(t, int64(792), tokens)
+
+	trimmed, tokens, err = trimToFit(msgs, msgs, 1000, 700)
+	require.NoError(t, err)
+	require.Equal(t, []string{"u3", "a3"}, messageIDs(trimmed))
+	require.Equal(t, int64(584), tokens)
+
+	// What was already left out counts.
+	trimmed, tokens, err = trimToFit(msgs, msgs[2:], 1000, 800)
+	require.NoError(t, err)
+	require.Equal(t, []string{"u2", "a2", "u3", "a3"}, messageIDs(trimmed))
+	require.Equal(t, int64(792), tokens)
+
+	_, _, err = trimToFit(msgs, msgs, 1000, 500)
+	require.True(t, errors.Is(err, ErrContextWindowExceeded))
+}
@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/provider"
+	"github.com/charmbracelet/crush/internal/pubsub"
+)
+
+// activeModel is a provider answering the requests of the agent.
+type activeModel struct {
+	provider   provider.Provider
+	providerID string
+	// Number of fallback models of the selected model that were switched to,
+	// the last of them being this one. 0 for the selected model.
+	fallbacks int
+}
+
+// active returns the model answering the requests of the session: the
+// fallback model its run switched to, or the selected model.
+func (a *agent) active(sessionID string) activeModel {
+	if fallback, ok := a.fallbackModels.Get(sessionID); ok {
+		return fallback
+	}
+	a.primaryMu.RLock()
+	defer a.primaryMu.RUnlock()
+	return a.primary
+}
+
+// switchToFallback makes the session use the next fallback model of the
+// selected model, skipping the ones whose provider cannot be created. It
+// reports false when no fallback model is left. The switch lasts until the
+// run of the session ends.
+func (a *agent) switchToFallback(sessionID string, reason error) (config.SelectedModel, bool) {
+	fallbacks := config.Get().Models[a.agentCfg.Model].Fallbacks
+	for next := a.active(sessionID).fallbacks; next < len(fallbacks); next++ {
+		fallback := fallbacks[next]
+		fallbackProvider, err := a.newFallbackProvider(fallback)
+		if err != nil {
+			slog.Warn("Failed to create fallback provider", "provider", fallback.Provider, "model", fallback.Model, "error", err)
+			continue
+		}
+		a.fallbackModels.Set(sessionID, activeModel{
+			provider:   fallbackProvider,
+			providerID: fallback.Provider,
+			fallbacks:  next + 1,
+		})
+		a.Publish(pubsub.CreatedEvent, AgentEvent{
+			Type:      AgentEventTypeFallback,
+			SessionID: sessionID,
+			Error:     reason,
+			Fallback:  fallback,
+		})
+		return fallback, true
+	}
+	return config.SelectedModel{}, false
+}
+
+func (a *agent) newFallbackProvider(fallback config.SelectedModel) (provider.Provider, error) {
+	providerCfg, ok := config.Get().Providers.Get(fallback.Provider)
+	if !ok {
+		return nil, fmt.Errorf("provider %s not found in config", fallback.Provider)
+	}
+	// The system prompt depends on the provider.
+	systemPrompt, err := agentSystemPrompt(a.agentCfg, providerCfg.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompt for agent %s: %w", a.agentCfg.Name, err)
+	}
+	return provider.NewProvider(
+		providerCfg,
+		provider.WithModel(a.agentCfg.Model),
+		provider.WithSelectedModel(fallback),
+		provider.WithSystemMessage(systemPrompt),
+	)
+}
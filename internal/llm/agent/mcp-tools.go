@@ -142,6 +142,20 @@ var mcpInitRequest = mcp.InitializeRequest{
 	},
 }
 
+// mcpToolAllowed reports whether the agent may use the given MCP tool. A nil
+// AllowedMCP allows every server, and a nil tool list allows every tool of
+// that server.
+func mcpToolAllowed(agentCfg config.Agent, tool *McpTool) bool {
+	if agentCfg.AllowedMCP == nil {
+		return true
+	}
+	allowed, ok := agentCfg.AllowedMCP[tool.mcpName]
+	if !ok {
+		return false
+	}
+	return allowed == nil || slices.Contains(allowed, tool.tool.Name)
+}
+
 func doGetMCPTools(ctx context.Context, permissions permission.Service, cfg *config.Config) []tools.BaseTool {
 	var wg sync.WaitGroup
 	result := csync.NewSlice[tools.BaseTool]()
@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/llm/prompt"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+const PlanToolName = "submit_plan"
+
+// planModeTools are the tools available while planning. None of them change
+// files or run commands.
+var planModeTools = []string{
+	AgentToolName,
+	PlanToolName,
+	tools.DiagnosticsToolName,
+	tools.GlobToolName,
+	tools.GrepToolName,
+	tools.LSToolName,
+	tools.SourcegraphToolName,
+	tools.ViewToolName,
+}
+
+// Plan is the step-by-step plan the agent proposes in plan mode.
+type Plan struct {
+	Summary string   `json:"summary"`
+	Steps   []string `json:"steps"`
+}
+
+// String renders the plan as markdown.
+func (p Plan) String() string {
+	var sb strings.Builder
+	if p.Summary != "" {
+		sb.WriteString(p.Summary)
+		sb.WriteString("\n\n")
+	}
+	for i, step := range p.Steps {
+		fmt.Fprintf(&sb, "%d. %s\n", i+1, step)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+type planTool struct {
+	plans *csync.Map[string, Plan]
+}
+
+func (p *planTool) Name() string {
+	return PlanToolName
+}
+
+func (p *planTool) Info() tools.ToolInfo {
+	return tools.ToolInfo{
+		Name:        PlanToolName,
+		Description: "Submit a step-by-step plan for the user to review. Only available in plan mode. Call it once you understand the task, then stop and wait: nothing is carried out until the user approves the plan.",
+		Parameters: map[string]any{
+			"summary": map[string]any{
+				"type":        "string",
+				"description": "A short description of the approach",
+			},
+			"steps": map[string]any{
+				"type":        "array",
+				"description": "The steps to carry out, in order",
+				"items": map[string]any{
+					"type": "string",
+				},
+			},
+		},
+		Required: []string{"summary", "steps"},
+	}
+}
+
+func (p *planTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolResponse, error) {
+	var plan Plan
+	if err := json.Unmarshal([]byte(call.Input), &plan); err != nil {
+		return tools.NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	plan.Steps = slices.DeleteFunc(plan.Steps, func(step string) bool {
+		return strings.TrimSpace(step) == ""
+	})
+	if len(plan.Steps) == 0 {
+		return tools.NewTextErrorResponse("the plan needs at least one step"), nil
+	}
+
+	sessionID, _ := tools.GetContextValues(ctx)
+	if sessionID == "" {
+		return tools.ToolResponse{}, fmt.Errorf("session_id is required")
+	}
+	p.plans.Set(sessionID, plan)
+	return tools.NewTextResponse("Plan submitted for review. Stop here and wait for the user's decision."), nil
+}
+
+// withPlanModePrompt returns a copy of the history where the last user
+// message carries the plan mode instructions. The stored message is left
+// untouched.
+func withPlanModePrompt(msgHistory []message.Message) []message.Message {
+	idx := -1
+	for i, msg := range slices.Backward(msgHistory) {
+		if msg.Role == message.User {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 {
+		return msgHistory
+	}
+	msgHistory = slices.Clone(msgHistory)
+	msg := msgHistory[idx]
+	msg.Parts = append(slices.Clone(msg.Parts), message.TextContent{Text: prompt.PlanModePrompt()})
+	msgHistory[idx] = msg
+	return msgHistory
+}
+
+// approvedPlanPrompt is sent to the agent once the user approved its plan.
+func approvedPlanPrompt(plan string) string {
+	return "The plan was approved. Carry it out now, step by step:\n\n" + plan
+}
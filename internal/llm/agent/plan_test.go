@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanMode(t *testing.T) {
+	t.Parallel()
+
+	plans := csync.NewMap[string, Plan]()
+	a := newTestAgent(
+		&sleepTool{name: tools.ViewToolName},
+		&sleepTool{name: tools.BashToolName},
+	)
+	a.planMode = csync.NewMap[string, bool]()
+	a.plans = plans
+	a.planTool = &planTool{plans: plans}
+
+	names := func(ts []tools.BaseTool) []string {
+		var names []string
+		for _, tool := range ts {
+			names = append(names, tool.Name())
+		}
+		return names
+	}
+	require.Equal(t, []string{tools.ViewToolName, tools.BashToolName}, names(a.sessionTools("session")))
+
+	a.SetPlanMode("session", true)
+	require.True(t, a.IsPlanMode("session"))
+	require.False(t, a.IsPlanMode("other"))
+	require.Equal(t, []string{PlanToolName, tools.ViewToolName}, names(a.sessionTools("session")))
+
+	a.SetPlanMode("session", false)
+	require.False(t, a.IsPlanMode("session"))
+}
+
+func TestPlanTool(t *testing.T) {
+	t.Parallel()
+
+	plans := csync.NewMap[string, Plan]()
+	tool := &planTool{plans: plans}
+	ctx := context.WithValue(t.Context(), tools.SessionIDContextKey, "session")
+
+	resp, err := tool.Run(ctx, tools.ToolCall{Input: `{"summary":"Fix it","steps":["Edit a.go",""]}`})
+	require.NoError(t, err)
+	require.False(t, resp.IsError)
+
+	plan, ok := plans.Get("session")
+	require.True(t, ok)
+	require.Equal(t, "Fix it\n\n1. Edit a.go", plan.String())
+
+	resp, err = tool.Run(ctx, tools.ToolCall{Input: `{"summary":"Nothing","steps":[]}`})
+	require.NoError(t, err)
+	require.True(t, resp.IsError)
+}
+
+func TestWithPlanModePrompt(t *testing.T) {
+	t.Parallel()
+
+	history := []message.Message{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "first"}}},
+		{Role: message.Assistant, Parts: []message.ContentPart{message.TextContent{Text: "answer"}}},
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "second"}}},
+	}
+	got := withPlanModePrompt(history)
+	require.Len(t, got[2].Parts, 2)
+	require.Len(t, got[0].Parts, 1)
+	// The original history is left untouched.
+	require.Len(t, history[2].Parts, 1)
+}
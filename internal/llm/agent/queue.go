@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+// QueuedPrompt is a prompt submitted while its session was busy.
+type QueuedPrompt struct {
+	Content     string
+	Attachments []message.Attachment
+}
+
+// promptQueue holds the prompts waiting for each session, oldest first.
+type promptQueue struct {
+	mu      sync.Mutex
+	prompts map[string][]QueuedPrompt
+}
+
+func newPromptQueue() *promptQueue {
+	return &promptQueue{prompts: make(map[string][]QueuedPrompt)}
+}
+
+func (q *promptQueue) push(sessionID string, prompt QueuedPrompt) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prompts[sessionID] = append(q.prompts[sessionID], prompt)
+}
+
+// pushFront puts a prompt back at the head of the queue.
+func (q *promptQueue) pushFront(sessionID string, prompt QueuedPrompt) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prompts[sessionID] = slices.Insert(q.prompts[sessionID], 0, prompt)
+}
+
+// pop takes the oldest prompt of the session.
+func (q *promptQueue) pop(sessionID string) (QueuedPrompt, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	prompts := q.prompts[sessionID]
+	if len(prompts) == 0 {
+		return QueuedPrompt{}, false
+	}
+	q.set(sessionID, prompts[1:])
+	return prompts[0], true
+}
+
+// drain takes every prompt of the session.
+func (q *promptQueue) drain(sessionID string) []QueuedPrompt {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	prompts := q.prompts[sessionID]
+	delete(q.prompts, sessionID)
+	return prompts
+}
+
+// remove takes the prompt at the given position.
+func (q *promptQueue) remove(sessionID string, index int) (QueuedPrompt, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	prompts := q.prompts[sessionID]
+	if index < 0 || index >= len(prompts) {
+		return QueuedPrompt{}, false
+	}
+	prompt := prompts[index]
+	q.set(sessionID, slices.Delete(slices.Clone(prompts), index, index+1))
+	return prompt, true
+}
+
+func (q *promptQueue) list(sessionID string) []QueuedPrompt {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.Clone(q.prompts[sessionID])
+}
+
+func (q *promptQueue) set(sessionID string, prompts []QueuedPrompt) {
+	if len(prompts) == 0 {
+		delete(q.prompts, sessionID)
+		return
+	}
+	q.prompts[sessionID] = prompts
+}
@@ -0,0 +1,35 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptQueue(t *testing.T) {
+	t.Parallel()
+
+	q := newPromptQueue()
+	q.push("a", QueuedPrompt{Content: "first"})
+	q.push("a", QueuedPrompt{Content: "second"})
+	q.push("a", QueuedPrompt{Content: "third"})
+	q.push("b", QueuedPrompt{Content: "other"})
+
+	prompt, ok := q.pop("a")
+	require.True(t, ok)
+	require.Equal(t, "first", prompt.Content)
+
+	prompt, ok = q.remove("a", 1)
+	require.True(t, ok)
+	require.Equal(t, "third", prompt.Content)
+	_, ok = q.remove("a", 1)
+	require.False(t, ok)
+
+	q.pushFront("a", QueuedPrompt{Content: "again"})
+	require.Equal(t, []QueuedPrompt{{Content: "again"}, {Content: "second"}}, q.list("a"))
+
+	require.Len(t, q.drain("a"), 2)
+	_, ok = q.pop("a")
+	require.False(t, ok)
+	require.Equal(t, []QueuedPrompt{{Content: "other"}}, q.list("b"))
+}
@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/shell"
+)
+
+const (
+	defaultSandboxMaxCPUSeconds = 600
+	defaultSandboxMaxMemoryMB   = 4096
+)
+
+// bashSandbox returns the sandbox of the bash tool for the working directory,
+// or nil when it isn't enabled.
+func bashSandbox(cfg *config.Sandbox, workingDir string) *shell.Sandbox {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+
+	writable := []string{workingDir, os.TempDir()}
+	home, _ := os.UserHomeDir()
+	for _, path := range cfg.WritablePaths {
+		if home != "" && (path == "~" || strings.HasPrefix(path, "~/")) {
+			path = filepath.Join(home, path[1:])
+		} else if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
+		writable = append(writable, path)
+	}
+
+	return &shell.Sandbox{
+		WritablePaths:  writable,
+		AllowNetwork:   cfg.AllowNetwork,
+		MaxCPUSeconds:  uint64(orDefault(cfg.MaxCPUSeconds, defaultSandboxMaxCPUSeconds)),
+		MaxMemoryBytes: uint64(orDefault(cfg.MaxMemoryMB, defaultSandboxMaxMemoryMB)) * 1024 * 1024,
+		MaxProcesses:   uint64(max(cfg.MaxProcesses, 0)),
+	}
+}
+
+func orDefault(value, fallback int) int {
+	if value <= 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,35 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/shell"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBashSandbox(t *testing.T) {
+	t.Parallel()
+
+	require.Nil(t, bashSandbox(nil, "/work"))
+	require.Nil(t, bashSandbox(&config.Sandbox{WritablePaths: []string{"out"}}, "/work"))
+
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	cfg := &config.Sandbox{
+		Enabled:       true,
+		WritablePaths: []string{"out", "~/.cache", "/var/cache"},
+		MaxMemoryMB:   256,
+	}
+	require.Equal(t, &shell.Sandbox{
+		WritablePaths:  []string{"/work", os.TempDir(), "/work/out", filepath.Join(home, ".cache"), "/var/cache"},
+		MaxCPUSeconds:  defaultSandboxMaxCPUSeconds,
+		MaxMemoryBytes: 256 * 1024 * 1024,
+	}, bashSandbox(cfg, "/work"))
+
+	cfg.MaxProcesses = 4096
+	require.Equal(t, uint64(4096), bashSandbox(cfg, "/work").MaxProcesses)
+}
@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/prompt"
+	"github.com/charmbracelet/crush/internal/llm/provider"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+const droppedToolResult = "[Tool result removed to save context]"
+
+// summarizeModelType returns the model used for summaries: the summarize
+// model when one is configured, the small model otherwise.
+func summarizeModelType(cfg *config.Config) config.SelectedModelType {
+	if _, ok := cfg.Models[config.SelectedModelTypeSummarize]; ok {
+		return config.SelectedModelTypeSummarize
+	}
+	return config.SelectedModelTypeSmall
+}
+
+func newSummarizeProvider(cfg *config.Config) (provider.Provider, string, error) {
+	modelType := summarizeModelType(cfg)
+	providerCfg := cfg.GetProviderForModel(modelType)
+	if providerCfg == nil {
+		return nil, "", fmt.Errorf("provider %s not found in config", cfg.Models[modelType].Provider)
+	}
+	summarizeOpts := []provider.ProviderClientOption{
+		provider.WithModel(modelType),
+		provider.WithSystemMessage(prompt.GetPrompt(prompt.PromptSummarizer, providerCfg.ID)),
+	}
+	summarizeProvider, err := provider.NewProvider(*providerCfg, summarizeOpts...)
+	if err != nil {
+		return nil, "", err
+	}
+	return summarizeProvider, string(providerCfg.ID), nil
+}
+
+// sessionHistory returns the messages of the session that are sent to the
+// model: the last summary followed by the messages it left out, with the
+// results of old tool calls removed when they have been dropped.
+func (a *agent) sessionHistory(sess session.Session, msgs []message.Message) []message.Message {
+	msgs = summarizedHistory(sess.SummaryMessageID, msgs)
+	if sess.ToolResultsDropped {
+		msgs = dropToolResults(msgs, config.Get().Options.Summarize.KeepTurns)
+	}
+	return msgs
+}
+
+func summarizedHistory(summaryMessageID string, msgs []message.Message) []message.Message {
+	if summaryMessageID == "" {
+		return msgs
+	}
+	idx := slices.IndexFunc(msgs, func(msg message.Message) bool {
+		return msg.ID == summaryMessageID
+	})
+	if idx == -1 {
+		return msgs
+	}
+
+	summary := msgs[idx]
+	summary.Role = message.User
+	history := []message.Message{summary}
+	if part := summary.SummaryPart(); part != nil && part.KeepFromMessageID != "" {
+		from := slices.IndexFunc(msgs[:idx], func(msg message.Message) bool {
+			return msg.ID == part.KeepFromMessageID
+		})
+		if from != -1 {
+			for _, msg := range msgs[from:idx] {
+				// Earlier summaries were replaced by this one.
+				if msg.SummaryPart() == nil {
+					history = append(history, msg)
+				}
+			}
+		}
+	}
+	return append(history, msgs[idx+1:]...)
+}
+
+// recentTurnsStart returns the index of the first message of the last n
+// turns, where a turn starts with a user message. It returns 0 when there are
+// no more than n turns.
+func recentTurnsStart(msgs []message.Message, n int) int {
+	for i := len(msgs) - 1; i > 0; i-- {
+		if msgs[i].Role != message.User {
+			continue
+		}
+		n--
+		if n == 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// dropToolResults returns a copy of msgs where the results of the tool calls
+// made before the last keepTurns turns are replaced by a short note.
+func dropToolResults(msgs []message.Message, keepTurns int) []message.Message {
+	start := recentTurnsStart(msgs, keepTurns)
+	dropped := slices.Clone(msgs)
+	for i := range start {
+		if dropped[i].Role != message.Tool {
+			continue
+		}
+		parts := make([]message.ContentPart, len(dropped[i].Parts))
+		for j, part := range dropped[i].Parts {
+			if result, ok := part.(message.ToolResult); ok {
+				result.Content = droppedToolResult
+				result.Metadata = ""
+				part = result
+			}
+			parts[j] = part
+		}
+		dropped[i].Parts = parts
+	}
+	return dropped
+}
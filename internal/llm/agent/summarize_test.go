@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func testMessage(id string, role message.MessageRole, parts ...message.ContentPart) message.Message {
+	return message.Message{ID: id, Role: role, Parts: parts}
+}
+
+func messageIDs(msgs []message.Message) []string {
+	var ids []string
+	for _, msg := range msgs {
+		ids = append(ids, msg.ID)
+	}
+	return ids
+}
+
+func TestSummarizedHistory(t *testing.T) {
+	t.Parallel()
+
+	msgs := []message.Message{
+		testMessage("u1", message.User),
+		testMessage("a1", message.Assistant),
+		testMessage("u2", message.User),
+		testMessage("a2", message.Assistant),
+		testMessage("s1", message.Assistant, message.Summary{KeepFromMessageID: "u2"}),
+		testMessage("u3", message.User),
+		testMessage("a3", message.Assistant),
+	}
+
+	history := summarizedHistory("", msgs)
+	require.Equal(t, messageIDs(msgs), messageIDs(history))
+
+	history = summarizedHistory("s1", msgs)
+	require.Equal(t, []string{"s1", "u2", "a2", "u3", "a3"}, messageIDs(history))
+	require.Equal(t, message.User, history[0].Role)
+	require.Equal(t, message.Assistant, msgs[4].Role)
+
+	// Earlier summaries within the kept messages are left out.
+	msgs = append(msgs, testMessage("s2", message.Assistant, message.Summary{KeepFromMessageID: "u2"}))
+	history = summarizedHistory("s2", msgs)
+	require.Equal(t, []string{"s2", "u2", "a2", "u3", "a3"}, messageIDs(history))
+
+	msgs[len(msgs)-1] = testMessage("s2", message.Assistant, message.Summary{})
+	history = summarizedHistory("s2", msgs)
+	require.Equal(t, []string{"s2"}, messageIDs(history))
+}
+
+func TestRecentTurnsStart(t *testing.T) {
+	t.Parallel()
+
+	msgs := []message.Message{
+		testMessage("u1", message.User),
+		testMessage("a1", message.Assistant),
+		testMessage("t1", message.Tool),
+		testMessage("u2", message.User),
+		testMessage("a2", message.Assistant),
+		testMessage("u3", message.User),
+	}
+	require.Equal(t, 5, recentTurnsStart(msgs, 1))
+	require.Equal(t, 3, recentTurnsStart(msgs, 2))
+	require.Equal(t, 0, recentTurnsStart(msgs, 3))
+	require.Equal(t, 0, recentTurnsStart(msgs, 10))
+}
+
+func TestDropToolResults(t *testing.T) {
+	t.Parallel()
+
+	result := func(content string) message.ToolResult {
+		return message.ToolResult{ToolCallID: "call", Content: content, Metadata: "{}"}
+	}
+	msgs := []message.Message{
+		testMessage("u1", message.User),
+		testMessage("t1", message.Tool, result("old")),
+		testMessage("u2", message.User),
+		testMessage("t2", message.Tool, result("recent")),
+	}
+
+	dropped := dropToolResults(msgs, 1)
+	require.Equal(t, droppedToolResult, dropped[1].ToolResults()[0].Content)
+	require.Empty(t, dropped[1].ToolResults()[0].Metadata)
+	require.Equal(t, "recent", dropped[3].ToolResults()[0].Content)
+	require.Equal(t, "old", msgs[1].ToolResults()[0].Content)
+}
@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/provider"
+	"github.com/charmbracelet/crush/internal/usage"
+)
+
+// requestCost returns what a call to the model that took the given tokens
+// cost.
+func requestCost(model catwalk.Model, tokens provider.TokenUsage) float64 {
+	return model.CostPer1MInCached/1e6*float64(tokens.CacheCreationTokens) +
+		model.CostPer1MOutCached/1e6*float64(tokens.CacheReadTokens) +
+		model.CostPer1MIn/1e6*float64(tokens.InputTokens) +
+		model.CostPer1MOut/1e6*float64(tokens.OutputTokens)
+}
+
+// recordUsage adds a provider call to the usage ledger and returns its cost.
+// The call is only logged when it can't be recorded, the conversation goes on.
+func (a *agent) recordUsage(ctx context.Context, kind, sessionID, messageID, providerID string, model catwalk.Model, tokens provider.TokenUsage, latency time.Duration) float64 {
+	cost := requestCost(model, tokens)
+	if a.ledger == nil {
+		return cost
+	}
+	_, err := a.ledger.Create(context.WithoutCancel(ctx), usage.Record{
+		SessionID:           sessionID,
+		MessageID:           messageID,
+		Kind:                kind,
+		Project:             config.Get().WorkingDir(),
+		Provider:            providerID,
+		Model:               model.ID,
+		InputTokens:         tokens.InputTokens,
+		OutputTokens:        tokens.OutputTokens,
+		CacheCreationTokens: tokens.CacheCreationTokens,
+		CacheReadTokens:     tokens.CacheReadTokens,
+		Cost:                cost,
+		Latency:             latency,
+	})
+	if err != nil {
+		slog.Error("Failed to record usage", "session_id", sessionID, "kind", kind, "error", err)
+	}
+	return cost
+}
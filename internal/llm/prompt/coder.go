@@ -33,7 +33,7 @@ func CoderPrompt(p string, contextFiles ...string) string {
 
 	contextContent := getContextFromPaths(config.Get().WorkingDir(), contextFiles)
 	if contextContent != "" {
-		return fmt.Sprintf("%s\n\n# Project-Specific Context\n Make sure to follow the instructions in the context below\n%s", basePrompt, contextContent)
+		return fmt.Sprintf("%s\n\n%s\n Make sure to follow the instructions in the context below\n%s", basePrompt, ProjectContextHeading, contextContent)
 	}
 	return basePrompt
 }
@@ -0,0 +1,31 @@
+package prompt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+// CustomPrompt builds the system prompt of a user-defined agent from the file
+// at promptPath, adding the same environment and project context the coder
+// prompt gets.
+func CustomPrompt(promptPath string, contextFiles ...string) (string, error) {
+	promptPath = expandPath(promptPath)
+	if !filepath.IsAbs(promptPath) {
+		promptPath = filepath.Join(config.Get().WorkingDir(), promptPath)
+	}
+	content, err := os.ReadFile(promptPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read prompt file: %w", err)
+	}
+
+	basePrompt := fmt.Sprintf("%s\n\n%s\n%s", content, getEnvironmentInfo(), lspInformation())
+
+	contextContent := getContextFromPaths(config.Get().WorkingDir(), contextFiles)
+	if contextContent != "" {
+		return fmt.Sprintf("%s\n\n%s\n Make sure to follow the instructions in the context below\n%s", basePrompt, ProjectContextHeading, contextContent), nil
+	}
+	return basePrompt, nil
+}
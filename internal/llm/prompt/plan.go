@@ -0,0 +1,12 @@
+package prompt
+
+import _ "embed"
+
+//go:embed plan.md
+var planPrompt []byte
+
+// PlanModePrompt returns the instructions given to the agent while it is in
+// plan mode.
+func PlanModePrompt() string {
+	return string(planPrompt)
+}
@@ -11,6 +11,10 @@ import (
 	"github.com/charmbracelet/crush/internal/env"
 )
 
+// ProjectContextHeading starts the part of the system prompt that holds the
+// context files of the project.
+const ProjectContextHeading = "# Project-Specific Context"
+
 type PromptID string
 
 const (
@@ -19,7 +19,6 @@ import (
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/llm/tools"
-	"github.com/charmbracelet/crush/internal/log"
 	"github.com/charmbracelet/crush/internal/message"
 )
 
@@ -52,7 +51,8 @@ func newAnthropicClient(opts providerClientOptions, tp AnthropicClientType) Anth
 }
 
 func createAnthropicClient(opts providerClientOptions, tp AnthropicClientType) anthropic.Client {
-	anthropicClientOptions := []option.RequestOption{}
+	// Retries are left to the provider's retry policy.
+	anthropicClientOptions := []option.RequestOption{option.WithMaxRetries(0)}
 
 	// Check if Authorization header is provided in extra headers
 	hasBearerAuth := false
@@ -79,14 +79,13 @@ func createAnthropicClient(opts providerClientOptions, tp AnthropicClientType) a
 		slog.Debug("Skipping X-Api-Key header because Authorization header is provided")
 	}
 
-	if config.Get().Options.Debug {
-		httpClient := log.NewHTTPClient()
-		anthropicClientOptions = append(anthropicClientOptions, option.WithHTTPClient(httpClient))
+	if opts.httpClient != nil {
+		anthropicClientOptions = append(anthropicClientOptions, option.WithHTTPClient(opts.httpClient))
 	}
 
 	switch tp {
 	case AnthropicClientTypeBedrock:
-		anthropicClientOptions = append(anthropicClientOptions, bedrock.WithLoadDefaultConfig(context.Background()))
+		anthropicClientOptions = append(anthropicClientOptions, bedrock.WithLoadDefaultConfig(context.Background(), bedrockConfigOptions(opts)...))
 	case AnthropicClientTypeVertex:
 		project := opts.extraParams["project"]
 		location := opts.extraParams["location"]
@@ -102,19 +101,10 @@ func createAnthropicClient(opts providerClientOptions, tp AnthropicClientType) a
 }
 
 func (a *anthropicClient) convertMessages(messages []message.Message) (anthropicMessages []anthropic.MessageParam) {
-	for i, msg := range messages {
-		cache := false
-		if i > len(messages)-3 {
-			cache = true
-		}
+	for _, msg := range messages {
 		switch msg.Role {
 		case message.User:
 			content := anthropic.NewTextBlock(msg.Content().String())
-			if cache && !a.providerOptions.disableCache {
-				content.OfText.CacheControl = anthropic.CacheControlEphemeralParam{
-					Type: "ephemeral",
-				}
-			}
 			var contentBlocks []anthropic.ContentBlockParamUnion
 			contentBlocks = append(contentBlocks, content)
 			for _, binaryContent := range msg.BinaryContent() {
@@ -127,20 +117,15 @@ func (a *anthropicClient) convertMessages(messages []message.Message) (anthropic
 		case message.Assistant:
 			blocks := []anthropic.ContentBlockParamUnion{}
 
-			// Add thinking blocks first if present (required when thinking is enabled with tool use)
-			if reasoningContent := msg.ReasoningContent(); reasoningContent.Thinking != "" {
+			// Add thinking blocks first if present (required when thinking is enabled with tool use).
+			// Reasoning from other providers has no signature and cannot be sent back.
+			if reasoningContent := msg.ReasoningContent(); reasoningContent.Thinking != "" && reasoningContent.Signature != "" {
 				thinkingBlock := anthropic.NewThinkingBlock(reasoningContent.Signature, reasoningContent.Thinking)
 				blocks = append(blocks, thinkingBlock)
 			}
 
 			if msg.Content().String() != "" {
-				content := anthropic.NewTextBlock(msg.Content().String())
-				if cache && !a.providerOptions.disableCache {
-					content.OfText.CacheControl = anthropic.CacheControlEphemeralParam{
-						Type: "ephemeral",
-					}
-				}
-				blocks = append(blocks, content)
+				blocks = append(blocks, anthropic.NewTextBlock(msg.Content().String()))
 			}
 
 			for _, toolCall := range msg.ToolCalls() {
@@ -166,11 +151,28 @@ func (a *anthropicClient) convertMessages(messages []message.Message) (anthropic
 			anthropicMessages = append(anthropicMessages, anthropic.NewUserMessage(results...))
 		}
 	}
+
+	// The breakpoint of a message goes on its last block that can take one,
+	// thinking blocks can't.
+	plan := a.providerOptions.cachePlan()
+	for i := range anthropicMessages {
+		if !plan.cacheMessage(i, len(anthropicMessages)) {
+			continue
+		}
+		blocks := anthropicMessages[i].Content
+		for j := len(blocks) - 1; j >= 0; j-- {
+			if cc := blocks[j].GetCacheControl(); cc != nil {
+				*cc = a.cacheControl(plan)
+				break
+			}
+		}
+	}
 	return
 }
 
 func (a *anthropicClient) convertTools(tools []tools.BaseTool) []anthropic.ToolUnionParam {
 	anthropicTools := make([]anthropic.ToolUnionParam, len(tools))
+	plan := a.providerOptions.cachePlan()
 
 	for i, tool := range tools {
 		info := tool.Info()
@@ -183,10 +185,8 @@ func (a *anthropicClient) convertTools(tools []tools.BaseTool) []anthropic.ToolU
 			},
 		}
 
-		if i == len(tools)-1 && !a.providerOptions.disableCache {
-			toolParam.CacheControl = anthropic.CacheControlEphemeralParam{
-				Type: "ephemeral",
-			}
+		if i == len(tools)-1 && plan.tools {
+			toolParam.CacheControl = a.cacheControl(plan)
 		}
 
 		anthropicTools[i] = anthropic.ToolUnionParam{OfTool: &toolParam}
@@ -211,22 +211,14 @@ func (a *anthropicClient) finishReason(reason string) message.FinishReason {
 }
 
 func (a *anthropicClient) isThinkingEnabled() bool {
-	cfg := config.Get()
-	modelConfig := cfg.Models[config.SelectedModelTypeLarge]
-	if a.providerOptions.modelType == config.SelectedModelTypeSmall {
-		modelConfig = cfg.Models[config.SelectedModelTypeSmall]
-	}
+	modelConfig := a.providerOptions.modelConfig()
 	return a.Model().CanReason && modelConfig.Think
 }
 
 func (a *anthropicClient) preparedMessages(messages []anthropic.MessageParam, tools []anthropic.ToolUnionParam) anthropic.MessageNewParams {
 	model := a.providerOptions.model(a.providerOptions.modelType)
 	var thinkingParam anthropic.ThinkingConfigParamUnion
-	cfg := config.Get()
-	modelConfig := cfg.Models[config.SelectedModelTypeLarge]
-	if a.providerOptions.modelType == config.SelectedModelTypeSmall {
-		modelConfig = cfg.Models[config.SelectedModelTypeSmall]
-	}
+	modelConfig := a.providerOptions.modelConfig()
 	temperature := anthropic.Float(0)
 
 	maxTokens := model.DefaultMaxTokens
@@ -247,34 +239,65 @@ func (a *anthropicClient) preparedMessages(messages []anthropic.MessageParam, to
 		maxTokens = int64(a.adjustedMaxTokens)
 	}
 
+	return anthropic.MessageNewParams{
+		Model:       anthropic.Model(model.ID),
+		MaxTokens:   maxTokens,
+		Temperature: temperature,
+		Messages:    messages,
+		Tools:       tools,
+		Thinking:    thinkingParam,
+		System:      a.systemBlocks(),
+	}
+}
+
+// systemBlocks returns the system prompt, with the context files in a block
+// of their own so that each part can get a cache breakpoint.
+func (a *anthropicClient) systemBlocks() []anthropic.TextBlockParam {
+	plan := a.providerOptions.cachePlan()
 	systemBlocks := []anthropic.TextBlockParam{}
 
 	// Add custom system prompt prefix if configured
 	if a.providerOptions.systemPromptPrefix != "" {
 		systemBlocks = append(systemBlocks, anthropic.TextBlockParam{
 			Text: a.providerOptions.systemPromptPrefix,
-			CacheControl: anthropic.CacheControlEphemeralParam{
-				Type: "ephemeral",
-			},
 		})
 	}
 
-	systemBlocks = append(systemBlocks, anthropic.TextBlockParam{
-		Text: a.providerOptions.systemMessage,
-		CacheControl: anthropic.CacheControlEphemeralParam{
-			Type: "ephemeral",
-		},
-	})
+	instructions, contextFiles := splitSystemPrompt(a.providerOptions.systemMessage)
+	block := anthropic.TextBlockParam{Text: instructions}
+	if plan.systemPrompt || (plan.contextFiles && contextFiles == "") {
+		block.CacheControl = a.cacheControl(plan)
+	}
+	systemBlocks = append(systemBlocks, block)
 
-	return anthropic.MessageNewParams{
-		Model:       anthropic.Model(model.ID),
-		MaxTokens:   maxTokens,
-		Temperature: temperature,
-		Messages:    messages,
-		Tools:       tools,
-		Thinking:    thinkingParam,
-		System:      systemBlocks,
+	if contextFiles != "" {
+		block := anthropic.TextBlockParam{Text: contextFiles}
+		if plan.contextFiles {
+			block.CacheControl = a.cacheControl(plan)
+		}
+		systemBlocks = append(systemBlocks, block)
+	}
+	return systemBlocks
+}
+
+func (a *anthropicClient) cacheControl(plan cachePlan) anthropic.CacheControlEphemeralParam {
+	cc := anthropic.NewCacheControlEphemeralParam()
+	if plan.ttl != "" {
+		cc.SetExtraFields(map[string]any{"ttl": plan.ttl})
 	}
+	return cc
+}
+
+// requestOptions returns the beta headers the request needs.
+func (a *anthropicClient) requestOptions() []option.RequestOption {
+	var opts []option.RequestOption
+	if a.isThinkingEnabled() {
+		opts = append(opts, option.WithHeaderAdd("anthropic-beta", "interleaved-thinking-2025-05-14"))
+	}
+	if a.providerOptions.cachePlan().ttl == "1h" {
+		opts = append(opts, option.WithHeaderAdd("anthropic-beta", string(anthropic.AnthropicBetaExtendedCacheTTL2025_04_11)))
+	}
+	return opts
 }
 
 func (a *anthropicClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (response *ProviderResponse, err error) {
@@ -284,10 +307,7 @@ func (a *anthropicClient) send(ctx context.Context, messages []message.Message,
 		// Prepare messages on each attempt in case max_tokens was adjusted
 		preparedMessages := a.preparedMessages(a.convertMessages(messages), a.convertTools(tools))
 
-		var opts []option.RequestOption
-		if a.isThinkingEnabled() {
-			opts = append(opts, option.WithHeaderAdd("anthropic-beta", "interleaved-thinking-2025-05-14"))
-		}
+		opts := a.requestOptions()
 		anthropicResponse, err := a.client.Messages.New(
 			ctx,
 			preparedMessages,
@@ -295,21 +315,16 @@ func (a *anthropicClient) send(ctx context.Context, messages []message.Message,
 		)
 		// If there is an error we are going to see if we can retry the call
 		if err != nil {
-			slog.Error("Anthropic API error", "error", err.Error(), "attempt", attempts, "max_retries", maxRetries)
+			slog.Error("Anthropic API error", "error", err.Error(), "attempt", attempts, "max_attempts", a.providerOptions.retry.MaxAttempts)
 			retry, after, retryErr := a.shouldRetry(attempts, err)
-			if retryErr != nil {
+			if !retry {
 				return nil, retryErr
 			}
-			if retry {
-				slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries)
-				select {
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				case <-time.After(time.Duration(after) * time.Millisecond):
-					continue
-				}
+			a.providerOptions.retry.logRetry(attempts, after, err)
+			if err := wait(ctx, after); err != nil {
+				return nil, err
 			}
-			return nil, retryErr
+			continue
 		}
 
 		content := ""
@@ -336,10 +351,7 @@ func (a *anthropicClient) stream(ctx context.Context, messages []message.Message
 			// Prepare messages on each attempt in case max_tokens was adjusted
 			preparedMessages := a.preparedMessages(a.convertMessages(messages), a.convertTools(tools))
 
-			var opts []option.RequestOption
-			if a.isThinkingEnabled() {
-				opts = append(opts, option.WithHeaderAdd("anthropic-beta", "interleaved-thinking-2025-05-14"))
-			}
+			opts := a.requestOptions()
 
 			anthropicStream := a.client.Messages.NewStreaming(
 				ctx,
@@ -444,81 +456,44 @@ func (a *anthropicClient) stream(ctx context.Context, messages []message.Message
 
 			// If there is an error we are going to see if we can retry the call
 			retry, after, retryErr := a.shouldRetry(attempts, err)
-			if retryErr != nil {
+			if !retry {
 				eventChan <- ProviderEvent{Type: EventError, Error: retryErr}
 				close(eventChan)
 				return
 			}
-			if retry {
-				slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries)
-				select {
-				case <-ctx.Done():
-					// context cancelled
-					if ctx.Err() != nil {
-						eventChan <- ProviderEvent{Type: EventError, Error: ctx.Err()}
-					}
-					close(eventChan)
-					return
-				case <-time.After(time.Duration(after) * time.Millisecond):
-					continue
-				}
-			}
-			if ctx.Err() != nil {
-				eventChan <- ProviderEvent{Type: EventError, Error: ctx.Err()}
+			eventChan <- ProviderEvent{Type: EventRetry, Retry: a.providerOptions.retry.logRetry(attempts, after, err)}
+			if err := wait(ctx, after); err != nil {
+				eventChan <- ProviderEvent{Type: EventError, Error: err}
+				close(eventChan)
+				return
 			}
-
-			close(eventChan)
-			return
 		}
 	}()
 	return eventChan
 }
 
-func (a *anthropicClient) shouldRetry(attempts int, err error) (bool, int64, error) {
+func (a *anthropicClient) shouldRetry(attempts int, err error) (bool, time.Duration, error) {
 	var apiErr *anthropic.Error
-	if !errors.As(err, &apiErr) {
-		return false, 0, err
-	}
-
-	if attempts > maxRetries {
-		return false, 0, fmt.Errorf("maximum retry attempts reached for rate limit: %d retries", maxRetries)
-	}
-
-	if apiErr.StatusCode == 401 {
-		a.providerOptions.apiKey, err = config.Get().Resolve(a.providerOptions.config.APIKey)
-		if err != nil {
-			return false, 0, fmt.Errorf("failed to resolve API key: %w", err)
-		}
-		a.client = createAnthropicClient(a.providerOptions, a.tp)
-		return true, 0, nil
-	}
-
-	// Handle context limit exceeded error (400 Bad Request)
-	if apiErr.StatusCode == 400 {
-		if adjusted, ok := a.handleContextLimitError(apiErr); ok {
-			a.adjustedMaxTokens = adjusted
-			slog.Debug("Adjusted max_tokens due to context limit", "new_max_tokens", adjusted)
+	if errors.As(err, &apiErr) && attempts < a.providerOptions.retry.MaxAttempts {
+		if apiErr.StatusCode == 401 {
+			a.providerOptions.apiKey, err = config.Get().Resolve(a.providerOptions.config.APIKey)
+			if err != nil {
+				return false, 0, fmt.Errorf("failed to resolve API key: %w", err)
+			}
+			a.client = createAnthropicClient(a.providerOptions, a.tp)
 			return true, 0, nil
 		}
-	}
-
-	isOverloaded := strings.Contains(apiErr.Error(), "overloaded") || strings.Contains(apiErr.Error(), "rate limit exceeded")
-	if apiErr.StatusCode != 429 && apiErr.StatusCode != 529 && !isOverloaded {
-		return false, 0, err
-	}
 
-	retryMs := 0
-	retryAfterValues := apiErr.Response.Header.Values("Retry-After")
-
-	backoffMs := 2000 * (1 << (attempts - 1))
-	jitterMs := int(float64(backoffMs) * 0.2)
-	retryMs = backoffMs + jitterMs
-	if len(retryAfterValues) > 0 {
-		if _, err := fmt.Sscanf(retryAfterValues[0], "%d", &retryMs); err == nil {
-			retryMs = retryMs * 1000
+		// Handle context limit exceeded error (400 Bad Request)
+		if apiErr.StatusCode == 400 {
+			if adjusted, ok := a.handleContextLimitError(apiErr); ok {
+				a.adjustedMaxTokens = adjusted
+				slog.Debug("Adjusted max_tokens due to context limit", "new_max_tokens", adjusted)
+				return true, 0, nil
+			}
 		}
 	}
-	return true, int64(retryMs), nil
+	return a.providerOptions.retry.shouldRetry(attempts, err)
 }
 
 // handleContextLimitError parses context limit error and returns adjusted max_tokens
@@ -577,6 +552,34 @@ func (a *anthropicClient) usage(msg anthropic.Message) TokenUsage {
 	}
 }
 
+// countTokens estimates the size of the request and asks the API for the
+// exact count when the estimate comes close to the context window. Counting is
+// a request of its own, which Bedrock and Vertex AI don't offer.
+func (a *anthropicClient) countTokens(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (int64, error) {
+	convertedTools := a.convertTools(tools)
+	estimate := anthropicTokenizer.estimate(a.providerOptions.systemPrompt(), messages, convertedTools)
+	if a.tp != AnthropicClientTypeNormal || estimate < a.Model().ContextWindow/2 {
+		return estimate, nil
+	}
+
+	params := a.preparedMessages(a.convertMessages(messages), convertedTools)
+	countParams := anthropic.MessageCountTokensParams{
+		Model:    params.Model,
+		Messages: params.Messages,
+		System:   anthropic.MessageCountTokensParamsSystemUnion{OfTextBlockArray: params.System},
+		Thinking: params.Thinking,
+	}
+	for _, tool := range convertedTools {
+		countParams.Tools = append(countParams.Tools, anthropic.MessageCountTokensToolUnionParam{OfTool: tool.OfTool})
+	}
+	count, err := a.client.Messages.CountTokens(ctx, countParams)
+	if err != nil {
+		slog.Debug("Failed to count tokens, using the estimate", "error", err)
+		return estimate, nil
+	}
+	return count.InputTokens, nil
+}
+
 func (a *anthropicClient) Model() catwalk.Model {
 	return a.providerOptions.model(a.providerOptions.modelType)
 }
@@ -1,8 +1,6 @@
 package provider
 
 import (
-	"github.com/charmbracelet/crush/internal/config"
-	"github.com/charmbracelet/crush/internal/log"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/azure"
 	"github.com/openai/openai-go/option"
@@ -22,11 +20,12 @@ func newAzureClient(opts providerClientOptions) AzureClient {
 
 	reqOpts := []option.RequestOption{
 		azure.WithEndpoint(opts.baseURL, apiVersion),
+		// Retries are left to the provider's retry policy.
+		option.WithMaxRetries(0),
 	}
 
-	if config.Get().Options.Debug {
-		httpClient := log.NewHTTPClient()
-		reqOpts = append(reqOpts, option.WithHTTPClient(httpClient))
+	if opts.httpClient != nil {
+		reqOpts = append(reqOpts, option.WithHTTPClient(opts.httpClient))
 	}
 
 	reqOpts = append(reqOpts, azure.WithAPIKey(opts.apiKey))
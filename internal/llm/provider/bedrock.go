@@ -1,11 +1,12 @@
 package provider
 
 import (
+	"cmp"
 	"context"
-	"errors"
 	"fmt"
 	"strings"
 
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/llm/tools"
@@ -19,75 +20,91 @@ type bedrockClient struct {
 
 type BedrockClient ProviderClient
 
-func newBedrockClient(opts providerClientOptions) BedrockClient {
-	// Get AWS region from environment
-	region := opts.extraParams["region"]
-	if region == "" {
-		region = "us-east-1" // default region
-	}
+func newBedrockClient(opts providerClientOptions) (BedrockClient, error) {
+	region := bedrockRegion(opts)
 	if len(region) < 2 {
-		return &bedrockClient{
-			providerOptions: opts,
-			childProvider:   nil, // Will cause an error when used
-		}
+		return nil, fmt.Errorf("invalid AWS region for bedrock: %q", region)
 	}
 
+	baseModel := opts.model
 	opts.model = func(modelType config.SelectedModelType) catwalk.Model {
-		model := config.Get().GetModelByType(modelType)
-
-		// Prefix the model name with region
-		regionPrefix := region[:2]
-		modelName := model.ID
-		model.ID = fmt.Sprintf("%s.%s", regionPrefix, modelName)
-		return *model
+		model := baseModel(modelType)
+		model.ID = bedrockModelID(model.ID, region)
+		return model
 	}
 
 	model := opts.model(opts.modelType)
+	if !bedrockCanConverse(model.ID) {
+		return nil, fmt.Errorf("bedrock model %s does not support conversations", model.ID)
+	}
 
-	// Determine which provider to use based on the model
-	if strings.Contains(string(model.ID), "anthropic") {
-		// Create Anthropic client with Bedrock configuration
-		anthropicOpts := opts
-		// TODO: later find a way to check if the AWS account has caching enabled
-		opts.disableCache = true // Disable cache for Bedrock
+	// Anthropic models get the Anthropic client, which knows about thinking
+	// and caching, every other model goes through the Converse API.
+	if strings.Contains(model.ID, "anthropic") {
+		// Not every AWS account has prompt caching, it is only used when the
+		// provider's cache settings ask for it.
+		if opts.config.Cache == nil {
+			opts.disableCache = true
+		}
 		return &bedrockClient{
 			providerOptions: opts,
-			childProvider:   newAnthropicClient(anthropicOpts, AnthropicClientTypeBedrock),
-		}
+			childProvider:   newAnthropicClient(opts, AnthropicClientTypeBedrock),
+		}, nil
 	}
 
-	// Return client with nil childProvider if model is not supported
-	// This will cause an error when used
+	converseClient, err := newBedrockConverseClient(opts)
+	if err != nil {
+		return nil, err
+	}
 	return &bedrockClient{
 		providerOptions: opts,
-		childProvider:   nil,
+		childProvider:   converseClient,
+	}, nil
+}
+
+func bedrockRegion(opts providerClientOptions) string {
+	return cmp.Or(opts.extraParams["region"], "us-east-1")
+}
+
+// bedrockConfigOptions points the AWS SDK at the provider's region and
+// profile.
+func bedrockConfigOptions(opts providerClientOptions) []func(*awsconfig.LoadOptions) error {
+	options := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(bedrockRegion(opts))}
+	if profile := opts.extraParams["profile"]; profile != "" {
+		options = append(options, awsconfig.WithSharedConfigProfile(profile))
 	}
+	return options
 }
 
-func (b *bedrockClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
-	if b.childProvider == nil {
-		return nil, errors.New("unsupported model for bedrock provider")
+// bedrockModelID returns the ID to call a model by. Anthropic models are
+// served through cross-region inference profiles, named after the geography
+// of the region. Other models are called by their configured ID, which can be
+// an inference profile too.
+func bedrockModelID(id, region string) string {
+	if !strings.HasPrefix(id, "anthropic.") {
+		return id
 	}
+	return region[:2] + "." + id
+}
+
+// bedrockCanConverse reports whether a model can hold a conversation, as
+// opposed to e.g. embedding and image generation models.
+func bedrockCanConverse(id string) bool {
+	return !contains(id, "embed", "rerank", "stability.", "titan-image", "nova-canvas", "nova-reel")
+}
+
+func (b *bedrockClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
 	return b.childProvider.send(ctx, messages, tools)
 }
 
 func (b *bedrockClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
-	eventChan := make(chan ProviderEvent)
-
-	if b.childProvider == nil {
-		go func() {
-			eventChan <- ProviderEvent{
-				Type:  EventError,
-				Error: errors.New("unsupported model for bedrock provider"),
-			}
-			close(eventChan)
-		}()
-		return eventChan
-	}
-
 	return b.childProvider.stream(ctx, messages, tools)
 }
 
+func (b *bedrockClient) countTokens(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (int64, error) {
+	return b.childProvider.countTokens(ctx, messages, tools)
+}
+
 func (b *bedrockClient) Model() catwalk.Model {
 	return b.providerOptions.model(b.providerOptions.modelType)
 }
@@ -0,0 +1,443 @@
+package provider
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+// bedrockConverseClient talks to Bedrock's Converse API, which serves every
+// model family that supports conversations through the same request format.
+type bedrockConverseClient struct {
+	providerOptions providerClientOptions
+	client          *bedrockruntime.Client
+}
+
+func newBedrockConverseClient(opts providerClientOptions) (*bedrockConverseClient, error) {
+	awsConfig, err := awsconfig.LoadDefaultConfig(context.Background(), bedrockConfigOptions(opts)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	client := bedrockruntime.NewFromConfig(awsConfig, func(o *bedrockruntime.Options) {
+		// Failed requests are retried by the provider's retry policy.
+		o.Retryer = aws.NopRetryer{}
+		if opts.httpClient != nil {
+			o.HTTPClient = opts.httpClient
+		}
+		for key, value := range opts.extraHeaders {
+			o.APIOptions = append(o.APIOptions, smithyhttp.SetHeaderValue(key, value))
+		}
+	})
+	return &bedrockConverseClient{
+		providerOptions: opts,
+		client:          client,
+	}, nil
+}
+
+// Statuses of the exceptions Bedrock sends in the middle of a stream, which
+// come without a response to take the status from.
+var bedrockExceptionStatus = map[string]int{
+	"ThrottlingException":         http.StatusTooManyRequests,
+	"ServiceUnavailableException": http.StatusServiceUnavailable,
+	"InternalServerException":     http.StatusInternalServerError,
+	"ModelStreamErrorException":   http.StatusFailedDependency,
+	"ValidationException":         http.StatusBadRequest,
+}
+
+// bedrockImageFormat returns the Converse format of an image type.
+func bedrockImageFormat(mimeType string) (types.ImageFormat, error) {
+	switch mimeType {
+	case "image/png":
+		return types.ImageFormatPng, nil
+	case "image/jpeg", "image/jpg":
+		return types.ImageFormatJpeg, nil
+	case "image/gif":
+		return types.ImageFormatGif, nil
+	case "image/webp":
+		return types.ImageFormatWebp, nil
+	default:
+		return "", fmt.Errorf("bedrock: unsupported image type %q, use PNG, JPEG, GIF or WebP", mimeType)
+	}
+}
+
+func (b *bedrockConverseClient) convertMessages(messages []message.Message) (bedrockMessages []types.Message, err error) {
+	for _, msg := range messages {
+		var role types.ConversationRole
+		var content []types.ContentBlock
+		switch msg.Role {
+		case message.User:
+			role = types.ConversationRoleUser
+			if text := msg.Content().String(); text != "" {
+				content = append(content, &types.ContentBlockMemberText{Value: text})
+			}
+			for _, binaryContent := range msg.BinaryContent() {
+				format, err := bedrockImageFormat(binaryContent.MIMEType)
+				if err != nil {
+					return nil, err
+				}
+				content = append(content, &types.ContentBlockMemberImage{
+					Value: types.ImageBlock{
+						Format: format,
+						Source: &types.ImageSourceMemberBytes{Value: binaryContent.Data},
+					},
+				})
+			}
+		case message.Assistant:
+			role = types.ConversationRoleAssistant
+			if text := msg.Content().String(); text != "" {
+				content = append(content, &types.ContentBlockMemberText{Value: text})
+			}
+			for _, call := range msg.ToolCalls() {
+				input := map[string]any{}
+				_ = json.Unmarshal([]byte(call.Input), &input)
+				content = append(content, &types.ContentBlockMemberToolUse{
+					Value: types.ToolUseBlock{
+						ToolUseId: aws.String(call.ID),
+						Name:      aws.String(call.Name),
+						Input:     document.NewLazyDocument(input),
+					},
+				})
+			}
+		case message.Tool:
+			// Tool results are sent by the user.
+			role = types.ConversationRoleUser
+			for _, result := range msg.ToolResults() {
+				status := types.ToolResultStatusSuccess
+				if result.IsError {
+					status = types.ToolResultStatusError
+				}
+				content = append(content, &types.ContentBlockMemberToolResult{
+					Value: types.ToolResultBlock{
+						ToolUseId: aws.String(result.ToolCallID),
+						Content: []types.ToolResultContentBlock{
+							&types.ToolResultContentBlockMemberText{Value: cmp.Or(result.Content, "(no output)")},
+						},
+						Status: status,
+					},
+				})
+			}
+		}
+		if len(content) == 0 {
+			continue
+		}
+		// Converse wants the roles to alternate.
+		if n := len(bedrockMessages); n > 0 && bedrockMessages[n-1].Role == role {
+			bedrockMessages[n-1].Content = append(bedrockMessages[n-1].Content, content...)
+			continue
+		}
+		bedrockMessages = append(bedrockMessages, types.Message{Role: role, Content: content})
+	}
+	return bedrockMessages, nil
+}
+
+func (b *bedrockConverseClient) convertTools(tools []tools.BaseTool) *types.ToolConfiguration {
+	if len(tools) == 0 {
+		return nil
+	}
+	toolConfig := &types.ToolConfiguration{}
+	for _, tool := range tools {
+		info := tool.Info()
+		toolConfig.Tools = append(toolConfig.Tools, &types.ToolMemberToolSpec{
+			Value: types.ToolSpecification{
+				Name:        aws.String(info.Name),
+				Description: aws.String(info.Description),
+				InputSchema: &types.ToolInputSchemaMemberJson{
+					Value: document.NewLazyDocument(map[string]any{
+						"type":       "object",
+						"properties": info.Parameters,
+						"required":   info.Required,
+					}),
+				},
+			},
+		})
+	}
+	return toolConfig
+}
+
+func (b *bedrockConverseClient) preparedRequest(messages []types.Message, tools *types.ToolConfiguration) *bedrockruntime.ConverseInput {
+	model := b.providerOptions.model(b.providerOptions.modelType)
+	modelConfig := b.providerOptions.modelConfig()
+
+	systemMessage := b.providerOptions.systemMessage
+	if b.providerOptions.systemPromptPrefix != "" {
+		systemMessage = b.providerOptions.systemPromptPrefix + "\n" + systemMessage
+	}
+
+	maxTokens := model.DefaultMaxTokens
+	if modelConfig.MaxTokens > 0 {
+		maxTokens = modelConfig.MaxTokens
+	}
+	// Override max tokens if set in provider options
+	if b.providerOptions.maxTokens > 0 {
+		maxTokens = b.providerOptions.maxTokens
+	}
+
+	request := &bedrockruntime.ConverseInput{
+		ModelId:    aws.String(model.ID),
+		Messages:   messages,
+		ToolConfig: tools,
+	}
+	if maxTokens > 0 {
+		request.InferenceConfig = &types.InferenceConfiguration{MaxTokens: aws.Int32(int32(maxTokens))}
+	}
+	if systemMessage != "" {
+		request.System = []types.SystemContentBlock{&types.SystemContentBlockMemberText{Value: systemMessage}}
+	}
+	// Model specific parameters, taken from the provider's extra body.
+	if len(b.providerOptions.extraBody) > 0 {
+		request.AdditionalModelRequestFields = document.NewLazyDocument(b.providerOptions.extraBody)
+	}
+	return request
+}
+
+func (b *bedrockConverseClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	bedrockMessages, err := b.convertMessages(messages)
+	if err != nil {
+		return nil, err
+	}
+	request := b.preparedRequest(bedrockMessages, b.convertTools(tools))
+	attempts := 0
+	for {
+		attempts++
+		converseResp, err := b.client.Converse(ctx, request)
+		if err != nil {
+			retry, after, retryErr := b.providerOptions.retry.shouldRetry(attempts, err)
+			if !retry {
+				return nil, retryErr
+			}
+			b.providerOptions.retry.logRetry(attempts, after, err)
+			if err := wait(ctx, after); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var content strings.Builder
+		var toolCalls []message.ToolCall
+		if output, ok := converseResp.Output.(*types.ConverseOutputMemberMessage); ok {
+			for _, block := range output.Value.Content {
+				switch block := block.(type) {
+				case *types.ContentBlockMemberText:
+					content.WriteString(block.Value)
+				case *types.ContentBlockMemberToolUse:
+					input := "{}"
+					if block.Value.Input != nil {
+						data, err := block.Value.Input.MarshalSmithyDocument()
+						if err != nil {
+							return nil, fmt.Errorf("failed to decode Bedrock tool input: %w", err)
+						}
+						input = string(data)
+					}
+					toolCalls = append(toolCalls, message.ToolCall{
+						ID:       aws.ToString(block.Value.ToolUseId),
+						Name:     aws.ToString(block.Value.Name),
+						Input:    input,
+						Type:     "function",
+						Finished: true,
+					})
+				}
+			}
+		}
+		finishReason := b.finishReason(converseResp.StopReason)
+		if len(toolCalls) > 0 {
+			finishReason = message.FinishReasonToolUse
+		}
+		return &ProviderResponse{
+			Content:      content.String(),
+			ToolCalls:    toolCalls,
+			Usage:        b.usage(converseResp.Usage),
+			FinishReason: finishReason,
+		}, nil
+	}
+}
+
+func (b *bedrockConverseClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	eventChan := make(chan ProviderEvent)
+	bedrockMessages, err := b.convertMessages(messages)
+	if err != nil {
+		go func() {
+			eventChan <- ProviderEvent{Type: EventError, Error: err}
+			close(eventChan)
+		}()
+		return eventChan
+	}
+	request := b.preparedRequest(bedrockMessages, b.convertTools(tools))
+	streamRequest := &bedrockruntime.ConverseStreamInput{
+		ModelId:                      request.ModelId,
+		Messages:                     request.Messages,
+		System:                       request.System,
+		InferenceConfig:              request.InferenceConfig,
+		ToolConfig:                   request.ToolConfig,
+		AdditionalModelRequestFields: request.AdditionalModelRequestFields,
+	}
+
+	go func() {
+		defer close(eventChan)
+		attempts := 0
+		for {
+			attempts++
+			output, err := b.client.ConverseStream(ctx, streamRequest)
+			if err == nil {
+				stream := output.GetStream()
+				err = b.readStream(stream, eventChan)
+				stream.Close()
+				if err == nil {
+					return
+				}
+			}
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+
+			retry, after, retryErr := b.providerOptions.retry.shouldRetry(attempts, err)
+			if !retry {
+				eventChan <- ProviderEvent{Type: EventError, Error: retryErr}
+				return
+			}
+			eventChan <- ProviderEvent{Type: EventRetry, Retry: b.providerOptions.retry.logRetry(attempts, after, err)}
+			if err := wait(ctx, after); err != nil {
+				eventChan <- ProviderEvent{Type: EventError, Error: err}
+				return
+			}
+		}
+	}()
+
+	return eventChan
+}
+
+// bedrockEventStream is the part of a ConverseStream response that readStream
+// reads.
+type bedrockEventStream interface {
+	Events() <-chan types.ConverseStreamOutput
+	Err() error
+}
+
+// readStream turns the events of a ConverseStream response into provider
+// events. It returns an error when the stream fails before the response is
+// complete.
+func (b *bedrockConverseClient) readStream(stream bedrockEventStream, eventChan chan<- ProviderEvent) error {
+	var content strings.Builder
+	var toolCalls []message.ToolCall
+	// Tool calls by the index of their content block.
+	blocks := map[int32]*message.ToolCall{}
+	var stopReason types.StopReason
+	var usage *types.TokenUsage
+
+	for event := range stream.Events() {
+		switch event := event.(type) {
+		case *types.ConverseStreamOutputMemberContentBlockStart:
+			if start, ok := event.Value.Start.(*types.ContentBlockStartMemberToolUse); ok {
+				toolCall := &message.ToolCall{
+					ID:   aws.ToString(start.Value.ToolUseId),
+					Name: aws.ToString(start.Value.Name),
+					Type: "function",
+				}
+				blocks[aws.ToInt32(event.Value.ContentBlockIndex)] = toolCall
+				eventChan <- ProviderEvent{Type: EventToolUseStart, ToolCall: &message.ToolCall{ID: toolCall.ID, Name: toolCall.Name}}
+			}
+		case *types.ConverseStreamOutputMemberContentBlockDelta:
+			switch delta := event.Value.Delta.(type) {
+			case *types.ContentBlockDeltaMemberToolUse:
+				if toolCall, ok := blocks[aws.ToInt32(event.Value.ContentBlockIndex)]; ok {
+					input := aws.ToString(delta.Value.Input)
+					toolCall.Input += input
+					eventChan <- ProviderEvent{Type: EventToolUseDelta, ToolCall: &message.ToolCall{ID: toolCall.ID, Input: input}}
+				}
+			case *types.ContentBlockDeltaMemberReasoningContent:
+				if text, ok := delta.Value.(*types.ReasoningContentBlockDeltaMemberText); ok && text.Value != "" {
+					eventChan <- ProviderEvent{Type: EventThinkingDelta, Thinking: text.Value}
+				}
+			case *types.ContentBlockDeltaMemberText:
+				if delta.Value != "" {
+					eventChan <- ProviderEvent{Type: EventContentDelta, Content: delta.Value}
+					content.WriteString(delta.Value)
+				}
+			}
+		case *types.ConverseStreamOutputMemberContentBlockStop:
+			if toolCall, ok := blocks[aws.ToInt32(event.Value.ContentBlockIndex)]; ok {
+				toolCall.Input = cmp.Or(toolCall.Input, "{}")
+				toolCall.Finished = true
+				toolCalls = append(toolCalls, *toolCall)
+				eventChan <- ProviderEvent{Type: EventToolUseStop, ToolCall: toolCall}
+			}
+		case *types.ConverseStreamOutputMemberMessageStop:
+			stopReason = event.Value.StopReason
+		case *types.ConverseStreamOutputMemberMetadata:
+			usage = event.Value.Usage
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return err
+	}
+
+	if stopReason == "" {
+		return errors.New("bedrock: stream ended before the response was done")
+	}
+	finishReason := b.finishReason(stopReason)
+	if len(toolCalls) > 0 {
+		finishReason = message.FinishReasonToolUse
+	}
+	eventChan <- ProviderEvent{
+		Type: EventComplete,
+		Response: &ProviderResponse{
+			Content:      content.String(),
+			ToolCalls:    toolCalls,
+			Usage:        b.usage(usage),
+			FinishReason: finishReason,
+		},
+	}
+	return nil
+}
+
+func (b *bedrockConverseClient) finishReason(reason types.StopReason) message.FinishReason {
+	switch reason {
+	case types.StopReasonEndTurn, types.StopReasonStopSequence:
+		return message.FinishReasonEndTurn
+	case types.StopReasonMaxTokens:
+		return message.FinishReasonMaxTokens
+	case types.StopReasonToolUse:
+		return message.FinishReasonToolUse
+	default:
+		return message.FinishReasonUnknown
+	}
+}
+
+func (b *bedrockConverseClient) usage(usage *types.TokenUsage) TokenUsage {
+	if usage == nil {
+		return TokenUsage{}
+	}
+	return TokenUsage{
+		InputTokens:         int64(aws.ToInt32(usage.InputTokens)),
+		OutputTokens:        int64(aws.ToInt32(usage.OutputTokens)),
+		CacheCreationTokens: int64(aws.ToInt32(usage.CacheWriteInputTokens)),
+		CacheReadTokens:     int64(aws.ToInt32(usage.CacheReadInputTokens)),
+	}
+}
+
+func (b *bedrockConverseClient) countTokens(ctx context.Context, messages []message.Message, toolset []tools.BaseTool) (int64, error) {
+	// The SDK's tool schemas don't marshal to JSON, their size is taken from
+	// the tool infos instead.
+	infos := make([]tools.ToolInfo, len(toolset))
+	for i, tool := range toolset {
+		infos[i] = tool.Info()
+	}
+	return defaultTokenizer.estimate(b.providerOptions.systemPrompt(), messages, infos), nil
+}
+
+func (b *bedrockConverseClient) Model() catwalk.Model {
+	return b.providerOptions.model(b.providerOptions.modelType)
+}
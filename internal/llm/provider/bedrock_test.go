@@ -0,0 +1,264 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBedrockModelID(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "us.anthropic.claude-sonnet-4-20250514-v1:0", bedrockModelID("anthropic.claude-sonnet-4-20250514-v1:0", "us-east-1"))
+	require.Equal(t, "eu.anthropic.claude-sonnet-4-20250514-v1:0", bedrockModelID("anthropic.claude-sonnet-4-20250514-v1:0", "eu-west-1"))
+	require.Equal(t, "us.meta.llama3-3-70b-instruct-v1:0", bedrockModelID("us.meta.llama3-3-70b-instruct-v1:0", "us-east-1"))
+	require.Equal(t, "mistral.mistral-large-2407-v1:0", bedrockModelID("mistral.mistral-large-2407-v1:0", "us-west-2"))
+
+	require.True(t, bedrockCanConverse("amazon.nova-pro-v1:0"))
+	require.False(t, bedrockCanConverse("amazon.titan-embed-text-v2:0"))
+	require.False(t, bedrockCanConverse("stability.sd3-large-v1:0"))
+}
+
+func TestBedrockConverseMessages(t *testing.T) {
+	t.Parallel()
+
+	client := &bedrockConverseClient{}
+	messages, err := client.convertMessages([]message.Message{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "List the files"}}},
+		{Role: message.Assistant, Parts: []message.ContentPart{
+			message.ToolCall{ID: "tool_1", Name: "ls", Input: `{"path":"."}`},
+			message.ToolCall{ID: "tool_2", Name: "pwd"},
+		}},
+		{Role: message.Tool, Parts: []message.ContentPart{
+			message.ToolResult{ToolCallID: "tool_1", Content: "main.go"},
+			message.ToolResult{ToolCallID: "tool_2", Content: "no such command", IsError: true},
+		}},
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "Thanks"}}},
+	})
+	require.NoError(t, err)
+
+	// The tool results and the next user message share a turn.
+	require.Len(t, messages, 3)
+	require.Equal(t, types.ConversationRoleAssistant, messages[1].Role)
+	toolUse, ok := messages[1].Content[1].(*types.ContentBlockMemberToolUse)
+	require.True(t, ok)
+	input, err := toolUse.Value.Input.MarshalSmithyDocument()
+	require.NoError(t, err)
+	require.JSONEq(t, "{}", string(input))
+	require.Equal(t, types.ConversationRoleUser, messages[2].Role)
+	require.Len(t, messages[2].Content, 3)
+	toolResult, ok := messages[2].Content[1].(*types.ContentBlockMemberToolResult)
+	require.True(t, ok)
+	require.Equal(t, types.ToolResultStatusError, toolResult.Value.Status)
+	require.Equal(t, &types.ContentBlockMemberText{Value: "Thanks"}, messages[2].Content[2])
+}
+
+func TestBedrockConverseImages(t *testing.T) {
+	t.Parallel()
+
+	image := func(mimeType string) []message.Message {
+		return []message.Message{{Role: message.User, Parts: []message.ContentPart{
+			message.TextContent{Text: "What is this?"},
+			message.BinaryContent{Path: "image", MIMEType: mimeType, Data: []byte("data")},
+		}}}
+	}
+
+	client := &bedrockConverseClient{}
+	for mimeType, format := range map[string]types.ImageFormat{
+		"image/png":  types.ImageFormatPng,
+		"image/jpeg": types.ImageFormatJpeg,
+		"image/jpg":  types.ImageFormatJpeg,
+		"image/gif":  types.ImageFormatGif,
+		"image/webp": types.ImageFormatWebp,
+	} {
+		messages, err := client.convertMessages(image(mimeType))
+		require.NoError(t, err)
+		block, ok := messages[0].Content[1].(*types.ContentBlockMemberImage)
+		require.True(t, ok)
+		require.Equal(t, format, block.Value.Format, mimeType)
+	}
+
+	_, err := client.convertMessages(image("image/bmp"))
+	require.ErrorContains(t, err, `unsupported image type "image/bmp"`)
+
+	// Streams fail before anything is sent.
+	var event ProviderEvent
+	for event = range client.stream(t.Context(), image("image/tiff"), nil) {
+	}
+	require.Equal(t, EventError, event.Type)
+	require.ErrorContains(t, event.Error, `unsupported image type "image/tiff"`)
+}
+
+func TestBedrockConverseSend(t *testing.T) {
+	t.Parallel()
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		require.Equal(t, "/model/mistral.mistral-large-2407-v1:0/converse", r.URL.Path)
+		require.NotEmpty(t, r.Header.Get("Authorization"))
+		require.Equal(t, "test", r.Header.Get("X-Test"))
+		if requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.Header().Set("X-Amzn-Errortype", "ThrottlingException")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"message":"Too many requests"}`))
+			return
+		}
+
+		var request map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		require.Equal(t, []any{map[string]any{"text": "test"}}, request["system"])
+		require.Equal(t, map[string]any{"maxTokens": float64(4096)}, request["inferenceConfig"])
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"output": {"message": {"role": "assistant", "content": [
+				{"text": "Let me look."},
+				{"toolUse": {"toolUseId": "tool_1", "name": "ls", "input": {"path": "."}}}
+			]}},
+			"stopReason": "tool_use",
+			"usage": {"inputTokens": 12, "outputTokens": 7, "totalTokens": 19}
+		}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client := &bedrockConverseClient{
+		providerOptions: providerClientOptions{
+			modelType:     config.SelectedModelTypeLarge,
+			systemMessage: "test",
+			model: func(config.SelectedModelType) catwalk.Model {
+				return catwalk.Model{ID: "mistral.mistral-large-2407-v1:0", DefaultMaxTokens: 4096}
+			},
+			selectedModel: &config.SelectedModel{Model: "mistral.mistral-large-2407-v1:0"},
+			retry:         RetryPolicy{MaxAttempts: 2, RetryableStatusCodes: []int{429}},
+		},
+		client: bedrockruntime.New(bedrockruntime.Options{
+			Region:       "us-east-1",
+			BaseEndpoint: aws.String(server.URL),
+			Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+				return aws.Credentials{AccessKeyID: "key", SecretAccessKey: "secret"}, nil
+			}),
+			Retryer: aws.NopRetryer{},
+			APIOptions: []func(*middleware.Stack) error{
+				smithyhttp.SetHeaderValue("X-Test", "test"),
+			},
+		}),
+	}
+
+	resp, err := client.send(t.Context(), []message.Message{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "List the files"}}},
+	}, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, requests)
+	require.Equal(t, &ProviderResponse{
+		Content:      "Let me look.",
+		ToolCalls:    []message.ToolCall{{ID: "tool_1", Name: "ls", Input: `{"path":"."}`, Type: "function", Finished: true}},
+		Usage:        TokenUsage{InputTokens: 12, OutputTokens: 7},
+		FinishReason: message.FinishReasonToolUse,
+	}, resp)
+}
+
+// fakeBedrockStream replays events as a ConverseStream response would.
+type fakeBedrockStream struct {
+	events []types.ConverseStreamOutput
+	err    error
+}
+
+func (s *fakeBedrockStream) Events() <-chan types.ConverseStreamOutput {
+	events := make(chan types.ConverseStreamOutput, len(s.events))
+	for _, event := range s.events {
+		events <- event
+	}
+	close(events)
+	return events
+}
+
+func (s *fakeBedrockStream) Err() error {
+	return s.err
+}
+
+func TestBedrockConverseStream(t *testing.T) {
+	t.Parallel()
+
+	delta := func(index int32, delta types.ContentBlockDelta) types.ConverseStreamOutput {
+		return &types.ConverseStreamOutputMemberContentBlockDelta{
+			Value: types.ContentBlockDeltaEvent{ContentBlockIndex: aws.Int32(index), Delta: delta},
+		}
+	}
+	stop := func(index int32) types.ConverseStreamOutput {
+		return &types.ConverseStreamOutputMemberContentBlockStop{
+			Value: types.ContentBlockStopEvent{ContentBlockIndex: aws.Int32(index)},
+		}
+	}
+
+	t.Run("turns events into provider events", func(t *testing.T) {
+		t.Parallel()
+		stream := &fakeBedrockStream{events: []types.ConverseStreamOutput{
+			&types.ConverseStreamOutputMemberMessageStart{Value: types.MessageStartEvent{Role: types.ConversationRoleAssistant}},
+			delta(0, &types.ContentBlockDeltaMemberText{Value: "Let me look."}),
+			stop(0),
+			&types.ConverseStreamOutputMemberContentBlockStart{Value: types.ContentBlockStartEvent{
+				ContentBlockIndex: aws.Int32(1),
+				Start:             &types.ContentBlockStartMemberToolUse{Value: types.ToolUseBlockStart{ToolUseId: aws.String("tool_1"), Name: aws.String("ls")}},
+			}},
+			delta(1, &types.ContentBlockDeltaMemberToolUse{Value: types.ToolUseBlockDelta{Input: aws.String(`{"path":`)}}),
+			delta(1, &types.ContentBlockDeltaMemberToolUse{Value: types.ToolUseBlockDelta{Input: aws.String(`"."}`)}}),
+			stop(1),
+			&types.ConverseStreamOutputMemberMessageStop{Value: types.MessageStopEvent{StopReason: types.StopReasonToolUse}},
+			&types.ConverseStreamOutputMemberMetadata{Value: types.ConverseStreamMetadataEvent{
+				Usage: &types.TokenUsage{InputTokens: aws.Int32(12), OutputTokens: aws.Int32(7)},
+			}},
+		}}
+
+		client := &bedrockConverseClient{}
+		eventChan := make(chan ProviderEvent, 20)
+		require.NoError(t, client.readStream(stream, eventChan))
+		close(eventChan)
+
+		var events []ProviderEvent
+		for event := range eventChan {
+			events = append(events, event)
+		}
+		require.Len(t, events, 6)
+		require.Equal(t, ProviderEvent{Type: EventContentDelta, Content: "Let me look."}, events[0])
+		require.Equal(t, EventToolUseStart, events[1].Type)
+		require.Equal(t, EventToolUseStop, events[4].Type)
+		require.Equal(t, EventComplete, events[5].Type)
+		require.Equal(t, &ProviderResponse{
+			Content:      "Let me look.",
+			ToolCalls:    []message.ToolCall{{ID: "tool_1", Name: "ls", Input: `{"path":"."}`, Type: "function", Finished: true}},
+			Usage:        TokenUsage{InputTokens: 12, OutputTokens: 7},
+			FinishReason: message.FinishReasonToolUse,
+		}, events[5].Response)
+	})
+
+	t.Run("returns exceptions as retryable errors", func(t *testing.T) {
+		t.Parallel()
+		stream := &fakeBedrockStream{
+			events: []types.ConverseStreamOutput{
+				&types.ConverseStreamOutputMemberMessageStart{Value: types.MessageStartEvent{Role: types.ConversationRoleAssistant}},
+			},
+			err: &types.ThrottlingException{Message: aws.String("Too many requests")},
+		}
+
+		client := &bedrockConverseClient{}
+		err := client.readStream(stream, make(chan ProviderEvent, 20))
+		var throttlingErr *types.ThrottlingException
+		require.ErrorAs(t, err, &throttlingErr)
+		statusCode, _ := errorStatus(err)
+		require.Equal(t, http.StatusTooManyRequests, statusCode)
+	})
+}
@@ -0,0 +1,97 @@
+package provider
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/prompt"
+)
+
+// maxCacheBreakpoints is the number of cache breakpoints Anthropic accepts in
+// a request.
+const maxCacheBreakpoints = 4
+
+const defaultCacheMessages = 2
+
+var defaultCacheBreakpoints = []config.CacheBreakpoint{
+	config.CacheBreakpointTools,
+	config.CacheBreakpointContextFiles,
+	config.CacheBreakpointMessages,
+}
+
+// cachePlan says where the cache breakpoints of a request go.
+type cachePlan struct {
+	systemPrompt bool
+	tools        bool
+	contextFiles bool
+	// Number of most recent messages that get a breakpoint.
+	messages int
+	// Empty for the provider's default.
+	ttl string
+}
+
+// cachePlan returns where the breakpoints of the client's requests go. The
+// messages only get the breakpoints the others leave.
+func (o providerClientOptions) cachePlan() cachePlan {
+	cache := o.config.Cache
+	if o.disableCache || (cache != nil && cache.Disable) {
+		return cachePlan{}
+	}
+	if cache == nil {
+		cache = &config.PromptCache{}
+	}
+	breakpoints := cache.Breakpoints
+	if len(breakpoints) == 0 {
+		breakpoints = defaultCacheBreakpoints
+	}
+
+	plan := cachePlan{
+		systemPrompt: slices.Contains(breakpoints, config.CacheBreakpointSystemPrompt),
+		tools:        slices.Contains(breakpoints, config.CacheBreakpointTools),
+		contextFiles: slices.Contains(breakpoints, config.CacheBreakpointContextFiles),
+		ttl:          cache.TTL,
+	}
+	if slices.Contains(breakpoints, config.CacheBreakpointMessages) {
+		left := maxCacheBreakpoints
+		for _, used := range []bool{plan.systemPrompt, plan.tools, plan.contextFiles} {
+			if used {
+				left--
+			}
+		}
+		plan.messages = min(cmp.Or(cache.Messages, defaultCacheMessages), left)
+	}
+	return plan
+}
+
+// system reports whether the system prompt gets a breakpoint, for providers
+// that take it as a single block.
+func (p cachePlan) system() bool {
+	return p.systemPrompt || p.contextFiles
+}
+
+// cacheMessage reports whether the message at index i of n gets a breakpoint.
+func (p cachePlan) cacheMessage(i, n int) bool {
+	return i >= n-p.messages
+}
+
+// cacheControl returns the cache_control field of a block with a breakpoint,
+// for APIs that don't have a type for it.
+func (p cachePlan) cacheControl() map[string]string {
+	cc := map[string]string{"type": "ephemeral"}
+	if p.ttl != "" {
+		cc["ttl"] = p.ttl
+	}
+	return cc
+}
+
+// splitSystemPrompt splits the system prompt into its instructions and the
+// context files of the project that follow them, if any.
+func splitSystemPrompt(system string) (instructions, contextFiles string) {
+	i := strings.Index(system, "\n\n"+prompt.ProjectContextHeading)
+	if i < 0 {
+		return system, ""
+	}
+	return system[:i], system[i+2:]
+}
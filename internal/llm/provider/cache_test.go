@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachePlan(t *testing.T) {
+	t.Parallel()
+
+	plan := func(cache *config.PromptCache) cachePlan {
+		return providerClientOptions{config: config.ProviderConfig{Cache: cache}}.cachePlan()
+	}
+
+	require.Equal(t, cachePlan{tools: true, contextFiles: true, messages: 2}, plan(nil))
+	require.Equal(t, cachePlan{}, plan(&config.PromptCache{Disable: true}))
+	require.Equal(t, cachePlan{}, providerClientOptions{disableCache: true}.cachePlan())
+
+	// The messages get what the other breakpoints leave.
+	all := &config.PromptCache{
+		Breakpoints: []config.CacheBreakpoint{
+			config.CacheBreakpointSystemPrompt,
+			config.CacheBreakpointTools,
+			config.CacheBreakpointContextFiles,
+			config.CacheBreakpointMessages,
+		},
+		Messages: 3,
+		TTL:      "1h",
+	}
+	require.Equal(t, cachePlan{systemPrompt: true, tools: true, contextFiles: true, messages: 1, ttl: "1h"}, plan(all))
+
+	messages := plan(&config.PromptCache{Breakpoints: []config.CacheBreakpoint{config.CacheBreakpointMessages}, Messages: 4})
+	require.Equal(t, 4, messages.messages)
+	require.False(t, messages.cacheMessage(0, 5))
+	require.True(t, messages.cacheMessage(1, 5))
+	require.Equal(t, map[string]string{"type": "ephemeral"}, messages.cacheControl())
+}
+
+func TestSplitSystemPrompt(t *testing.T) {
+	t.Parallel()
+
+	instructions, contextFiles := splitSystemPrompt("You are Crush.\n\n# Project-Specific Context\nUse tabs.")
+	require.Equal(t, "You are Crush.", instructions)
+	require.Equal(t, "# Project-Specific Context\nUse tabs.", contextFiles)
+
+	instructions, contextFiles = splitSystemPrompt("You are Crush.")
+	require.Equal(t, "You are Crush.", instructions)
+	require.Empty(t, contextFiles)
+}
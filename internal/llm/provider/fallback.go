@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ErrMaxRetries is returned once a request kept failing with retryable errors.
+var ErrMaxRetries = errors.New("maximum retry attempts reached")
+
+// IsFallbackError reports whether err means the model cannot serve the request
+// for now, so that switching to a fallback model is worth it: retries ran out,
+// the provider is overloaded or failing, or the conversation does not fit in
+// the model's context window.
+func IsFallbackError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, ErrMaxRetries) || errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+
+	statusCode, _ := errorStatus(err)
+	switch statusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+		529: // Anthropic's overloaded status
+		return true
+	}
+
+	return contains(err.Error(),
+		"overloaded",
+		"resource exhausted",
+		"context length",
+		"context_length_exceeded",
+		"context window",
+		"prompt is too long",
+		"input is too long",
+		"too many tokens",
+	)
+}
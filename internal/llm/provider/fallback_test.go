@@ -0,0 +1,35 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsFallbackError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"canceled", fmt.Errorf("stream: %w", context.Canceled), false},
+		{"retries exhausted", fmt.Errorf("%w after %d attempts: %w", ErrMaxRetries, 8, errors.New("overloaded")), true},
+		{"overloaded status", &ollamaError{StatusCode: 503, Message: "busy"}, true},
+		{"bad request status", &ollamaError{StatusCode: 400, Message: "invalid tool"}, false},
+		{"context length", errors.New("This model's maximum context length is 128000 tokens"), true},
+		{"prompt too long", errors.New("prompt is too long: 210000 tokens > 200000 maximum"), true},
+		{"other", errors.New("invalid api key"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, IsFallbackError(tt.err))
+		})
+	}
+}
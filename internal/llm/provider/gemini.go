@@ -3,9 +3,7 @@ package provider
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"strings"
 	"time"
@@ -13,7 +11,6 @@ import (
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/llm/tools"
-	"github.com/charmbracelet/crush/internal/log"
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/google/uuid"
 	"google.golang.org/genai"
@@ -44,8 +41,8 @@ func createGeminiClient(opts providerClientOptions) (*genai.Client, error) {
 		APIKey:  opts.apiKey,
 		Backend: genai.BackendGeminiAPI,
 	}
-	if config.Get().Options.Debug {
-		cc.HTTPClient = log.NewHTTPClient()
+	if opts.httpClient != nil {
+		cc.HTTPClient = opts.httpClient
 	}
 	client, err := genai.NewClient(context.Background(), cc)
 	if err != nil {
@@ -173,12 +170,7 @@ func (g *geminiClient) send(ctx context.Context, messages []message.Message, too
 	// Convert messages
 	geminiMessages := g.convertMessages(messages)
 	model := g.providerOptions.model(g.providerOptions.modelType)
-	cfg := config.Get()
-
-	modelConfig := cfg.Models[config.SelectedModelTypeLarge]
-	if g.providerOptions.modelType == config.SelectedModelTypeSmall {
-		modelConfig = cfg.Models[config.SelectedModelTypeSmall]
-	}
+	modelConfig := g.providerOptions.modelConfig()
 
 	maxTokens := model.DefaultMaxTokens
 	if modelConfig.MaxTokens > 0 {
@@ -212,19 +204,14 @@ func (g *geminiClient) send(ctx context.Context, messages []message.Message, too
 		// If there is an error we are going to see if we can retry the call
 		if err != nil {
 			retry, after, retryErr := g.shouldRetry(attempts, err)
-			if retryErr != nil {
+			if !retry {
 				return nil, retryErr
 			}
-			if retry {
-				slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries)
-				select {
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				case <-time.After(time.Duration(after) * time.Millisecond):
-					continue
-				}
+			g.providerOptions.retry.logRetry(attempts, after, err)
+			if err := wait(ctx, after); err != nil {
+				return nil, err
 			}
-			return nil, retryErr
+			continue
 		}
 
 		content := ""
@@ -269,12 +256,7 @@ func (g *geminiClient) stream(ctx context.Context, messages []message.Message, t
 	geminiMessages := g.convertMessages(messages)
 
 	model := g.providerOptions.model(g.providerOptions.modelType)
-	cfg := config.Get()
-
-	modelConfig := cfg.Models[config.SelectedModelTypeLarge]
-	if g.providerOptions.modelType == config.SelectedModelTypeSmall {
-		modelConfig = cfg.Models[config.SelectedModelTypeSmall]
-	}
+	modelConfig := g.providerOptions.modelConfig()
 	maxTokens := model.DefaultMaxTokens
 	if modelConfig.MaxTokens > 0 {
 		maxTokens = modelConfig.MaxTokens
@@ -305,6 +287,7 @@ func (g *geminiClient) stream(ctx context.Context, messages []message.Message, t
 	go func() {
 		defer close(eventChan)
 
+	outer:
 		for {
 			attempts++
 
@@ -322,26 +305,16 @@ func (g *geminiClient) stream(ctx context.Context, messages []message.Message, t
 			for resp, err := range chat.SendMessageStream(ctx, lastMsgParts...) {
 				if err != nil {
 					retry, after, retryErr := g.shouldRetry(attempts, err)
-					if retryErr != nil {
+					if !retry {
 						eventChan <- ProviderEvent{Type: EventError, Error: retryErr}
 						return
 					}
-					if retry {
-						slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries)
-						select {
-						case <-ctx.Done():
-							if ctx.Err() != nil {
-								eventChan <- ProviderEvent{Type: EventError, Error: ctx.Err()}
-							}
-
-							return
-						case <-time.After(time.Duration(after) * time.Millisecond):
-							break
-						}
-					} else {
+					eventChan <- ProviderEvent{Type: EventRetry, Retry: g.providerOptions.retry.logRetry(attempts, after, err)}
+					if err := wait(ctx, after); err != nil {
 						eventChan <- ProviderEvent{Type: EventError, Error: err}
 						return
 					}
+					continue outer
 				}
 
 				finalResp = resp
@@ -412,23 +385,9 @@ func (g *geminiClient) stream(ctx context.Context, messages []message.Message, t
 	return eventChan
 }
 
-func (g *geminiClient) shouldRetry(attempts int, err error) (bool, int64, error) {
-	// Check if error is a rate limit error
-	if attempts > maxRetries {
-		return false, 0, fmt.Errorf("maximum retry attempts reached for rate limit: %d retries", maxRetries)
-	}
-
-	// Gemini doesn't have a standard error type we can check against
-	// So we'll check the error message for rate limit indicators
-	if errors.Is(err, io.EOF) {
-		return false, 0, err
-	}
-
-	errMsg := err.Error()
-	isRateLimit := contains(errMsg, "rate limit", "quota exceeded", "too many requests")
-
+func (g *geminiClient) shouldRetry(attempts int, err error) (bool, time.Duration, error) {
 	// Check for token expiration (401 Unauthorized)
-	if contains(errMsg, "unauthorized", "invalid api key", "api key expired") {
+	if contains(err.Error(), "unauthorized", "invalid api key", "api key expired") && attempts < g.providerOptions.retry.MaxAttempts {
 		g.providerOptions.apiKey, err = config.Get().Resolve(g.providerOptions.config.APIKey)
 		if err != nil {
 			return false, 0, fmt.Errorf("failed to resolve API key: %w", err)
@@ -439,19 +398,7 @@ func (g *geminiClient) shouldRetry(attempts int, err error) (bool, int64, error)
 		}
 		return true, 0, nil
 	}
-
-	// Check for common rate limit error messages
-
-	if !isRateLimit {
-		return false, 0, err
-	}
-
-	// Calculate backoff with jitter
-	backoffMs := 2000 * (1 << (attempts - 1))
-	jitterMs := int(float64(backoffMs) * 0.2)
-	retryMs := backoffMs + jitterMs
-
-	return true, int64(retryMs), nil
+	return g.providerOptions.retry.shouldRetry(attempts, err)
 }
 
 func (g *geminiClient) usage(resp *genai.GenerateContentResponse) TokenUsage {
@@ -467,6 +414,10 @@ func (g *geminiClient) usage(resp *genai.GenerateContentResponse) TokenUsage {
 	}
 }
 
+func (g *geminiClient) countTokens(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (int64, error) {
+	return geminiTokenizer.estimate(g.providerOptions.systemPrompt(), messages, g.convertTools(tools)), nil
+}
+
 func (g *geminiClient) Model() catwalk.Model {
 	return g.providerOptions.model(g.providerOptions.modelType)
 }
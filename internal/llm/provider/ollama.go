@@ -0,0 +1,426 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/google/uuid"
+)
+
+type ollamaClient struct {
+	providerOptions providerClientOptions
+	baseURL         string
+	httpClient      *http.Client
+}
+
+type OllamaClient ProviderClient
+
+func newOllamaClient(opts providerClientOptions) OllamaClient {
+	baseURL, err := config.Get().Resolve(opts.baseURL)
+	if err != nil || baseURL == "" {
+		baseURL = opts.baseURL
+	}
+	httpClient := cmp.Or(opts.httpClient, http.DefaultClient)
+	return &ollamaClient{
+		providerOptions: opts,
+		baseURL:         strings.TrimSuffix(baseURL, "/"),
+		httpClient:      httpClient,
+	}
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function ollamaToolCallFunction `json:"function"`
+}
+
+type ollamaToolCallFunction struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type ollamaTool struct {
+	Type     string             `json:"type"`
+	Function ollamaToolFunction `json:"function"`
+}
+
+type ollamaToolFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Tools    []ollamaTool    `json:"tools,omitempty"`
+	Stream   bool            `json:"stream"`
+	Think    *bool           `json:"think,omitempty"`
+	Options  map[string]any  `json:"options,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int64         `json:"prompt_eval_count"`
+	EvalCount       int64         `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+// ollamaError is returned when the server answers with an error status.
+type ollamaError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *ollamaError) Error() string {
+	return fmt.Sprintf("ollama: %d %s", e.StatusCode, e.Message)
+}
+
+func (o *ollamaClient) convertMessages(messages []message.Message) (ollamaMessages []ollamaMessage) {
+	systemMessage := o.providerOptions.systemMessage
+	if o.providerOptions.systemPromptPrefix != "" {
+		systemMessage = o.providerOptions.systemPromptPrefix + "\n" + systemMessage
+	}
+	ollamaMessages = append(ollamaMessages, ollamaMessage{Role: "system", Content: systemMessage})
+
+	// Ollama matches tool results to calls by name, there are no call IDs.
+	toolNames := make(map[string]string)
+	for _, msg := range messages {
+		switch msg.Role {
+		case message.User:
+			userMsg := ollamaMessage{Role: "user", Content: msg.Content().String()}
+			for _, binaryContent := range msg.BinaryContent() {
+				userMsg.Images = append(userMsg.Images, base64.StdEncoding.EncodeToString(binaryContent.Data))
+			}
+			ollamaMessages = append(ollamaMessages, userMsg)
+
+		case message.Assistant:
+			assistantMsg := ollamaMessage{Role: "assistant", Content: msg.Content().String()}
+			for _, call := range msg.ToolCalls() {
+				toolNames[call.ID] = call.Name
+				arguments := json.RawMessage(call.Input)
+				if !json.Valid(arguments) {
+					arguments = json.RawMessage("{}")
+				}
+				assistantMsg.ToolCalls = append(assistantMsg.ToolCalls, ollamaToolCall{
+					Function: ollamaToolCallFunction{Name: call.Name, Arguments: arguments},
+				})
+			}
+			if assistantMsg.Content == "" && len(assistantMsg.ToolCalls) == 0 {
+				slog.Warn("There is a message without content, investigate, this should not happen")
+				continue
+			}
+			ollamaMessages = append(ollamaMessages, assistantMsg)
+
+		case message.Tool:
+			for _, result := range msg.ToolResults() {
+				ollamaMessages = append(ollamaMessages, ollamaMessage{
+					Role:     "tool",
+					Content:  result.Content,
+					ToolName: cmp.Or(result.Name, toolNames[result.ToolCallID]),
+				})
+			}
+		}
+	}
+	return
+}
+
+func (o *ollamaClient) convertTools(tools []tools.BaseTool) []ollamaTool {
+	ollamaTools := make([]ollamaTool, len(tools))
+	for i, tool := range tools {
+		info := tool.Info()
+		ollamaTools[i] = ollamaTool{
+			Type: "function",
+			Function: ollamaToolFunction{
+				Name:        info.Name,
+				Description: info.Description,
+				Parameters: map[string]any{
+					"type":       "object",
+					"properties": info.Parameters,
+					"required":   info.Required,
+				},
+			},
+		}
+	}
+	return ollamaTools
+}
+
+func (o *ollamaClient) finishReason(reason string) message.FinishReason {
+	switch reason {
+	case "stop":
+		return message.FinishReasonEndTurn
+	case "length":
+		return message.FinishReasonMaxTokens
+	default:
+		return message.FinishReasonUnknown
+	}
+}
+
+func (o *ollamaClient) preparedRequest(messages []ollamaMessage, tools []ollamaTool, stream bool) ollamaChatRequest {
+	model := o.providerOptions.model(o.providerOptions.modelType)
+	modelConfig := o.providerOptions.modelConfig()
+
+	maxTokens := model.DefaultMaxTokens
+	if modelConfig.MaxTokens > 0 {
+		maxTokens = modelConfig.MaxTokens
+	}
+	// Override max tokens if set in provider options
+	if o.providerOptions.maxTokens > 0 {
+		maxTokens = o.providerOptions.maxTokens
+	}
+
+	request := ollamaChatRequest{
+		Model:    model.ID,
+		Messages: messages,
+		Tools:    tools,
+		Stream:   stream,
+		Options:  map[string]any{},
+	}
+	// Ollama uses a small context window unless told otherwise.
+	if model.ContextWindow > 0 {
+		request.Options["num_ctx"] = model.ContextWindow
+	}
+	if maxTokens > 0 {
+		request.Options["num_predict"] = maxTokens
+	}
+	if model.CanReason {
+		think := modelConfig.Think
+		request.Think = &think
+	}
+	return request
+}
+
+// do posts the chat request and returns the response body.
+func (o *ollamaClient) do(ctx context.Context, request ollamaChatRequest) (io.ReadCloser, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	if len(o.providerOptions.extraBody) > 0 {
+		var merged map[string]any
+		if err := json.Unmarshal(body, &merged); err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		for key, value := range o.providerOptions.extraBody {
+			merged[key] = value
+		}
+		if body, err = json.Marshal(merged); err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.providerOptions.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.providerOptions.apiKey)
+	}
+	for key, value := range o.providerOptions.extraHeaders {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var errResp ollamaChatResponse
+		_ = json.NewDecoder(resp.Body).Decode(&errResp)
+		return nil, &ollamaError{StatusCode: resp.StatusCode, Message: cmp.Or(errResp.Error, resp.Status)}
+	}
+	return resp.Body, nil
+}
+
+func (o *ollamaClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	request := o.preparedRequest(o.convertMessages(messages), o.convertTools(tools), false)
+	attempts := 0
+	for {
+		attempts++
+		body, err := o.do(ctx, request)
+		if err != nil {
+			retry, after, retryErr := o.providerOptions.retry.shouldRetry(attempts, err)
+			if !retry {
+				return nil, retryErr
+			}
+			o.providerOptions.retry.logRetry(attempts, after, err)
+			if err := wait(ctx, after); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var chatResp ollamaChatResponse
+		err = json.NewDecoder(body).Decode(&chatResp)
+		body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode Ollama response: %w", err)
+		}
+		if chatResp.Error != "" {
+			return nil, fmt.Errorf("ollama: %s", chatResp.Error)
+		}
+
+		toolCalls := o.toolCalls(chatResp.Message)
+		finishReason := o.finishReason(chatResp.DoneReason)
+		if len(toolCalls) > 0 {
+			finishReason = message.FinishReasonToolUse
+		}
+		return &ProviderResponse{
+			Content:      chatResp.Message.Content,
+			ToolCalls:    toolCalls,
+			Usage:        o.usage(chatResp),
+			FinishReason: finishReason,
+		}, nil
+	}
+}
+
+func (o *ollamaClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	request := o.preparedRequest(o.convertMessages(messages), o.convertTools(tools), true)
+	eventChan := make(chan ProviderEvent)
+
+	go func() {
+		defer close(eventChan)
+		attempts := 0
+		for {
+			attempts++
+			body, err := o.do(ctx, request)
+			if err != nil {
+				retry, after, retryErr := o.providerOptions.retry.shouldRetry(attempts, err)
+				if !retry {
+					eventChan <- ProviderEvent{Type: EventError, Error: retryErr}
+					return
+				}
+				eventChan <- ProviderEvent{Type: EventRetry, Retry: o.providerOptions.retry.logRetry(attempts, after, err)}
+				if err := wait(ctx, after); err != nil {
+					eventChan <- ProviderEvent{Type: EventError, Error: err}
+					return
+				}
+				continue
+			}
+			o.readStream(ctx, body, eventChan)
+			body.Close()
+			return
+		}
+	}()
+
+	return eventChan
+}
+
+// readStream turns the newline delimited JSON chunks of a streaming chat
+// response into provider events.
+func (o *ollamaClient) readStream(ctx context.Context, body io.Reader, eventChan chan<- ProviderEvent) {
+	var content strings.Builder
+	var toolCalls []message.ToolCall
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			eventChan <- ProviderEvent{Type: EventError, Error: fmt.Errorf("failed to decode Ollama response: %w", err)}
+			return
+		}
+		if chunk.Error != "" {
+			eventChan <- ProviderEvent{Type: EventError, Error: fmt.Errorf("ollama: %s", chunk.Error)}
+			return
+		}
+
+		if chunk.Message.Thinking != "" {
+			eventChan <- ProviderEvent{Type: EventThinkingDelta, Thinking: chunk.Message.Thinking}
+		}
+		if chunk.Message.Content != "" {
+			eventChan <- ProviderEvent{Type: EventContentDelta, Content: chunk.Message.Content}
+			content.WriteString(chunk.Message.Content)
+		}
+		// Ollama sends each tool call whole, in a single chunk.
+		for _, toolCall := range o.toolCalls(chunk.Message) {
+			eventChan <- ProviderEvent{Type: EventToolUseStart, ToolCall: &toolCall}
+			eventChan <- ProviderEvent{Type: EventToolUseStop, ToolCall: &toolCall}
+			toolCalls = append(toolCalls, toolCall)
+		}
+
+		if chunk.Done {
+			finishReason := o.finishReason(chunk.DoneReason)
+			if len(toolCalls) > 0 {
+				finishReason = message.FinishReasonToolUse
+			}
+			eventChan <- ProviderEvent{
+				Type: EventComplete,
+				Response: &ProviderResponse{
+					Content:      content.String(),
+					ToolCalls:    toolCalls,
+					Usage:        o.usage(chunk),
+					FinishReason: finishReason,
+				},
+			}
+			return
+		}
+	}
+
+	err := scanner.Err()
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = fmt.Errorf("ollama: stream ended before the response was done")
+	}
+	eventChan <- ProviderEvent{Type: EventError, Error: err}
+}
+
+func (o *ollamaClient) toolCalls(msg ollamaMessage) []message.ToolCall {
+	var toolCalls []message.ToolCall
+	for _, call := range msg.ToolCalls {
+		// Ollama does not give tool calls an ID.
+		toolCalls = append(toolCalls, message.ToolCall{
+			ID:       "call_" + uuid.NewString(),
+			Name:     call.Function.Name,
+			Input:    string(call.Function.Arguments),
+			Type:     "function",
+			Finished: true,
+		})
+	}
+	return toolCalls
+}
+
+func (o *ollamaClient) usage(resp ollamaChatResponse) TokenUsage {
+	return TokenUsage{
+		InputTokens:  resp.PromptEvalCount,
+		OutputTokens: resp.EvalCount,
+	}
+}
+
+func (o *ollamaClient) countTokens(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (int64, error) {
+	return defaultTokenizer.estimate(o.providerOptions.systemPrompt(), messages, o.convertTools(tools)), nil
+}
+
+func (o *ollamaClient) Model() catwalk.Model {
+	return o.providerOptions.model(o.providerOptions.modelType)
+}
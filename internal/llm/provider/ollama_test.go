@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOllamaClientStream(t *testing.T) {
+	var request ollamaChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/chat", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		chunks := []string{
+			`{"message":{"role":"assistant","content":"Let me "},"done":false}`,
+			`{"message":{"role":"assistant","content":"look."},"done":false}`,
+			`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"view","arguments":{"file_path":"main.go"}}}]},"done":false}`,
+			`{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":42,"eval_count":7}`,
+		}
+		for _, chunk := range chunks {
+			_, _ = w.Write([]byte(chunk + "\n"))
+		}
+	}))
+	defer server.Close()
+
+	client := &ollamaClient{
+		providerOptions: providerClientOptions{
+			modelType:     config.SelectedModelTypeLarge,
+			systemMessage: "test",
+			model: func(config.SelectedModelType) catwalk.Model {
+				return catwalk.Model{
+					ID:               "qwen3:8b",
+					ContextWindow:    40960,
+					DefaultMaxTokens: 4096,
+				}
+			},
+		},
+		baseURL:    server.URL,
+		httpClient: http.DefaultClient,
+	}
+
+	messages := []message.Message{
+		{
+			Role:  message.User,
+			Parts: []message.ContentPart{message.TextContent{Text: "Hello"}},
+		},
+		{
+			Role:  message.Assistant,
+			Parts: []message.ContentPart{message.ToolCall{ID: "call_1", Name: "ls", Input: `{"path":"."}`}},
+		},
+		{
+			Role:  message.Tool,
+			Parts: []message.ContentPart{message.ToolResult{ToolCallID: "call_1", Content: "main.go"}},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var events []ProviderEvent
+	for event := range client.stream(ctx, messages, nil) {
+		events = append(events, event)
+	}
+
+	require.True(t, request.Stream)
+	require.Equal(t, "qwen3:8b", request.Model)
+	require.EqualValues(t, 40960, request.Options["num_ctx"])
+	require.Len(t, request.Messages, 4)
+	require.Equal(t, "system", request.Messages[0].Role)
+	require.JSONEq(t, `{"path":"."}`, string(request.Messages[2].ToolCalls[0].Function.Arguments))
+	require.Equal(t, "ls", request.Messages[3].ToolName)
+
+	last := events[len(events)-1]
+	require.Equal(t, EventComplete, last.Type)
+	require.Equal(t, "Let me look.", last.Response.Content)
+	require.Equal(t, message.FinishReasonToolUse, last.Response.FinishReason)
+	require.Equal(t, int64(42), last.Response.Usage.InputTokens)
+	require.Equal(t, int64(7), last.Response.Usage.OutputTokens)
+	require.Len(t, last.Response.ToolCalls, 1)
+	require.Equal(t, "view", last.Response.ToolCalls[0].Name)
+	require.JSONEq(t, `{"file_path":"main.go"}`, last.Response.ToolCalls[0].Input)
+	require.NotEmpty(t, last.Response.ToolCalls[0].ID)
+}
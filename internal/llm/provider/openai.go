@@ -12,7 +12,6 @@ import (
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/llm/tools"
-	"github.com/charmbracelet/crush/internal/log"
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
@@ -35,7 +34,8 @@ func newOpenAIClient(opts providerClientOptions) OpenAIClient {
 }
 
 func createOpenAIClient(opts providerClientOptions) openai.Client {
-	openaiClientOptions := []option.RequestOption{}
+	// Retries are left to the provider's retry policy.
+	openaiClientOptions := []option.RequestOption{option.WithMaxRetries(0)}
 	if opts.apiKey != "" {
 		openaiClientOptions = append(openaiClientOptions, option.WithAPIKey(opts.apiKey))
 	}
@@ -46,9 +46,8 @@ func createOpenAIClient(opts providerClientOptions) openai.Client {
 		}
 	}
 
-	if config.Get().Options.Debug {
-		httpClient := log.NewHTTPClient()
-		openaiClientOptions = append(openaiClientOptions, option.WithHTTPClient(httpClient))
+	if opts.httpClient != nil {
+		openaiClientOptions = append(openaiClientOptions, option.WithHTTPClient(opts.httpClient))
 	}
 
 	for key, value := range opts.extraHeaders {
@@ -64,6 +63,7 @@ func createOpenAIClient(opts providerClientOptions) openai.Client {
 
 func (o *openaiClient) convertMessages(messages []message.Message) (openaiMessages []openai.ChatCompletionMessageParamUnion) {
 	isAnthropicModel := o.providerOptions.config.ID == string(catwalk.InferenceProviderOpenRouter) && strings.HasPrefix(o.Model().ID, "anthropic/")
+	plan := o.providerOptions.cachePlan()
 	// Add system message first
 	systemMessage := o.providerOptions.systemMessage
 	if o.providerOptions.systemPromptPrefix != "" {
@@ -71,12 +71,10 @@ func (o *openaiClient) convertMessages(messages []message.Message) (openaiMessag
 	}
 
 	systemTextBlock := openai.ChatCompletionContentPartTextParam{Text: systemMessage}
-	if isAnthropicModel && !o.providerOptions.disableCache {
+	if isAnthropicModel && plan.system() {
 		systemTextBlock.SetExtraFields(
 			map[string]any{
-				"cache_control": map[string]string{
-					"type": "ephemeral",
-				},
+				"cache_control": plan.cacheControl(),
 			},
 		)
 	}
@@ -86,10 +84,7 @@ func (o *openaiClient) convertMessages(messages []message.Message) (openaiMessag
 	openaiMessages = append(openaiMessages, system)
 
 	for i, msg := range messages {
-		cache := false
-		if i > len(messages)-3 {
-			cache = true
-		}
+		cache := isAnthropicModel && plan.cacheMessage(i, len(messages))
 		switch msg.Role {
 		case message.User:
 			var content []openai.ChatCompletionContentPartUnionParam
@@ -101,11 +96,9 @@ func (o *openaiClient) convertMessages(messages []message.Message) (openaiMessag
 
 				content = append(content, openai.ChatCompletionContentPartUnionParam{OfImageURL: &imageBlock})
 			}
-			if cache && !o.providerOptions.disableCache && isAnthropicModel {
+			if cache {
 				textBlock.SetExtraFields(map[string]any{
-					"cache_control": map[string]string{
-						"type": "ephemeral",
-					},
+					"cache_control": plan.cacheControl(),
 				})
 			}
 
@@ -120,11 +113,9 @@ func (o *openaiClient) convertMessages(messages []message.Message) (openaiMessag
 			if msg.Content().String() != "" {
 				hasContent = true
 				textBlock := openai.ChatCompletionContentPartTextParam{Text: msg.Content().String()}
-				if cache && !o.providerOptions.disableCache && isAnthropicModel {
+				if cache {
 					textBlock.SetExtraFields(map[string]any{
-						"cache_control": map[string]string{
-							"type": "ephemeral",
-						},
+						"cache_control": plan.cacheControl(),
 					})
 				}
 				assistantMsg.Content = openai.ChatCompletionAssistantMessageParamContentUnion{
@@ -210,12 +201,7 @@ func (o *openaiClient) finishReason(reason string) message.FinishReason {
 
 func (o *openaiClient) preparedParams(messages []openai.ChatCompletionMessageParamUnion, tools []openai.ChatCompletionToolParam) openai.ChatCompletionNewParams {
 	model := o.providerOptions.model(o.providerOptions.modelType)
-	cfg := config.Get()
-
-	modelConfig := cfg.Models[config.SelectedModelTypeLarge]
-	if o.providerOptions.modelType == config.SelectedModelTypeSmall {
-		modelConfig = cfg.Models[config.SelectedModelTypeSmall]
-	}
+	modelConfig := o.providerOptions.modelConfig()
 
 	reasoningEffort := modelConfig.ReasoningEffort
 
@@ -265,19 +251,14 @@ func (o *openaiClient) send(ctx context.Context, messages []message.Message, too
 		// If there is an error we are going to see if we can retry the call
 		if err != nil {
 			retry, after, retryErr := o.shouldRetry(attempts, err)
-			if retryErr != nil {
+			if !retry {
 				return nil, retryErr
 			}
-			if retry {
-				slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries)
-				select {
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				case <-time.After(time.Duration(after) * time.Millisecond):
-					continue
-				}
+			o.providerOptions.retry.logRetry(attempts, after, err)
+			if err := wait(ctx, after); err != nil {
+				return nil, err
 			}
-			return nil, retryErr
+			continue
 		}
 
 		if len(openaiResponse.Choices) == 0 {
@@ -452,47 +433,29 @@ func (o *openaiClient) stream(ctx context.Context, messages []message.Message, t
 
 			// If there is an error we are going to see if we can retry the call
 			retry, after, retryErr := o.shouldRetry(attempts, err)
-			if retryErr != nil {
+			if !retry {
 				eventChan <- ProviderEvent{Type: EventError, Error: retryErr}
 				close(eventChan)
 				return
 			}
-			if retry {
-				slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries)
-				select {
-				case <-ctx.Done():
-					// context cancelled
-					if ctx.Err() == nil {
-						eventChan <- ProviderEvent{Type: EventError, Error: ctx.Err()}
-					}
-					close(eventChan)
-					return
-				case <-time.After(time.Duration(after) * time.Millisecond):
-					continue
-				}
+			eventChan <- ProviderEvent{Type: EventRetry, Retry: o.providerOptions.retry.logRetry(attempts, after, err)}
+			if err := wait(ctx, after); err != nil {
+				eventChan <- ProviderEvent{Type: EventError, Error: err}
+				close(eventChan)
+				return
 			}
-			eventChan <- ProviderEvent{Type: EventError, Error: retryErr}
-			close(eventChan)
-			return
 		}
 	}()
 
 	return eventChan
 }
 
-func (o *openaiClient) shouldRetry(attempts int, err error) (bool, int64, error) {
-	if attempts > maxRetries {
-		return false, 0, fmt.Errorf("maximum retry attempts reached for rate limit: %d retries", maxRetries)
-	}
-	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-		return false, 0, err
-	}
+func (o *openaiClient) shouldRetry(attempts int, err error) (bool, time.Duration, error) {
 	var apiErr *openai.Error
-	retryMs := 0
-	retryAfterValues := []string{}
 	if errors.As(err, &apiErr) {
+		slog.Warn("OpenAI API error", "status_code", apiErr.StatusCode, "message", apiErr.Message, "type", apiErr.Type)
 		// Check for token expiration (401 Unauthorized)
-		if apiErr.StatusCode == 401 {
+		if apiErr.StatusCode == 401 && attempts < o.providerOptions.retry.MaxAttempts {
 			o.providerOptions.apiKey, err = config.Get().Resolve(o.providerOptions.config.APIKey)
 			if err != nil {
 				return false, 0, fmt.Errorf("failed to resolve API key: %w", err)
@@ -500,32 +463,10 @@ func (o *openaiClient) shouldRetry(attempts int, err error) (bool, int64, error)
 			o.client = createOpenAIClient(o.providerOptions)
 			return true, 0, nil
 		}
-
-		if apiErr.StatusCode != 429 && apiErr.StatusCode != 500 {
-			return false, 0, err
-		}
-
-		retryAfterValues = apiErr.Response.Header.Values("Retry-After")
-	}
-
-	if apiErr != nil {
-		slog.Warn("OpenAI API error", "status_code", apiErr.StatusCode, "message", apiErr.Message, "type", apiErr.Type)
-		if len(retryAfterValues) > 0 {
-			slog.Warn("Retry-After header", "values", retryAfterValues)
-		}
-	} else {
-		slog.Error("OpenAI API error", "error", err.Error(), "attempt", attempts, "max_retries", maxRetries)
+	} else if !errors.Is(err, context.Canceled) {
+		slog.Error("OpenAI API error", "error", err.Error(), "attempt", attempts, "max_attempts", o.providerOptions.retry.MaxAttempts)
 	}
-
-	backoffMs := 2000 * (1 << (attempts - 1))
-	jitterMs := int(float64(backoffMs) * 0.2)
-	retryMs = backoffMs + jitterMs
-	if len(retryAfterValues) > 0 {
-		if _, err := fmt.Sscanf(retryAfterValues[0], "%d", &retryMs); err == nil {
-			retryMs = retryMs * 1000
-		}
-	}
-	return true, int64(retryMs), nil
+	return o.providerOptions.retry.shouldRetry(attempts, err)
 }
 
 func (o *openaiClient) toolCalls(completion openai.ChatCompletion) []message.ToolCall {
@@ -559,6 +500,10 @@ func (o *openaiClient) usage(completion openai.ChatCompletion) TokenUsage {
 	}
 }
 
+func (o *openaiClient) countTokens(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (int64, error) {
+	return openaiTokenizer.estimate(o.providerOptions.systemPrompt(), messages, o.convertTools(tools)), nil
+}
+
 func (o *openaiClient) Model() catwalk.Model {
 	return o.providerOptions.model(o.providerOptions.modelType)
 }
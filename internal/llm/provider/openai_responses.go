@@ -0,0 +1,348 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/responses"
+	"github.com/openai/openai-go/shared"
+)
+
+// openaiResponsesClient talks to the Responses API instead of Chat
+// Completions. Only it exposes the reasoning summaries of reasoning models.
+type openaiResponsesClient struct {
+	*openaiClient
+}
+
+func newOpenAIResponsesClient(opts providerClientOptions) OpenAIClient {
+	return &openaiResponsesClient{
+		openaiClient: &openaiClient{
+			providerOptions: opts,
+			client:          createOpenAIClient(opts),
+		},
+	}
+}
+
+func (o *openaiResponsesClient) convertMessages(messages []message.Message) responses.ResponseInputParam {
+	var input responses.ResponseInputParam
+	for _, msg := range messages {
+		switch msg.Role {
+		case message.User:
+			content := responses.ResponseInputMessageContentListParam{
+				{OfInputText: &responses.ResponseInputTextParam{Text: msg.Content().String()}},
+			}
+			for _, binaryContent := range msg.BinaryContent() {
+				content = append(content, responses.ResponseInputContentUnionParam{
+					OfInputImage: &responses.ResponseInputImageParam{
+						ImageURL: openai.String(binaryContent.String(catwalk.InferenceProviderOpenAI)),
+						Detail:   responses.ResponseInputImageDetailAuto,
+					},
+				})
+			}
+			input = append(input, responses.ResponseInputItemUnionParam{
+				OfMessage: &responses.EasyInputMessageParam{
+					Role:    responses.EasyInputMessageRoleUser,
+					Content: responses.EasyInputMessageContentUnionParam{OfInputItemContentList: content},
+				},
+			})
+
+		case message.Assistant:
+			// The reasoning goes back first, so the model picks up where it
+			// left off.
+			for _, item := range msg.ReasoningContent().Items {
+				if item.ID == "" || item.EncryptedContent == "" {
+					continue
+				}
+				summary := []responses.ResponseReasoningItemSummaryParam{}
+				for _, text := range item.Summary {
+					summary = append(summary, responses.ResponseReasoningItemSummaryParam{Text: text})
+				}
+				input = append(input, responses.ResponseInputItemUnionParam{
+					OfReasoning: &responses.ResponseReasoningItemParam{
+						ID:               item.ID,
+						Summary:          summary,
+						EncryptedContent: openai.String(item.EncryptedContent),
+					},
+				})
+			}
+			if text := msg.Content().String(); text != "" {
+				input = append(input, responses.ResponseInputItemUnionParam{
+					OfMessage: &responses.EasyInputMessageParam{
+						Role:    responses.EasyInputMessageRoleAssistant,
+						Content: responses.EasyInputMessageContentUnionParam{OfString: openai.String(text)},
+					},
+				})
+			}
+			for _, call := range msg.ToolCalls() {
+				input = append(input, responses.ResponseInputItemUnionParam{
+					OfFunctionCall: &responses.ResponseFunctionToolCallParam{
+						CallID:    call.ID,
+						Name:      call.Name,
+						Arguments: call.Input,
+					},
+				})
+			}
+
+		case message.Tool:
+			for _, result := range msg.ToolResults() {
+				input = append(input, responses.ResponseInputItemUnionParam{
+					OfFunctionCallOutput: &responses.ResponseInputItemFunctionCallOutputParam{
+						CallID: result.ToolCallID,
+						Output: result.Content,
+					},
+				})
+			}
+		}
+	}
+	return input
+}
+
+func (o *openaiResponsesClient) convertTools(tools []tools.BaseTool) []responses.ToolUnionParam {
+	responsesTools := make([]responses.ToolUnionParam, len(tools))
+	for i, tool := range tools {
+		info := tool.Info()
+		responsesTools[i] = responses.ToolUnionParam{
+			OfFunction: &responses.FunctionToolParam{
+				Name:        info.Name,
+				Description: openai.String(info.Description),
+				Parameters: map[string]any{
+					"type":       "object",
+					"properties": info.Parameters,
+					"required":   info.Required,
+				},
+				Strict: openai.Bool(false),
+			},
+		}
+	}
+	return responsesTools
+}
+
+func (o *openaiResponsesClient) preparedParams(input responses.ResponseInputParam, tools []responses.ToolUnionParam) responses.ResponseNewParams {
+	model := o.providerOptions.model(o.providerOptions.modelType)
+	modelConfig := o.providerOptions.modelConfig()
+
+	systemMessage := o.providerOptions.systemMessage
+	if o.providerOptions.systemPromptPrefix != "" {
+		systemMessage = o.providerOptions.systemPromptPrefix + "\n" + systemMessage
+	}
+
+	maxTokens := model.DefaultMaxTokens
+	if modelConfig.MaxTokens > 0 {
+		maxTokens = modelConfig.MaxTokens
+	}
+	// Override max tokens if set in provider options
+	if o.providerOptions.maxTokens > 0 {
+		maxTokens = o.providerOptions.maxTokens
+	}
+
+	params := responses.ResponseNewParams{
+		Model:           shared.ResponsesModel(model.ID),
+		Instructions:    openai.String(systemMessage),
+		Input:           responses.ResponseNewParamsInputUnion{OfInputItemList: input},
+		Tools:           tools,
+		MaxOutputTokens: openai.Int(maxTokens),
+		// Nothing is kept on OpenAI's side, the reasoning is carried over
+		// between turns through its encrypted content instead.
+		Store: openai.Bool(false),
+	}
+	if model.CanReason {
+		params.Reasoning = shared.ReasoningParam{
+			Effort:  shared.ReasoningEffort(modelConfig.ReasoningEffort),
+			Summary: shared.ReasoningSummaryAuto,
+		}
+		params.Include = []responses.ResponseIncludable{responses.ResponseIncludableReasoningEncryptedContent}
+	}
+	return params
+}
+
+func (o *openaiResponsesClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	params := o.preparedParams(o.convertMessages(messages), o.convertTools(tools))
+	attempts := 0
+	for {
+		attempts++
+		response, err := o.client.Responses.New(ctx, params)
+		if err != nil {
+			retry, after, retryErr := o.shouldRetry(attempts, err)
+			if !retry {
+				return nil, retryErr
+			}
+			o.providerOptions.retry.logRetry(attempts, after, err)
+			if err := wait(ctx, after); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return o.providerResponse(response), nil
+	}
+}
+
+func (o *openaiResponsesClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	params := o.preparedParams(o.convertMessages(messages), o.convertTools(tools))
+	attempts := 0
+	eventChan := make(chan ProviderEvent)
+
+	go func() {
+		defer close(eventChan)
+		for {
+			attempts++
+			responsesStream := o.client.Responses.NewStreaming(ctx, params)
+
+			// Arguments deltas refer to the function call item, not the call.
+			callIDs := map[string]string{}
+			// The summaries of all the reasoning items make up the thinking.
+			thinking := false
+			var final *responses.Response
+			var streamErr error
+			for responsesStream.Next() {
+				event := responsesStream.Current()
+				switch event.Type {
+				case "response.reasoning_summary_part.added":
+					if thinking {
+						eventChan <- ProviderEvent{Type: EventThinkingDelta, Thinking: "\n\n"}
+					}
+				case "response.reasoning_summary_text.delta":
+					thinking = true
+					eventChan <- ProviderEvent{Type: EventThinkingDelta, Thinking: event.AsResponseReasoningSummaryTextDelta().Delta}
+				case "response.output_text.delta":
+					eventChan <- ProviderEvent{Type: EventContentDelta, Content: event.AsResponseOutputTextDelta().Delta}
+				case "response.output_item.added":
+					item := event.AsResponseOutputItemAdded().Item
+					if item.Type == "function_call" {
+						call := item.AsFunctionCall()
+						callIDs[call.ID] = call.CallID
+						eventChan <- ProviderEvent{
+							Type:     EventToolUseStart,
+							ToolCall: &message.ToolCall{ID: call.CallID, Name: call.Name},
+						}
+					}
+				case "response.function_call_arguments.delta":
+					delta := event.AsResponseFunctionCallArgumentsDelta()
+					eventChan <- ProviderEvent{
+						Type:     EventToolUseDelta,
+						ToolCall: &message.ToolCall{ID: callIDs[delta.ItemID], Input: delta.Delta},
+					}
+				case "response.output_item.done":
+					item := event.AsResponseOutputItemDone().Item
+					switch item.Type {
+					case "function_call":
+						call := item.AsFunctionCall()
+						eventChan <- ProviderEvent{
+							Type:     EventToolUseStop,
+							ToolCall: &message.ToolCall{ID: call.CallID, Name: call.Name, Input: call.Arguments, Finished: true},
+						}
+					case "reasoning":
+						reasoning := item.AsReasoning()
+						var summary []string
+						for _, part := range reasoning.Summary {
+							summary = append(summary, part.Text)
+						}
+						eventChan <- ProviderEvent{
+							Type: EventReasoningItem,
+							Reasoning: &message.ReasoningItem{
+								ID:               reasoning.ID,
+								EncryptedContent: reasoning.EncryptedContent,
+								Summary:          summary,
+							},
+						}
+					}
+				case "response.completed":
+					response := event.AsResponseCompleted().Response
+					final = &response
+				case "response.incomplete":
+					response := event.AsResponseIncomplete().Response
+					final = &response
+				case "response.failed":
+					streamErr = fmt.Errorf("response failed: %s", event.AsResponseFailed().Response.Error.Message)
+				case "error":
+					streamErr = fmt.Errorf("response error: %s", event.AsError().Message)
+				}
+			}
+
+			err := responsesStream.Err()
+			if err == nil || errors.Is(err, io.EOF) {
+				err = streamErr
+			}
+			if err == nil {
+				if final == nil {
+					eventChan <- ProviderEvent{
+						Type:  EventError,
+						Error: errors.New("received empty streaming response from OpenAI API - check endpoint configuration"),
+					}
+					return
+				}
+				eventChan <- ProviderEvent{Type: EventComplete, Response: o.providerResponse(final)}
+				return
+			}
+
+			retry, after, retryErr := o.shouldRetry(attempts, err)
+			if !retry {
+				eventChan <- ProviderEvent{Type: EventError, Error: retryErr}
+				return
+			}
+			eventChan <- ProviderEvent{Type: EventRetry, Retry: o.providerOptions.retry.logRetry(attempts, after, err)}
+			if err := wait(ctx, after); err != nil {
+				eventChan <- ProviderEvent{Type: EventError, Error: err}
+				return
+			}
+		}
+	}()
+
+	return eventChan
+}
+
+func (o *openaiResponsesClient) countTokens(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (int64, error) {
+	return openaiTokenizer.estimate(o.providerOptions.systemPrompt(), messages, o.convertTools(tools)), nil
+}
+
+func (o *openaiResponsesClient) providerResponse(response *responses.Response) *ProviderResponse {
+	var content strings.Builder
+	var toolCalls []message.ToolCall
+	for _, item := range response.Output {
+		switch item.Type {
+		case "message":
+			for _, part := range item.AsMessage().Content {
+				if part.Type == "output_text" {
+					content.WriteString(part.Text)
+				}
+			}
+		case "function_call":
+			call := item.AsFunctionCall()
+			toolCalls = append(toolCalls, message.ToolCall{
+				ID:       call.CallID,
+				Name:     call.Name,
+				Input:    call.Arguments,
+				Type:     "function",
+				Finished: true,
+			})
+		}
+	}
+
+	finishReason := message.FinishReasonEndTurn
+	switch {
+	case len(toolCalls) > 0:
+		finishReason = message.FinishReasonToolUse
+	case response.IncompleteDetails.Reason == "max_output_tokens":
+		finishReason = message.FinishReasonMaxTokens
+	case response.Status == "incomplete":
+		finishReason = message.FinishReasonUnknown
+	}
+
+	cachedTokens := response.Usage.InputTokensDetails.CachedTokens
+	return &ProviderResponse{
+		Content:   content.String(),
+		ToolCalls: toolCalls,
+		Usage: TokenUsage{
+			InputTokens:     response.Usage.InputTokens - cachedTokens,
+			OutputTokens:    response.Usage.OutputTokens,
+			CacheReadTokens: cachedTokens,
+		},
+		FinishReason: finishReason,
+	}
+}
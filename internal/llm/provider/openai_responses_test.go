@@ -0,0 +1,144 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestResponsesClient(baseURL string) *openaiResponsesClient {
+	return &openaiResponsesClient{
+		openaiClient: &openaiClient{
+			providerOptions: providerClientOptions{
+				modelType:     config.SelectedModelTypeLarge,
+				apiKey:        "test-key",
+				systemMessage: "test",
+				model: func(config.SelectedModelType) catwalk.Model {
+					return catwalk.Model{ID: "o4-mini", CanReason: true}
+				},
+				selectedModel: &config.SelectedModel{Model: "o4-mini", ReasoningEffort: "high"},
+			},
+			client: openai.NewClient(
+				option.WithAPIKey("test-key"),
+				option.WithBaseURL(baseURL),
+				option.WithMaxRetries(0),
+			),
+		},
+	}
+}
+
+func TestOpenAIResponsesClientStream(t *testing.T) {
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+
+		reasoning := map[string]any{"type": "reasoning", "id": "rs_1", "summary": []any{map[string]any{"type": "summary_text", "text": "Listing"}, map[string]any{"type": "summary_text", "text": "files"}}, "encrypted_content": "secret"}
+		second := map[string]any{"type": "reasoning", "id": "rs_2", "summary": []any{map[string]any{"type": "summary_text", "text": "Checking"}}, "encrypted_content": "more"}
+		call := map[string]any{"type": "function_call", "id": "fc_1", "call_id": "call_1", "name": "ls", "arguments": `{"path":"."}`, "status": "completed"}
+		events := []map[string]any{
+			{"type": "response.output_item.added", "output_index": 0, "item": map[string]any{"type": "reasoning", "id": "rs_1", "summary": []any{}}},
+			{"type": "response.reasoning_summary_part.added", "item_id": "rs_1", "output_index": 0, "summary_index": 0, "part": map[string]any{"type": "summary_text", "text": ""}},
+			{"type": "response.reasoning_summary_text.delta", "item_id": "rs_1", "output_index": 0, "summary_index": 0, "delta": "Listing"},
+			{"type": "response.reasoning_summary_part.added", "item_id": "rs_1", "output_index": 0, "summary_index": 1, "part": map[string]any{"type": "summary_text", "text": ""}},
+			{"type": "response.reasoning_summary_text.delta", "item_id": "rs_1", "output_index": 0, "summary_index": 1, "delta": "files"},
+			{"type": "response.output_item.done", "output_index": 0, "item": reasoning},
+			{"type": "response.output_item.added", "output_index": 1, "item": map[string]any{"type": "reasoning", "id": "rs_2", "summary": []any{}}},
+			{"type": "response.reasoning_summary_part.added", "item_id": "rs_2", "output_index": 1, "summary_index": 0, "part": map[string]any{"type": "summary_text", "text": ""}},
+			{"type": "response.reasoning_summary_text.delta", "item_id": "rs_2", "output_index": 1, "summary_index": 0, "delta": "Checking"},
+			{"type": "response.output_item.done", "output_index": 1, "item": second},
+			{"type": "response.output_item.added", "output_index": 2, "item": map[string]any{"type": "function_call", "id": "fc_1", "call_id": "call_1", "name": "ls", "arguments": ""}},
+			{"type": "response.function_call_arguments.delta", "item_id": "fc_1", "output_index": 2, "delta": `{"path":"."}`},
+			{"type": "response.output_item.done", "output_index": 2, "item": call},
+			{"type": "response.completed", "response": map[string]any{
+				"id":     "resp_1",
+				"object": "response",
+				"status": "completed",
+				"output": []any{reasoning, second, call},
+				"usage": map[string]any{
+					"input_tokens":          100,
+					"input_tokens_details":  map[string]any{"cached_tokens": 40},
+					"output_tokens":         20,
+					"output_tokens_details": map[string]any{"reasoning_tokens": 10},
+					"total_tokens":          120,
+				},
+			}},
+		}
+		for i, event := range events {
+			event["sequence_number"] = i
+			data, _ := json.Marshal(event)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event["type"], data)
+		}
+	}))
+	defer server.Close()
+
+	client := newTestResponsesClient(server.URL)
+	messages := []message.Message{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "What is here?"}}},
+		{Role: message.Assistant, Parts: []message.ContentPart{
+			message.ReasoningContent{Thinking: "Looking\n\nAgain", Items: []message.ReasoningItem{
+				{ID: "rs_0", EncryptedContent: "earlier", Summary: []string{"Looking"}},
+				{ID: "rs_00", EncryptedContent: "again", Summary: []string{"Again"}},
+			}},
+			message.ToolCall{ID: "call_0", Name: "pwd", Input: "{}"},
+		}},
+		{Role: message.Tool, Parts: []message.ContentPart{message.ToolResult{ToolCallID: "call_0", Content: "/tmp"}}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var thinking string
+	var reasoning []message.ReasoningItem
+	var response *ProviderResponse
+	for event := range client.stream(ctx, messages, nil) {
+		switch event.Type {
+		case EventThinkingDelta:
+			thinking += event.Thinking
+		case EventReasoningItem:
+			reasoning = append(reasoning, *event.Reasoning)
+		case EventComplete:
+			response = event.Response
+		case EventError:
+			require.NoError(t, event.Error)
+		}
+	}
+
+	require.Equal(t, "Listing\n\nfiles\n\nChecking", thinking)
+	require.Equal(t, []message.ReasoningItem{
+		{ID: "rs_1", EncryptedContent: "secret", Summary: []string{"Listing", "files"}},
+		{ID: "rs_2", EncryptedContent: "more", Summary: []string{"Checking"}},
+	}, reasoning)
+	require.NotNil(t, response)
+	require.Equal(t, message.FinishReasonToolUse, response.FinishReason)
+	require.Equal(t, []message.ToolCall{{ID: "call_1", Name: "ls", Input: `{"path":"."}`, Type: "function", Finished: true}}, response.ToolCalls)
+	require.Equal(t, TokenUsage{InputTokens: 60, OutputTokens: 20, CacheReadTokens: 40}, response.Usage)
+
+	// The earlier reasoning items are sent back ahead of their tool call.
+	require.Equal(t, false, request["store"])
+	require.Equal(t, []any{"reasoning.encrypted_content"}, request["include"])
+	require.Equal(t, map[string]any{"effort": "high", "summary": "auto"}, request["reasoning"])
+	input := request["input"].([]any)
+	require.Len(t, input, 5)
+	require.Equal(t, "reasoning", input[1].(map[string]any)["type"])
+	require.Equal(t, "rs_0", input[1].(map[string]any)["id"])
+	require.Equal(t, "earlier", input[1].(map[string]any)["encrypted_content"])
+	require.Equal(t, []any{map[string]any{"type": "summary_text", "text": "Looking"}}, input[1].(map[string]any)["summary"])
+	require.Equal(t, "reasoning", input[2].(map[string]any)["type"])
+	require.Equal(t, "rs_00", input[2].(map[string]any)["id"])
+	require.Equal(t, "again", input[2].(map[string]any)["encrypted_content"])
+	require.Equal(t, "function_call", input[3].(map[string]any)["type"])
+	require.Equal(t, "function_call_output", input[4].(map[string]any)["type"])
+}
@@ -3,17 +3,17 @@ package provider
 import (
 	"context"
 	"fmt"
+	"net/http"
 
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/log"
 	"github.com/charmbracelet/crush/internal/message"
 )
 
 type EventType string
 
-const maxRetries = 8
-
 const (
 	EventContentStart   EventType = "content_start"
 	EventToolUseStart   EventType = "tool_use_start"
@@ -26,6 +26,9 @@ const (
 	EventComplete       EventType = "complete"
 	EventError          EventType = "error"
 	EventWarning        EventType = "warning"
+	EventRetry          EventType = "retry"
+	// The reasoning item of the OpenAI Responses API is done.
+	EventReasoningItem EventType = "reasoning_item"
 )
 
 type TokenUsage struct {
@@ -51,12 +54,19 @@ type ProviderEvent struct {
 	Response  *ProviderResponse
 	ToolCall  *message.ToolCall
 	Error     error
+	Retry     *RetryInfo
+	Reasoning *message.ReasoningItem
 }
 type Provider interface {
 	SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error)
 
 	StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent
 
+	// CountTokens returns the number of input tokens a request with the
+	// messages and tools would take, estimated when the provider can't count
+	// them.
+	CountTokens(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (int64, error)
+
 	Model() catwalk.Model
 }
 
@@ -66,6 +76,7 @@ type providerClientOptions struct {
 	apiKey             string
 	modelType          config.SelectedModelType
 	model              func(config.SelectedModelType) catwalk.Model
+	selectedModel      *config.SelectedModel
 	disableCache       bool
 	systemMessage      string
 	systemPromptPrefix string
@@ -73,13 +84,25 @@ type providerClientOptions struct {
 	extraHeaders       map[string]string
 	extraBody          map[string]any
 	extraParams        map[string]string
+	retry              RetryPolicy
+	// Nil when the SDK's default client will do.
+	httpClient *http.Client
 }
 
 type ProviderClientOption func(*providerClientOptions)
 
+// modelConfig returns the user's settings for the model the client talks to.
+func (o providerClientOptions) modelConfig() config.SelectedModel {
+	if o.selectedModel != nil {
+		return *o.selectedModel
+	}
+	return config.Get().Models[o.modelType]
+}
+
 type ProviderClient interface {
 	send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error)
 	stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent
+	countTokens(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (int64, error)
 
 	Model() catwalk.Model
 }
@@ -87,6 +110,7 @@ type ProviderClient interface {
 type baseProvider[C ProviderClient] struct {
 	options providerClientOptions
 	client  C
+	breaker *circuitBreaker
 }
 
 func (p *baseProvider[C]) cleanMessages(messages []message.Message) (cleaned []message.Message) {
@@ -102,12 +126,61 @@ func (p *baseProvider[C]) cleanMessages(messages []message.Message) (cleaned []m
 
 func (p *baseProvider[C]) SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
 	messages = p.cleanMessages(messages)
-	return p.client.send(ctx, messages, tools)
+	if err := p.breaker.allow(); err != nil {
+		return nil, err
+	}
+	response, err := p.client.send(ctx, messages, tools)
+	p.breaker.record(err)
+	return response, err
 }
 
 func (p *baseProvider[C]) StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
 	messages = p.cleanMessages(messages)
-	return p.client.stream(ctx, messages, tools)
+	if err := p.breaker.allow(); err != nil {
+		eventChan := make(chan ProviderEvent, 1)
+		eventChan <- ProviderEvent{Type: EventError, Error: err}
+		close(eventChan)
+		return eventChan
+	}
+	if p.breaker == nil {
+		return p.client.stream(ctx, messages, tools)
+	}
+
+	events := p.client.stream(ctx, messages, tools)
+	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		recorded := false
+		for event := range events {
+			switch event.Type {
+			case EventComplete:
+				p.breaker.record(nil)
+				recorded = true
+			case EventError:
+				p.breaker.record(event.Error)
+				recorded = true
+			}
+			select {
+			case eventChan <- event:
+			case <-ctx.Done():
+				// Nobody is listening anymore, let the client wind down.
+				for range events {
+				}
+				if !recorded {
+					p.breaker.record(ctx.Err())
+				}
+				return
+			}
+		}
+		if !recorded {
+			p.breaker.record(ctx.Err())
+		}
+	}()
+	return eventChan
+}
+
+func (p *baseProvider[C]) CountTokens(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (int64, error) {
+	return p.client.countTokens(ctx, p.cleanMessages(messages), tools)
 }
 
 func (p *baseProvider[C]) Model() catwalk.Model {
@@ -120,6 +193,20 @@ func WithModel(model config.SelectedModelType) ProviderClientOption {
 	}
 }
 
+// WithSelectedModel makes the client use the given model and settings instead
+// of the ones selected for its model type, e.g. for fallback models.
+func WithSelectedModel(model config.SelectedModel) ProviderClientOption {
+	return func(options *providerClientOptions) {
+		options.selectedModel = &model
+		options.model = func(config.SelectedModelType) catwalk.Model {
+			if m := config.Get().GetModel(model.Provider, model.Model); m != nil {
+				return *m
+			}
+			return catwalk.Model{ID: model.Model, Name: model.Model}
+		}
+	}
+}
+
 func WithDisableCache(disableCache bool) ProviderClientOption {
 	return func(options *providerClientOptions) {
 		options.disableCache = disableCache
@@ -165,41 +252,75 @@ func NewProvider(cfg config.ProviderConfig, opts ...ProviderClientOption) (Provi
 		model: func(tp config.SelectedModelType) catwalk.Model {
 			return *config.Get().GetModelByType(tp)
 		},
+		retry: newRetryPolicy(config.Get().Options.Retry),
 	}
 	for _, o := range opts {
 		o(&clientOptions)
 	}
+	if httpCfg := cfg.HTTP(); !httpCfg.IsZero() || config.Get().Options.Debug {
+		transport, err := httpCfg.Transport()
+		if err != nil {
+			return nil, fmt.Errorf("invalid HTTP settings for provider %s: %w", cfg.ID, err)
+		}
+		clientOptions.httpClient = log.NewHTTPClient(transport)
+	}
+	breaker := breakerFor(cfg.ID, config.Get().Options.Retry)
 	switch cfg.Type {
 	case catwalk.TypeAnthropic:
 		return &baseProvider[AnthropicClient]{
 			options: clientOptions,
 			client:  newAnthropicClient(clientOptions, AnthropicClientTypeNormal),
+			breaker: breaker,
 		}, nil
 	case catwalk.TypeOpenAI:
+		if cfg.ResponsesAPI {
+			return &baseProvider[OpenAIClient]{
+				options: clientOptions,
+				client:  newOpenAIResponsesClient(clientOptions),
+				breaker: breaker,
+			}, nil
+		}
 		return &baseProvider[OpenAIClient]{
 			options: clientOptions,
 			client:  newOpenAIClient(clientOptions),
+			breaker: breaker,
 		}, nil
 	case catwalk.TypeGemini:
 		return &baseProvider[GeminiClient]{
 			options: clientOptions,
 			client:  newGeminiClient(clientOptions),
+			breaker: breaker,
 		}, nil
 	case catwalk.TypeBedrock:
+		client, err := newBedrockClient(clientOptions)
+		if err != nil {
+			return nil, err
+		}
 		return &baseProvider[BedrockClient]{
 			options: clientOptions,
-			client:  newBedrockClient(clientOptions),
+			client:  client,
+			breaker: breaker,
 		}, nil
 	case catwalk.TypeAzure:
 		return &baseProvider[AzureClient]{
 			options: clientOptions,
 			client:  newAzureClient(clientOptions),
+			breaker: breaker,
 		}, nil
 	case catwalk.TypeVertexAI:
 		return &baseProvider[VertexAIClient]{
 			options: clientOptions,
 			client:  newVertexAIClient(clientOptions),
+			breaker: breaker,
+		}, nil
+	case config.TypeOllama:
+		return &baseProvider[OllamaClient]{
+			options: clientOptions,
+			client:  newOllamaClient(clientOptions),
+			breaker: breaker,
 		}, nil
+	case config.TypeReplay:
+		return newReplayProvider(cfg, clientOptions, opts)
 	}
 	return nil, fmt.Errorf("provider not supported: %s", cfg.Type)
 }
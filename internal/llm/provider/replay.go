@@ -0,0 +1,407 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+// ErrNoRecording is returned when replaying a request that was not recorded.
+var ErrNoRecording = errors.New("no recorded response for request")
+
+// cassette holds the responses recorded for requests, keyed by the hash of the
+// request. Requests made several times get their responses in the order they
+// were recorded in.
+type cassette struct {
+	path string
+
+	mu           sync.Mutex
+	Interactions []interaction `json:"interactions"`
+	played       map[string]int
+}
+
+type interaction struct {
+	Request string          `json:"request"`
+	Events  []recordedEvent `json:"events"`
+}
+
+// recordedEvent is a ProviderEvent as it is written to a cassette.
+type recordedEvent struct {
+	Type      EventType         `json:"type"`
+	Content   string            `json:"content,omitempty"`
+	Thinking  string            `json:"thinking,omitempty"`
+	Signature string            `json:"signature,omitempty"`
+	Response  *ProviderResponse `json:"response,omitempty"`
+	ToolCall  *message.ToolCall `json:"tool_call,omitempty"`
+	Error     string            `json:"error,omitempty"`
+
+	Reasoning *message.ReasoningItem `json:"reasoning,omitempty"`
+
+	// What the agent checks errors for, kept so that it handles replayed
+	// errors like the recorded ones.
+	ErrorKind  string `json:"error_kind,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+}
+
+// errorKinds are the errors whose identity is kept in cassettes.
+var errorKinds = []struct {
+	kind string
+	err  error
+}{
+	{"max_retries", ErrMaxRetries},
+	{"circuit_open", ErrCircuitOpen},
+	{"no_recording", ErrNoRecording},
+	{"deadline_exceeded", context.DeadlineExceeded},
+}
+
+// replayedError is an error played back from a cassette. It wraps the error
+// of its kind and carries the status code of the recorded one.
+type replayedError struct {
+	message    string
+	kind       error
+	statusCode int
+}
+
+func (e *replayedError) Error() string { return e.message }
+
+func (e *replayedError) Unwrap() error { return e.kind }
+
+func newRecordedEvent(event ProviderEvent) recordedEvent {
+	recorded := recordedEvent{
+		Type:      event.Type,
+		Content:   event.Content,
+		Thinking:  event.Thinking,
+		Signature: event.Signature,
+		Response:  event.Response,
+		ToolCall:  event.ToolCall,
+		Reasoning: event.Reasoning,
+	}
+	if event.Error != nil {
+		recorded.Error = event.Error.Error()
+		recorded.StatusCode, _ = errorStatus(event.Error)
+		for _, k := range errorKinds {
+			if errors.Is(event.Error, k.err) {
+				recorded.ErrorKind = k.kind
+				break
+			}
+		}
+	}
+	return recorded
+}
+
+func (e recordedEvent) event() ProviderEvent {
+	event := ProviderEvent{
+		Type:      e.Type,
+		Content:   e.Content,
+		Thinking:  e.Thinking,
+		Signature: e.Signature,
+		Response:  e.Response,
+		ToolCall:  e.ToolCall,
+		Reasoning: e.Reasoning,
+	}
+	if e.Error != "" {
+		err := &replayedError{message: e.Error, statusCode: e.StatusCode}
+		for _, k := range errorKinds {
+			if e.ErrorKind == k.kind {
+				err.kind = k.err
+			}
+		}
+		event.Error = err
+	}
+	return event
+}
+
+var (
+	cassettesMu sync.Mutex
+	cassettes   = map[string]*cassette{}
+)
+
+// openCassette returns the cassette at path, shared by all the providers using
+// it. A cassette opened for recording starts out empty, so recording again
+// replaces the previous recordings.
+func openCassette(path string, record bool) (*cassette, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	cassettesMu.Lock()
+	defer cassettesMu.Unlock()
+	if c, ok := cassettes[path]; ok {
+		return c, nil
+	}
+	c := &cassette{path: path, played: map[string]int{}}
+	if !record {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cassette: %w", err)
+		}
+		if err := json.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+		}
+	}
+	cassettes[path] = c
+	return c, nil
+}
+
+// record adds the events of a request to the cassette and saves it.
+func (c *cassette) record(request string, events []recordedEvent) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Interactions = append(c.Interactions, interaction{Request: request, Events: events})
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0o644)
+}
+
+// play returns the events recorded for the next request with the given hash.
+// Once all of them are played, the last one is played again.
+func (c *cassette) play(request string) ([]recordedEvent, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var matches []interaction
+	for _, i := range c.Interactions {
+		if i.Request == request {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, false
+	}
+	n := min(c.played[request], len(matches)-1)
+	c.played[request]++
+	return matches[n].Events, true
+}
+
+// requestHash identifies a request by what the model gets to see. Message IDs,
+// timestamps and the system prompt, which holds the date and the state of the
+// working directory, are left out so recordings keep matching.
+func requestHash(model string, messages []message.Message, tools []tools.BaseTool) string {
+	type part struct {
+		Type    string `json:"type"`
+		ID      string `json:"id,omitempty"`
+		Name    string `json:"name,omitempty"`
+		Text    string `json:"text,omitempty"`
+		IsError bool   `json:"is_error,omitempty"`
+	}
+	type request struct {
+		Model    string   `json:"model"`
+		Tools    []string `json:"tools"`
+		Messages [][]part `json:"messages"`
+	}
+	r := request{Model: model}
+	for _, tool := range tools {
+		r.Tools = append(r.Tools, tool.Name())
+	}
+	slices.Sort(r.Tools)
+	for _, msg := range messages {
+		parts := []part{{Type: string(msg.Role)}}
+		for _, p := range msg.Parts {
+			switch p := p.(type) {
+			case message.TextContent:
+				parts = append(parts, part{Type: "text", Text: p.Text})
+			case message.ReasoningContent:
+				parts = append(parts, part{Type: "reasoning", Text: p.Thinking})
+			case message.ImageURLContent:
+				parts = append(parts, part{Type: "image_url", Text: p.URL})
+			case message.BinaryContent:
+				sum := sha256.Sum256(p.Data)
+				parts = append(parts, part{Type: "binary", Name: p.MIMEType, Text: hex.EncodeToString(sum[:])})
+			case message.ToolCall:
+				parts = append(parts, part{Type: "tool_call", ID: p.ID, Name: p.Name, Text: p.Input})
+			case message.ToolResult:
+				parts = append(parts, part{Type: "tool_result", ID: p.ToolCallID, Name: p.Name, Text: p.Content, IsError: p.IsError})
+			}
+		}
+		r.Messages = append(r.Messages, parts)
+	}
+	data, _ := json.Marshal(r)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// replayProvider records the responses of another provider to a cassette, or
+// plays recorded responses back when it has none to record.
+type replayProvider struct {
+	cassette *cassette
+	recorded Provider
+	model    func() catwalk.Model
+}
+
+// NewRecordingProvider returns a provider that sends requests to the given one
+// and records its responses to the cassette at path.
+func NewRecordingProvider(recorded Provider, path string) (Provider, error) {
+	c, err := openCassette(path, true)
+	if err != nil {
+		return nil, err
+	}
+	return &replayProvider{
+		cassette: c,
+		recorded: recorded,
+		model:    recorded.Model,
+	}, nil
+}
+
+// NewReplayProvider returns a provider that answers requests with the
+// responses recorded to the cassette at path, without any network access.
+func NewReplayProvider(path string, model catwalk.Model) (Provider, error) {
+	c, err := openCassette(path, false)
+	if err != nil {
+		return nil, err
+	}
+	return &replayProvider{
+		cassette: c,
+		model:    func() catwalk.Model { return model },
+	}, nil
+}
+
+func newReplayProvider(cfg config.ProviderConfig, options providerClientOptions, opts []ProviderClientOption) (Provider, error) {
+	if cfg.Replay == nil {
+		return nil, fmt.Errorf("missing replay settings for provider %s", cfg.ID)
+	}
+	path := cfg.Replay.Cassette
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(config.Get().WorkingDir(), path)
+	}
+	if cfg.Replay.Mode != config.ReplayModeRecord {
+		return NewReplayProvider(path, options.model(options.modelType))
+	}
+
+	recordedConfig, ok := config.Get().Providers.Get(cfg.Replay.Provider)
+	if !ok {
+		return nil, fmt.Errorf("provider to record not found: %s", cfg.Replay.Provider)
+	}
+	recorded, err := NewProvider(recordedConfig, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewRecordingProvider(recorded, path)
+}
+
+func (p *replayProvider) SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	request := requestHash(p.Model().ID, messages, tools)
+	if p.recorded != nil {
+		response, err := p.recorded.SendMessages(ctx, messages, tools)
+		switch {
+		case err == nil:
+			p.save(request, []recordedEvent{newRecordedEvent(ProviderEvent{Type: EventComplete, Response: response})})
+		case !errors.Is(err, context.Canceled):
+			p.save(request, []recordedEvent{newRecordedEvent(ProviderEvent{Type: EventError, Error: err})})
+		}
+		return response, err
+	}
+
+	events, ok := p.cassette.play(request)
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrNoRecording, request)
+	}
+	for _, e := range events {
+		switch event := e.event(); event.Type {
+		case EventComplete:
+			return event.Response, nil
+		case EventError:
+			return nil, event.Error
+		}
+	}
+	return nil, fmt.Errorf("recorded response for request %s did not complete", request)
+}
+
+func (p *replayProvider) StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	request := requestHash(p.Model().ID, messages, tools)
+	if p.recorded != nil {
+		return p.record(ctx, request, p.recorded.StreamResponse(ctx, messages, tools))
+	}
+
+	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		events, ok := p.cassette.play(request)
+		if !ok {
+			events = []recordedEvent{newRecordedEvent(ProviderEvent{
+				Type:  EventError,
+				Error: fmt.Errorf("%w %s", ErrNoRecording, request),
+			})}
+		}
+		for _, e := range events {
+			select {
+			case eventChan <- e.event():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return eventChan
+}
+
+// CountTokens estimates the size of replayed requests, as there is no provider
+// to ask.
+func (p *replayProvider) CountTokens(ctx context.Context, messages []message.Message, toolset []tools.BaseTool) (int64, error) {
+	if p.recorded != nil {
+		return p.recorded.CountTokens(ctx, messages, toolset)
+	}
+	infos := make([]tools.ToolInfo, len(toolset))
+	for i, tool := range toolset {
+		infos[i] = tool.Info()
+	}
+	return defaultTokenizer.estimate("", messages, infos), nil
+}
+
+// record passes the events of a request on and saves them once the request
+// completes or fails. Only the last attempt of retried requests is saved,
+// canceled requests are not.
+func (p *replayProvider) record(ctx context.Context, request string, events <-chan ProviderEvent) <-chan ProviderEvent {
+	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		var recorded []recordedEvent
+		for event := range events {
+			switch {
+			case event.Type == EventRetry:
+				// The events of the failed attempt are dropped.
+				recorded = nil
+			case event.Type == EventComplete,
+				event.Type == EventError && !errors.Is(event.Error, context.Canceled):
+				recorded = append(recorded, newRecordedEvent(event))
+				p.save(request, recorded)
+			default:
+				recorded = append(recorded, newRecordedEvent(event))
+			}
+			select {
+			case eventChan <- event:
+			case <-ctx.Done():
+				for range events {
+				}
+				return
+			}
+		}
+	}()
+	return eventChan
+}
+
+func (p *replayProvider) save(request string, events []recordedEvent) {
+	if err := p.cassette.record(request, events); err != nil {
+		// Recording must not get in the way of the session.
+		slog.Error("Failed to save cassette", "path", p.cassette.path, "error", err)
+	}
+}
+
+func (p *replayProvider) Model() catwalk.Model {
+	return p.model()
+}
@@ -0,0 +1,182 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedProvider answers every request with the same events.
+type scriptedProvider struct {
+	events []ProviderEvent
+	calls  int
+}
+
+func (p *scriptedProvider) SendMessages(context.Context, []message.Message, []tools.BaseTool) (*ProviderResponse, error) {
+	p.calls++
+	for _, event := range p.events {
+		if event.Type == EventComplete {
+			return event.Response, nil
+		}
+	}
+	return nil, errors.New("no response")
+}
+
+func (p *scriptedProvider) StreamResponse(context.Context, []message.Message, []tools.BaseTool) <-chan ProviderEvent {
+	p.calls++
+	eventChan := make(chan ProviderEvent, len(p.events))
+	for _, event := range p.events {
+		eventChan <- event
+	}
+	close(eventChan)
+	return eventChan
+}
+
+func (p *scriptedProvider) CountTokens(context.Context, []message.Message, []tools.BaseTool) (int64, error) {
+	return 0, nil
+}
+
+func (p *scriptedProvider) Model() catwalk.Model {
+	return catwalk.Model{ID: "scripted"}
+}
+
+func collect(events <-chan ProviderEvent) []ProviderEvent {
+	var collected []ProviderEvent
+	for event := range events {
+		collected = append(collected, event)
+	}
+	return collected
+}
+
+func TestReplayProvider(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	response := &ProviderResponse{
+		Content:      "Hello!",
+		ToolCalls:    []message.ToolCall{{ID: "call_1", Name: "view", Input: `{"file_path":"main.go"}`, Finished: true}},
+		Usage:        TokenUsage{InputTokens: 10, OutputTokens: 5},
+		FinishReason: message.FinishReasonToolUse,
+	}
+	scripted := &scriptedProvider{events: []ProviderEvent{
+		{Type: EventContentStart},
+		{Type: EventContentDelta, Content: "Hello!"},
+		{Type: EventRetry, Retry: &RetryInfo{Attempt: 2}},
+		{Type: EventContentDelta, Content: "Hello!"},
+		{Type: EventComplete, Response: response},
+	}}
+	messages := []message.Message{{
+		ID:        "first-run",
+		Role:      message.User,
+		Parts:     []message.ContentPart{message.TextContent{Text: "Hi"}},
+		CreatedAt: 1,
+	}}
+
+	recorder, err := NewRecordingProvider(scripted, path)
+	require.NoError(t, err)
+	recorded := collect(recorder.StreamResponse(t.Context(), messages, nil))
+	require.Equal(t, scripted.events, recorded)
+
+	// A fresh cassette reads the recording from disk.
+	cassettesMu.Lock()
+	delete(cassettes, path)
+	cassettesMu.Unlock()
+
+	replayer, err := NewReplayProvider(path, catwalk.Model{ID: "scripted"})
+	require.NoError(t, err)
+
+	t.Run("plays back the last attempt of the recorded request", func(t *testing.T) {
+		// IDs and timestamps do not take part in matching requests.
+		messages := []message.Message{{
+			ID:        "second-run",
+			Role:      message.User,
+			Parts:     []message.ContentPart{message.TextContent{Text: "Hi"}},
+			CreatedAt: 2,
+		}}
+		replayed := collect(replayer.StreamResponse(t.Context(), messages, nil))
+		require.Equal(t, []ProviderEvent{
+			{Type: EventContentDelta, Content: "Hello!"},
+			{Type: EventComplete, Response: response},
+		}, replayed)
+
+		got, err := replayer.SendMessages(t.Context(), messages, nil)
+		require.NoError(t, err)
+		require.Equal(t, response, got)
+	})
+
+	t.Run("fails requests that were not recorded", func(t *testing.T) {
+		messages := []message.Message{{
+			Role:  message.User,
+			Parts: []message.ContentPart{message.TextContent{Text: "Bye"}},
+		}}
+		replayed := collect(replayer.StreamResponse(t.Context(), messages, nil))
+		require.Len(t, replayed, 1)
+		require.Equal(t, EventError, replayed[0].Type)
+		require.ErrorIs(t, replayed[0].Error, ErrNoRecording)
+
+		_, err := replayer.SendMessages(t.Context(), messages, nil)
+		require.ErrorIs(t, err, ErrNoRecording)
+	})
+
+	require.Equal(t, 1, scripted.calls)
+}
+
+func TestReplayedErrors(t *testing.T) {
+	t.Parallel()
+
+	replay := func(err error) error {
+		data, jsonErr := json.Marshal(newRecordedEvent(ProviderEvent{Type: EventError, Error: err}))
+		require.NoError(t, jsonErr)
+		var recorded recordedEvent
+		require.NoError(t, json.Unmarshal(data, &recorded))
+		return recorded.event().Error
+	}
+
+	err := replay(fmt.Errorf("%w after 3 attempts: %w", ErrMaxRetries, errors.New("upstream unavailable")))
+	require.ErrorIs(t, err, ErrMaxRetries)
+	require.EqualError(t, err, "maximum retry attempts reached after 3 attempts: upstream unavailable")
+	require.True(t, IsFallbackError(err))
+
+	err = replay(&ollamaError{StatusCode: http.StatusServiceUnavailable, Message: "busy"})
+	statusCode, _ := errorStatus(err)
+	require.Equal(t, http.StatusServiceUnavailable, statusCode)
+	require.True(t, IsFallbackError(err))
+
+	err = replay(errors.New("invalid request"))
+	require.EqualError(t, err, "invalid request")
+	require.False(t, IsFallbackError(err))
+}
+
+func TestRequestHash(t *testing.T) {
+	t.Parallel()
+
+	messages := []message.Message{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "List the files"}}},
+		{Role: message.Assistant, Parts: []message.ContentPart{
+			message.ToolCall{ID: "call_1", Name: "ls", Input: "{}"},
+			message.Finish{Reason: message.FinishReasonToolUse, Time: 1},
+		}},
+		{Role: message.Tool, Parts: []message.ContentPart{message.ToolResult{ToolCallID: "call_1", Content: "main.go"}}},
+	}
+	hash := requestHash("model", messages, nil)
+
+	later := []message.Message{messages[0], {Role: message.Assistant, Parts: []message.ContentPart{
+		message.ToolCall{ID: "call_1", Name: "ls", Input: "{}"},
+		message.Finish{Reason: message.FinishReasonToolUse, Time: 2},
+	}}, messages[2]}
+	require.Equal(t, hash, requestHash("model", later, nil))
+
+	require.NotEqual(t, hash, requestHash("other-model", messages, nil))
+	require.NotEqual(t, hash, requestHash("model", messages[:1], nil))
+	changed := []message.Message{messages[0], messages[1], {Role: message.Tool, Parts: []message.ContentPart{message.ToolResult{ToolCallID: "call_1", Content: "go.mod"}}}}
+	require.NotEqual(t, hash, requestHash("model", changed, nil))
+}
@@ -0,0 +1,272 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/openai/openai-go"
+	"google.golang.org/genai"
+)
+
+// ErrCircuitOpen is returned without calling the provider while its circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("provider keeps failing, not calling it for now")
+
+// RetryPolicy decides whether a failed provider request is sent again, and
+// when. The zero value never retries.
+type RetryPolicy struct {
+	MaxAttempts          int
+	BaseDelay            time.Duration
+	MaxDelay             time.Duration
+	RetryableStatusCodes []int
+}
+
+// RetryInfo describes a retry that is about to happen.
+type RetryInfo struct {
+	// The attempt that is about to be made, starting at 2.
+	Attempt     int
+	MaxAttempts int
+	Delay       time.Duration
+	Err         error
+}
+
+func newRetryPolicy(cfg *config.Retry) RetryPolicy {
+	if cfg == nil {
+		return RetryPolicy{}
+	}
+	return RetryPolicy{
+		MaxAttempts:          cfg.MaxAttempts,
+		BaseDelay:            time.Duration(cfg.BaseDelayMs) * time.Millisecond,
+		MaxDelay:             time.Duration(cfg.MaxDelayMs) * time.Millisecond,
+		RetryableStatusCodes: cfg.RetryableStatusCodes,
+	}
+}
+
+// shouldRetry reports whether the request that failed with err on the given
+// attempt should be sent again and after how long. When it should not, the
+// error to give up with is returned.
+func (p RetryPolicy) shouldRetry(attempts int, err error) (bool, time.Duration, error) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false, 0, err
+	}
+	statusCode, header := errorStatus(err)
+	if !p.retryable(statusCode, err) {
+		return false, 0, err
+	}
+	if attempts >= p.MaxAttempts {
+		return false, 0, fmt.Errorf("%w after %d attempts: %w", ErrMaxRetries, attempts, err)
+	}
+	return true, p.delay(attempts, header.Get("Retry-After")), nil
+}
+
+func (p RetryPolicy) retryable(statusCode int, err error) bool {
+	if statusCode != 0 {
+		return slices.Contains(p.RetryableStatusCodes, statusCode)
+	}
+	// Dropped connections and providers that only describe the error.
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		contains(err.Error(), "rate limit", "quota exceeded", "too many requests", "overloaded", "resource exhausted")
+}
+
+// delay returns how long to wait before the attempt that follows the given
+// one. The server's Retry-After wins over the exponential backoff, both are
+// capped by the maximum delay.
+func (p RetryPolicy) delay(attempts int, retryAfter string) time.Duration {
+	if d, ok := parseRetryAfter(retryAfter, time.Now()); ok {
+		return min(d, p.MaxDelay)
+	}
+	backoff := p.BaseDelay << (attempts - 1)
+	if backoff <= 0 || backoff > p.MaxDelay {
+		backoff = p.MaxDelay
+	}
+	// Up to 20% of jitter keeps clients from retrying in lockstep.
+	if jitter := int64(backoff) / 5; jitter > 0 {
+		backoff += time.Duration(rand.Int64N(jitter))
+	}
+	return min(backoff, p.MaxDelay)
+}
+
+// parseRetryAfter reads a Retry-After header, given either in seconds or as an
+// HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// errorStatus returns the HTTP status code and headers of a provider API
+// error, if there are any.
+func errorStatus(err error) (int, http.Header) {
+	var anthropicErr *anthropic.Error
+	var openaiErr *openai.Error
+	var genaiErr genai.APIError
+	var ollamaErr *ollamaError
+	var awsErr *awshttp.ResponseError
+	var bedrockErr smithy.APIError
+	var replayedErr *replayedError
+	switch {
+	case errors.As(err, &anthropicErr):
+		return anthropicErr.StatusCode, responseHeader(anthropicErr.Response)
+	case errors.As(err, &openaiErr):
+		return openaiErr.StatusCode, responseHeader(openaiErr.Response)
+	case errors.As(err, &genaiErr):
+		return genaiErr.Code, nil
+	case errors.As(err, &ollamaErr):
+		return ollamaErr.StatusCode, nil
+	case errors.As(err, &awsErr):
+		return awsErr.HTTPStatusCode(), responseHeader(awsErr.Response.Response)
+	case errors.As(err, &bedrockErr) && bedrockExceptionStatus[bedrockErr.ErrorCode()] != 0:
+		return bedrockExceptionStatus[bedrockErr.ErrorCode()], nil
+	case errors.As(err, &replayedErr):
+		return replayedErr.statusCode, nil
+	}
+	return 0, nil
+}
+
+func responseHeader(resp *http.Response) http.Header {
+	if resp == nil {
+		return nil
+	}
+	return resp.Header
+}
+
+// logRetry logs the retry that follows the given attempt and describes it.
+func (p RetryPolicy) logRetry(attempts int, delay time.Duration, err error) *RetryInfo {
+	slog.Warn("Retrying provider request", "attempt", attempts+1, "max_attempts", p.MaxAttempts, "delay", delay, "error", err)
+	return &RetryInfo{
+		Attempt:     attempts + 1,
+		MaxAttempts: p.MaxAttempts,
+		Delay:       delay,
+		Err:         err,
+	}
+}
+
+// wait sleeps for the given delay, or until the context is done.
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// circuitBreaker stops calling a provider whose requests keep failing. Once the
+// cooldown has passed a single request is let through, and its outcome closes
+// the breaker or opens it again.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = map[string]*circuitBreaker{}
+)
+
+// breakerFor returns the circuit breaker shared by all clients of a provider.
+// A nil breaker, when the policy disables it, lets every request through.
+func breakerFor(providerID string, cfg *config.Retry) *circuitBreaker {
+	if cfg == nil || cfg.BreakerThreshold <= 0 {
+		return nil
+	}
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	breaker, ok := breakers[providerID]
+	if !ok {
+		breaker = &circuitBreaker{now: time.Now}
+		breakers[providerID] = breaker
+	}
+	breaker.mu.Lock()
+	breaker.threshold = cfg.BreakerThreshold
+	breaker.cooldown = time.Duration(cfg.BreakerCooldownMs) * time.Millisecond
+	breaker.mu.Unlock()
+	return breaker
+}
+
+// allow returns ErrCircuitOpen when the provider should not be called.
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return nil
+	}
+	if remaining := b.cooldown - b.now().Sub(b.openedAt); remaining > 0 {
+		return fmt.Errorf("%w, trying again in %s", ErrCircuitOpen, remaining.Round(time.Second))
+	}
+	if b.probing {
+		return ErrCircuitOpen
+	}
+	b.probing = true
+	return nil
+}
+
+// record notes the outcome of a request that was allowed.
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		// The request tells nothing about the provider.
+	case isProviderFailure(err):
+		b.failures++
+		if b.failures >= b.threshold {
+			b.openedAt = b.now()
+		}
+	default:
+		b.failures = 0
+	}
+}
+
+// isProviderFailure reports whether err means the provider failed, as opposed
+// to rejecting the request.
+func isProviderFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrMaxRetries) {
+		return true
+	}
+	statusCode, _ := errorStatus(err)
+	return statusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,126 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	d, ok := parseRetryAfter("8", now)
+	require.True(t, ok)
+	require.Equal(t, 8*time.Second, d)
+
+	d, ok = parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now)
+	require.True(t, ok)
+	require.Equal(t, 30*time.Second, d)
+
+	d, ok = parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now)
+	require.True(t, ok)
+	require.Zero(t, d)
+
+	_, ok = parseRetryAfter("", now)
+	require.False(t, ok)
+	_, ok = parseRetryAfter("soon", now)
+	require.False(t, ok)
+}
+
+func TestRetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy := RetryPolicy{
+		MaxAttempts:          3,
+		BaseDelay:            time.Second,
+		MaxDelay:             3 * time.Second,
+		RetryableStatusCodes: []int{429, 503},
+	}
+
+	t.Run("retries retryable status codes with backoff", func(t *testing.T) {
+		t.Parallel()
+		err := &ollamaError{StatusCode: 503, Message: "loading model"}
+		retry, after, retryErr := policy.shouldRetry(1, err)
+		require.True(t, retry)
+		require.NoError(t, retryErr)
+		require.GreaterOrEqual(t, after, time.Second)
+		require.Less(t, after, 1200*time.Millisecond)
+
+		retry, after, _ = policy.shouldRetry(2, err)
+		require.True(t, retry)
+		require.GreaterOrEqual(t, after, 2*time.Second)
+		require.LessOrEqual(t, after, 3*time.Second)
+	})
+
+	t.Run("gives up after the last attempt", func(t *testing.T) {
+		t.Parallel()
+		err := &ollamaError{StatusCode: 429, Message: "slow down"}
+		retry, _, retryErr := policy.shouldRetry(3, err)
+		require.False(t, retry)
+		require.ErrorIs(t, retryErr, ErrMaxRetries)
+		var ollamaErr *ollamaError
+		require.ErrorAs(t, retryErr, &ollamaErr)
+	})
+
+	t.Run("does not retry other errors", func(t *testing.T) {
+		t.Parallel()
+		err := &ollamaError{StatusCode: 400, Message: "bad request"}
+		retry, _, retryErr := policy.shouldRetry(1, err)
+		require.False(t, retry)
+		require.Equal(t, err, retryErr)
+
+		retry, _, retryErr = policy.shouldRetry(1, context.Canceled)
+		require.False(t, retry)
+		require.ErrorIs(t, retryErr, context.Canceled)
+	})
+
+	t.Run("caps delays", func(t *testing.T) {
+		t.Parallel()
+		require.Equal(t, 3*time.Second, policy.delay(10, ""))
+		require.Equal(t, 2*time.Second, policy.delay(1, "2"))
+		require.Equal(t, 3*time.Second, policy.delay(1, "120"))
+	})
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	breaker := &circuitBreaker{
+		threshold: 2,
+		cooldown:  time.Minute,
+		now:       func() time.Time { return now },
+	}
+	failure := &ollamaError{StatusCode: 500, Message: "boom"}
+
+	require.NoError(t, breaker.allow())
+	breaker.record(failure)
+	require.NoError(t, breaker.allow())
+	breaker.record(failure)
+	require.ErrorIs(t, breaker.allow(), ErrCircuitOpen)
+
+	// A single request is let through once the cooldown has passed.
+	now = now.Add(time.Minute)
+	require.NoError(t, breaker.allow())
+	require.ErrorIs(t, breaker.allow(), ErrCircuitOpen)
+	breaker.record(failure)
+	require.ErrorIs(t, breaker.allow(), ErrCircuitOpen)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, breaker.allow())
+	breaker.record(nil)
+	require.NoError(t, breaker.allow())
+	require.NoError(t, breaker.allow())
+
+	// Rejected requests do not count as failures.
+	breaker.record(failure)
+	breaker.record(errors.New("invalid request"))
+	breaker.record(failure)
+	require.NoError(t, breaker.allow())
+}
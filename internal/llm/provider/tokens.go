@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+// tokenizer approximates how a provider's models turn requests into tokens.
+// Providers don't share a tokenizer and most have no way to count tokens
+// without sending the request, so the size of a request is estimated from its
+// length.
+type tokenizer struct {
+	charsPerToken float64
+	// Tokens taken by an image, whatever its size.
+	imageTokens int64
+	// Tokens added around every message for its role and delimiters.
+	messageTokens int64
+}
+
+var (
+	openaiTokenizer    = tokenizer{charsPerToken: 4, imageTokens: 765, messageTokens: 4}
+	anthropicTokenizer = tokenizer{charsPerToken: 3.5, imageTokens: 1600, messageTokens: 4}
+	geminiTokenizer    = tokenizer{charsPerToken: 4, imageTokens: 258, messageTokens: 4}
+	// Used for open models, e.g. through Ollama or Bedrock, whose tokenizers
+	// tend to be less efficient with code.
+	defaultTokenizer = tokenizer{charsPerToken: 3.2, imageTokens: 1000, messageTokens: 4}
+	// Makes no more tokens than any of the above.
+	minTokenizer = tokenizer{charsPerToken: 4, imageTokens: 258, messageTokens: 4}
+)
+
+// EstimateTokens returns about the fewest tokens the messages take with any
+// provider, for when counting them with the provider costs a request.
+func EstimateTokens(messages []message.Message) int64 {
+	return minTokenizer.estimate("", messages, nil) - minTokenizer.messageTokens
+}
+
+// estimate returns the number of input tokens of a request made of the system
+// prompt, the messages and the tools, as converted for the provider.
+func (t tokenizer) estimate(systemMessage string, messages []message.Message, tools any) int64 {
+	var chars int
+	tokens := t.messageTokens
+	chars += len(systemMessage)
+	for _, msg := range messages {
+		tokens += t.messageTokens
+		for _, part := range msg.Parts {
+			switch part := part.(type) {
+			case message.TextContent:
+				chars += len(part.Text)
+			case message.ReasoningContent:
+				chars += len(part.Thinking)
+				for _, item := range part.Items {
+					chars += len(item.EncryptedContent)
+				}
+			case message.ToolCall:
+				chars += len(part.Name) + len(part.Input)
+			case message.ToolResult:
+				chars += len(part.Content)
+			case message.BinaryContent:
+				if strings.HasPrefix(part.MIMEType, "image/") {
+					tokens += t.imageTokens
+				} else {
+					chars += len(part.Data)
+				}
+			}
+		}
+	}
+	if schemas, err := json.Marshal(tools); err == nil && string(schemas) != "null" {
+		chars += len(schemas)
+	}
+	return tokens + int64(float64(chars)/t.charsPerToken)
+}
+
+// systemPrompt returns the whole system prompt sent by the client.
+func (o providerClientOptions) systemPrompt() string {
+	if o.systemPromptPrefix == "" {
+		return o.systemMessage
+	}
+	return o.systemPromptPrefix + "\n" + o.systemMessage
+}
@@ -0,0 +1,35 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenizerEstimate(t *testing.T) {
+	t.Parallel()
+
+	tokenizer := tokenizer{charsPerToken: 4, imageTokens: 100, messageTokens: 2}
+	require.Equal(t, int64(2+25), tokenizer.estimate(strings.Repeat("a", 100), nil, nil))
+
+	messages := []message.Message{
+		{Role: message.User, Parts: []message.ContentPart{
+			message.TextContent{Text: strings.Repeat("a", 40)},
+			message.BinaryContent{MIMEType: "image/png", Data: make([]byte, 1_000_000)},
+		}},
+		{Role: message.Assistant, Parts: []message.ContentPart{
+			message.ToolCall{Name: "ls", Input: strings.Repeat("b", 38)},
+		}},
+		{Role: message.Tool, Parts: []message.ContentPart{
+			message.ToolResult{Content: strings.Repeat("c", 80)},
+		}},
+	}
+	// Images are counted by the image, not by their size.
+	require.Equal(t, int64(2+3*2+100+40), tokenizer.estimate("", messages, nil))
+
+	// Tool schemas are counted as sent.
+	tools := []map[string]string{{"name": strings.Repeat("d", 30)}}
+	require.Equal(t, int64(2+10), tokenizer.estimate("", nil, tools))
+}
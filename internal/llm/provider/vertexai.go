@@ -5,8 +5,6 @@ import (
 	"log/slog"
 	"strings"
 
-	"github.com/charmbracelet/crush/internal/config"
-	"github.com/charmbracelet/crush/internal/log"
 	"google.golang.org/genai"
 )
 
@@ -20,8 +18,8 @@ func newVertexAIClient(opts providerClientOptions) VertexAIClient {
 		Location: location,
 		Backend:  genai.BackendVertexAI,
 	}
-	if config.Get().Options.Debug {
-		cc.HTTPClient = log.NewHTTPClient()
+	if opts.httpClient != nil {
+		cc.HTTPClient = opts.httpClient
 	}
 	client, err := genai.NewClient(context.Background(), cc)
 	if err != nil {
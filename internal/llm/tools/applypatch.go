@@ -0,0 +1,462 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/diff"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+type ApplyPatchParams struct {
+	Patch string `json:"patch"`
+}
+
+// PatchedFile is a file changed by a patch. OldPath is only set when the file
+// is renamed.
+type PatchedFile struct {
+	Path       string `json:"path"`
+	OldPath    string `json:"old_path,omitempty"`
+	OldContent string `json:"old_content,omitempty"`
+	NewContent string `json:"new_content,omitempty"`
+	Created    bool   `json:"created,omitempty"`
+	Deleted    bool   `json:"deleted,omitempty"`
+}
+
+type ApplyPatchPermissionsParams struct {
+	Files []PatchedFile `json:"files"`
+}
+
+type ApplyPatchResponseMetadata struct {
+	Files     []PatchedFile `json:"files"`
+	Additions int           `json:"additions"`
+	Removals  int           `json:"removals"`
+}
+
+type applyPatchTool struct {
+	lspClients  map[string]*lsp.Client
+	permissions permission.Service
+	files       history.Service
+	workingDir  string
+}
+
+const (
+	ApplyPatchToolName    = "apply_patch"
+	applyPatchDescription = `Applies a unified diff that can change several files at once, including creating, deleting and renaming files. Prefer this tool over Edit and MultiEdit for changes that span many files, like a refactor or a rename.
+
+Before using this tool:
+
+1. Use the View tool to read every file the patch changes, patches to files that were not read are rejected
+
+2. Write the patch against the current contents of the files
+
+PATCH FORMAT:
+- The same format as git diff or diff -u
+- Each file starts with a --- line with its old path and a +++ line with its new path, a/ and b/ prefixes are optional
+- Use /dev/null as the old path to create a file, and as the new path to delete it
+- To rename a file, use different old and new paths, with hunks for any changes to its content
+- Each hunk starts with an @@ line; line numbers like @@ -12,4 +12,5 @@ are optional, a bare @@ works
+- Hunk lines start with a space for context, - for removed lines and + for added lines
+
+Example:
+
+--- a/internal/config/config.go
++++ b/internal/config/config.go
+@@ -10,3 +10,3 @@
+ type Config struct {
+-	Name string
++	Title string
+ }
+--- /dev/null
++++ b/internal/config/doc.go
+@@ -0,0 +1 @@
++package config
+
+MATCHING:
+- Hunks are found by their content, closest to the line numbers given, so line numbers don't have to be exact
+- Lines that only differ in whitespace still match
+- If a hunk can't be found with all its context, up to 2 context lines at each end are left out
+- Include about 3 lines of context around each change, enough to make the location unique
+
+IMPORTANT:
+- The patch is applied as a whole - if any file or hunk can't be applied or written, no file is changed
+- The user approves the whole patch at once
+- Relative paths are resolved against the working directory
+- Only use emojis if the user explicitly requests it. Avoid adding emojis to files unless asked.`
+)
+
+func NewApplyPatchTool(lspClients map[string]*lsp.Client, permissions permission.Service, files history.Service, workingDir string) BaseTool {
+	return &applyPatchTool{
+		lspClients:  lspClients,
+		permissions: permissions,
+		files:       files,
+		workingDir:  workingDir,
+	}
+}
+
+func (a *applyPatchTool) Name() string {
+	return ApplyPatchToolName
+}
+
+func (a *applyPatchTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        ApplyPatchToolName,
+		Description: applyPatchDescription,
+		Parameters: map[string]any{
+			"patch": map[string]any{
+				"type":        "string",
+				"description": "The unified diff to apply",
+			},
+		},
+		Required: []string{"patch"},
+	}
+}
+
+func (a *applyPatchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params ApplyPatchParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse("invalid parameters"), nil
+	}
+
+	if strings.TrimSpace(params.Patch) == "" {
+		return NewTextErrorResponse("patch is required"), nil
+	}
+
+	patches, err := parsePatch(params.Patch)
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("invalid patch: %s", err)), nil
+	}
+
+	sessionID, messageID := GetContextValues(ctx)
+	if sessionID == "" || messageID == "" {
+		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for applying a patch")
+	}
+
+	// Apply every hunk in memory first, so nothing is written unless the
+	// whole patch applies.
+	files := make([]PatchedFile, 0, len(patches))
+	seen := make(map[string]bool)
+	for _, patch := range patches {
+		file, err := a.patchFile(patch)
+		if err != nil {
+			return NewTextErrorResponse(err.Error()), nil
+		}
+		for _, path := range []string{file.Path, file.OldPath} {
+			if path == "" {
+				continue
+			}
+			if seen[path] {
+				return NewTextErrorResponse(fmt.Sprintf("%s is changed more than once in the patch", path)), nil
+			}
+			seen[path] = true
+		}
+		files = append(files, file)
+	}
+
+	additions, removals := patchedFilesStats(files, a.workingDir)
+
+	p := requestPatchedFilesPermission(a.permissions, permission.CreatePermissionRequest{
+		SessionID:   sessionID,
+		ToolCallID:  call.ID,
+		ToolName:    ApplyPatchToolName,
+		Action:      "write",
+		Description: fmt.Sprintf("Apply patch to %d files", len(files)),
+		Params:      ApplyPatchPermissionsParams{Files: files},
+	}, files, a.workingDir)
+	if !p {
+		return ToolResponse{}, permission.ErrorPermissionDenied
+	}
+
+	summary, lastWritten, err := writePatchedFiles(ctx, a.files, sessionID, files)
+	if err != nil {
+		return ToolResponse{}, err
+	}
+	notifyLspPatchedFiles(ctx, a.lspClients, files, lastWritten)
+
+	text := fmt.Sprintf("<result>\nApplied patch to %d files:\n%s\n</result>\n", len(files), strings.Join(summary, "\n"))
+	if lastWritten != "" {
+		waitForLspDiagnostics(ctx, lastWritten, a.lspClients)
+		text += getDiagnostics(lastWritten, a.lspClients)
+	}
+	return WithResponseMetadata(
+		NewTextResponse(text),
+		ApplyPatchResponseMetadata{
+			Files:     files,
+			Additions: additions,
+			Removals:  removals,
+		},
+	), nil
+}
+
+// patchFile checks that the patch of a file can be applied and returns the
+// file with its old and new content.
+func (a *applyPatchTool) patchFile(patch filePatch) (PatchedFile, error) {
+	oldPath, newPath := a.absPath(patch.oldPath), a.absPath(patch.newPath)
+
+	if oldPath == "" {
+		if _, err := os.Stat(newPath); err == nil {
+			return PatchedFile{}, fmt.Errorf("file already exists: %s", newPath)
+		}
+		content, err := applyHunks("", patch.hunks)
+		if err != nil {
+			return PatchedFile{}, fmt.Errorf("%s: %w", newPath, err)
+		}
+		return PatchedFile{Path: newPath, NewContent: content, Created: true}, nil
+	}
+
+	fileInfo, err := os.Stat(oldPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return PatchedFile{}, fmt.Errorf("file not found: %s", oldPath)
+		}
+		return PatchedFile{}, fmt.Errorf("failed to access file %s: %w", oldPath, err)
+	}
+	if fileInfo.IsDir() {
+		return PatchedFile{}, fmt.Errorf("path is a directory, not a file: %s", oldPath)
+	}
+
+	lastRead := getLastReadTime(oldPath)
+	if lastRead.IsZero() {
+		return PatchedFile{}, fmt.Errorf("you must read %s before patching it. Use the View tool first", oldPath)
+	}
+	if modTime := fileInfo.ModTime(); modTime.After(lastRead) {
+		return PatchedFile{}, fmt.Errorf("file %s has been modified since it was last read (mod time: %s, last read: %s)",
+			oldPath, modTime.Format(time.RFC3339), lastRead.Format(time.RFC3339))
+	}
+
+	content, err := os.ReadFile(oldPath)
+	if err != nil {
+		return PatchedFile{}, fmt.Errorf("failed to read file %s: %w", oldPath, err)
+	}
+	oldContent := string(content)
+
+	if newPath == "" {
+		return PatchedFile{Path: oldPath, OldContent: oldContent, Deleted: true}, nil
+	}
+
+	newContent, err := applyHunks(oldContent, patch.hunks)
+	if err != nil {
+		return PatchedFile{}, fmt.Errorf("%s: %w", oldPath, err)
+	}
+	file := PatchedFile{Path: newPath, OldContent: oldContent, NewContent: newContent}
+	if newPath != oldPath {
+		if _, err := os.Stat(newPath); err == nil {
+			return PatchedFile{}, fmt.Errorf("cannot rename %s, file already exists: %s", oldPath, newPath)
+		}
+		file.OldPath = oldPath
+	}
+	return file, nil
+}
+
+func (a *applyPatchTool) absPath(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(a.workingDir, path)
+}
+
+// patchedFilesStats returns the number of lines added and removed in the
+// files.
+func patchedFilesStats(files []PatchedFile, workingDir string) (additions, removals int) {
+	for _, file := range files {
+		_, added, removed := diff.GenerateDiff(file.OldContent, file.NewContent, strings.TrimPrefix(file.Path, workingDir))
+		additions += added
+		removals += removed
+	}
+	return additions, removals
+}
+
+// requestPatchedFilesPermission asks permission to write the files, for each
+// of the paths patchedFilesPermissionPaths returns, and reports whether all
+// of them were granted.
+func requestPatchedFilesPermission(permissions permission.Service, req permission.CreatePermissionRequest, files []PatchedFile, workingDir string) bool {
+	for _, path := range patchedFilesPermissionPaths(files, workingDir) {
+		req.Path = path
+		if !permissions.Request(req) {
+			return false
+		}
+	}
+	return true
+}
+
+// patchedFilesPermissionPaths returns the paths to ask permission for: the
+// working directory for the files in it, and each file outside of it, which
+// needs its own approval.
+func patchedFilesPermissionPaths(files []PatchedFile, workingDir string) []string {
+	var paths []string
+	for _, file := range files {
+		for _, path := range []string{file.Path, file.OldPath} {
+			if path == "" {
+				continue
+			}
+			if path = fsext.PathOrPrefix(path, workingDir); !slices.Contains(paths, path) {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// writePatchedFiles writes the files to disk and records them in the file
+// history. When a file can't be written, the files written before it are
+// restored, so that no file is changed. It returns a line per file for the
+// result, and the last file that was written and not deleted.
+func writePatchedFiles(ctx context.Context, fileHistory history.Service, sessionID string, files []PatchedFile) (summary []string, lastWritten string, err error) {
+	for i, file := range files {
+		if err := writePatchedFile(file); err != nil {
+			for _, written := range slices.Backward(files[:i]) {
+				restorePatchedFile(written)
+			}
+			return nil, "", err
+		}
+	}
+
+	for _, file := range files {
+		recordPatchedFile(ctx, fileHistory, sessionID, file)
+		switch {
+		case file.Created:
+			summary = append(summary, "A "+file.Path)
+		case file.Deleted:
+			summary = append(summary, "D "+file.Path)
+		case file.OldPath != "":
+			summary = append(summary, fmt.Sprintf("R %s -> %s", file.OldPath, file.Path))
+		default:
+			summary = append(summary, "M "+file.Path)
+		}
+		if !file.Deleted {
+			lastWritten = file.Path
+		}
+	}
+	return summary, lastWritten, nil
+}
+
+// writePatchedFile writes the file to disk, leaving it as it was on error.
+func writePatchedFile(file PatchedFile) error {
+	if file.Deleted {
+		if err := os.Remove(file.Path); err != nil {
+			return fmt.Errorf("failed to delete file: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file.Path), 0o755); err != nil {
+		return fmt.Errorf("failed to create parent directories: %w", err)
+	}
+	if err := os.WriteFile(file.Path, []byte(file.NewContent), 0o644); err != nil {
+		if file.Created || file.OldPath != "" {
+			_ = os.Remove(file.Path)
+		} else {
+			_ = os.WriteFile(file.Path, []byte(file.OldContent), 0o644)
+		}
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if file.OldPath != "" {
+		if err := os.Remove(file.OldPath); err != nil {
+			_ = os.Remove(file.Path)
+			return fmt.Errorf("failed to remove renamed file: %w", err)
+		}
+	}
+	return nil
+}
+
+// restorePatchedFile puts back the file as it was before it was written.
+func restorePatchedFile(file PatchedFile) {
+	var err error
+	switch {
+	case file.Created:
+		err = os.Remove(file.Path)
+	case file.Deleted:
+		err = os.WriteFile(file.Path, []byte(file.OldContent), 0o644)
+	case file.OldPath != "":
+		if err = os.WriteFile(file.OldPath, []byte(file.OldContent), 0o644); err == nil {
+			err = os.Remove(file.Path)
+		}
+	default:
+		err = os.WriteFile(file.Path, []byte(file.OldContent), 0o644)
+	}
+	if err != nil {
+		slog.Error("Failed to restore file", "path", file.Path, "error", err)
+	}
+}
+
+// recordPatchedFile records the written file in the file history.
+func recordPatchedFile(ctx context.Context, fileHistory history.Service, sessionID string, file PatchedFile) {
+	_, messageID := GetContextValues(ctx)
+	if file.OldPath != "" {
+		recordFileHistory(ctx, fileHistory, sessionID, messageID, file.OldPath, file.OldContent, "", true, false)
+		recordFileHistory(ctx, fileHistory, sessionID, messageID, file.Path, "", file.NewContent, false, true)
+	} else {
+		recordFileHistory(ctx, fileHistory, sessionID, messageID, file.Path, file.OldContent, file.NewContent, !file.Created, !file.Deleted)
+	}
+	if file.Deleted {
+		return
+	}
+
+	recordFileWrite(file.Path)
+	recordFileRead(file.Path)
+}
+
+// recordFileHistory records the change of the file from its old content to
+// the new one. existed and exists tell whether the file was there before and
+// after the change.
+func recordFileHistory(ctx context.Context, files history.Service, sessionID, messageID, path, oldContent, newContent string, existed, exists bool) {
+	file, err := files.GetByPathAndSession(ctx, path, sessionID)
+	if err != nil {
+		if !existed {
+			_, err = files.CreateAbsent(ctx, sessionID, messageID, path)
+		} else {
+			_, err = files.Create(ctx, sessionID, messageID, path, oldContent)
+		}
+		if err != nil {
+			slog.Debug("Error creating file history", "error", err)
+			return
+		}
+	} else if file.Content != oldContent || file.Absent == existed {
+		// User manually changed the file, store an intermediate version
+		if err := recordFileVersion(ctx, files, sessionID, messageID, path, oldContent, existed); err != nil {
+			slog.Debug("Error creating file history version", "error", err)
+		}
+	}
+
+	if err := recordFileVersion(ctx, files, sessionID, messageID, path, newContent, exists); err != nil {
+		slog.Debug("Error creating file history version", "error", err)
+	}
+}
+
+func recordFileVersion(ctx context.Context, files history.Service, sessionID, messageID, path, content string, exists bool) error {
+	var err error
+	if exists {
+		_, err = files.CreateVersion(ctx, sessionID, messageID, path, content)
+	} else {
+		_, err = files.CreateAbsent(ctx, sessionID, messageID, path)
+	}
+	return err
+}
+
+// notifyLspPatchedFiles tells the language servers about the changed files.
+// The last written file is left to waitForLspDiagnostics, which notifies it
+// too.
+func notifyLspPatchedFiles(ctx context.Context, lsps map[string]*lsp.Client, files []PatchedFile, lastWritten string) {
+	for _, file := range files {
+		if file.Deleted {
+			closeLspFile(ctx, file.Path, lsps)
+			continue
+		}
+		if file.OldPath != "" {
+			closeLspFile(ctx, file.OldPath, lsps)
+		}
+		if file.Path != lastWritten {
+			notifyLspChange(ctx, file.Path, lsps)
+		}
+	}
+}
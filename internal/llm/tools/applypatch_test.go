@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritePatchedFilesRestores(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	modified := filepath.Join(dir, "modified.go")
+	deleted := filepath.Join(dir, "deleted.go")
+	renamed := filepath.Join(dir, "renamed.go")
+	blocker := filepath.Join(dir, "blocker")
+	for path, content := range map[string]string{modified: "old\n", deleted: "gone\n", renamed: "moved\n", blocker: ""} {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	files := []PatchedFile{
+		{Path: modified, OldContent: "old\n", NewContent: "new\n"},
+		{Path: deleted, OldContent: "gone\n", Deleted: true},
+		{Path: filepath.Join(dir, "moved.go"), OldPath: renamed, OldContent: "moved\n", NewContent: "moved\n"},
+		{Path: filepath.Join(dir, "created.go"), NewContent: "created\n", Created: true},
+		// The parent directory can't be created over a file.
+		{Path: filepath.Join(blocker, "failed.go"), NewContent: "failed\n", Created: true},
+	}
+	_, _, err := writePatchedFiles(t.Context(), nil, "session", files)
+	require.Error(t, err)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	require.ElementsMatch(t, []string{"blocker", "deleted.go", "modified.go", "renamed.go"}, names)
+	for path, content := range map[string]string{modified: "old\n", deleted: "gone\n", renamed: "moved\n"} {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, content, string(data))
+	}
+}
+
+func TestPatchedFilesPermissionPaths(t *testing.T) {
+	t.Parallel()
+
+	files := []PatchedFile{
+		{Path: "/work/a.go"},
+		{Path: "/other/b.go"},
+		{Path: "/work/c.go", OldPath: "/elsewhere/c.go"},
+		{Path: "/other/b_test.go"},
+	}
+	require.Equal(t, []string{"/work", "/other/b.go", "/elsewhere/c.go", "/other/b_test.go"}, patchedFilesPermissionPaths(files, "/work"))
+	require.Equal(t, []string{"/work"}, patchedFilesPermissionPaths(files[:1], "/work"))
+}
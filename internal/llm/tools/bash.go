@@ -12,13 +12,15 @@ import (
 )
 
 type BashParams struct {
-	Command string `json:"command"`
-	Timeout int    `json:"timeout"`
+	Command         string `json:"command"`
+	Timeout         int    `json:"timeout"`
+	RunInBackground bool   `json:"run_in_background,omitempty"`
 }
 
 type BashPermissionsParams struct {
-	Command string `json:"command"`
-	Timeout int    `json:"timeout"`
+	Command         string `json:"command"`
+	Timeout         int    `json:"timeout"`
+	RunInBackground bool   `json:"run_in_background,omitempty"`
 }
 
 type BashResponseMetadata struct {
@@ -26,10 +28,13 @@ type BashResponseMetadata struct {
 	EndTime          int64  `json:"end_time"`
 	Output           string `json:"output"`
 	WorkingDirectory string `json:"working_directory"`
+	// Set when the command runs in the background.
+	JobID string `json:"job_id,omitempty"`
 }
 type bashTool struct {
 	permissions permission.Service
 	workingDir  string
+	sandboxed   bool
 }
 
 const (
@@ -114,8 +119,12 @@ var bannedCommands = []string{
 	"ufw",
 }
 
-func bashDescription() string {
+func bashDescription(sandboxed bool) string {
 	bannedCommandsStr := strings.Join(bannedCommands, ", ")
+	var sandboxNote string
+	if sandboxed {
+		sandboxNote = "\n- Commands run in a sandbox: they may only write to the working directory, the temporary directory and the paths the User allowed, may not access the network unless the User allowed it, and their CPU time, memory and number of processes are limited. When a command fails because of the sandbox, explain it to the User instead of working around it."
+	}
 	return fmt.Sprintf(`Executes a given bash command in a persistent shell session with optional timeout, ensuring proper handling and security measures.
 
 CROSS-PLATFORM SHELL SUPPORT:
@@ -152,6 +161,8 @@ Before executing the command, please follow these steps:
 Usage notes:
 - The command argument is required.
 - You can specify an optional timeout in milliseconds (up to 600000ms / 10 minutes). If not specified, commands will timeout after 30 minutes.
+- Set run_in_background to true for commands that keep running, like dev servers and watchers, or that take longer than the timeout, like large test suites. The command starts in the background and a job ID is returned right away; the timeout doesn't apply. Use the job_output tool to read its output, job_list to list jobs and job_kill to stop it. Don't add '&' to run a command in the background.
+- Background commands start from the current directory and environment of the shell, but changes they make to them, like 'cd' or 'export', don't persist.%s
 - VERY IMPORTANT: You MUST avoid using search commands like 'find' and 'grep'. Instead use Grep, Glob, or Agent tools to search. You MUST avoid read tools like 'cat', 'head', 'tail', and 'ls', and use FileRead and LS tools to read files.
 - When issuing multiple commands, use the ';' or '&&' operator to separate them. DO NOT use newlines (newlines are ok in quoted strings).
 - IMPORTANT: All commands share the same shell session. Shell state (environment variables, virtual environments, current directory, etc.) persist between commands. For example, if you set an environment variable as part of a command, the environment variable will persist for subsequent commands.
@@ -269,7 +280,7 @@ EOF
 
 Important:
 - Return an empty response - the user will see the gh output directly
-- Never update git config`, bannedCommandsStr, MaxOutputLength)
+- Never update git config`, bannedCommandsStr, MaxOutputLength, sandboxNote)
 }
 
 func blockFuncs() []shell.BlockFunc {
@@ -303,14 +314,16 @@ func blockFuncs() []shell.BlockFunc {
 	}
 }
 
-func NewBashTool(permission permission.Service, workingDir string) BaseTool {
-	// Set up command blocking on the persistent shell
+func NewBashTool(permission permission.Service, workingDir string, sandbox *shell.Sandbox) BaseTool {
+	// Set up command blocking and the sandbox on the persistent shell
 	persistentShell := shell.GetPersistentShell(workingDir)
 	persistentShell.SetBlockFuncs(blockFuncs())
+	persistentShell.SetSandbox(sandbox)
 
 	return &bashTool{
 		permissions: permission,
 		workingDir:  workingDir,
+		sandboxed:   sandbox != nil,
 	}
 }
 
@@ -321,7 +334,7 @@ func (b *bashTool) Name() string {
 func (b *bashTool) Info() ToolInfo {
 	return ToolInfo{
 		Name:        BashToolName,
-		Description: bashDescription(),
+		Description: bashDescription(b.sandboxed),
 		Parameters: map[string]any{
 			"command": map[string]any{
 				"type":        "string",
@@ -331,6 +344,10 @@ func (b *bashTool) Info() ToolInfo {
 				"type":        "number",
 				"description": "Optional timeout in milliseconds (max 600000)",
 			},
+			"run_in_background": map[string]any{
+				"type":        "boolean",
+				"description": "Run the command in the background and return a job ID to read its output with the job_output tool",
+			},
 		},
 		Required: []string{"command"},
 	}
@@ -369,6 +386,10 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for creating a new file")
 	}
 	if !isSafeReadOnly {
+		description := fmt.Sprintf("Execute command: %s", params.Command)
+		if params.RunInBackground {
+			description = fmt.Sprintf("Execute command in the background: %s", params.Command)
+		}
 		p := b.permissions.Request(
 			permission.CreatePermissionRequest{
 				SessionID:   sessionID,
@@ -376,9 +397,10 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 				ToolCallID:  call.ID,
 				ToolName:    BashToolName,
 				Action:      "execute",
-				Description: fmt.Sprintf("Execute command: %s", params.Command),
+				Description: description,
 				Params: BashPermissionsParams{
-					Command: params.Command,
+					Command:         params.Command,
+					RunInBackground: params.RunInBackground,
 				},
 			},
 		)
@@ -387,13 +409,26 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		}
 	}
 	startTime := time.Now()
+	persistentShell := shell.GetPersistentShell(b.workingDir)
+
+	if params.RunInBackground {
+		job := shell.BackgroundJobs().Start(sessionID, persistentShell.Shell, params.Command)
+		metadata := BashResponseMetadata{
+			StartTime:        startTime.UnixMilli(),
+			EndTime:          time.Now().UnixMilli(),
+			WorkingDirectory: persistentShell.GetWorkingDir(),
+			JobID:            job.ID,
+		}
+		text := fmt.Sprintf("Started job %s in the background. Use the job_output tool to read its output and job_kill to stop it.", job.ID)
+		return WithResponseMetadata(NewTextResponse(text), metadata), nil
+	}
+
 	if params.Timeout > 0 {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, time.Duration(params.Timeout)*time.Millisecond)
 		defer cancel()
 	}
 
-	persistentShell := shell.GetPersistentShell(b.workingDir)
 	stdout, stderr, err := persistentShell.Exec(ctx, params.Command)
 
 	// Get the current working directory after command execution
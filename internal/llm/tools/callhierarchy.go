@@ -0,0 +1,177 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+)
+
+type CallHierarchyParams struct {
+	FilePath  string `json:"file_path"`
+	Line      int    `json:"line"`
+	Symbol    string `json:"symbol,omitempty"`
+	Column    int    `json:"column,omitempty"`
+	Direction string `json:"direction,omitempty"`
+}
+
+const (
+	callDirectionIncoming = "incoming"
+	callDirectionOutgoing = "outgoing"
+	callDirectionBoth     = "both"
+)
+
+type callHierarchyTool struct {
+	lspClients map[string]*lsp.Client
+	workingDir string
+}
+
+const (
+	CallHierarchyToolName    = "call_hierarchy"
+	callHierarchyDescription = `Lists the functions that call a function, or the functions it calls, using the language servers of the project.
+WHEN TO USE THIS TOOL:
+- Use to find who calls a function and from where, to understand how it is used or what a change to it affects
+- Use to see what a function depends on without reading all of it
+HOW TO USE:
+- Give the file and line where the function appears, its definition or a call to it, and its name as the symbol
+- Set direction to "incoming" for its callers (default), "outgoing" for the functions it calls, or "both"
+- Incoming calls are listed as the path:line:column of each call, the text of the line and the calling function
+- Outgoing calls are listed as the path:line:column of each called function and the lines it is called on
+LIMITATIONS:
+- Only works for files a configured language server handles, and not every language server supports it
+- Only lists direct calls; call the tool again on a result to go further
+- Lists at most 100 calls in each direction
+TIPS:
+- Use the references tool for uses that aren't calls, like a function passed as a value
+`
+)
+
+func NewCallHierarchyTool(lspClients map[string]*lsp.Client, workingDir string) BaseTool {
+	return &callHierarchyTool{
+		lspClients: lspClients,
+		workingDir: workingDir,
+	}
+}
+
+func (c *callHierarchyTool) Name() string {
+	return CallHierarchyToolName
+}
+
+func (c *callHierarchyTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        CallHierarchyToolName,
+		Description: callHierarchyDescription,
+		Parameters: withPositionParameters(map[string]any{
+			"direction": map[string]any{
+				"type":        "string",
+				"enum":        []string{callDirectionIncoming, callDirectionOutgoing, callDirectionBoth},
+				"description": "Whether to list the callers of the function (default), the functions it calls, or both",
+			},
+		}),
+		Required: []string{"file_path", "line"},
+	}
+}
+
+func (c *callHierarchyTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params CallHierarchyParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+
+	if params.FilePath == "" {
+		return NewTextErrorResponse("file_path is required"), nil
+	}
+	if !filepath.IsAbs(params.FilePath) {
+		params.FilePath = filepath.Join(c.workingDir, params.FilePath)
+	}
+	switch params.Direction {
+	case "":
+		params.Direction = callDirectionIncoming
+	case callDirectionIncoming, callDirectionOutgoing, callDirectionBoth:
+	default:
+		return NewTextErrorResponse(fmt.Sprintf("unknown direction %q, use incoming, outgoing or both", params.Direction)), nil
+	}
+
+	pos, err := lspPosition(params.FilePath, params.Line, params.Column, params.Symbol)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	// The calls are asked to the server that found the function.
+	var client *lsp.Client
+	items, err := firstLspResult(ctx, c.lspClients, params.FilePath, func(lspClient *lsp.Client) ([]protocol.CallHierarchyItem, error) {
+		client = lspClient
+		return lspClient.PrepareCallHierarchy(ctx, protocol.CallHierarchyPrepareParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(params.FilePath)},
+				Position:     pos,
+			},
+		})
+	})
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to prepare call hierarchy: %s", err)), nil
+	}
+	if len(items) == 0 {
+		return NewTextResponse("No function found at this position"), nil
+	}
+
+	formatter := newLocationFormatter(c.workingDir)
+	var sections []string
+	for _, item := range items {
+		if params.Direction != callDirectionOutgoing {
+			calls, err := client.IncomingCalls(ctx, protocol.CallHierarchyIncomingCallsParams{Item: item})
+			if err != nil {
+				return NewTextErrorResponse(fmt.Sprintf("failed to get incoming calls: %s", err)), nil
+			}
+			sections = append(sections, formatIncomingCalls(formatter, item, calls))
+		}
+		if params.Direction != callDirectionIncoming {
+			calls, err := client.OutgoingCalls(ctx, protocol.CallHierarchyOutgoingCallsParams{Item: item})
+			if err != nil {
+				return NewTextErrorResponse(fmt.Sprintf("failed to get outgoing calls: %s", err)), nil
+			}
+			sections = append(sections, formatOutgoingCalls(formatter, item, calls))
+		}
+	}
+
+	return NewTextResponse(strings.Join(sections, "\n\n")), nil
+}
+
+func formatIncomingCalls(formatter *locationFormatter, item protocol.CallHierarchyItem, calls []protocol.CallHierarchyIncomingCall) string {
+	if len(calls) == 0 {
+		return fmt.Sprintf("No calls to %s", item.Name)
+	}
+
+	var lines []string
+	for _, call := range calls {
+		for _, r := range call.FromRanges {
+			lines = append(lines, fmt.Sprintf("%s (in %s)", formatter.location(protocol.Location{URI: call.From.URI, Range: r}), call.From.Name))
+		}
+	}
+	return fmt.Sprintf("Calls to %s:\n%s", item.Name, truncateNavigationLines(lines))
+}
+
+func formatOutgoingCalls(formatter *locationFormatter, item protocol.CallHierarchyItem, calls []protocol.CallHierarchyOutgoingCall) string {
+	if len(calls) == 0 {
+		return fmt.Sprintf("No calls from %s", item.Name)
+	}
+
+	var lines []string
+	for _, call := range calls {
+		// The ranges of outgoing calls are in the file of the caller.
+		var callLines []string
+		for _, r := range call.FromRanges {
+			callLines = append(callLines, fmt.Sprintf("%d", r.Start.Line+1))
+		}
+		line := fmt.Sprintf("%s: %s %s", formatter.position(call.To.URI, call.To.SelectionRange.Start), symbolKind(call.To.Kind), call.To.Name)
+		if len(callLines) > 0 {
+			line += ", called on line " + strings.Join(callLines, ", ")
+		}
+		lines = append(lines, line)
+	}
+	return fmt.Sprintf("Calls from %s:\n%s", item.Name, truncateNavigationLines(lines))
+}
@@ -0,0 +1,317 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+type ApplyCodeActionParams struct {
+	FilePath string `json:"file_path"`
+	Line     int    `json:"line,omitempty"`
+	EndLine  int    `json:"end_line,omitempty"`
+	Kind     string `json:"kind,omitempty"`
+	Title    string `json:"title,omitempty"`
+}
+
+type ApplyCodeActionPermissionsParams struct {
+	FilePath string        `json:"file_path"`
+	Title    string        `json:"title"`
+	Kind     string        `json:"kind,omitempty"`
+	Files    []PatchedFile `json:"files"`
+}
+
+type applyCodeActionTool struct {
+	lspClients  map[string]*lsp.Client
+	permissions permission.Service
+	files       history.Service
+	workingDir  string
+}
+
+const (
+	ApplyCodeActionToolName    = "apply_code_action"
+	applyCodeActionDescription = `Lists and applies the code actions of the language servers of the project, like organizing imports or the quick fixes of a diagnostic.
+WHEN TO USE THIS TOOL:
+- Use to organize the imports of a file
+- Use to fix a diagnostic with the quick fix the language server offers, like adding a missing import or filling in a struct
+- Use for refactors the language server offers, like extracting a function or inlining a variable
+HOW TO USE:
+- First call it without a title to list the code actions available for the file, or for some of its lines
+- Then call it again with the same file, lines and kind, and the title of the action to apply
+- Give line, and end_line for several lines, to get the actions of those lines, including the quick fixes of their diagnostics; leave them out for the whole file
+- Set kind to only get actions of that kind, like "quickfix", "source.organizeImports", "source.fixAll" or "refactor.extract"
+- The title is matched exactly, or by a part of it that only one action contains, ignoring case
+- The user approves the changes to all the files at once
+LIMITATIONS:
+- Only works for files a configured language server handles
+- Actions that run a command on the language server instead of returning their changes can't be previewed and are not supported
+TIPS:
+- To organize imports, call it with kind "source.organizeImports" and the title of the action the listing returns
+- Check the returned diagnostics after applying an action
+`
+)
+
+func NewApplyCodeActionTool(lspClients map[string]*lsp.Client, permissions permission.Service, files history.Service, workingDir string) BaseTool {
+	return &applyCodeActionTool{
+		lspClients:  lspClients,
+		permissions: permissions,
+		files:       files,
+		workingDir:  workingDir,
+	}
+}
+
+func (a *applyCodeActionTool) Name() string {
+	return ApplyCodeActionToolName
+}
+
+func (a *applyCodeActionTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        ApplyCodeActionToolName,
+		Description: applyCodeActionDescription,
+		Parameters: map[string]any{
+			"file_path": map[string]any{
+				"type":        "string",
+				"description": "The path to the file to get the code actions of",
+			},
+			"line": map[string]any{
+				"type":        "integer",
+				"description": "The first line to get the code actions of (1-based), the whole file when not given",
+			},
+			"end_line": map[string]any{
+				"type":        "integer",
+				"description": "The last line to get the code actions of (1-based), the same as line when not given",
+			},
+			"kind": map[string]any{
+				"type":        "string",
+				"description": "Only get code actions of this kind, like quickfix or source.organizeImports",
+			},
+			"title": map[string]any{
+				"type":        "string",
+				"description": "The title of the code action to apply, leave it out to list the available actions",
+			},
+		},
+		Required: []string{"file_path"},
+	}
+}
+
+func (a *applyCodeActionTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params ApplyCodeActionParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+
+	if params.FilePath == "" {
+		return NewTextErrorResponse("file_path is required"), nil
+	}
+	if !filepath.IsAbs(params.FilePath) {
+		params.FilePath = filepath.Join(a.workingDir, params.FilePath)
+	}
+
+	sessionID, messageID := GetContextValues(ctx)
+	if sessionID == "" || messageID == "" {
+		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for applying a code action")
+	}
+
+	r, err := codeActionRange(params.FilePath, params.Line, params.EndLine)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	// The action is resolved by the server that offered it.
+	var client *lsp.Client
+	uri := protocol.URIFromPath(params.FilePath)
+	actions, err := firstLspResult(ctx, a.lspClients, params.FilePath, func(lspClient *lsp.Client) ([]protocol.CodeAction, error) {
+		client = lspClient
+		codeActionContext := protocol.CodeActionContext{
+			Diagnostics: overlappingDiagnostics(lspClient.GetFileDiagnostics(uri), r),
+		}
+		if params.Kind != "" {
+			codeActionContext.Only = []protocol.CodeActionKind{protocol.CodeActionKind(params.Kind)}
+		}
+		result, err := lspClient.CodeAction(ctx, protocol.CodeActionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Range:        r,
+			Context:      codeActionContext,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return codeActions(result), nil
+	})
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to get code actions: %s", err)), nil
+	}
+	if len(actions) == 0 {
+		return NewTextResponse("No code actions available"), nil
+	}
+
+	if params.Title == "" {
+		return NewTextResponse(formatCodeActions(actions)), nil
+	}
+
+	action, err := findCodeAction(actions, params.Title)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	if action.Disabled != nil {
+		return NewTextErrorResponse(fmt.Sprintf("the code action %q can't be applied: %s", action.Title, action.Disabled.Reason)), nil
+	}
+	if action.Edit == nil && action.Command == nil {
+		action, err = client.ResolveCodeAction(ctx, action)
+		if err != nil {
+			return NewTextErrorResponse(fmt.Sprintf("failed to resolve code action: %s", err)), nil
+		}
+	}
+	// Commands make the server change the files itself, without a preview
+	// the user could approve first.
+	if action.Command != nil {
+		return NewTextErrorResponse(fmt.Sprintf("the code action %q runs a command on the language server, which is not supported", action.Title)), nil
+	}
+	if action.Edit == nil {
+		return NewTextErrorResponse(fmt.Sprintf("the code action %q has no changes", action.Title)), nil
+	}
+
+	files, err := workspaceEditFiles(*action.Edit)
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to prepare the code action: %s", err)), nil
+	}
+	if len(files) == 0 {
+		return NewTextErrorResponse(fmt.Sprintf("the code action %q doesn't change any file", action.Title)), nil
+	}
+
+	p := requestPatchedFilesPermission(a.permissions, permission.CreatePermissionRequest{
+		SessionID:   sessionID,
+		ToolCallID:  call.ID,
+		ToolName:    ApplyCodeActionToolName,
+		Action:      "write",
+		Description: fmt.Sprintf("Apply code action %q to %d files", action.Title, len(files)),
+		Params: ApplyCodeActionPermissionsParams{
+			FilePath: params.FilePath,
+			Title:    action.Title,
+			Kind:     string(action.Kind),
+			Files:    files,
+		},
+	}, files, a.workingDir)
+	if !p {
+		return ToolResponse{}, permission.ErrorPermissionDenied
+	}
+
+	summary := fmt.Sprintf("Applied code action %q to %d files", action.Title, len(files))
+	return applyWorkspaceEditFiles(ctx, a.lspClients, a.files, sessionID, a.workingDir, summary, files)
+}
+
+// codeActionRange returns the range of the 1-based lines of the file, or of
+// the whole file when line is 0.
+func codeActionRange(filePath string, line, endLine int) (protocol.Range, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return protocol.Range{}, fmt.Errorf("file not found: %s", filePath)
+		}
+		return protocol.Range{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+
+	if line == 0 {
+		line, endLine = 1, len(lines)
+	}
+	if endLine == 0 {
+		endLine = line
+	}
+	switch {
+	case line < 0:
+		return protocol.Range{}, fmt.Errorf("line must be 1 or greater")
+	case endLine < line:
+		return protocol.Range{}, fmt.Errorf("end_line must not be before line")
+	case endLine > len(lines):
+		return protocol.Range{}, fmt.Errorf("line %d is past the end of %s, which has %d lines", endLine, filePath, len(lines))
+	}
+
+	return protocol.Range{
+		Start: protocol.Position{Line: uint32(line - 1)},
+		End:   protocol.Position{Line: uint32(endLine - 1), Character: utf16Len(lines[endLine-1])},
+	}, nil
+}
+
+// overlappingDiagnostics returns the diagnostics that overlap the range, for
+// the server to offer their quick fixes.
+func overlappingDiagnostics(diagnostics []protocol.Diagnostic, r protocol.Range) []protocol.Diagnostic {
+	overlapping := []protocol.Diagnostic{}
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Range.End.Line < r.Start.Line || diagnostic.Range.Start.Line > r.End.Line {
+			continue
+		}
+		overlapping = append(overlapping, diagnostic)
+	}
+	return overlapping
+}
+
+// codeActions returns the result of a code action request as code actions,
+// with bare commands as actions that only have a command.
+func codeActions(result []protocol.Or_Result_textDocument_codeAction_Item0_Elem) []protocol.CodeAction {
+	actions := make([]protocol.CodeAction, 0, len(result))
+	for _, item := range result {
+		switch v := item.Value.(type) {
+		case protocol.CodeAction:
+			actions = append(actions, v)
+		case protocol.Command:
+			actions = append(actions, protocol.CodeAction{Title: v.Title, Command: &v})
+		}
+	}
+	return actions
+}
+
+// findCodeAction returns the action with the title, or the only one whose
+// title contains it, ignoring case.
+func findCodeAction(actions []protocol.CodeAction, title string) (protocol.CodeAction, error) {
+	for _, action := range actions {
+		if action.Title == title {
+			return action, nil
+		}
+	}
+
+	var matches []protocol.CodeAction
+	for _, action := range actions {
+		if strings.Contains(strings.ToLower(action.Title), strings.ToLower(title)) {
+			matches = append(matches, action)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return protocol.CodeAction{}, fmt.Errorf("no code action matches %q, the available actions are:\n%s", title, formatCodeActions(actions))
+	case 1:
+		return matches[0], nil
+	default:
+		return protocol.CodeAction{}, fmt.Errorf("%d code actions match %q, give more of the title:\n%s", len(matches), title, formatCodeActions(matches))
+	}
+}
+
+func formatCodeActions(actions []protocol.CodeAction) string {
+	lines := make([]string, 0, len(actions))
+	for _, action := range actions {
+		line := "- " + action.Title
+		var details []string
+		if action.Kind != "" {
+			details = append(details, string(action.Kind))
+		}
+		if action.IsPreferred {
+			details = append(details, "preferred")
+		}
+		if action.Disabled != nil {
+			details = append(details, "disabled: "+action.Disabled.Reason)
+		}
+		if len(details) > 0 {
+			line += " (" + strings.Join(details, ", ") + ")"
+		}
+		lines = append(lines, line)
+	}
+	return fmt.Sprintf("%d code actions:\n%s", len(actions), strings.Join(lines, "\n"))
+}
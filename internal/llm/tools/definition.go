@@ -0,0 +1,122 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+)
+
+type DefinitionParams struct {
+	FilePath string `json:"file_path"`
+	Line     int    `json:"line"`
+	Symbol   string `json:"symbol,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Kind     string `json:"kind,omitempty"`
+}
+
+const (
+	definitionKindDefinition     = "definition"
+	definitionKindTypeDefinition = "type_definition"
+	definitionKindImplementation = "implementation"
+)
+
+type definitionTool struct {
+	lspClients map[string]*lsp.Client
+	workingDir string
+}
+
+const (
+	DefinitionToolName    = "definition"
+	definitionDescription = `Finds where a symbol is defined, using the language servers of the project.
+WHEN TO USE THIS TOOL:
+- Use to jump from a use of a function, type, method or variable to its definition
+- Use to find the type of a variable, or the implementations of an interface or abstract method
+- Prefer it over grep when following code, it isn't fooled by names that appear in several places
+HOW TO USE:
+- Give the file and line where the symbol appears, and the symbol itself
+- Set kind to "type_definition" to go to the definition of the symbol's type, or to "implementation" to list the implementations of an interface or method
+- Results are listed as path:line:column followed by the text of the line
+LIMITATIONS:
+- Only works for files a configured language server handles
+- Results depend on the language server; they may be empty for generated or excluded code
+TIPS:
+- Use the View tool on a result to read the whole definition
+- Use the references tool to go the other way, from a definition to its uses
+`
+)
+
+func NewDefinitionTool(lspClients map[string]*lsp.Client, workingDir string) BaseTool {
+	return &definitionTool{
+		lspClients: lspClients,
+		workingDir: workingDir,
+	}
+}
+
+func (d *definitionTool) Name() string {
+	return DefinitionToolName
+}
+
+func (d *definitionTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        DefinitionToolName,
+		Description: definitionDescription,
+		Parameters: withPositionParameters(map[string]any{
+			"kind": map[string]any{
+				"type":        "string",
+				"enum":        []string{definitionKindDefinition, definitionKindTypeDefinition, definitionKindImplementation},
+				"description": "What to find: the definition of the symbol (default), the definition of its type, or its implementations",
+			},
+		}),
+		Required: []string{"file_path", "line"},
+	}
+}
+
+func (d *definitionTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params DefinitionParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+
+	if params.FilePath == "" {
+		return NewTextErrorResponse("file_path is required"), nil
+	}
+	if !filepath.IsAbs(params.FilePath) {
+		params.FilePath = filepath.Join(d.workingDir, params.FilePath)
+	}
+
+	pos, err := lspPosition(params.FilePath, params.Line, params.Column, params.Symbol)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	document := protocol.TextDocumentPositionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(params.FilePath)},
+		Position:     pos,
+	}
+
+	locations, err := firstLspResult(ctx, d.lspClients, params.FilePath, func(client *lsp.Client) ([]protocol.Location, error) {
+		switch params.Kind {
+		case "", definitionKindDefinition:
+			result, err := client.Definition(ctx, protocol.DefinitionParams{TextDocumentPositionParams: document})
+			return lspLocations(result.Value), err
+		case definitionKindTypeDefinition:
+			result, err := client.TypeDefinition(ctx, protocol.TypeDefinitionParams{TextDocumentPositionParams: document})
+			return lspLocations(result.Value), err
+		case definitionKindImplementation:
+			result, err := client.Implementation(ctx, protocol.ImplementationParams{TextDocumentPositionParams: document})
+			return lspLocations(result.Value), err
+		}
+		return nil, fmt.Errorf("unknown kind %q", params.Kind)
+	})
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to find definition: %s", err)), nil
+	}
+	if len(locations) == 0 {
+		return NewTextResponse("No definition found"), nil
+	}
+
+	return NewTextResponse(newLocationFormatter(d.workingDir).locations(locations)), nil
+}
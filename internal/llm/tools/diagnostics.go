@@ -101,6 +101,31 @@ func notifyLspOpenFile(ctx context.Context, filePath string, lsps map[string]*ls
 	}
 }
 
+func notifyLspChange(ctx context.Context, filePath string, lsps map[string]*lsp.Client) {
+	for _, client := range lsps {
+		if client.IsFileOpen(filePath) {
+			if err := client.NotifyChange(ctx, filePath); err != nil {
+				slog.Debug("Failed to notify LSP of file change", "file", filePath, "error", err)
+			}
+			continue
+		}
+		if err := client.OpenFile(ctx, filePath); err != nil {
+			slog.Debug("Failed to open file in LSP", "file", filePath, "error", err)
+		}
+	}
+}
+
+func closeLspFile(ctx context.Context, filePath string, lsps map[string]*lsp.Client) {
+	for _, client := range lsps {
+		if !client.IsFileOpen(filePath) {
+			continue
+		}
+		if err := client.CloseFile(ctx, filePath); err != nil {
+			slog.Debug("Failed to close file in LSP", "file", filePath, "error", err)
+		}
+	}
+}
+
 func waitForLspDiagnostics(ctx context.Context, filePath string, lsps map[string]*lsp.Client) {
 	if len(lsps) == 0 {
 		return
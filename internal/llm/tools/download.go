@@ -65,15 +65,13 @@ TIPS:
 - Set appropriate timeouts for large files or slow connections`
 )
 
-func NewDownloadTool(permissions permission.Service, workingDir string) BaseTool {
+// NewDownloadTool creates the download tool. Its requests go through the
+// given transport, or the default one when it is nil.
+func NewDownloadTool(permissions permission.Service, workingDir string, transport *http.Transport) BaseTool {
 	return &downloadTool{
 		client: &http.Client{
-			Timeout: 5 * time.Minute, // Default 5 minute timeout for downloads
-			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 10,
-				IdleConnTimeout:     90 * time.Second,
-			},
+			Timeout:   5 * time.Minute, // Default 5 minute timeout for downloads
+			Transport: pooledTransport(transport),
 		},
 		permissions: permissions,
 		workingDir:  workingDir,
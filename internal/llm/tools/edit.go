@@ -241,14 +241,14 @@ func (e *editTool) createNewFile(ctx context.Context, filePath, content string,
 	}
 
 	// File can't be in the history so we create a new file history
-	_, err = e.files.Create(ctx, sessionID, filePath, "")
+	_, err = e.files.CreateAbsent(ctx, sessionID, messageID, filePath)
 	if err != nil {
 		// Log error but don't fail the operation
 		return ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
 	}
 
 	// Add the new content to the file history
-	_, err = e.files.CreateVersion(ctx, sessionID, filePath, content)
+	_, err = e.files.CreateVersion(ctx, sessionID, messageID, filePath, content)
 	if err != nil {
 		// Log error but don't fail the operation
 		slog.Debug("Error creating file history version", "error", err)
@@ -364,7 +364,7 @@ func (e *editTool) deleteContent(ctx context.Context, filePath, oldString string
 	// Check if file exists in history
 	file, err := e.files.GetByPathAndSession(ctx, filePath, sessionID)
 	if err != nil {
-		_, err = e.files.Create(ctx, sessionID, filePath, oldContent)
+		_, err = e.files.Create(ctx, sessionID, messageID, filePath, oldContent)
 		if err != nil {
 			// Log error but don't fail the operation
 			return ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
@@ -372,13 +372,13 @@ func (e *editTool) deleteContent(ctx context.Context, filePath, oldString string
 	}
 	if file.Content != oldContent {
 		// User Manually changed the content store an intermediate version
-		_, err = e.files.CreateVersion(ctx, sessionID, filePath, oldContent)
+		_, err = e.files.CreateVersion(ctx, sessionID, messageID, filePath, oldContent)
 		if err != nil {
 			slog.Debug("Error creating file history version", "error", err)
 		}
 	}
 	// Store the new version
-	_, err = e.files.CreateVersion(ctx, sessionID, filePath, "")
+	_, err = e.files.CreateVersion(ctx, sessionID, messageID, filePath, "")
 	if err != nil {
 		slog.Debug("Error creating file history version", "error", err)
 	}
@@ -495,7 +495,7 @@ func (e *editTool) replaceContent(ctx context.Context, filePath, oldString, newS
 	// Check if file exists in history
 	file, err := e.files.GetByPathAndSession(ctx, filePath, sessionID)
 	if err != nil {
-		_, err = e.files.Create(ctx, sessionID, filePath, oldContent)
+		_, err = e.files.Create(ctx, sessionID, messageID, filePath, oldContent)
 		if err != nil {
 			// Log error but don't fail the operation
 			return ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
@@ -503,13 +503,13 @@ func (e *editTool) replaceContent(ctx context.Context, filePath, oldString, newS
 	}
 	if file.Content != oldContent {
 		// User Manually changed the content store an intermediate version
-		_, err = e.files.CreateVersion(ctx, sessionID, filePath, oldContent)
+		_, err = e.files.CreateVersion(ctx, sessionID, messageID, filePath, oldContent)
 		if err != nil {
 			slog.Debug("Error creating file history version", "error", err)
 		}
 	}
 	// Store the new version
-	_, err = e.files.CreateVersion(ctx, sessionID, filePath, newContent)
+	_, err = e.files.CreateVersion(ctx, sessionID, messageID, filePath, newContent)
 	if err != nil {
 		slog.Debug("Error creating file history version", "error", err)
 	}
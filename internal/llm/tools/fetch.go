@@ -66,21 +66,32 @@ TIPS:
 - Set appropriate timeouts for potentially slow websites`
 )
 
-func NewFetchTool(permissions permission.Service, workingDir string) BaseTool {
+// NewFetchTool creates the fetch tool. Its requests go through the given
+// transport, or the default one when it is nil.
+func NewFetchTool(permissions permission.Service, workingDir string, transport *http.Transport) BaseTool {
 	return &fetchTool{
 		client: &http.Client{
-			Timeout: 30 * time.Second,
-			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 10,
-				IdleConnTimeout:     90 * time.Second,
-			},
+			Timeout:   30 * time.Second,
+			Transport: pooledTransport(transport),
 		},
 		permissions: permissions,
 		workingDir:  workingDir,
 	}
 }
 
+// pooledTransport returns a copy of transport, or of the default transport
+// when it is nil, that keeps connections open for the next requests.
+func pooledTransport(transport *http.Transport) *http.Transport {
+	if transport == nil {
+		transport = http.DefaultTransport.(*http.Transport)
+	}
+	transport = transport.Clone()
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 10
+	transport.IdleConnTimeout = 90 * time.Second
+	return transport
+}
+
 func (t *fetchTool) Name() string {
 	return FetchToolName
 }
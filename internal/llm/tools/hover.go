@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+)
+
+type HoverParams struct {
+	FilePath string `json:"file_path"`
+	Line     int    `json:"line"`
+	Symbol   string `json:"symbol,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+type hoverTool struct {
+	lspClients map[string]*lsp.Client
+	workingDir string
+}
+
+const (
+	HoverToolName    = "hover"
+	hoverDescription = `Shows the type, signature and documentation of a symbol, using the language servers of the project.
+WHEN TO USE THIS TOOL:
+- Use to find the type of a variable or expression, or the signature of a function, without reading its definition
+- Use to read the documentation of a function or type from a dependency
+HOW TO USE:
+- Give the file and line where the symbol appears, and the symbol itself
+- The result is what an editor shows when hovering the symbol, usually markdown
+LIMITATIONS:
+- Only works for files a configured language server handles
+TIPS:
+- Use the definition tool to go to the code of the symbol
+`
+)
+
+func NewHoverTool(lspClients map[string]*lsp.Client, workingDir string) BaseTool {
+	return &hoverTool{
+		lspClients: lspClients,
+		workingDir: workingDir,
+	}
+}
+
+func (h *hoverTool) Name() string {
+	return HoverToolName
+}
+
+func (h *hoverTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        HoverToolName,
+		Description: hoverDescription,
+		Parameters:  withPositionParameters(nil),
+		Required:    []string{"file_path", "line"},
+	}
+}
+
+func (h *hoverTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params HoverParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+
+	if params.FilePath == "" {
+		return NewTextErrorResponse("file_path is required"), nil
+	}
+	if !filepath.IsAbs(params.FilePath) {
+		params.FilePath = filepath.Join(h.workingDir, params.FilePath)
+	}
+
+	pos, err := lspPosition(params.FilePath, params.Line, params.Column, params.Symbol)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	contents, err := firstLspResult(ctx, h.lspClients, params.FilePath, func(client *lsp.Client) ([]string, error) {
+		result, err := client.Hover(ctx, protocol.HoverParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(params.FilePath)},
+				Position:     pos,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if value := strings.TrimSpace(result.Contents.Value); value != "" {
+			return []string{value}, nil
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to get hover information: %s", err)), nil
+	}
+	if len(contents) == 0 {
+		return NewTextResponse("No hover information found"), nil
+	}
+
+	return NewTextResponse(contents[0]), nil
+}
@@ -0,0 +1,237 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/shell"
+)
+
+type JobOutputParams struct {
+	JobID string `json:"job_id"`
+	Wait  int    `json:"wait,omitempty"`
+}
+
+type JobKillParams struct {
+	JobID string `json:"job_id"`
+}
+
+type JobResponseMetadata struct {
+	JobID   string `json:"job_id"`
+	Command string `json:"command"`
+	Status  string `json:"status"`
+}
+
+type (
+	jobOutputTool struct{}
+	jobListTool   struct{}
+	jobKillTool   struct{}
+)
+
+const (
+	JobOutputToolName    = "job_output"
+	jobOutputDescription = `Reads the new output of a command started in the background with the bash tool.
+WHEN TO USE THIS TOOL:
+- Use to check on a dev server, watcher, build or test suite started with run_in_background
+- Use to wait for a background command to finish
+HOW TO USE:
+- Give the ID of the job the bash tool returned
+- Only the output written since the last call is returned, along with the state of the job
+- Set wait to the number of milliseconds to wait for the job to exit before reading its output (max 600000)
+LIMITATIONS:
+- Standard output and standard error are returned together
+- Output that isn't read is kept up to 1MB, older output is dropped
+TIPS:
+- Use the job_list tool to find the IDs of the jobs of the session
+`
+
+	JobListToolName    = "job_list"
+	jobListDescription = `Lists the commands started in the background with the bash tool in this session, with their IDs and state.
+WHEN TO USE THIS TOOL:
+- Use to find the ID of a background job, or to check which ones are still running
+`
+
+	JobKillToolName    = "job_kill"
+	jobKillDescription = `Stops a command started in the background with the bash tool.
+WHEN TO USE THIS TOOL:
+- Use to stop a dev server or watcher that is no longer needed, or a command that hangs
+HOW TO USE:
+- Give the ID of the job the bash tool returned
+- The job's unread output is returned
+TIPS:
+- Stop the background jobs you started once you are done with them
+`
+)
+
+func NewJobOutputTool() BaseTool {
+	return &jobOutputTool{}
+}
+
+func (j *jobOutputTool) Name() string {
+	return JobOutputToolName
+}
+
+func (j *jobOutputTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        JobOutputToolName,
+		Description: jobOutputDescription,
+		Parameters: map[string]any{
+			"job_id": map[string]any{
+				"type":        "string",
+				"description": "The ID of the job to read the output of",
+			},
+			"wait": map[string]any{
+				"type":        "number",
+				"description": "Optional time in milliseconds to wait for the job to exit (max 600000)",
+			},
+		},
+		Required: []string{"job_id"},
+	}
+}
+
+func (j *jobOutputTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params JobOutputParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+
+	job, err := sessionJob(ctx, params.JobID)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	if params.Wait > 0 && job.Status == shell.JobRunning {
+		waitCtx, cancel := context.WithTimeout(ctx, time.Duration(min(params.Wait, MaxTimeout))*time.Millisecond)
+		err = shell.BackgroundJobs().Wait(waitCtx, job.ID)
+		cancel()
+		// Stop waiting when the tool call is cancelled, but not when the wait
+		// is over.
+		if err != nil && ctx.Err() != nil {
+			return ToolResponse{}, ctx.Err()
+		}
+	}
+
+	return jobOutputResponse(job.ID)
+}
+
+func NewJobListTool() BaseTool {
+	return &jobListTool{}
+}
+
+func (j *jobListTool) Name() string {
+	return JobListToolName
+}
+
+func (j *jobListTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        JobListToolName,
+		Description: jobListDescription,
+		Parameters:  map[string]any{},
+		Required:    []string{},
+	}
+}
+
+func (j *jobListTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	sessionID, _ := GetContextValues(ctx)
+	if sessionID == "" {
+		return ToolResponse{}, fmt.Errorf("session ID is required for listing jobs")
+	}
+
+	jobs := shell.BackgroundJobs().List(sessionID)
+	if len(jobs) == 0 {
+		return NewTextResponse("No background jobs"), nil
+	}
+
+	lines := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		lines = append(lines, fmt.Sprintf("%s: %s (%s)", job.ID, job.Command, job))
+	}
+	return NewTextResponse(strings.Join(lines, "\n")), nil
+}
+
+func NewJobKillTool() BaseTool {
+	return &jobKillTool{}
+}
+
+func (j *jobKillTool) Name() string {
+	return JobKillToolName
+}
+
+func (j *jobKillTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        JobKillToolName,
+		Description: jobKillDescription,
+		Parameters: map[string]any{
+			"job_id": map[string]any{
+				"type":        "string",
+				"description": "The ID of the job to stop",
+			},
+		},
+		Required: []string{"job_id"},
+	}
+}
+
+func (j *jobKillTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params JobKillParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+
+	job, err := sessionJob(ctx, params.JobID)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	if job.Status == shell.JobRunning {
+		if _, err := shell.BackgroundJobs().Kill(job.ID); err != nil {
+			return NewTextErrorResponse(err.Error()), nil
+		}
+	}
+
+	return jobOutputResponse(job.ID)
+}
+
+// sessionJob returns the job with the ID if it belongs to the session of the
+// tool call.
+func sessionJob(ctx context.Context, id string) (shell.Job, error) {
+	if id == "" {
+		return shell.Job{}, fmt.Errorf("job_id is required")
+	}
+	sessionID, _ := GetContextValues(ctx)
+	job, ok := shell.BackgroundJobs().Get(id)
+	if !ok || job.SessionID != sessionID {
+		return shell.Job{}, fmt.Errorf("job not found: %s. Use the job_list tool to list the jobs of the session", id)
+	}
+	return job, nil
+}
+
+// jobOutputResponse returns the state of the job and its unread output.
+func jobOutputResponse(id string) (ToolResponse, error) {
+	output, dropped, job, err := shell.BackgroundJobs().Output(id)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Job %s %s\n", job.ID, job)
+	if dropped > 0 {
+		fmt.Fprintf(&text, "[%d bytes of output were dropped before they were read]\n", dropped)
+	}
+	if output == "" {
+		text.WriteString("No new output")
+	} else {
+		text.WriteString("\n" + truncateOutput(output))
+	}
+
+	return WithResponseMetadata(
+		NewTextResponse(text.String()),
+		JobResponseMetadata{
+			JobID:   job.ID,
+			Command: job.Command,
+			Status:  job.String(),
+		},
+	), nil
+}
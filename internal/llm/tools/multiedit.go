@@ -275,12 +275,12 @@ func (m *multiEditTool) processMultiEditWithCreation(ctx context.Context, params
 	}
 
 	// Update file history
-	_, err = m.files.Create(ctx, sessionID, params.FilePath, "")
+	_, err = m.files.CreateAbsent(ctx, sessionID, messageID, params.FilePath)
 	if err != nil {
 		return ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
 	}
 
-	_, err = m.files.CreateVersion(ctx, sessionID, params.FilePath, currentContent)
+	_, err = m.files.CreateVersion(ctx, sessionID, messageID, params.FilePath, currentContent)
 	if err != nil {
 		slog.Debug("Error creating file history version", "error", err)
 	}
@@ -386,21 +386,21 @@ func (m *multiEditTool) processMultiEditExistingFile(ctx context.Context, params
 	// Update file history
 	file, err := m.files.GetByPathAndSession(ctx, params.FilePath, sessionID)
 	if err != nil {
-		_, err = m.files.Create(ctx, sessionID, params.FilePath, oldContent)
+		_, err = m.files.Create(ctx, sessionID, messageID, params.FilePath, oldContent)
 		if err != nil {
 			return ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
 		}
 	}
 	if file.Content != oldContent {
 		// User manually changed the content, store an intermediate version
-		_, err = m.files.CreateVersion(ctx, sessionID, params.FilePath, oldContent)
+		_, err = m.files.CreateVersion(ctx, sessionID, messageID, params.FilePath, oldContent)
 		if err != nil {
 			slog.Debug("Error creating file history version", "error", err)
 		}
 	}
 
 	// Store the new version
-	_, err = m.files.CreateVersion(ctx, sessionID, params.FilePath, currentContent)
+	_, err = m.files.CreateVersion(ctx, sessionID, messageID, params.FilePath, currentContent)
 	if err != nil {
 		slog.Debug("Error creating file history version", "error", err)
 	}
@@ -0,0 +1,288 @@
+package tools
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+)
+
+// maxNavigationResults is the number of locations the navigation tools list
+// before leaving the rest out.
+const maxNavigationResults = 100
+
+// positionParameters are the parameters of the navigation tools that point at
+// a symbol in a file.
+var positionParameters = map[string]any{
+	"file_path": map[string]any{
+		"type":        "string",
+		"description": "The path to the file the symbol appears in",
+	},
+	"line": map[string]any{
+		"type":        "integer",
+		"description": "The line the symbol appears on (1-based)",
+	},
+	"symbol": map[string]any{
+		"type":        "string",
+		"description": "The symbol on that line, like MyFunc or pkg.MyFunc; its first whole-word occurrence on the line is used",
+	},
+	"column": map[string]any{
+		"type":        "integer",
+		"description": "The column of the symbol (1-based), only used when symbol is not given",
+	},
+}
+
+// withPositionParameters returns the position parameters and the extra ones
+// of a tool.
+func withPositionParameters(extra map[string]any) map[string]any {
+	params := maps.Clone(positionParameters)
+	maps.Copy(params, extra)
+	return params
+}
+
+// lspPosition returns the position of the symbol on the 1-based line of the
+// file, or of the 1-based column when no symbol is given, or of the first
+// non-blank character of the line.
+func lspPosition(filePath string, line, column int, symbol string) (protocol.Position, error) {
+	if line < 1 {
+		return protocol.Position{}, fmt.Errorf("line must be 1 or greater")
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return protocol.Position{}, fmt.Errorf("file not found: %s", filePath)
+		}
+		return protocol.Position{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	if line > len(lines) {
+		return protocol.Position{}, fmt.Errorf("line %d is past the end of %s, which has %d lines", line, filePath, len(lines))
+	}
+	text := lines[line-1]
+
+	var offset int
+	switch {
+	case symbol != "":
+		offset = indexWord(text, symbol)
+		if offset < 0 {
+			return protocol.Position{}, fmt.Errorf("symbol %q not found on line %d: %s", symbol, line, strings.TrimSpace(text))
+		}
+		// For qualified names, point at the name itself.
+		offset += strings.LastIndex(symbol, ".") + 1
+	case column > 0:
+		offset = len(text)
+		for i, n := 0, 0; i < len(text); n++ {
+			if n == column-1 {
+				offset = i
+				break
+			}
+			_, size := utf8.DecodeRuneInString(text[i:])
+			i += size
+		}
+	default:
+		offset = len(text) - len(strings.TrimLeft(text, " \t"))
+	}
+
+	return protocol.Position{
+		Line:      uint32(line - 1),
+		Character: utf16Len(text[:offset]),
+	}, nil
+}
+
+// indexWord returns the byte offset of the first occurrence of word in s that
+// isn't part of a longer identifier, or of its first occurrence when there is
+// no such one, or -1.
+func indexWord(s, word string) int {
+	first := strings.Index(s, word)
+	for i := first; i >= 0; {
+		end := i + len(word)
+		before, _ := utf8.DecodeLastRuneInString(s[:i])
+		after, _ := utf8.DecodeRuneInString(s[end:])
+		if !isIdentRune(before) && !isIdentRune(after) {
+			return i
+		}
+		next := strings.Index(s[i+1:], word)
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return first
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// utf16Len returns the length of s in UTF-16 code units, which is how LSP
+// counts characters.
+func utf16Len(s string) uint32 {
+	var n int
+	for _, r := range s {
+		n += max(utf16.RuneLen(r), 1)
+	}
+	return uint32(n)
+}
+
+// lspLocations returns the locations of a definition, type definition or
+// implementation result.
+func lspLocations(value any) []protocol.Location {
+	switch v := value.(type) {
+	case protocol.Or_Definition:
+		return lspLocations(v.Value)
+	case protocol.Location:
+		return []protocol.Location{v}
+	case []protocol.Location:
+		return v
+	case []protocol.LocationLink:
+		locations := make([]protocol.Location, 0, len(v))
+		for _, link := range v {
+			locations = append(locations, protocol.Location{URI: link.TargetURI, Range: link.TargetSelectionRange})
+		}
+		return locations
+	}
+	return nil
+}
+
+// firstLspResult asks the language servers in turn, in the order of their
+// names, and returns the first results that aren't empty. The file is opened
+// in each server first when one is given.
+func firstLspResult[T any](ctx context.Context, lsps map[string]*lsp.Client, filePath string, query func(client *lsp.Client) ([]T, error)) ([]T, error) {
+	if len(lsps) == 0 {
+		return nil, fmt.Errorf("no language servers are running")
+	}
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(lsps)) {
+		client := lsps[name]
+		if filePath != "" {
+			if err := client.OpenFileOnDemand(ctx, filePath); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				continue
+			}
+		}
+		results, err := query(client)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		if len(results) > 0 {
+			return results, nil
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// locationFormatter formats locations as compact path:line:column lines
+// followed by the text of the line.
+type locationFormatter struct {
+	workingDir string
+	files      map[string][]string
+}
+
+func newLocationFormatter(workingDir string) *locationFormatter {
+	return &locationFormatter{
+		workingDir: workingDir,
+		files:      make(map[string][]string),
+	}
+}
+
+// path returns the path of the URI relative to the working directory when it
+// is inside it.
+func (f *locationFormatter) path(uri protocol.DocumentURI) string {
+	path, err := uri.Path()
+	if err != nil {
+		return string(uri)
+	}
+	if fsext.HasPrefix(path, f.workingDir) {
+		if rel, err := filepath.Rel(f.workingDir, path); err == nil {
+			return rel
+		}
+	}
+	return path
+}
+
+// snippet returns the trimmed text of the 0-based line of the file.
+func (f *locationFormatter) snippet(uri protocol.DocumentURI, line uint32) string {
+	path, err := uri.Path()
+	if err != nil {
+		return ""
+	}
+	lines, ok := f.files[path]
+	if !ok {
+		if content, err := os.ReadFile(path); err == nil {
+			lines = strings.Split(string(content), "\n")
+		}
+		f.files[path] = lines
+	}
+	if int(line) >= len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(lines[line])
+}
+
+// position returns the path:line:column of a position in a file, 1-based.
+func (f *locationFormatter) position(uri protocol.DocumentURI, pos protocol.Position) string {
+	return fmt.Sprintf("%s:%d:%d", f.path(uri), pos.Line+1, pos.Character+1)
+}
+
+func (f *locationFormatter) location(loc protocol.Location) string {
+	line := f.position(loc.URI, loc.Range.Start)
+	if snippet := f.snippet(loc.URI, loc.Range.Start.Line); snippet != "" {
+		line += ": " + snippet
+	}
+	return line
+}
+
+// locations formats the locations sorted by path and position, without
+// duplicates and up to maxNavigationResults of them.
+func (f *locationFormatter) locations(locations []protocol.Location) string {
+	locations = slices.Clone(locations)
+	slices.SortFunc(locations, func(a, b protocol.Location) int {
+		return cmp.Or(
+			cmp.Compare(a.URI, b.URI),
+			cmp.Compare(a.Range.Start.Line, b.Range.Start.Line),
+			cmp.Compare(a.Range.Start.Character, b.Range.Start.Character),
+		)
+	})
+	locations = slices.CompactFunc(locations, func(a, b protocol.Location) bool {
+		return a.URI == b.URI && a.Range.Start == b.Range.Start
+	})
+
+	var lines []string
+	for i, loc := range locations {
+		if i == maxNavigationResults {
+			lines = append(lines, fmt.Sprintf("... and %d more", len(locations)-i))
+			break
+		}
+		lines = append(lines, f.location(loc))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// truncateNavigationLines joins the lines, up to maxNavigationResults of them.
+func truncateNavigationLines(lines []string) string {
+	if len(lines) > maxNavigationResults {
+		lines = append(lines[:maxNavigationResults], fmt.Sprintf("... and %d more", len(lines)-maxNavigationResults))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// symbolKind returns the name of a symbol kind.
+func symbolKind(kind protocol.SymbolKind) string {
+	if name, ok := protocol.TableKindMap[kind]; ok {
+		return name
+	}
+	return "Symbol"
+}
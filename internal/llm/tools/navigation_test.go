@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLspPosition(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "main.go")
+	require.NoError(t, os.WriteFile(file, []byte("package main\n\n\tx := config.Get(getter, get)\ns := \"é😀\" + name\n"), 0o644))
+
+	pos, err := lspPosition(file, 3, 0, "config.Get")
+	require.NoError(t, err)
+	require.Equal(t, protocol.Position{Line: 2, Character: 13}, pos)
+
+	// Whole words are preferred over parts of longer ones.
+	pos, err = lspPosition(file, 3, 0, "get")
+	require.NoError(t, err)
+	require.Equal(t, protocol.Position{Line: 2, Character: 25}, pos)
+
+	// Characters are counted in UTF-16.
+	pos, err = lspPosition(file, 4, 0, "name")
+	require.NoError(t, err)
+	require.Equal(t, protocol.Position{Line: 3, Character: 13}, pos)
+	pos, err = lspPosition(file, 4, 9, "")
+	require.NoError(t, err)
+	require.Equal(t, protocol.Position{Line: 3, Character: 9}, pos)
+
+	pos, err = lspPosition(file, 3, 0, "")
+	require.NoError(t, err)
+	require.Equal(t, protocol.Position{Line: 2, Character: 1}, pos)
+
+	_, err = lspPosition(file, 3, 0, "missing")
+	require.ErrorContains(t, err, "not found on line 3")
+	_, err = lspPosition(file, 40, 0, "x")
+	require.Error(t, err)
+	_, err = lspPosition(file, 0, 0, "x")
+	require.Error(t, err)
+}
+
+func TestLspLocations(t *testing.T) {
+	t.Parallel()
+
+	loc := protocol.Location{URI: "file:///a.go", Range: protocol.Range{Start: protocol.Position{Line: 1}}}
+	require.Equal(t, []protocol.Location{loc}, lspLocations(protocol.Or_Definition{Value: loc}))
+	require.Equal(t, []protocol.Location{loc}, lspLocations(protocol.Or_Definition{Value: []protocol.Location{loc}}))
+	require.Equal(t, []protocol.Location{loc}, lspLocations([]protocol.LocationLink{{TargetURI: loc.URI, TargetSelectionRange: loc.Range}}))
+	require.Nil(t, lspLocations(nil))
+}
+
+func TestLocationFormatter(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "pkg", "a.go")
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o755))
+	require.NoError(t, os.WriteFile(file, []byte("package pkg\n\nfunc A() {\n\tB()\n}\n"), 0o644))
+
+	uri := protocol.URIFromPath(file)
+	at := func(line, character uint32) protocol.Location {
+		return protocol.Location{URI: uri, Range: protocol.Range{Start: protocol.Position{Line: line, Character: character}}}
+	}
+
+	output := newLocationFormatter(dir).locations([]protocol.Location{at(3, 1), at(2, 5), at(3, 1)})
+	require.Equal(t, filepath.Join("pkg", "a.go")+":3:6: func A() {\n"+filepath.Join("pkg", "a.go")+":4:2: B()", output)
+}
+
+func TestFormatOutline(t *testing.T) {
+	t.Parallel()
+
+	lines := formatOutline([]protocol.DocumentSymbol{{
+		Name:  "Server",
+		Kind:  protocol.Struct,
+		Range: protocol.Range{Start: protocol.Position{Line: 9}, End: protocol.Position{Line: 19}},
+		Children: []protocol.DocumentSymbol{{
+			Name:   "Start",
+			Detail: "func() error",
+			Kind:   protocol.Method,
+			Range:  protocol.Range{Start: protocol.Position{Line: 11}, End: protocol.Position{Line: 14}},
+		}},
+	}})
+	require.Equal(t, []string{
+		"10-20: Struct Server",
+		"  12-15: Method Start func() error",
+	}, lines)
+}
@@ -0,0 +1,130 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+)
+
+type OutlineParams struct {
+	FilePath string `json:"file_path"`
+}
+
+type outlineTool struct {
+	lspClients map[string]*lsp.Client
+	workingDir string
+}
+
+const (
+	OutlineToolName    = "outline"
+	outlineDescription = `Lists the symbols of a file as a tree, using the language servers of the project.
+WHEN TO USE THIS TOOL:
+- Use to get an overview of a large file before reading it: its types, functions, methods and their lines
+- Use to find the lines of a function or method, and then view only those
+HOW TO USE:
+- Give the path of the file
+- Each symbol is listed with its lines, kind, name and details like its signature, nested under the symbol that contains it
+LIMITATIONS:
+- Only works for files a configured language server handles
+TIPS:
+- Use the View tool with an offset and limit to read a symbol from the outline
+- Use the symbols tool to search symbols across the whole project
+`
+)
+
+func NewOutlineTool(lspClients map[string]*lsp.Client, workingDir string) BaseTool {
+	return &outlineTool{
+		lspClients: lspClients,
+		workingDir: workingDir,
+	}
+}
+
+func (o *outlineTool) Name() string {
+	return OutlineToolName
+}
+
+func (o *outlineTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        OutlineToolName,
+		Description: outlineDescription,
+		Parameters: map[string]any{
+			"file_path": map[string]any{
+				"type":        "string",
+				"description": "The path to the file to list the symbols of",
+			},
+		},
+		Required: []string{"file_path"},
+	}
+}
+
+func (o *outlineTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params OutlineParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+
+	if params.FilePath == "" {
+		return NewTextErrorResponse("file_path is required"), nil
+	}
+	if !filepath.IsAbs(params.FilePath) {
+		params.FilePath = filepath.Join(o.workingDir, params.FilePath)
+	}
+
+	lines, err := firstLspResult(ctx, o.lspClients, params.FilePath, func(client *lsp.Client) ([]string, error) {
+		result, err := client.DocumentSymbol(ctx, protocol.DocumentSymbolParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(params.FilePath)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return formatOutline(result.Value), nil
+	})
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to get symbols: %s", err)), nil
+	}
+	if len(lines) == 0 {
+		return NewTextResponse("No symbols found"), nil
+	}
+
+	return NewTextResponse(strings.Join(lines, "\n")), nil
+}
+
+// formatOutline formats the symbols of a document, one per line. Servers
+// that return a flat list have their symbols nested under their container
+// name.
+func formatOutline(value any) []string {
+	var lines []string
+	switch symbols := value.(type) {
+	case []protocol.DocumentSymbol:
+		var walk func(symbols []protocol.DocumentSymbol, depth int)
+		walk = func(symbols []protocol.DocumentSymbol, depth int) {
+			for _, symbol := range symbols {
+				lines = append(lines, formatOutlineSymbol(depth, symbol.Range, symbol.Kind, symbol.Name, symbol.Detail))
+				walk(symbol.Children, depth+1)
+			}
+		}
+		walk(symbols, 0)
+	case []protocol.SymbolInformation:
+		for _, symbol := range symbols {
+			depth := 0
+			if symbol.ContainerName != "" {
+				depth = 1
+			}
+			lines = append(lines, formatOutlineSymbol(depth, symbol.Location.Range, symbol.Kind, symbol.Name, ""))
+		}
+	}
+	return lines
+}
+
+func formatOutlineSymbol(depth int, r protocol.Range, kind protocol.SymbolKind, name, detail string) string {
+	line := fmt.Sprintf("%s%d-%d: %s %s", strings.Repeat("  ", depth), r.Start.Line+1, r.End.Line+1, symbolKind(kind), name)
+	if detail != "" {
+		line += " " + detail
+	}
+	return line
+}
@@ -0,0 +1,381 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// filePatch is the part of a unified diff that changes one file. The old path
+// is empty when the file is created, the new path when it is deleted.
+type filePatch struct {
+	oldPath string
+	newPath string
+	hunks   []patchHunk
+}
+
+type patchHunk struct {
+	// Line the hunk starts at in the old file, 1-based, or -1 when the diff
+	// doesn't say. Only a hint, hunks are found by their content.
+	oldStart int
+	lines    []patchLine
+	// The last line of the old or new side has no newline.
+	oldNoEOL bool
+	newNoEOL bool
+}
+
+type patchLine struct {
+	// One of ' ', '-' or '+'.
+	op   byte
+	text string
+}
+
+var hunkHeaderRegex = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// parsePatch parses a unified diff that may change several files, as written
+// by git diff or diff -u. Hunk headers without line numbers are accepted, and
+// so are line counts that don't add up, since models often get them wrong.
+func parsePatch(patch string) ([]filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	var files []filePatch
+	var current *filePatch
+	var renameFrom, renameTo string
+
+	flushRename := func() {
+		// A git rename without changes has no --- and +++ lines.
+		if renameFrom != "" && renameTo != "" && (current == nil || current.oldPath != renameFrom || current.newPath != renameTo) {
+			files = append(files, filePatch{oldPath: renameFrom, newPath: renameTo})
+		}
+		renameFrom, renameTo = "", ""
+	}
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flushRename()
+			current = nil
+			i++
+		case strings.HasPrefix(line, "rename from "):
+			renameFrom = strings.TrimPrefix(line, "rename from ")
+			i++
+		case strings.HasPrefix(line, "rename to "):
+			renameTo = strings.TrimPrefix(line, "rename to ")
+			i++
+		case isFileHeader(lines, i):
+			files = append(files, filePatch{
+				oldPath: headerPath(strings.TrimPrefix(lines[i], "--- "), "a/"),
+				newPath: headerPath(strings.TrimPrefix(lines[i+1], "+++ "), "b/"),
+			})
+			current = &files[len(files)-1]
+			i += 2
+		case strings.HasPrefix(line, "@@"):
+			if current == nil {
+				return nil, fmt.Errorf("line %d: hunk without --- and +++ file headers", i+1)
+			}
+			hunk, next, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			current.hunks = append(current.hunks, hunk)
+			i = next
+		default:
+			// Anything else, like git's index lines or text around the
+			// diff, is skipped.
+			i++
+		}
+	}
+	flushRename()
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file changes found, the patch must be a unified diff with --- and +++ file headers")
+	}
+	for _, file := range files {
+		if file.oldPath == "" && file.newPath == "" {
+			return nil, fmt.Errorf("a file change has neither an old nor a new path")
+		}
+		if file.oldPath != "" && file.newPath != "" && len(file.hunks) == 0 && file.oldPath == file.newPath {
+			return nil, fmt.Errorf("%s: no hunks", file.newPath)
+		}
+	}
+	return files, nil
+}
+
+// isFileHeader reports whether the lines at i are the --- and +++ lines that
+// start the changes of a file.
+func isFileHeader(lines []string, i int) bool {
+	return strings.HasPrefix(lines[i], "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")
+}
+
+// headerPath returns the path of a --- or +++ line, without git's a/ or b/
+// prefix and the timestamp diff -u adds. It returns an empty string for
+// /dev/null.
+func headerPath(value, prefix string) string {
+	if tab := strings.IndexByte(value, '\t'); tab >= 0 {
+		value = value[:tab]
+	}
+	value = strings.TrimSpace(value)
+	if value == "/dev/null" {
+		return ""
+	}
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+	return strings.TrimPrefix(value, prefix)
+}
+
+// parseHunk parses the hunk that starts at lines[start] and returns the index
+// of the line after it.
+func parseHunk(lines []string, start int) (patchHunk, int, error) {
+	hunk := patchHunk{oldStart: -1}
+	oldLeft, newLeft := -1, -1
+	if m := hunkHeaderRegex.FindStringSubmatch(lines[start]); m != nil {
+		hunk.oldStart, _ = strconv.Atoi(m[1])
+		oldLeft, newLeft = 1, 1
+		if m[2] != "" {
+			oldLeft, _ = strconv.Atoi(m[2])
+		}
+		if m[4] != "" {
+			newLeft, _ = strconv.Atoi(m[4])
+		}
+	}
+	counted := oldLeft >= 0
+
+	i := start + 1
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, "@@") || strings.HasPrefix(line, "diff --git ") {
+			break
+		}
+		if strings.HasPrefix(line, `\`) {
+			// "\ No newline at end of file" is about the line before it.
+			if n := len(hunk.lines); n > 0 {
+				switch hunk.lines[n-1].op {
+				case '-':
+					hunk.oldNoEOL = true
+				case '+':
+					hunk.newNoEOL = true
+				default:
+					hunk.oldNoEOL, hunk.newNoEOL = true, true
+				}
+			}
+			continue
+		}
+		if counted && oldLeft <= 0 && newLeft <= 0 {
+			break
+		}
+		// The counts can be wrong, a file header always ends the hunk.
+		if isFileHeader(lines, i) && i+2 < len(lines) && strings.HasPrefix(lines[i+2], "@@") {
+			break
+		}
+		if !counted && isFileHeader(lines, i) {
+			break
+		}
+
+		op := byte(' ')
+		text := line
+		if line != "" {
+			op, text = line[0], line[1:]
+		} else if !counted && !hunkContinues(lines, i) {
+			// An empty line is a blank context line whose space was
+			// dropped, unless the hunk ends there.
+			break
+		}
+		switch op {
+		case ' ':
+			oldLeft--
+			newLeft--
+		case '-':
+			oldLeft--
+		case '+':
+			newLeft--
+		default:
+			if counted {
+				return hunk, i, fmt.Errorf("line %d: unexpected line in hunk: %q", i+1, line)
+			}
+			return finishHunk(hunk, i, start)
+		}
+		hunk.lines = append(hunk.lines, patchLine{op: op, text: text})
+	}
+	return finishHunk(hunk, i, start)
+}
+
+func finishHunk(hunk patchHunk, next, start int) (patchHunk, int, error) {
+	if len(hunk.lines) == 0 {
+		return hunk, next, fmt.Errorf("line %d: empty hunk", start+1)
+	}
+	return hunk, next, nil
+}
+
+// hunkContinues reports whether more lines of the hunk follow the empty line
+// at i.
+func hunkContinues(lines []string, i int) bool {
+	for _, line := range lines[i+1:] {
+		if line == "" {
+			continue
+		}
+		switch line[0] {
+		case ' ', '-', '+', '\\':
+			return !isFileHeader(lines, i+1)
+		}
+		return false
+	}
+	return false
+}
+
+// lineMatchers compare a line of a hunk with a line of the file, from the
+// strictest to the loosest.
+var lineMatchers = []func(a, b string) bool{
+	func(a, b string) bool { return a == b },
+	func(a, b string) bool { return strings.TrimRight(a, " \t") == strings.TrimRight(b, " \t") },
+	func(a, b string) bool { return strings.TrimSpace(a) == strings.TrimSpace(b) },
+}
+
+// maxFuzz is the number of context lines that may be left out at each end of
+// a hunk when it can't be found with all of them.
+const maxFuzz = 2
+
+// applyHunks applies the hunks of a file to its content. Hunks are found by
+// their content, closest to the line they say they start at: exactly first,
+// then ignoring whitespace, then with up to maxFuzz context lines left out at
+// each end. Context lines keep the text they have in the file.
+func applyHunks(content string, hunks []patchHunk) (string, error) {
+	crlf := strings.Contains(content, "\r\n")
+	if crlf {
+		content = strings.ReplaceAll(content, "\r\n", "\n")
+	}
+	eol := content == "" || strings.HasSuffix(content, "\n")
+	var lines []string
+	if content != "" {
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+
+	// Lines before from were already patched. offset is the number of lines
+	// the previous hunks added, to adjust the line numbers of the next ones.
+	from, offset := 0, 0
+	for n, hunk := range hunks {
+		hunkLines, pos, ok := locateHunk(lines, hunk, from, offset)
+		if !ok {
+			return "", fmt.Errorf("hunk %d does not match the file, expected:\n%s", n+1, strings.Join(oldSide(hunk.lines), "\n"))
+		}
+
+		var patched []string
+		end := pos
+		for _, line := range hunkLines {
+			switch line.op {
+			case ' ':
+				patched = append(patched, lines[end])
+				end++
+			case '-':
+				end++
+			case '+':
+				patched = append(patched, line.text)
+			}
+		}
+		if end == len(lines) {
+			if hunk.newNoEOL {
+				eol = false
+			} else if hunk.oldNoEOL {
+				eol = true
+			}
+		}
+		lines = slices.Concat(lines[:pos], patched, lines[end:])
+		from = pos + len(patched)
+		offset += len(patched) - (end - pos)
+	}
+
+	result := strings.Join(lines, "\n")
+	if eol && len(lines) > 0 {
+		result += "\n"
+	}
+	if crlf {
+		result = strings.ReplaceAll(result, "\n", "\r\n")
+	}
+	return result, nil
+}
+
+// locateHunk finds where the hunk applies at or after the line from. It
+// returns the lines of the hunk that apply there, which lack the context
+// lines that were left out to find it.
+func locateHunk(lines []string, hunk patchHunk, from, offset int) ([]patchLine, int, bool) {
+	if len(oldSide(hunk.lines)) == 0 {
+		// Only additions, they go where the hunk says or at the end.
+		pos := len(lines)
+		if hunk.oldStart >= 0 {
+			pos = min(max(hunk.oldStart+offset, from), len(lines))
+		}
+		return hunk.lines, pos, true
+	}
+
+	hint := from
+	if hunk.oldStart > 0 {
+		hint = hunk.oldStart - 1 + offset
+	}
+	previous := -1
+	for fuzz := 0; fuzz <= maxFuzz; fuzz++ {
+		hunkLines := trimContext(hunk.lines, fuzz)
+		old := oldSide(hunkLines)
+		if len(hunkLines) == previous || len(old) == 0 {
+			break
+		}
+		previous = len(hunkLines)
+		for _, match := range lineMatchers {
+			if pos, ok := findLines(lines, old, hint, from, match); ok {
+				return hunkLines, pos, true
+			}
+		}
+	}
+	return nil, 0, false
+}
+
+// trimContext returns the hunk lines without up to n context lines at each
+// end.
+func trimContext(lines []patchLine, n int) []patchLine {
+	start, end := 0, len(lines)
+	for start < n && start < end && lines[start].op == ' ' {
+		start++
+	}
+	for len(lines)-end < n && end > start && lines[end-1].op == ' ' {
+		end--
+	}
+	return lines[start:end]
+}
+
+// findLines returns the position at or after from where the file has the
+// lines, the closest to hint when there are several.
+func findLines(lines, want []string, hint, from int, match func(a, b string) bool) (int, bool) {
+	best, found := 0, false
+	for pos := from; pos+len(want) <= len(lines); pos++ {
+		ok := true
+		for i, line := range want {
+			if !match(lines[pos+i], line) {
+				ok = false
+				break
+			}
+		}
+		if ok && (!found || abs(pos-hint) < abs(best-hint)) {
+			best, found = pos, true
+		}
+	}
+	return best, found
+}
+
+// oldSide returns the lines the hunk expects in the file.
+func oldSide(lines []patchLine) []string {
+	var old []string
+	for _, line := range lines {
+		if line.op != '+' {
+			old = append(old, line.text)
+		}
+	}
+	return old
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,180 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePatch(t *testing.T) {
+	t.Parallel()
+
+	patch := `Some text the model wrote before the diff.
+diff --git a/main.go b/main.go
+index 83db48f..bf269f4 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@
+ package main
+-var a = 1
++var a = 2
+
+diff --git a/new.go b/new.go
+new file mode 100644
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1,2 @@
++package main
++var b = 3
+diff --git a/old.go b/old.go
+deleted file mode 100644
+--- a/old.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package main
+diff --git a/from.go b/to.go
+similarity index 100%
+rename from from.go
+rename to to.go
+`
+	files, err := parsePatch(patch)
+	require.NoError(t, err)
+	require.Len(t, files, 4)
+
+	require.Equal(t, "main.go", files[0].oldPath)
+	require.Equal(t, "main.go", files[0].newPath)
+	require.Len(t, files[0].hunks, 1)
+	require.Equal(t, 1, files[0].hunks[0].oldStart)
+	require.Equal(t, []patchLine{
+		{' ', "package main"},
+		{'-', "var a = 1"},
+		{'+', "var a = 2"},
+		{' ', ""},
+	}, files[0].hunks[0].lines)
+
+	require.Empty(t, files[1].oldPath)
+	require.Equal(t, "new.go", files[1].newPath)
+	require.Equal(t, "old.go", files[2].oldPath)
+	require.Empty(t, files[2].newPath)
+	require.Equal(t, filePatch{oldPath: "from.go", newPath: "to.go"}, files[3])
+}
+
+func TestParsePatchLenient(t *testing.T) {
+	t.Parallel()
+
+	// Headers without line numbers and blank context lines without their
+	// space, as models write them.
+	files, err := parsePatch(`--- a.go
++++ a.go
+@@
+ func a() {
+
+-	return 1
++	return 2
+ }
+@@
+-x
++y
+`)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Len(t, files[0].hunks, 2)
+	require.Equal(t, -1, files[0].hunks[0].oldStart)
+	require.Len(t, files[0].hunks[0].lines, 5)
+
+	// Wrong counts don't swallow the next file.
+	files, err = parsePatch(`--- a/a.go
++++ b/a.go
+@@ -1,9 +1,9 @@
+-x
++y
+--- a/b.go
++++ b/b.go
+@@ -1 +1 @@
+-x
++y
+`)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	require.Len(t, files[0].hunks[0].lines, 2)
+
+	_, err = parsePatch("not a diff")
+	require.Error(t, err)
+	_, err = parsePatch("@@ -1 +1 @@\n-x\n+y\n")
+	require.Error(t, err)
+}
+
+func TestApplyHunks(t *testing.T) {
+	t.Parallel()
+
+	apply := func(content, patch string) (string, error) {
+		files, err := parsePatch("--- a/f\n+++ b/f\n" + patch)
+		require.NoError(t, err)
+		return applyHunks(content, files[0].hunks)
+	}
+
+	content := "a\nb\nc\nd\ne\nf\ng\n"
+
+	t.Run("exact", func(t *testing.T) {
+		t.Parallel()
+		result, err := apply(content, "@@ -2,3 +2,3 @@\n b\n-c\n+C\n d\n@@ -6,2 +6,3 @@\n f\n+F\n g\n")
+		require.NoError(t, err)
+		require.Equal(t, "a\nb\nC\nd\ne\nf\nF\ng\n", result)
+	})
+
+	t.Run("wrong line numbers", func(t *testing.T) {
+		t.Parallel()
+		result, err := apply(content, "@@ -40,3 +40,3 @@\n d\n-e\n+E\n f\n")
+		require.NoError(t, err)
+		require.Equal(t, "a\nb\nc\nd\nE\nf\ng\n", result)
+	})
+
+	t.Run("closest to the line number", func(t *testing.T) {
+		t.Parallel()
+		result, err := apply("x\ny\nx\ny\nx\n", "@@ -3,2 +3,2 @@\n x\n-y\n+z\n")
+		require.NoError(t, err)
+		require.Equal(t, "x\ny\nx\nz\nx\n", result)
+	})
+
+	t.Run("whitespace", func(t *testing.T) {
+		t.Parallel()
+		result, err := apply("func a() {\n\treturn 1\n}\n", "@@\n func a() {\n-    return 1\n+\treturn 2\n }\n")
+		require.NoError(t, err)
+		require.Equal(t, "func a() {\n\treturn 2\n}\n", result)
+	})
+
+	t.Run("fuzz", func(t *testing.T) {
+		t.Parallel()
+		result, err := apply(content, "@@ -1,5 +1,5 @@\n stale\n b\n-c\n+C\n d\n stale\n")
+		require.NoError(t, err)
+		require.Equal(t, "a\nb\nC\nd\ne\nf\ng\n", result)
+
+		_, err = apply(content, "@@ -2,3 +2,3 @@\n b\n-x\n+C\n d\n")
+		require.Error(t, err)
+	})
+
+	t.Run("create", func(t *testing.T) {
+		t.Parallel()
+		result, err := apply("", "@@ -0,0 +1,2 @@\n+one\n+two\n")
+		require.NoError(t, err)
+		require.Equal(t, "one\ntwo\n", result)
+	})
+
+	t.Run("no newline at end of file", func(t *testing.T) {
+		t.Parallel()
+		result, err := apply("a\nb", "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n")
+		require.NoError(t, err)
+		require.Equal(t, "a\nc\n", result)
+
+		result, err = apply("a\nb\n", "@@ -1,2 +1,2 @@\n a\n-b\n+c\n\\ No newline at end of file\n")
+		require.NoError(t, err)
+		require.Equal(t, "a\nc", result)
+	})
+
+	t.Run("crlf", func(t *testing.T) {
+		t.Parallel()
+		result, err := apply("a\r\nb\r\n", "@@ -1,2 +1,2 @@\n a\n-b\n+c\n")
+		require.NoError(t, err)
+		require.Equal(t, "a\r\nc\r\n", result)
+	})
+}
@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+)
+
+type ReferencesParams struct {
+	FilePath           string `json:"file_path"`
+	Line               int    `json:"line"`
+	Symbol             string `json:"symbol,omitempty"`
+	Column             int    `json:"column,omitempty"`
+	IncludeDeclaration bool   `json:"include_declaration,omitempty"`
+}
+
+type referencesTool struct {
+	lspClients map[string]*lsp.Client
+	workingDir string
+}
+
+const (
+	ReferencesToolName    = "references"
+	referencesDescription = `Finds every use of a symbol across the project, using the language servers of the project.
+WHEN TO USE THIS TOOL:
+- Use to find the callers of a function, the uses of a type or the reads and writes of a field
+- Use before changing a signature, to see everything the change affects
+- Prefer it over grep for identifiers, it only lists uses of this symbol and not of others with the same name
+HOW TO USE:
+- Give the file and line where the symbol appears, its definition or any use of it, and the symbol itself
+- Set include_declaration to also list the declaration
+- Results are listed as path:line:column followed by the text of the line
+LIMITATIONS:
+- Only works for files a configured language server handles
+- Lists at most 100 references
+TIPS:
+- Use the call_hierarchy tool to see which functions call a function
+`
+)
+
+func NewReferencesTool(lspClients map[string]*lsp.Client, workingDir string) BaseTool {
+	return &referencesTool{
+		lspClients: lspClients,
+		workingDir: workingDir,
+	}
+}
+
+func (r *referencesTool) Name() string {
+	return ReferencesToolName
+}
+
+func (r *referencesTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        ReferencesToolName,
+		Description: referencesDescription,
+		Parameters: withPositionParameters(map[string]any{
+			"include_declaration": map[string]any{
+				"type":        "boolean",
+				"description": "Also list the declaration of the symbol (default false)",
+			},
+		}),
+		Required: []string{"file_path", "line"},
+	}
+}
+
+func (r *referencesTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params ReferencesParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+
+	if params.FilePath == "" {
+		return NewTextErrorResponse("file_path is required"), nil
+	}
+	if !filepath.IsAbs(params.FilePath) {
+		params.FilePath = filepath.Join(r.workingDir, params.FilePath)
+	}
+
+	pos, err := lspPosition(params.FilePath, params.Line, params.Column, params.Symbol)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	locations, err := firstLspResult(ctx, r.lspClients, params.FilePath, func(client *lsp.Client) ([]protocol.Location, error) {
+		return client.References(ctx, protocol.ReferenceParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(params.FilePath)},
+				Position:     pos,
+			},
+			Context: protocol.ReferenceContext{IncludeDeclaration: params.IncludeDeclaration},
+		})
+	})
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to find references: %s", err)), nil
+	}
+	if len(locations) == 0 {
+		return NewTextResponse("No references found"), nil
+	}
+
+	output := fmt.Sprintf("%d references\n", len(locations))
+	output += newLocationFormatter(r.workingDir).locations(locations)
+	return NewTextResponse(output), nil
+}
@@ -0,0 +1,171 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+type RenameSymbolParams struct {
+	FilePath string `json:"file_path"`
+	Line     int    `json:"line"`
+	Symbol   string `json:"symbol,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	NewName  string `json:"new_name"`
+}
+
+type RenameSymbolPermissionsParams struct {
+	FilePath string        `json:"file_path"`
+	Symbol   string        `json:"symbol,omitempty"`
+	NewName  string        `json:"new_name"`
+	Files    []PatchedFile `json:"files"`
+}
+
+type renameSymbolTool struct {
+	lspClients  map[string]*lsp.Client
+	permissions permission.Service
+	files       history.Service
+	workingDir  string
+}
+
+const (
+	RenameSymbolToolName    = "rename_symbol"
+	renameSymbolDescription = `Renames a symbol everywhere it is used in the project, using the language servers of the project.
+WHEN TO USE THIS TOOL:
+- Use to rename a function, type, method, field, variable, constant or package across all the files that use it
+- Prefer it over Edit, MultiEdit or apply_patch for renames, the language server finds every use and leaves unrelated text with the same name alone
+HOW TO USE:
+- Give the file and line where the symbol appears, its declaration or any use of it, and its current name as the symbol
+- Give the new name, without any qualifier
+- The user approves the changes to all the files at once
+- The changed files and the diagnostics after the rename are returned
+LIMITATIONS:
+- Only works for files a configured language server handles
+- Text the language server doesn't know about, like comments, docs or strings, may keep the old name
+TIPS:
+- Use the references tool first to see where the symbol is used
+- Check the returned diagnostics for conflicts with existing names
+`
+)
+
+func NewRenameSymbolTool(lspClients map[string]*lsp.Client, permissions permission.Service, files history.Service, workingDir string) BaseTool {
+	return &renameSymbolTool{
+		lspClients:  lspClients,
+		permissions: permissions,
+		files:       files,
+		workingDir:  workingDir,
+	}
+}
+
+func (r *renameSymbolTool) Name() string {
+	return RenameSymbolToolName
+}
+
+func (r *renameSymbolTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        RenameSymbolToolName,
+		Description: renameSymbolDescription,
+		Parameters: withPositionParameters(map[string]any{
+			"new_name": map[string]any{
+				"type":        "string",
+				"description": "The new name of the symbol",
+			},
+		}),
+		Required: []string{"file_path", "line", "new_name"},
+	}
+}
+
+func (r *renameSymbolTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params RenameSymbolParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+
+	if params.FilePath == "" {
+		return NewTextErrorResponse("file_path is required"), nil
+	}
+	if params.NewName == "" {
+		return NewTextErrorResponse("new_name is required"), nil
+	}
+	if !filepath.IsAbs(params.FilePath) {
+		params.FilePath = filepath.Join(r.workingDir, params.FilePath)
+	}
+
+	sessionID, messageID := GetContextValues(ctx)
+	if sessionID == "" || messageID == "" {
+		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for renaming a symbol")
+	}
+
+	pos, err := lspPosition(params.FilePath, params.Line, params.Column, params.Symbol)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	textDocumentPosition := protocol.TextDocumentPositionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(params.FilePath)},
+		Position:     pos,
+	}
+	edits, err := firstLspResult(ctx, r.lspClients, params.FilePath, func(client *lsp.Client) ([]protocol.WorkspaceEdit, error) {
+		// Not every server supports preparing a rename, so only a successful
+		// answer that there is nothing to rename is taken into account.
+		prepared, err := client.PrepareRename(ctx, protocol.PrepareRenameParams{TextDocumentPositionParams: textDocumentPosition})
+		if err == nil && prepared.Value == nil {
+			return nil, fmt.Errorf("no symbol that can be renamed at line %d", params.Line)
+		}
+		edit, err := client.Rename(ctx, protocol.RenameParams{
+			TextDocument: textDocumentPosition.TextDocument,
+			Position:     pos,
+			NewName:      params.NewName,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(edit.Changes) == 0 && len(edit.DocumentChanges) == 0 {
+			return nil, nil
+		}
+		return []protocol.WorkspaceEdit{edit}, nil
+	})
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to rename symbol: %s", err)), nil
+	}
+	if len(edits) == 0 {
+		return NewTextErrorResponse("the language server found nothing to rename at this position"), nil
+	}
+
+	files, err := workspaceEditFiles(edits[0])
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to prepare the rename: %s", err)), nil
+	}
+	if len(files) == 0 {
+		return NewTextErrorResponse("the rename doesn't change any file"), nil
+	}
+
+	description := fmt.Sprintf("Rename to %s in %d files", params.NewName, len(files))
+	if params.Symbol != "" {
+		description = fmt.Sprintf("Rename %s to %s in %d files", params.Symbol, params.NewName, len(files))
+	}
+	p := requestPatchedFilesPermission(r.permissions, permission.CreatePermissionRequest{
+		SessionID:   sessionID,
+		ToolCallID:  call.ID,
+		ToolName:    RenameSymbolToolName,
+		Action:      "write",
+		Description: description,
+		Params: RenameSymbolPermissionsParams{
+			FilePath: params.FilePath,
+			Symbol:   params.Symbol,
+			NewName:  params.NewName,
+			Files:    files,
+		},
+	}, files, r.workingDir)
+	if !p {
+		return ToolResponse{}, permission.ErrorPermissionDenied
+	}
+
+	return applyWorkspaceEditFiles(ctx, r.lspClients, r.files, sessionID, r.workingDir, description, files)
+}
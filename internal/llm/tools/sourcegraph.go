@@ -125,15 +125,13 @@ TIPS:
 - Use type:file to find relevant files`
 )
 
-func NewSourcegraphTool() BaseTool {
+// NewSourcegraphTool creates the sourcegraph tool. Its requests go through
+// the given transport, or the default one when it is nil.
+func NewSourcegraphTool(transport *http.Transport) BaseTool {
 	return &sourcegraphTool{
 		client: &http.Client{
-			Timeout: 30 * time.Second,
-			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 10,
-				IdleConnTimeout:     90 * time.Second,
-			},
+			Timeout:   30 * time.Second,
+			Transport: pooledTransport(transport),
 		},
 	}
 }
@@ -0,0 +1,124 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+)
+
+type SymbolsParams struct {
+	Query string `json:"query"`
+}
+
+type symbolsTool struct {
+	lspClients map[string]*lsp.Client
+	workingDir string
+}
+
+const (
+	SymbolsToolName    = "symbols"
+	symbolsDescription = `Searches the symbols of the whole project by name, using the language servers of the project.
+WHEN TO USE THIS TOOL:
+- Use to find where a function, type, method, constant or variable is declared when you know its name but not its file
+- Prefer it over grep for declarations, it lists the declarations and not every line the name appears on
+HOW TO USE:
+- Give the name, or part of it, as the query; most language servers match it fuzzily
+- Results are listed as path:line:column, the kind of symbol and its name, and the symbol that contains it
+LIMITATIONS:
+- Only covers the languages of the configured language servers
+- Lists at most 100 symbols
+TIPS:
+- Use the outline tool to list the symbols of a single file
+- Use the definition or references tools from a result to follow the code
+`
+)
+
+func NewSymbolsTool(lspClients map[string]*lsp.Client, workingDir string) BaseTool {
+	return &symbolsTool{
+		lspClients: lspClients,
+		workingDir: workingDir,
+	}
+}
+
+func (s *symbolsTool) Name() string {
+	return SymbolsToolName
+}
+
+func (s *symbolsTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        SymbolsToolName,
+		Description: symbolsDescription,
+		Parameters: map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "The name of the symbols to find, or part of it",
+			},
+		},
+		Required: []string{"query"},
+	}
+}
+
+func (s *symbolsTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params SymbolsParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+
+	if params.Query == "" {
+		return NewTextErrorResponse("query is required"), nil
+	}
+	if len(s.lspClients) == 0 {
+		return NewTextErrorResponse("no language servers are running"), nil
+	}
+
+	// Each server knows the symbols of its own languages, so all of them are
+	// asked.
+	formatter := newLocationFormatter(s.workingDir)
+	var lines, errs []string
+	for _, name := range slices.Sorted(maps.Keys(s.lspClients)) {
+		result, err := s.lspClients[name].Symbol(ctx, protocol.WorkspaceSymbolParams{Query: params.Query})
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		symbols, err := result.Results()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		for _, symbol := range symbols {
+			lines = append(lines, formatWorkspaceSymbol(formatter, symbol))
+		}
+	}
+	if len(lines) == 0 {
+		if len(errs) > 0 {
+			return NewTextErrorResponse(fmt.Sprintf("failed to search symbols: %s", strings.Join(errs, "; "))), nil
+		}
+		return NewTextResponse("No symbols found"), nil
+	}
+
+	return NewTextResponse(fmt.Sprintf("%d symbols\n%s", len(lines), truncateNavigationLines(lines))), nil
+}
+
+func formatWorkspaceSymbol(formatter *locationFormatter, symbol protocol.WorkspaceSymbolResult) string {
+	var kind protocol.SymbolKind
+	var container string
+	switch v := symbol.(type) {
+	case *protocol.WorkspaceSymbol:
+		kind, container = v.Kind, v.ContainerName
+	case *protocol.SymbolInformation:
+		kind, container = v.Kind, v.ContainerName
+	}
+	loc := symbol.GetLocation()
+	line := fmt.Sprintf("%s: %s %s", formatter.position(loc.URI, loc.Range.Start), symbolKind(kind), symbol.GetName())
+	if container != "" {
+		line += " in " + container
+	}
+	return line
+}
@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+	"github.com/charmbracelet/crush/internal/lsp/util"
+)
+
+// WorkspaceEditResponseMetadata is the metadata of the tools that apply a
+// workspace edit from a language server.
+type WorkspaceEditResponseMetadata struct {
+	Files     []PatchedFile `json:"files"`
+	Additions int           `json:"additions"`
+	Removals  int           `json:"removals"`
+}
+
+// workspaceEditFiles returns the files a workspace edit changes, without
+// changing them.
+func workspaceEditFiles(edit protocol.WorkspaceEdit) ([]PatchedFile, error) {
+	changes, err := util.PreviewWorkspaceEdit(edit)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]PatchedFile, 0, len(changes))
+	for _, change := range changes {
+		files = append(files, PatchedFile{
+			Path:       change.Path,
+			OldPath:    change.OldPath,
+			OldContent: change.OldContent,
+			NewContent: change.NewContent,
+			Created:    change.Created,
+			Deleted:    change.Deleted,
+		})
+	}
+	return files, nil
+}
+
+// applyWorkspaceEditFiles writes the files of an approved workspace edit and
+// returns the response of the tool, starting with the summary line.
+func applyWorkspaceEditFiles(ctx context.Context, lsps map[string]*lsp.Client, fileHistory history.Service, sessionID, workingDir, summary string, files []PatchedFile) (ToolResponse, error) {
+	additions, removals := patchedFilesStats(files, workingDir)
+
+	lines, lastWritten, err := writePatchedFiles(ctx, fileHistory, sessionID, files)
+	if err != nil {
+		return ToolResponse{}, err
+	}
+	notifyLspPatchedFiles(ctx, lsps, files, lastWritten)
+
+	text := fmt.Sprintf("<result>\n%s:\n%s\n</result>\n", summary, strings.Join(lines, "\n"))
+	if lastWritten != "" {
+		waitForLspDiagnostics(ctx, lastWritten, lsps)
+		text += getDiagnostics(lastWritten, lsps)
+	}
+	return WithResponseMetadata(
+		NewTextResponse(text),
+		WorkspaceEditResponseMetadata{
+			Files:     files,
+			Additions: additions,
+			Removals:  removals,
+		},
+	), nil
+}
@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaceEditFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.go")
+	b := filepath.Join(dir, "b.go")
+	c := filepath.Join(dir, "c.go")
+	require.NoError(t, os.WriteFile(a, []byte("package a\n\nfunc Old() {}\n"), 0o644))
+	require.NoError(t, os.WriteFile(b, []byte("package a\n\nvar _ = Old\n"), 0o644))
+
+	rename := func(line, character uint32) protocol.TextEdit {
+		return protocol.TextEdit{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: line, Character: character},
+				End:   protocol.Position{Line: line, Character: character + 3},
+			},
+			NewText: "New",
+		}
+	}
+	files, err := workspaceEditFiles(protocol.WorkspaceEdit{
+		Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+			protocol.URIFromPath(b): {rename(2, 8)},
+			protocol.URIFromPath(a): {rename(2, 5)},
+		},
+		DocumentChanges: []protocol.DocumentChange{
+			{RenameFile: &protocol.RenameFile{OldURI: protocol.URIFromPath(b), NewURI: protocol.URIFromPath(c)}},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []PatchedFile{
+		{Path: a, OldContent: "package a\n\nfunc Old() {}\n", NewContent: "package a\n\nfunc New() {}\n"},
+		{Path: c, OldPath: b, OldContent: "package a\n\nvar _ = Old\n", NewContent: "package a\n\nvar _ = New\n"},
+	}, files)
+
+	// Nothing is written before the edit is approved.
+	content, err := os.ReadFile(a)
+	require.NoError(t, err)
+	require.Equal(t, "package a\n\nfunc Old() {}\n", string(content))
+
+	_, err = workspaceEditFiles(protocol.WorkspaceEdit{
+		DocumentChanges: []protocol.DocumentChange{
+			{CreateFile: &protocol.CreateFile{URI: protocol.URIFromPath(a)}},
+		},
+	})
+	require.ErrorContains(t, err, "file already exists")
+}
+
+func TestFindCodeAction(t *testing.T) {
+	t.Parallel()
+
+	actions := []protocol.CodeAction{
+		{Title: "Organize Imports", Kind: protocol.SourceOrganizeImports},
+		{Title: "Add import: \"fmt\"", Kind: protocol.QuickFix, IsPreferred: true},
+		{Title: "Add import: \"fmt/v2\"", Kind: protocol.QuickFix},
+	}
+
+	action, err := findCodeAction(actions, "Add import: \"fmt\"")
+	require.NoError(t, err)
+	require.Equal(t, actions[1], action)
+
+	action, err = findCodeAction(actions, "organize")
+	require.NoError(t, err)
+	require.Equal(t, actions[0], action)
+
+	_, err = findCodeAction(actions, "add import")
+	require.ErrorContains(t, err, "2 code actions match")
+	_, err = findCodeAction(actions, "extract")
+	require.ErrorContains(t, err, "no code action matches")
+
+	require.Equal(t, "3 code actions:\n"+
+		"- Organize Imports (source.organizeImports)\n"+
+		"- Add import: \"fmt\" (quickfix, preferred)\n"+
+		"- Add import: \"fmt/v2\" (quickfix)", formatCodeActions(actions))
+}
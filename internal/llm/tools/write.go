@@ -200,7 +200,11 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 	// Check if file exists in history
 	file, err := w.files.GetByPathAndSession(ctx, filePath, sessionID)
 	if err != nil {
-		_, err = w.files.Create(ctx, sessionID, filePath, oldContent)
+		if fileInfo == nil {
+			_, err = w.files.CreateAbsent(ctx, sessionID, messageID, filePath)
+		} else {
+			_, err = w.files.Create(ctx, sessionID, messageID, filePath, oldContent)
+		}
 		if err != nil {
 			// Log error but don't fail the operation
 			return ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
@@ -208,13 +212,13 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 	}
 	if file.Content != oldContent {
 		// User Manually changed the content store an intermediate version
-		_, err = w.files.CreateVersion(ctx, sessionID, filePath, oldContent)
+		_, err = w.files.CreateVersion(ctx, sessionID, messageID, filePath, oldContent)
 		if err != nil {
 			slog.Debug("Error creating file history version", "error", err)
 		}
 	}
 	// Store the new version
-	_, err = w.files.CreateVersion(ctx, sessionID, filePath, params.Content)
+	_, err = w.files.CreateVersion(ctx, sessionID, messageID, filePath, params.Content)
 	if err != nil {
 		slog.Debug("Error creating file history version", "error", err)
 	}
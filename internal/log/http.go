@@ -11,14 +11,19 @@ import (
 	"time"
 )
 
-// NewHTTPClient creates an HTTP client with debug logging enabled when debug mode is on.
-func NewHTTPClient() *http.Client {
+// NewHTTPClient creates an HTTP client that sends requests through the
+// transport, or the default one when it is nil, with debug logging enabled
+// when debug mode is on.
+func NewHTTPClient(transport http.RoundTripper) *http.Client {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
 	if !slog.Default().Enabled(context.TODO(), slog.LevelDebug) {
-		return http.DefaultClient
+		return &http.Client{Transport: transport}
 	}
 	return &http.Client{
 		Transport: &HTTPRoundTripLogger{
-			Transport: http.DefaultTransport,
+			Transport: transport,
 		},
 	}
 }
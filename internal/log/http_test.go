@@ -18,7 +18,7 @@ func TestHTTPRoundTripLogger(t *testing.T) {
 	defer server.Close()
 
 	// Create HTTP client with logging
-	client := NewHTTPClient()
+	client := NewHTTPClient(http.DefaultTransport)
 
 	// Make a request
 	req, err := http.NewRequestWithContext(
@@ -175,6 +175,15 @@ func (c *Client) InitializeLSPClient(ctx context.Context, workspaceDir string) (
 								ValueSet: []protocol.CodeActionKind{},
 							},
 						},
+						IsPreferredSupport: true,
+						DisabledSupport:    true,
+						DataSupport:        true,
+						ResolveSupport: &protocol.ClientCodeActionResolveOptions{
+							Properties: []string{"edit"},
+						},
+					},
+					Rename: &protocol.RenameClientCapabilities{
+						PrepareSupport: true,
 					},
 					PublishDiagnostics: protocol.PublishDiagnosticsClientCapabilities{
 						VersionSupport: true,
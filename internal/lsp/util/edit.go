@@ -1,7 +1,6 @@
 package util
 
 import (
-	"bytes"
 	"fmt"
 	"os"
 	"sort"
@@ -22,25 +21,38 @@ func applyTextEdits(uri protocol.DocumentURI, edits []protocol.TextEdit) error {
 		return fmt.Errorf("failed to read file: %w", err)
 	}
 
+	newContent, err := applyTextEditsToContent(string(content), edits)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, []byte(newContent), 0o644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
+
+func applyTextEditsToContent(content string, edits []protocol.TextEdit) (string, error) {
 	// Detect line ending style
 	var lineEnding string
-	if bytes.Contains(content, []byte("\r\n")) {
+	if strings.Contains(content, "\r\n") {
 		lineEnding = "\r\n"
 	} else {
 		lineEnding = "\n"
 	}
 
 	// Track if file ends with a newline
-	endsWithNewline := len(content) > 0 && bytes.HasSuffix(content, []byte(lineEnding))
+	endsWithNewline := len(content) > 0 && strings.HasSuffix(content, lineEnding)
 
 	// Split into lines without the endings
-	lines := strings.Split(string(content), lineEnding)
+	lines := strings.Split(content, lineEnding)
 
 	// Check for overlapping edits
 	for i, edit1 := range edits {
 		for j := i + 1; j < len(edits); j++ {
 			if rangesOverlap(edit1.Range, edits[j].Range) {
-				return fmt.Errorf("overlapping edits detected between edit %d and %d", i, j)
+				return "", fmt.Errorf("overlapping edits detected between edit %d and %d", i, j)
 			}
 		}
 	}
@@ -59,7 +71,7 @@ func applyTextEdits(uri protocol.DocumentURI, edits []protocol.TextEdit) error {
 	for _, edit := range sortedEdits {
 		newLines, err := applyTextEdit(lines, edit)
 		if err != nil {
-			return fmt.Errorf("failed to apply edit: %w", err)
+			return "", fmt.Errorf("failed to apply edit: %w", err)
 		}
 		lines = newLines
 	}
@@ -78,11 +90,7 @@ func applyTextEdits(uri protocol.DocumentURI, edits []protocol.TextEdit) error {
 		newContent.WriteString(lineEnding)
 	}
 
-	if err := os.WriteFile(path, []byte(newContent.String()), 0o644); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
-	}
-
-	return nil
+	return newContent.String(), nil
 }
 
 func applyTextEdit(lines []string, edit protocol.TextEdit) ([]string, error) {
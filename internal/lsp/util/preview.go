@@ -0,0 +1,197 @@
+package util
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+)
+
+// FileChange is the change a WorkspaceEdit makes to a file.
+type FileChange struct {
+	Path string
+	// Set when the file is renamed.
+	OldPath    string
+	OldContent string
+	NewContent string
+	Created    bool
+	Deleted    bool
+}
+
+// PreviewWorkspaceEdit returns the changes the edit makes to each file,
+// without making them. Files the edit leaves as they are are left out.
+func PreviewWorkspaceEdit(edit protocol.WorkspaceEdit) ([]FileChange, error) {
+	p := &workspacePreview{byPath: make(map[string]*FileChange)}
+
+	for _, uri := range slices.Sorted(maps.Keys(edit.Changes)) {
+		if err := p.textEdits(uri, edit.Changes[uri]); err != nil {
+			return nil, err
+		}
+	}
+	for _, change := range edit.DocumentChanges {
+		if err := p.documentChange(change); err != nil {
+			return nil, err
+		}
+	}
+
+	var changes []FileChange
+	for _, change := range p.changes {
+		if change.OldContent == change.NewContent && !change.Created && !change.Deleted && change.OldPath == "" {
+			continue
+		}
+		changes = append(changes, *change)
+	}
+	return changes, nil
+}
+
+// workspacePreview keeps the content of the files a WorkspaceEdit changes,
+// in the order it first changes them.
+type workspacePreview struct {
+	changes []*FileChange
+	byPath  map[string]*FileChange
+}
+
+// file returns the change of the file at path, read from disk the first time.
+func (p *workspacePreview) file(path string) (*FileChange, error) {
+	if change, ok := p.byPath[path]; ok {
+		if change.Deleted {
+			return nil, fmt.Errorf("file was deleted by the edit: %s", path)
+		}
+		return change, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	change := &FileChange{Path: path, OldContent: string(content), NewContent: string(content)}
+	p.add(change)
+	return change, nil
+}
+
+func (p *workspacePreview) add(change *FileChange) {
+	p.changes = append(p.changes, change)
+	p.byPath[change.Path] = change
+}
+
+func (p *workspacePreview) remove(path string) {
+	delete(p.byPath, path)
+	p.changes = slices.DeleteFunc(p.changes, func(change *FileChange) bool {
+		return change.Path == path
+	})
+}
+
+func (p *workspacePreview) exists(path string) bool {
+	if change, ok := p.byPath[path]; ok {
+		return !change.Deleted
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (p *workspacePreview) textEdits(uri protocol.DocumentURI, edits []protocol.TextEdit) error {
+	path, err := uri.Path()
+	if err != nil {
+		return fmt.Errorf("invalid URI: %w", err)
+	}
+	change, err := p.file(path)
+	if err != nil {
+		return err
+	}
+	change.NewContent, err = applyTextEditsToContent(change.NewContent, edits)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func (p *workspacePreview) documentChange(change protocol.DocumentChange) error {
+	switch {
+	case change.CreateFile != nil:
+		path, err := change.CreateFile.URI.Path()
+		if err != nil {
+			return fmt.Errorf("invalid URI: %w", err)
+		}
+		options := change.CreateFile.Options
+		if p.exists(path) {
+			if options != nil && options.IgnoreIfExists && !options.Overwrite {
+				return nil
+			}
+			if options == nil || !options.Overwrite {
+				return fmt.Errorf("file already exists: %s", path)
+			}
+			existing, err := p.file(path)
+			if err != nil {
+				return err
+			}
+			existing.NewContent = ""
+			return nil
+		}
+		if existing, ok := p.byPath[path]; ok {
+			// Deleted earlier in the same edit.
+			existing.Deleted = false
+			existing.NewContent = ""
+			return nil
+		}
+		p.add(&FileChange{Path: path, Created: true})
+
+	case change.DeleteFile != nil:
+		path, err := change.DeleteFile.URI.Path()
+		if err != nil {
+			return fmt.Errorf("invalid URI: %w", err)
+		}
+		if !p.exists(path) && change.DeleteFile.Options != nil && change.DeleteFile.Options.IgnoreIfNotExists {
+			return nil
+		}
+		existing, err := p.file(path)
+		if err != nil {
+			return err
+		}
+		if existing.Created {
+			p.remove(path)
+			return nil
+		}
+		existing.Deleted = true
+		existing.NewContent = ""
+
+	case change.RenameFile != nil:
+		oldPath, err := change.RenameFile.OldURI.Path()
+		if err != nil {
+			return fmt.Errorf("invalid URI: %w", err)
+		}
+		newPath, err := change.RenameFile.NewURI.Path()
+		if err != nil {
+			return fmt.Errorf("invalid URI: %w", err)
+		}
+		if p.exists(newPath) {
+			if options := change.RenameFile.Options; options != nil && options.IgnoreIfExists {
+				return nil
+			}
+			return fmt.Errorf("cannot rename %s, file already exists: %s", oldPath, newPath)
+		}
+		existing, err := p.file(oldPath)
+		if err != nil {
+			return err
+		}
+		p.remove(oldPath)
+		moved := *existing
+		moved.Path = newPath
+		if moved.OldPath == "" && !moved.Created {
+			moved.OldPath = oldPath
+		}
+		p.add(&moved)
+
+	case change.TextDocumentEdit != nil:
+		edits := make([]protocol.TextEdit, len(change.TextDocumentEdit.Edits))
+		for i, edit := range change.TextDocumentEdit.Edits {
+			var err error
+			edits[i], err = edit.AsTextEdit()
+			if err != nil {
+				return fmt.Errorf("invalid edit type: %w", err)
+			}
+		}
+		return p.textEdits(change.TextDocumentEdit.TextDocument.URI, edits)
+	}
+	return nil
+}
@@ -26,6 +26,7 @@ const (
 	FinishReasonCanceled         FinishReason = "canceled"
 	FinishReasonError            FinishReason = "error"
 	FinishReasonPermissionDenied FinishReason = "permission_denied"
+	FinishReasonBudgetExceeded   FinishReason = "budget_exceeded"
 
 	// Should never happen
 	FinishReasonUnknown FinishReason = "unknown"
@@ -40,6 +41,18 @@ type ReasoningContent struct {
 	Signature  string `json:"signature"`
 	StartedAt  int64  `json:"started_at,omitempty"`
 	FinishedAt int64  `json:"finished_at,omitempty"`
+
+	// The reasoning items of the OpenAI Responses API, sent back on the next
+	// turn so the model keeps its reasoning.
+	Items []ReasoningItem `json:"items,omitempty"`
+}
+
+// ReasoningItem is a reasoning item of the OpenAI Responses API, one of the
+// several a response can hold.
+type ReasoningItem struct {
+	ID               string   `json:"id"`
+	EncryptedContent string   `json:"encrypted_content"`
+	Summary          []string `json:"summary,omitempty"`
 }
 
 func (tc ReasoningContent) String() string {
@@ -113,6 +126,15 @@ type Finish struct {
 
 func (Finish) isPart() {}
 
+// Summary marks a message as the summary of the conversation before it. When
+// KeepFromMessageID is set, the messages from that one on were left out of
+// the summary and stay in the history verbatim.
+type Summary struct {
+	KeepFromMessageID string `json:"keep_from_message_id,omitempty"`
+}
+
+func (Summary) isPart() {}
+
 type Message struct {
 	ID        string
 	Role      MessageRole
@@ -200,6 +222,15 @@ func (m *Message) FinishPart() *Finish {
 	return nil
 }
 
+func (m *Message) SummaryPart() *Summary {
+	for _, part := range m.Parts {
+		if c, ok := part.(Summary); ok {
+			return &c
+		}
+	}
+	return nil
+}
+
 func (m *Message) FinishReason() FinishReason {
 	for _, part := range m.Parts {
 		if c, ok := part.(Finish); ok {
@@ -233,12 +264,8 @@ func (m *Message) AppendReasoningContent(delta string) {
 	found := false
 	for i, part := range m.Parts {
 		if c, ok := part.(ReasoningContent); ok {
-			m.Parts[i] = ReasoningContent{
-				Thinking:   c.Thinking + delta,
-				Signature:  c.Signature,
-				StartedAt:  c.StartedAt,
-				FinishedAt: c.FinishedAt,
-			}
+			c.Thinking += delta
+			m.Parts[i] = c
 			found = true
 		}
 	}
@@ -253,28 +280,33 @@ func (m *Message) AppendReasoningContent(delta string) {
 func (m *Message) AppendReasoningSignature(signature string) {
 	for i, part := range m.Parts {
 		if c, ok := part.(ReasoningContent); ok {
-			m.Parts[i] = ReasoningContent{
-				Thinking:   c.Thinking,
-				Signature:  c.Signature + signature,
-				StartedAt:  c.StartedAt,
-				FinishedAt: c.FinishedAt,
-			}
+			c.Signature += signature
+			m.Parts[i] = c
 			return
 		}
 	}
 	m.Parts = append(m.Parts, ReasoningContent{Signature: signature})
 }
 
+// AddReasoningItem stores a reasoning item of the OpenAI Responses API, after
+// the ones the response already gave.
+func (m *Message) AddReasoningItem(item ReasoningItem) {
+	for i, part := range m.Parts {
+		if c, ok := part.(ReasoningContent); ok {
+			c.Items = append(slices.Clip(c.Items), item)
+			m.Parts[i] = c
+			return
+		}
+	}
+	m.Parts = append(m.Parts, ReasoningContent{Items: []ReasoningItem{item}})
+}
+
 func (m *Message) FinishThinking() {
 	for i, part := range m.Parts {
 		if c, ok := part.(ReasoningContent); ok {
 			if c.FinishedAt == 0 {
-				m.Parts[i] = ReasoningContent{
-					Thinking:   c.Thinking,
-					Signature:  c.Signature,
-					StartedAt:  c.StartedAt,
-					FinishedAt: time.Now().Unix(),
-				}
+				c.FinishedAt = time.Now().Unix()
+				m.Parts[i] = c
 			}
 			return
 		}
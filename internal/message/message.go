@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/charmbracelet/crush/internal/db"
@@ -22,11 +23,13 @@ type CreateMessageParams struct {
 type Service interface {
 	pubsub.Suscriber[Message]
 	Create(ctx context.Context, sessionID string, params CreateMessageParams) (Message, error)
+	Copy(ctx context.Context, sessionID string, message Message) (Message, error)
 	Update(ctx context.Context, message Message) error
 	Get(ctx context.Context, id string) (Message, error)
 	List(ctx context.Context, sessionID string) ([]Message, error)
 	Delete(ctx context.Context, id string) error
 	DeleteSessionMessages(ctx context.Context, sessionID string) error
+	Truncate(ctx context.Context, sessionID, messageID string) ([]Message, error)
 }
 
 type service struct {
@@ -83,6 +86,40 @@ func (s *service) Create(ctx context.Context, sessionID string, params CreateMes
 	return message, nil
 }
 
+// Copy stores a copy of the given message in another session, keeping its
+// parts and timestamps intact.
+func (s *service) Copy(ctx context.Context, sessionID string, message Message) (Message, error) {
+	partsJSON, err := marshallParts(message.Parts)
+	if err != nil {
+		return Message{}, err
+	}
+	finishedAt := sql.NullInt64{}
+	if f := message.FinishPart(); f != nil {
+		finishedAt.Int64 = f.Time
+		finishedAt.Valid = true
+	}
+	dbMessage, err := s.q.CopyMessage(ctx, db.CopyMessageParams{
+		ID:         uuid.New().String(),
+		SessionID:  sessionID,
+		Role:       string(message.Role),
+		Parts:      string(partsJSON),
+		Model:      sql.NullString{String: message.Model, Valid: message.Model != ""},
+		Provider:   sql.NullString{String: message.Provider, Valid: message.Provider != ""},
+		CreatedAt:  message.CreatedAt,
+		UpdatedAt:  message.UpdatedAt,
+		FinishedAt: finishedAt,
+	})
+	if err != nil {
+		return Message{}, err
+	}
+	copied, err := s.fromDBItem(dbMessage)
+	if err != nil {
+		return Message{}, err
+	}
+	s.Publish(pubsub.CreatedEvent, copied)
+	return copied, nil
+}
+
 func (s *service) DeleteSessionMessages(ctx context.Context, sessionID string) error {
 	messages, err := s.List(ctx, sessionID)
 	if err != nil {
@@ -99,6 +136,29 @@ func (s *service) DeleteSessionMessages(ctx context.Context, sessionID string) e
 	return nil
 }
 
+// Truncate deletes the given message and every message that came after it in
+// the session, returning the deleted messages.
+func (s *service) Truncate(ctx context.Context, sessionID, messageID string) ([]Message, error) {
+	messages, err := s.List(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	idx := slices.IndexFunc(messages, func(message Message) bool {
+		return message.ID == messageID
+	})
+	if idx == -1 {
+		return nil, fmt.Errorf("message %s not found in session %s", messageID, sessionID)
+	}
+	deleted := messages[idx:]
+	for _, message := range slices.Backward(deleted) {
+		if err := s.q.DeleteMessage(ctx, message.ID); err != nil {
+			return nil, err
+		}
+		s.Publish(pubsub.DeletedEvent, message)
+	}
+	return deleted, nil
+}
+
 func (s *service) Update(ctx context.Context, message Message) error {
 	parts, err := marshallParts(message.Parts)
 	if err != nil {
@@ -172,6 +232,7 @@ const (
 	toolCallType   partType = "tool_call"
 	toolResultType partType = "tool_result"
 	finishType     partType = "finish"
+	summaryType    partType = "summary"
 )
 
 type partWrapper struct {
@@ -200,6 +261,8 @@ func marshallParts(parts []ContentPart) ([]byte, error) {
 			typ = toolResultType
 		case Finish:
 			typ = finishType
+		case Summary:
+			typ = summaryType
 		default:
 			return nil, fmt.Errorf("unknown part type: %T", part)
 		}
@@ -273,6 +336,12 @@ func unmarshallParts(data []byte) ([]ContentPart, error) {
 				return nil, err
 			}
 			parts = append(parts, part)
+		case summaryType:
+			part := Summary{}
+			if err := json.Unmarshal(wrapper.Data, &part); err != nil {
+				return nil, err
+			}
+			parts = append(parts, part)
 		default:
 			return nil, fmt.Errorf("unknown part type: %s", wrapper.Type)
 		}
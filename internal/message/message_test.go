@@ -0,0 +1,97 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncate(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	conn, err := db.Connect(ctx, t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	q := db.New(conn)
+	_, err = q.CreateSession(ctx, db.CreateSessionParams{ID: "session", Title: "test"})
+	require.NoError(t, err)
+	messages := NewService(q)
+
+	var ids []string
+	for _, role := range []MessageRole{User, Assistant, User, Assistant} {
+		msg, err := messages.Create(ctx, "session", CreateMessageParams{Role: role})
+		require.NoError(t, err)
+		ids = append(ids, msg.ID)
+	}
+
+	deleted, err := messages.Truncate(ctx, "session", ids[2])
+	require.NoError(t, err)
+	require.Len(t, deleted, 2)
+	require.Equal(t, ids[2], deleted[0].ID)
+	require.Equal(t, ids[3], deleted[1].ID)
+
+	remaining, err := messages.List(ctx, "session")
+	require.NoError(t, err)
+	require.Len(t, remaining, 2)
+	require.Equal(t, ids[0], remaining[0].ID)
+	require.Equal(t, ids[1], remaining[1].ID)
+
+	_, err = messages.Truncate(ctx, "session", "missing")
+	require.Error(t, err)
+}
+
+func TestAddReasoningItem(t *testing.T) {
+	t.Parallel()
+
+	msg := Message{Role: Assistant}
+	msg.AppendReasoningContent("Listing")
+	msg.AddReasoningItem(ReasoningItem{ID: "rs_1", EncryptedContent: "first"})
+	msg.AddReasoningItem(ReasoningItem{ID: "rs_2", EncryptedContent: "second"})
+
+	require.Len(t, msg.Parts, 1)
+	reasoning := msg.ReasoningContent()
+	require.Equal(t, "Listing", reasoning.Thinking)
+	require.Equal(t, []ReasoningItem{
+		{ID: "rs_1", EncryptedContent: "first"},
+		{ID: "rs_2", EncryptedContent: "second"},
+	}, reasoning.Items)
+}
+
+func TestCopy(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	conn, err := db.Connect(ctx, t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	q := db.New(conn)
+	for _, id := range []string{"origin", "fork"} {
+		_, err = q.CreateSession(ctx, db.CreateSessionParams{ID: id, Title: "test"})
+		require.NoError(t, err)
+	}
+	messages := NewService(q)
+
+	user, err := messages.Create(ctx, "origin", CreateMessageParams{Role: User, Parts: []ContentPart{TextContent{Text: "Hello"}}})
+	require.NoError(t, err)
+	copied, err := messages.Copy(ctx, "fork", Message{Role: User, Parts: user.Parts, CreatedAt: user.CreatedAt})
+	require.NoError(t, err)
+	require.Equal(t, "fork", copied.SessionID)
+	require.Equal(t, user.Content(), copied.Content())
+
+	// Messages without a model keep a NULL model in their copy.
+	row, err := q.GetMessage(ctx, copied.ID)
+	require.NoError(t, err)
+	require.False(t, row.Model.Valid)
+	require.False(t, row.Provider.Valid)
+
+	copied, err = messages.Copy(ctx, "fork", Message{Role: Assistant, Model: "gpt-4o", Provider: "openai"})
+	require.NoError(t, err)
+	row, err = q.GetMessage(ctx, copied.ID)
+	require.NoError(t, err)
+	require.Equal(t, "gpt-4o", row.Model.String)
+	require.Equal(t, "openai", row.Provider.String)
+}
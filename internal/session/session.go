@@ -10,16 +10,29 @@ import (
 )
 
 type Session struct {
-	ID               string
-	ParentSessionID  string
-	Title            string
-	MessageCount     int64
-	PromptTokens     int64
-	CompletionTokens int64
-	SummaryMessageID string
-	Cost             float64
-	CreatedAt        int64
-	UpdatedAt        int64
+	ID                  string
+	ParentSessionID     string
+	Title               string
+	MessageCount        int64
+	PromptTokens        int64
+	CompletionTokens    int64
+	SummaryMessageID    string
+	Cost                float64
+	CreatedAt           int64
+	UpdatedAt           int64
+	ForkedFromSessionID string
+	ForkedFromMessageID string
+	// Tokens of every request of the session, where PromptTokens and
+	// CompletionTokens are those of the last one.
+	TotalTokens int64
+	// Whether the results of old tool calls are left out of the requests,
+	// until the session is summarized.
+	ToolResultsDropped bool
+}
+
+// IsFork reports whether the session was forked from another session.
+func (s Session) IsFork() bool {
+	return s.ForkedFromSessionID != ""
 }
 
 type Service interface {
@@ -27,6 +40,7 @@ type Service interface {
 	Create(ctx context.Context, title string) (Session, error)
 	CreateTitleSession(ctx context.Context, parentSessionID string) (Session, error)
 	CreateTaskSession(ctx context.Context, toolCallID, parentSessionID, title string) (Session, error)
+	CreateForkSession(ctx context.Context, origin Session, messageID string) (Session, error)
 	Get(ctx context.Context, id string) (Session, error)
 	List(ctx context.Context) ([]Session, error)
 	Save(ctx context.Context, session Session) (Session, error)
@@ -65,6 +79,23 @@ func (s *service) CreateTaskSession(ctx context.Context, toolCallID, parentSessi
 	return session, nil
 }
 
+// CreateForkSession creates a new top-level session that records the session
+// and message it was forked from. Copying the messages is up to the caller.
+func (s *service) CreateForkSession(ctx context.Context, origin Session, messageID string) (Session, error) {
+	dbSession, err := s.q.CreateSession(ctx, db.CreateSessionParams{
+		ID:                  uuid.New().String(),
+		Title:               "Fork: " + origin.Title,
+		ForkedFromSessionID: sql.NullString{String: origin.ID, Valid: true},
+		ForkedFromMessageID: sql.NullString{String: messageID, Valid: messageID != ""},
+	})
+	if err != nil {
+		return Session{}, err
+	}
+	session := s.fromDBItem(dbSession)
+	s.Publish(pubsub.CreatedEvent, session)
+	return session, nil
+}
+
 func (s *service) CreateTitleSession(ctx context.Context, parentSessionID string) (Session, error) {
 	dbSession, err := s.q.CreateSession(ctx, db.CreateSessionParams{
 		ID:              "title-" + parentSessionID,
@@ -110,7 +141,9 @@ func (s *service) Save(ctx context.Context, session Session) (Session, error) {
 			String: session.SummaryMessageID,
 			Valid:  session.SummaryMessageID != "",
 		},
-		Cost: session.Cost,
+		Cost:               session.Cost,
+		TotalTokens:        session.TotalTokens,
+		ToolResultsDropped: session.ToolResultsDropped,
 	})
 	if err != nil {
 		return Session{}, err
@@ -134,16 +167,20 @@ func (s *service) List(ctx context.Context) ([]Session, error) {
 
 func (s service) fromDBItem(item db.Session) Session {
 	return Session{
-		ID:               item.ID,
-		ParentSessionID:  item.ParentSessionID.String,
-		Title:            item.Title,
-		MessageCount:     item.MessageCount,
-		PromptTokens:     item.PromptTokens,
-		CompletionTokens: item.CompletionTokens,
-		SummaryMessageID: item.SummaryMessageID.String,
-		Cost:             item.Cost,
-		CreatedAt:        item.CreatedAt,
-		UpdatedAt:        item.UpdatedAt,
+		ID:                  item.ID,
+		ParentSessionID:     item.ParentSessionID.String,
+		Title:               item.Title,
+		MessageCount:        item.MessageCount,
+		PromptTokens:        item.PromptTokens,
+		CompletionTokens:    item.CompletionTokens,
+		SummaryMessageID:    item.SummaryMessageID.String,
+		Cost:                item.Cost,
+		CreatedAt:           item.CreatedAt,
+		UpdatedAt:           item.UpdatedAt,
+		ForkedFromSessionID: item.ForkedFromSessionID.String,
+		ForkedFromMessageID: item.ForkedFromMessageID.String,
+		TotalTokens:         item.TotalTokens,
+		ToolResultsDropped:  item.ToolResultsDropped,
 	}
 }
 
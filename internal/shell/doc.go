@@ -28,3 +28,13 @@ package shell
 //	shell.SetWorkingDir("/tmp")
 //	cwd := shell.GetWorkingDir()
 //	env := shell.GetEnv()
+//
+// 5. Confining commands on Linux:
+//
+//	shell := shell.NewShell(&shell.Options{
+//	    WorkingDir: "/path/to/cwd",
+//	    Sandbox: &shell.Sandbox{
+//	        WritablePaths: []string{"/path/to/cwd", os.TempDir()},
+//	    },
+//	})
+//	shell.Exec(ctx, "make test")  // Can't write elsewhere or reach the network
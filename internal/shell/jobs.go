@@ -0,0 +1,295 @@
+package shell
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/pubsub"
+)
+
+// JobStatus is the state of a background job.
+type JobStatus string
+
+const (
+	JobRunning JobStatus = "running"
+	JobExited  JobStatus = "exited"
+	JobKilled  JobStatus = "killed"
+)
+
+// maxJobOutput is how much output of a job is kept until it is read. Older
+// output is dropped.
+const maxJobOutput = 1024 * 1024
+
+// jobKillTimeout is how long Kill waits for a job to exit.
+const jobKillTimeout = 5 * time.Second
+
+// Job is a command running in the background.
+type Job struct {
+	ID        string
+	SessionID string
+	Command   string
+	Status    JobStatus
+	ExitCode  int
+	StartedAt time.Time
+	EndedAt   time.Time
+}
+
+type backgroundJob struct {
+	Job
+	cancel context.CancelFunc
+	killed bool
+	done   chan struct{}
+
+	output []byte
+	// Bytes of output dropped from the start, and read so far, both counted
+	// from the first byte the job wrote.
+	dropped int
+	read    int
+}
+
+// Jobs runs commands in the background and keeps their output until it is
+// read. Each job belongs to a session.
+type Jobs struct {
+	*pubsub.Broker[Job]
+
+	mu     sync.Mutex
+	jobs   map[string]*backgroundJob
+	nextID int
+}
+
+var (
+	jobsOnce     sync.Once
+	jobsInstance *Jobs
+)
+
+// BackgroundJobs returns the background jobs of the application.
+func BackgroundJobs() *Jobs {
+	jobsOnce.Do(func() {
+		jobsInstance = NewJobs()
+	})
+	return jobsInstance
+}
+
+// NewJobs creates an empty set of background jobs.
+func NewJobs() *Jobs {
+	return &Jobs{
+		Broker: pubsub.NewBroker[Job](),
+		jobs:   make(map[string]*backgroundJob),
+	}
+}
+
+// Start runs the command in the background from the working directory and
+// environment of the shell, for the session.
+func (j *Jobs) Start(sessionID string, shell *Shell, command string) Job {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	j.mu.Lock()
+	j.nextID++
+	jb := &backgroundJob{
+		Job: Job{
+			ID:        fmt.Sprintf("job-%d", j.nextID),
+			SessionID: sessionID,
+			Command:   command,
+			Status:    JobRunning,
+			StartedAt: time.Now(),
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	j.jobs[jb.ID] = jb
+	j.mu.Unlock()
+	j.Publish(pubsub.CreatedEvent, jb.Job)
+
+	go func() {
+		defer close(jb.done)
+		w := &jobWriter{jobs: j, job: jb}
+		err := shell.ExecDetached(ctx, command, w, w)
+
+		j.mu.Lock()
+		jb.EndedAt = time.Now()
+		jb.ExitCode = ExitCode(err)
+		jb.Status = JobExited
+		if jb.killed {
+			jb.Status = JobKilled
+		}
+		snapshot := jb.Job
+		j.mu.Unlock()
+		cancel()
+		j.Publish(pubsub.UpdatedEvent, snapshot)
+	}()
+
+	return jb.Job
+}
+
+// jobWriter appends the output of a job to it.
+type jobWriter struct {
+	jobs *Jobs
+	job  *backgroundJob
+}
+
+func (w *jobWriter) Write(p []byte) (int, error) {
+	w.jobs.mu.Lock()
+	defer w.jobs.mu.Unlock()
+
+	jb := w.job
+	jb.output = append(jb.output, p...)
+	if len(jb.output) > maxJobOutput {
+		// Drop a quarter more than needed, so that a job writing a lot doesn't
+		// move its output on every write.
+		over := len(jb.output) - maxJobOutput*3/4
+		jb.output = slices.Delete(jb.output, 0, over)
+		jb.dropped += over
+	}
+	return len(p), nil
+}
+
+// Get returns the job with the ID.
+func (j *Jobs) Get(id string) (Job, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	jb, ok := j.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return jb.Job, true
+}
+
+// Output returns the output the job wrote since it was last read, and the
+// number of bytes of it that were dropped before they could be read.
+func (j *Jobs) Output(id string) (output string, dropped int, info Job, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	jb, ok := j.jobs[id]
+	if !ok {
+		return "", 0, Job{}, fmt.Errorf("job not found: %s", id)
+	}
+	if jb.read < jb.dropped {
+		dropped = jb.dropped - jb.read
+		jb.read = jb.dropped
+	}
+	output = string(jb.output[jb.read-jb.dropped:])
+	jb.read = jb.dropped + len(jb.output)
+	return output, dropped, jb.Job, nil
+}
+
+// Wait waits for the job to exit, or for the context to be done.
+func (j *Jobs) Wait(ctx context.Context, id string) error {
+	j.mu.Lock()
+	jb, ok := j.jobs[id]
+	j.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("job not found: %s", id)
+	}
+
+	select {
+	case <-jb.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// List returns the jobs of the session, oldest first.
+func (j *Jobs) List(sessionID string) []Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var jobs []Job
+	for _, jb := range j.jobs {
+		if jb.SessionID == sessionID {
+			jobs = append(jobs, jb.Job)
+		}
+	}
+	slices.SortFunc(jobs, func(a, b Job) int {
+		return a.StartedAt.Compare(b.StartedAt)
+	})
+	return jobs
+}
+
+// Kill stops the job and waits for it to exit.
+func (j *Jobs) Kill(id string) (Job, error) {
+	j.mu.Lock()
+	jb, ok := j.jobs[id]
+	if ok && jb.Status == JobRunning {
+		jb.killed = true
+		jb.cancel()
+	}
+	j.mu.Unlock()
+	if !ok {
+		return Job{}, fmt.Errorf("job not found: %s", id)
+	}
+
+	select {
+	case <-jb.done:
+	case <-time.After(jobKillTimeout):
+		return Job{}, fmt.Errorf("job %s did not exit after %s", id, jobKillTimeout)
+	}
+	killed, _ := j.Get(id)
+	return killed, nil
+}
+
+// KillSession stops the jobs of the session and forgets them.
+func (j *Jobs) KillSession(sessionID string) {
+	for _, jb := range j.List(sessionID) {
+		j.remove(jb.ID)
+	}
+}
+
+// Shutdown stops all the jobs.
+func (j *Jobs) Shutdown() {
+	j.mu.Lock()
+	ids := make([]string, 0, len(j.jobs))
+	for id := range j.jobs {
+		ids = append(ids, id)
+	}
+	j.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			j.remove(id)
+		}()
+	}
+	wg.Wait()
+	j.Broker.Shutdown()
+}
+
+func (j *Jobs) remove(id string) {
+	removed, err := j.Kill(id)
+	if err != nil {
+		removed, _ = j.Get(id)
+	}
+
+	j.mu.Lock()
+	delete(j.jobs, id)
+	j.mu.Unlock()
+	j.Publish(pubsub.DeletedEvent, removed)
+}
+
+// Duration returns how long the job ran, or has been running.
+func (job Job) Duration() time.Duration {
+	end := job.EndedAt
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return end.Sub(job.StartedAt).Round(time.Second)
+}
+
+// String describes the state of the job in a few words.
+func (job Job) String() string {
+	switch job.Status {
+	case JobRunning:
+		return fmt.Sprintf("running for %s", job.Duration())
+	case JobKilled:
+		return fmt.Sprintf("killed after %s", job.Duration())
+	default:
+		return fmt.Sprintf("exited with code %d after %s", job.ExitCode, job.Duration())
+	}
+}
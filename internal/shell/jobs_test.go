@@ -0,0 +1,87 @@
+package shell
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobsOutput(t *testing.T) {
+	jobs := NewJobs()
+	t.Cleanup(jobs.Shutdown)
+
+	shell := NewShell(&Options{WorkingDir: t.TempDir()})
+	job := jobs.Start("session", shell, "echo first; echo second >&2")
+	if job.Status != JobRunning {
+		t.Fatalf("Expected job to be running, got %s", job.Status)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	t.Cleanup(cancel)
+	if err := jobs.Wait(ctx, job.ID); err != nil {
+		t.Fatalf("Failed to wait for job: %v", err)
+	}
+
+	output, dropped, job, err := jobs.Output(job.ID)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if output != "first\nsecond\n" || dropped != 0 {
+		t.Fatalf("Unexpected output %q, %d bytes dropped", output, dropped)
+	}
+	if job.Status != JobExited || job.ExitCode != 0 {
+		t.Fatalf("Expected job to exit with code 0, got %s with code %d", job.Status, job.ExitCode)
+	}
+
+	// Output is only returned once.
+	output, _, _, err = jobs.Output(job.ID)
+	if err != nil || output != "" {
+		t.Fatalf("Expected no new output, got %q, %v", output, err)
+	}
+}
+
+func TestJobsKill(t *testing.T) {
+	jobs := NewJobs()
+	t.Cleanup(jobs.Shutdown)
+
+	shell := NewShell(&Options{WorkingDir: t.TempDir()})
+	job := jobs.Start("session", shell, "sleep 10")
+	jobs.Start("other", shell, "sleep 10")
+
+	if list := jobs.List("session"); len(list) != 1 || list[0].ID != job.ID {
+		t.Fatalf("Expected only %s in the session, got %v", job.ID, list)
+	}
+
+	job, err := jobs.Kill(job.ID)
+	if err != nil {
+		t.Fatalf("Failed to kill job: %v", err)
+	}
+	if job.Status != JobKilled {
+		t.Fatalf("Expected job to be killed, got %s", job.Status)
+	}
+
+	jobs.KillSession("other")
+	if list := jobs.List("other"); len(list) != 0 {
+		t.Fatalf("Expected the session's jobs to be removed, got %v", list)
+	}
+}
+
+func TestJobWriterDropsOldOutput(t *testing.T) {
+	jobs := NewJobs()
+	jb := &backgroundJob{}
+	w := &jobWriter{jobs: jobs, job: jb}
+
+	chunk := []byte(strings.Repeat("x", maxJobOutput/2))
+	for range 3 {
+		if _, err := w.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(jb.output) > maxJobOutput {
+		t.Fatalf("Expected at most %d bytes kept, got %d", maxJobOutput, len(jb.output))
+	}
+	if jb.dropped+len(jb.output) != 3*len(chunk) {
+		t.Fatalf("Expected dropped and kept output to add up, got %d and %d", jb.dropped, len(jb.output))
+	}
+}
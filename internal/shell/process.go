@@ -0,0 +1,82 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"mvdan.cc/sh/v3/interp"
+)
+
+// processGroupKillTimeout is how long the processes of a stopped command have
+// to exit after SIGTERM before they are killed.
+const processGroupKillTimeout = 3 * time.Second
+
+// processGroupHandler runs each program in a process group of its own, so
+// that the processes it starts, like the server behind a package manager's
+// dev script, are stopped along with it.
+func (s *Shell) processGroupHandler() func(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
+	return func(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
+		return func(ctx context.Context, args []string) error {
+			if len(args) == 0 {
+				return next(ctx, args)
+			}
+
+			hc := interp.HandlerCtx(ctx)
+			path, err := interp.LookPathDir(hc.Dir, hc.Env, args[0])
+			if err != nil {
+				fmt.Fprintln(hc.Stderr, err)
+				return interp.ExitStatus(127)
+			}
+			cmd := &exec.Cmd{
+				Path:   path,
+				Args:   args,
+				Env:    execEnv(hc.Env),
+				Dir:    hc.Dir,
+				Stdin:  hc.Stdin,
+				Stdout: hc.Stdout,
+				Stderr: hc.Stderr,
+			}
+			setProcessGroup(cmd)
+			if err := cmd.Start(); err != nil {
+				fmt.Fprintln(hc.Stderr, err)
+				return interp.ExitStatus(127)
+			}
+			return exitStatus(ctx, waitProcessGroup(ctx, cmd))
+		}
+	}
+}
+
+// waitProcessGroup waits for the started command to exit. When the context
+// is done, the process group of the command is stopped, and waited for.
+func waitProcessGroup(ctx context.Context, cmd *exec.Cmd) error {
+	stopped := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		defer close(stopped)
+		killProcessGroup(cmd)
+	})
+	err := cmd.Wait()
+	if !stop() {
+		<-stopped
+	}
+	return err
+}
+
+// exitStatus turns the error of a program into the exit status of the shell,
+// the way interp's default exec handler does.
+func exitStatus(ctx context.Context, err error) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return interp.ExitStatus(128 + status.Signal())
+	}
+	return interp.ExitStatus(exitErr.ExitCode())
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+
+package shell
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// killProcessGroup sends SIGTERM to the process group of the command, and
+// SIGKILL to what is left of it after processGroupKillTimeout.
+func killProcessGroup(cmd *exec.Cmd) {
+	pgid := -cmd.Process.Pid
+	if err := syscall.Kill(pgid, syscall.SIGTERM); err != nil {
+		return
+	}
+	deadline := time.Now().Add(processGroupKillTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		if err := syscall.Kill(pgid, 0); errors.Is(err, syscall.ESRCH) {
+			return
+		}
+	}
+	_ = syscall.Kill(pgid, syscall.SIGKILL)
+}
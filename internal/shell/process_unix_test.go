@@ -0,0 +1,69 @@
+//go:build !windows
+
+package shell
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestJobsKillProcessGroup(t *testing.T) {
+	jobs := NewJobs()
+	t.Cleanup(jobs.Shutdown)
+
+	dir := t.TempDir()
+	shell := NewShell(&Options{WorkingDir: dir})
+	// The child of the command outlives it unless its process group is
+	// stopped.
+	job := jobs.Start("session", shell, "sh -c 'sleep 30 & echo $! > child.pid; wait'")
+
+	var pid int
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	t.Cleanup(cancel)
+	for pid == 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("The command did not start its child")
+		case <-time.After(20 * time.Millisecond):
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "child.pid"))
+		if err == nil && strings.HasSuffix(string(data), "\n") {
+			pid, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+		}
+	}
+
+	if _, err := jobs.Kill(job.ID); err != nil {
+		t.Fatalf("Failed to kill job: %v", err)
+	}
+	for {
+		if !processRunning(pid) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("The child %d of the job is still running", pid)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+// processRunning reports whether the process exists and isn't a zombie.
+func processRunning(pid int) bool {
+	if err := syscall.Kill(pid, 0); errors.Is(err, syscall.ESRCH) {
+		return false
+	}
+	// Killed children that nobody has reaped yet still answer signals.
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return true
+	}
+	_, state, _ := strings.Cut(string(stat), ") ")
+	return !strings.HasPrefix(state, "Z")
+}
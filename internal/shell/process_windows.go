@@ -0,0 +1,13 @@
+//go:build windows
+
+package shell
+
+import "os/exec"
+
+// setProcessGroup does nothing, Windows has no process groups to signal.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the process of the command.
+func killProcessGroup(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+}
@@ -0,0 +1,126 @@
+package shell
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/interp"
+)
+
+// Sandbox confines the commands a shell runs. Only the paths it lists may be
+// written, the network may be cut off and each process is limited in what it
+// may use. It is only supported on Linux, where it relies on Landlock, user
+// and network namespaces and rlimits; elsewhere commands fail to run.
+//
+// With a sandbox, programs are always run as separate processes, so core
+// utils aren't run by the shell itself, and files opened by redirections must
+// be writable.
+type Sandbox struct {
+	// Paths that may be written, with everything under them. The rest of the
+	// file system is read-only.
+	WritablePaths []string
+	// Without network access, commands run in a network namespace of their
+	// own, where only the loopback interface is up.
+	AllowNetwork bool
+	// Limits of each process. Zero means no limit.
+	MaxCPUSeconds  uint64
+	MaxMemoryBytes uint64
+	// Limit of the processes of the user, counting those outside of the
+	// sandbox, past which commands can't start more. Zero means no limit.
+	MaxProcesses uint64
+}
+
+// writablePaths returns the absolute paths that may be written, with
+// symbolic links resolved.
+func (sb *Sandbox) writablePaths() []string {
+	paths := make([]string, 0, len(sb.WritablePaths)+1)
+	for _, path := range append([]string{os.DevNull}, sb.WritablePaths...) {
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			path = resolved
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			paths = append(paths, abs)
+		}
+	}
+	return paths
+}
+
+// canWrite reports whether the file at the path may be written.
+func (sb *Sandbox) canWrite(path string) bool {
+	path = filepath.Clean(path)
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	} else if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		// The file doesn't exist yet, it would be created in its directory.
+		path = filepath.Join(dir, filepath.Base(path))
+	}
+
+	for _, writable := range sb.writablePaths() {
+		if path == writable || strings.HasPrefix(path, writable+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sandboxHandler runs the programs in the sandbox.
+func (s *Shell) sandboxHandler(sb *Sandbox) func(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
+	return func(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
+		return func(ctx context.Context, args []string) error {
+			if len(args) == 0 {
+				return next(ctx, args)
+			}
+
+			hc := interp.HandlerCtx(ctx)
+			path, err := interp.LookPathDir(hc.Dir, hc.Env, args[0])
+			if err != nil {
+				fmt.Fprintln(hc.Stderr, err)
+				return interp.ExitStatus(127)
+			}
+			return sb.run(ctx, path, args, execEnv(hc.Env))
+		}
+	}
+}
+
+// sandboxOpenHandler only lets redirections write to the paths of the
+// sandbox, as the files they open are opened by the shell itself.
+func (s *Shell) sandboxOpenHandler(sb *Sandbox) interp.OpenHandlerFunc {
+	open := interp.DefaultOpenHandler()
+	return func(ctx context.Context, path string, flag int, perm os.FileMode) (io.ReadWriteCloser, error) {
+		writes := flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0
+		if writes {
+			abs := path
+			if !filepath.IsAbs(abs) {
+				abs = filepath.Join(interp.HandlerCtx(ctx).Dir, abs)
+			}
+			if !sb.canWrite(abs) {
+				return nil, &os.PathError{Op: "open", Path: path, Err: fmt.Errorf("not writable in the sandbox: %w", os.ErrPermission)}
+			}
+		}
+		return open(ctx, path, flag, perm)
+	}
+}
+
+// execEnv returns the exported variables of the environment, as programs get
+// them. It follows the one of interp's default exec handler.
+func execEnv(env expand.Environ) []string {
+	var list []string
+	for name, vr := range env.Each {
+		if !vr.IsSet() {
+			// Unset in the runner but maybe set globally.
+			list = slices.DeleteFunc(list, func(kv string) bool {
+				return strings.HasPrefix(kv, name+"=")
+			})
+		}
+		if vr.Exported && vr.Kind == expand.String {
+			list = append(list, name+"="+vr.String())
+		}
+	}
+	return list
+}
@@ -0,0 +1,242 @@
+package shell
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+	"mvdan.cc/sh/v3/interp"
+)
+
+// sandboxHelper is the name the process is started with to set up the
+// sandbox of a command before running it.
+const sandboxHelper = "crush-sandbox"
+
+// sandboxPolicy is what the sandbox helper gets to set up the sandbox.
+type sandboxPolicy struct {
+	WritablePaths  []string `json:"writable_paths"`
+	AllowNetwork   bool     `json:"allow_network"`
+	MaxCPUSeconds  uint64   `json:"max_cpu_seconds"`
+	MaxMemoryBytes uint64   `json:"max_memory_bytes"`
+	MaxProcesses   uint64   `json:"max_processes"`
+}
+
+// run runs the program in the sandbox. The process first runs this program
+// again as the sandbox helper, in new namespaces, which sets up the sandbox
+// and then replaces itself with the program.
+func (sb *Sandbox) run(ctx context.Context, path string, args, env []string) error {
+	hc := interp.HandlerCtx(ctx)
+
+	policy, err := json.Marshal(sandboxPolicy{
+		WritablePaths:  sb.writablePaths(),
+		AllowNetwork:   sb.AllowNetwork,
+		MaxCPUSeconds:  sb.MaxCPUSeconds,
+		MaxMemoryBytes: sb.MaxMemoryBytes,
+		MaxProcesses:   sb.MaxProcesses,
+	})
+	if err != nil {
+		return err
+	}
+
+	cmd := &exec.Cmd{
+		Path:        "/proc/self/exe",
+		Args:        append([]string{sandboxHelper, string(policy), path}, args...),
+		Env:         env,
+		Dir:         hc.Dir,
+		Stdin:       hc.Stdin,
+		Stdout:      hc.Stdout,
+		Stderr:      hc.Stderr,
+		SysProcAttr: &syscall.SysProcAttr{},
+	}
+	if !sb.AllowNetwork {
+		// The user namespace gives the helper the rights to bring up the
+		// loopback interface of the network namespace.
+		cmd.SysProcAttr.Cloneflags = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET
+		cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+		cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	}
+
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(hc.Stderr, "could not start the sandbox: %v\n", err)
+		return interp.ExitStatus(126)
+	}
+	return exitStatus(ctx, waitProcessGroup(ctx, cmd))
+}
+
+// RunSandboxHelper sets up the sandbox of a command and runs it, when the
+// process was started as the sandbox helper. It never returns then, and
+// returns right away otherwise. It must be called at the start of main.
+func RunSandboxHelper() {
+	if len(os.Args) < 4 || os.Args[0] != sandboxHelper {
+		return
+	}
+
+	// Landlock and no_new_privs apply to the thread that sets them, which must
+	// be the one that runs the command.
+	runtime.LockOSThread()
+
+	var policy sandboxPolicy
+	err := json.Unmarshal([]byte(os.Args[1]), &policy)
+	if err == nil {
+		err = policy.apply()
+	}
+	if err == nil {
+		err = unix.Exec(os.Args[2], os.Args[3:], os.Environ())
+	}
+	fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+	os.Exit(126)
+}
+
+func (p sandboxPolicy) apply() error {
+	if !p.AllowNetwork {
+		if err := loopbackUp(); err != nil {
+			return fmt.Errorf("could not bring up the loopback interface: %w", err)
+		}
+	}
+
+	limits := []struct {
+		resource int
+		value    uint64
+	}{
+		{unix.RLIMIT_CPU, p.MaxCPUSeconds},
+		// Address space reservations, which runtimes make a lot of, don't
+		// count towards the data limit, unlike the address space one.
+		{unix.RLIMIT_DATA, p.MaxMemoryBytes},
+		{unix.RLIMIT_NPROC, p.MaxProcesses},
+	}
+	for _, limit := range limits {
+		if limit.value == 0 {
+			continue
+		}
+		if err := unix.Setrlimit(limit.resource, &unix.Rlimit{Cur: limit.value, Max: limit.value}); err != nil {
+			return fmt.Errorf("could not set resource limit: %w", err)
+		}
+	}
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("could not set no_new_privs: %w", err)
+	}
+	return restrictWrites(p.WritablePaths)
+}
+
+// loopbackUp brings up the loopback interface of the network namespace.
+func loopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	ifreq, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifreq); err != nil {
+		return err
+	}
+	ifreq.SetUint16(ifreq.Uint16() | unix.IFF_UP)
+	return unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifreq)
+}
+
+const (
+	landlockReadAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR
+	// Rights that apply to files, as opposed to directories.
+	landlockFileAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_TRUNCATE
+)
+
+// landlockAccess returns the file system rights the version of the Landlock
+// ABI knows about.
+func landlockAccess(abi int) uint64 {
+	access := uint64(unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM)
+	if abi >= 2 {
+		access |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		access |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	return access
+}
+
+// restrictWrites makes the file system read-only for the thread and the
+// processes it starts, except for the paths.
+func restrictWrites(paths []string) error {
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return fmt.Errorf("landlock is not supported by the kernel: %w", errno)
+	}
+
+	access := landlockAccess(int(abi))
+	attr := unix.LandlockRulesetAttr{Access_fs: access}
+	ruleset, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("could not create landlock ruleset: %w", errno)
+	}
+	defer unix.Close(int(ruleset))
+
+	if err := allowPath(int(ruleset), "/", landlockReadAccess); err != nil {
+		return err
+	}
+	for _, path := range paths {
+		err := allowPath(int(ruleset), path, access)
+		if errors.Is(err, unix.ENOENT) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, ruleset, 0, 0); errno != 0 {
+		return fmt.Errorf("could not enforce landlock ruleset: %w", errno)
+	}
+	return nil
+}
+
+// allowPath grants the rights on the path, and everything under it if it is
+// a directory.
+func allowPath(ruleset int, path string, access uint64) error {
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", path, err)
+	}
+	defer unix.Close(fd)
+
+	var stat unix.Stat_t
+	if err := unix.Fstat(fd, &stat); err != nil {
+		return fmt.Errorf("could not stat %s: %w", path, err)
+	}
+	if stat.Mode&unix.S_IFMT != unix.S_IFDIR {
+		access &= landlockFileAccess
+	}
+
+	rule := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(fd)}
+	if _, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(ruleset), unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&rule)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("could not allow %s: %w", path, errno)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func sandboxedShell(t *testing.T, sb *Sandbox) (*Shell, string) {
+	t.Helper()
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION); errno != 0 {
+		t.Skipf("Landlock is not supported: %v", errno)
+	}
+	dir := t.TempDir()
+	sb.WritablePaths = append(sb.WritablePaths, dir)
+	return NewShell(&Options{WorkingDir: dir, Sandbox: sb}), dir
+}
+
+func TestSandboxWrites(t *testing.T) {
+	outside := t.TempDir()
+	shell, dir := sandboxedShell(t, &Sandbox{AllowNetwork: true})
+
+	stdout, stderr, err := shell.Exec(t.Context(), "touch inside.txt && cat inside.txt > /dev/null && echo ok")
+	if err != nil || stdout != "ok\n" {
+		t.Fatalf("Expected writes inside the sandbox to work, got %v: %s", err, stderr)
+	}
+
+	// Scripts started by the shell are confined as well.
+	target := filepath.Join(outside, "outside.txt")
+	_, stderr, err = shell.Exec(t.Context(), "sh -c 'echo outside > "+target+"'")
+	if ExitCode(err) == 0 {
+		t.Fatalf("Expected the write outside the sandbox to fail, got %v: %s", err, stderr)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Fatalf("Expected no file outside the sandbox, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "inside.txt")); err != nil {
+		t.Fatalf("Expected the file to be written: %v", err)
+	}
+}
+
+func TestSandboxNetwork(t *testing.T) {
+	shell, _ := sandboxedShell(t, &Sandbox{})
+
+	stdout, stderr, err := shell.Exec(t.Context(), "cat /proc/net/dev")
+	if err != nil {
+		t.Fatalf("Failed to list interfaces: %v: %s", err, stderr)
+	}
+	for line := range strings.Lines(stdout) {
+		name, _, found := strings.Cut(strings.TrimSpace(line), ":")
+		if found && name != "lo" {
+			t.Fatalf("Expected only the loopback interface, got %s", name)
+		}
+	}
+}
+
+func TestSandboxLimits(t *testing.T) {
+	shell, _ := sandboxedShell(t, &Sandbox{AllowNetwork: true, MaxCPUSeconds: 7, MaxProcesses: 64})
+
+	stdout, stderr, err := shell.Exec(t.Context(), "cat /proc/self/limits")
+	if err != nil {
+		t.Fatalf("Failed to read limits: %v: %s", err, stderr)
+	}
+	for _, want := range []string{
+		"Max cpu time              7                    7                    seconds",
+		"Max processes             64                   64                   processes",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Fatalf("Expected the limit %q, got:\n%s", want, stdout)
+		}
+	}
+}
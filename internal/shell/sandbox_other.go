@@ -0,0 +1,19 @@
+//go:build !linux
+
+package shell
+
+import (
+	"context"
+	"fmt"
+
+	"mvdan.cc/sh/v3/interp"
+)
+
+// run refuses to run the program, as the sandbox is only supported on Linux.
+func (sb *Sandbox) run(ctx context.Context, path string, args, env []string) error {
+	fmt.Fprintln(interp.HandlerCtx(ctx).Stderr, "the sandbox is only supported on Linux")
+	return interp.ExitStatus(126)
+}
+
+// RunSandboxHelper does nothing, as the sandbox is only supported on Linux.
+func RunSandboxHelper() {}
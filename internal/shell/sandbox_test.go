@@ -0,0 +1,66 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Sandboxed commands start the test binary as the sandbox helper.
+	RunSandboxHelper()
+	os.Exit(m.Run())
+}
+
+func TestSandboxCanWrite(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	sb := &Sandbox{WritablePaths: []string{dir}}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{dir, true},
+		{filepath.Join(dir, "new.txt"), true},
+		{filepath.Join(dir, "sub", "..", "new.txt"), true},
+		{filepath.Join(dir, "..", "new.txt"), false},
+		{dir + "-other", false},
+		{filepath.Join(dir, "link", "new.txt"), false},
+		{filepath.Join(outside, "new.txt"), false},
+		{os.DevNull, true},
+	}
+	for _, tt := range tests {
+		if got := sb.canWrite(tt.path); got != tt.want {
+			t.Errorf("canWrite(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSandboxRedirections(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	shell := NewShell(&Options{
+		WorkingDir: dir,
+		Sandbox:    &Sandbox{WritablePaths: []string{dir}},
+	})
+
+	if _, _, err := shell.Exec(t.Context(), "echo inside > inside.txt; echo gone > /dev/null"); err != nil {
+		t.Fatalf("Expected writes inside the sandbox to work, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "inside.txt")); err != nil {
+		t.Fatalf("Expected the file to be written: %v", err)
+	}
+
+	_, stderr, err := shell.Exec(t.Context(), "echo outside > "+filepath.Join(outside, "outside.txt"))
+	if err == nil || !strings.Contains(stderr, "not writable in the sandbox") {
+		t.Fatalf("Expected the write outside the sandbox to fail, got %v: %s", err, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "outside.txt")); !os.IsNotExist(err) {
+		t.Fatalf("Expected no file outside the sandbox, got %v", err)
+	}
+}
@@ -15,7 +15,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"strings"
 	"sync"
 
@@ -53,6 +55,7 @@ type Shell struct {
 	mu         sync.Mutex
 	logger     Logger
 	blockFuncs []BlockFunc
+	sandbox    *Sandbox
 }
 
 // Options for creating a new shell
@@ -61,6 +64,8 @@ type Options struct {
 	Env        []string
 	Logger     Logger
 	BlockFuncs []BlockFunc
+	// Commands run unconfined when nil.
+	Sandbox *Sandbox
 }
 
 // NewShell creates a new shell instance with the given options
@@ -89,6 +94,7 @@ func NewShell(opts *Options) *Shell {
 		env:        env,
 		logger:     logger,
 		blockFuncs: opts.BlockFuncs,
+		sandbox:    opts.Sandbox,
 	}
 }
 
@@ -97,7 +103,46 @@ func (s *Shell) Exec(ctx context.Context, command string) (string, string, error
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	return s.execPOSIX(ctx, command)
+	return s.execPOSIX(ctx, command, nil)
+}
+
+// ExecWithStdin executes a command in the shell, feeding it the given input
+func (s *Shell) ExecWithStdin(ctx context.Context, command string, stdin io.Reader) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.execPOSIX(ctx, command, stdin)
+}
+
+// ExecDetached executes a command from the current working directory and
+// environment of the shell, without waiting for other commands or changing
+// them, writing its output as it runs
+func (s *Shell) ExecDetached(ctx context.Context, command string, stdout, stderr io.Writer) error {
+	s.mu.Lock()
+	cwd := s.cwd
+	env := slices.Clone(s.env)
+	handlers := s.handlers(true)
+	s.mu.Unlock()
+
+	line, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil {
+		return fmt.Errorf("could not parse command: %w", err)
+	}
+
+	runner, err := interp.New(
+		interp.StdIO(nil, stdout, stderr),
+		interp.Interactive(false),
+		interp.Env(expand.ListEnviron(env...)),
+		interp.Dir(cwd),
+		handlers,
+	)
+	if err != nil {
+		return fmt.Errorf("could not run command: %w", err)
+	}
+
+	err = runner.Run(ctx, line)
+	s.logger.InfoPersist("POSIX background command finished", "command", command, "err", err)
+	return err
 }
 
 // GetWorkingDir returns the current working directory
@@ -154,6 +199,14 @@ func (s *Shell) SetBlockFuncs(blockFuncs []BlockFunc) {
 	s.blockFuncs = blockFuncs
 }
 
+// SetSandbox sets the sandbox commands run in, or lets them run unconfined
+// when nil
+func (s *Shell) SetSandbox(sandbox *Sandbox) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sandbox = sandbox
+}
+
 // CommandsBlocker creates a BlockFunc that blocks exact command matches
 func CommandsBlocker(bannedCommands []string) BlockFunc {
 	bannedSet := make(map[string]bool)
@@ -208,8 +261,27 @@ func (s *Shell) blockHandler() func(next interp.ExecHandlerFunc) interp.ExecHand
 	}
 }
 
+// handlers returns the handlers of the runner. Core utils are run by the
+// shell itself, so not in the sandbox. The programs of detached commands run
+// in process groups of their own, which are stopped with them.
+func (s *Shell) handlers(detached bool) interp.RunnerOption {
+	if s.sandbox == nil {
+		if detached {
+			return interp.ExecHandlers(s.blockHandler(), s.coreUtilsHandler(), s.processGroupHandler())
+		}
+		return interp.ExecHandlers(s.blockHandler(), s.coreUtilsHandler())
+	}
+	sandbox := s.sandbox
+	return func(r *interp.Runner) error {
+		if err := interp.ExecHandlers(s.blockHandler(), s.sandboxHandler(sandbox))(r); err != nil {
+			return err
+		}
+		return interp.OpenHandler(s.sandboxOpenHandler(sandbox))(r)
+	}
+}
+
 // execPOSIX executes commands using POSIX shell emulation (cross-platform)
-func (s *Shell) execPOSIX(ctx context.Context, command string) (string, string, error) {
+func (s *Shell) execPOSIX(ctx context.Context, command string, stdin io.Reader) (string, string, error) {
 	line, err := syntax.NewParser().Parse(strings.NewReader(command), "")
 	if err != nil {
 		return "", "", fmt.Errorf("could not parse command: %w", err)
@@ -217,11 +289,11 @@ func (s *Shell) execPOSIX(ctx context.Context, command string) (string, string,
 
 	var stdout, stderr bytes.Buffer
 	runner, err := interp.New(
-		interp.StdIO(nil, &stdout, &stderr),
+		interp.StdIO(stdin, &stdout, &stderr),
 		interp.Interactive(false),
 		interp.Env(expand.ListEnviron(s.env...)),
 		interp.Dir(s.cwd),
-		interp.ExecHandlers(s.blockHandler(), s.coreUtilsHandler()),
+		s.handlers(false),
 	)
 	if err != nil {
 		return "", "", fmt.Errorf("could not run command: %w", err)
@@ -14,6 +14,8 @@ import (
 	"github.com/charmbracelet/crush/internal/session"
 	"github.com/charmbracelet/crush/internal/tui/components/chat/messages"
 	"github.com/charmbracelet/crush/internal/tui/components/core/layout"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/rewind"
 	"github.com/charmbracelet/crush/internal/tui/exp/list"
 	"github.com/charmbracelet/crush/internal/tui/styles"
 	"github.com/charmbracelet/crush/internal/tui/util"
@@ -22,12 +24,27 @@ import (
 type SendMsg struct {
 	Text        string
 	Attachments []message.Attachment
+	// EditMessageID is set when the text replaces an earlier user message.
+	EditMessageID string
+}
+
+// EditMsg asks the editor to load an earlier user message so it can be
+// edited and sent again.
+type EditMsg struct {
+	Message message.Message
 }
 
 type SessionSelectedMsg = session.Session
 
 type SessionClearedMsg struct{}
 
+// ForkMsg requests a new session holding the conversation up to the given
+// message.
+type ForkMsg struct {
+	SessionID string
+	MessageID string
+}
+
 const (
 	NotFound = -1
 )
@@ -41,6 +58,7 @@ type MessageListCmp interface {
 	layout.Help
 
 	SetSession(session.Session) tea.Cmd
+	ReloadSession() tea.Cmd
 	GoToBottom() tea.Cmd
 }
 
@@ -56,6 +74,7 @@ type messageListCmp struct {
 
 	lastUserMessageTime int64
 	defaultListKeyMap   list.KeyMap
+	keyMap              KeyMap
 }
 
 // New creates a new message list component with custom keybindings
@@ -75,6 +94,7 @@ func New(app *app.App) MessageListCmp {
 		listCmp:           listCmp,
 		previousSelected:  "",
 		defaultListKeyMap: defaultListKeyMap,
+		keyMap:            DefaultKeyMap(),
 	}
 }
 
@@ -106,6 +126,19 @@ func (m *messageListCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		u, cmd := m.listCmp.Update(msg)
 		m.listCmp = u.(list.List[list.Item])
 		return m, cmd
+	case tea.KeyPressMsg:
+		if m.listCmp.IsFocused() && key.Matches(msg, m.keyMap.Rewind) {
+			return m, m.rewindToSelected()
+		}
+		if m.listCmp.IsFocused() && key.Matches(msg, m.keyMap.Fork) {
+			return m, m.forkFromSelected()
+		}
+		if m.listCmp.IsFocused() && key.Matches(msg, m.keyMap.Edit) {
+			return m, m.editSelected()
+		}
+		u, cmd := m.listCmp.Update(msg)
+		m.listCmp = u.(list.List[list.Item])
+		return m, cmd
 	default:
 		var cmds []tea.Cmd
 		u, cmd := m.listCmp.Update(msg)
@@ -419,12 +452,81 @@ func (m *messageListCmp) handleNewAssistantMessage(msg message.Message) tea.Cmd
 	return tea.Batch(cmds...)
 }
 
+// rewindToSelected asks for confirmation before rewinding the session to the
+// selected user message.
+func (m *messageListCmp) rewindToSelected() tea.Cmd {
+	selected := m.listCmp.SelectedItem()
+	if selected == nil {
+		return nil
+	}
+	msg, ok := (*selected).(messages.MessageCmp)
+	if !ok || msg.GetMessage().Role != message.User {
+		return util.ReportWarn("Select one of your messages to rewind to")
+	}
+	if m.app.CoderAgent != nil && m.app.CoderAgent.IsSessionBusy(m.session.ID) {
+		return util.ReportWarn("Agent is busy, please wait before rewinding...")
+	}
+	return util.CmdHandler(dialogs.OpenDialogMsg{
+		Model: rewind.NewRewindDialog(m.session.ID, msg.GetMessage().ID),
+	})
+}
+
+// editSelected loads the selected user message into the editor.
+func (m *messageListCmp) editSelected() tea.Cmd {
+	selected := m.listCmp.SelectedItem()
+	if selected == nil {
+		return nil
+	}
+	msg, ok := (*selected).(messages.MessageCmp)
+	if !ok || msg.GetMessage().Role != message.User {
+		return util.ReportWarn("Select one of your messages to edit")
+	}
+	if m.app.CoderAgent != nil && m.app.CoderAgent.IsSessionBusy(m.session.ID) {
+		return util.ReportWarn("Agent is busy, please wait before editing...")
+	}
+	return util.CmdHandler(EditMsg{Message: msg.GetMessage()})
+}
+
+// forkFromSelected forks the session at the selected message. Tool calls fork
+// from the assistant message that made them.
+func (m *messageListCmp) forkFromSelected() tea.Cmd {
+	selected := m.listCmp.SelectedItem()
+	if selected == nil {
+		return nil
+	}
+	var messageID string
+	switch item := (*selected).(type) {
+	case messages.MessageCmp:
+		messageID = item.GetMessage().ID
+	case messages.ToolCallCmp:
+		messageID = item.ParentMessageID()
+	default:
+		return util.ReportWarn("Select a message to fork from")
+	}
+	if m.app.CoderAgent != nil && m.app.CoderAgent.IsSessionBusy(m.session.ID) {
+		return util.ReportWarn("Agent is busy, please wait before forking...")
+	}
+	return util.CmdHandler(ForkMsg{
+		SessionID: m.session.ID,
+		MessageID: messageID,
+	})
+}
+
 // SetSession loads and displays messages for a new session.
 func (m *messageListCmp) SetSession(session session.Session) tea.Cmd {
 	if m.session.ID == session.ID {
 		return nil
 	}
+	return m.loadSession(session)
+}
+
+// ReloadSession reloads the messages of the current session, e.g. after some
+// of them were removed.
+func (m *messageListCmp) ReloadSession() tea.Cmd {
+	return m.loadSession(m.session)
+}
 
+func (m *messageListCmp) loadSession(session session.Session) tea.Cmd {
 	m.session = session
 	sessionMessages, err := m.app.Messages.List(context.Background(), session.ID)
 	if err != nil {
@@ -560,7 +662,7 @@ func (m *messageListCmp) IsFocused() bool {
 }
 
 func (m *messageListCmp) Bindings() []key.Binding {
-	return m.defaultListKeyMap.KeyBindings()
+	return append(m.defaultListKeyMap.KeyBindings(), m.keyMap.KeyBindings()...)
 }
 
 func (m *messageListCmp) GoToBottom() tea.Cmd {
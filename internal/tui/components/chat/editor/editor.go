@@ -18,6 +18,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea/v2"
 	"github.com/charmbracelet/crush/internal/app"
 	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/llm/agent"
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/session"
 	"github.com/charmbracelet/crush/internal/tui/components/chat"
@@ -30,6 +31,7 @@ import (
 	"github.com/charmbracelet/crush/internal/tui/styles"
 	"github.com/charmbracelet/crush/internal/tui/util"
 	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
 )
 
 type Editor interface {
@@ -40,6 +42,7 @@ type Editor interface {
 	layout.Positional
 
 	SetSession(session session.Session) tea.Cmd
+	QueueView() string
 	IsCompletionsOpen() bool
 	HasAttachments() bool
 	Cursor() *tea.Cursor
@@ -58,6 +61,8 @@ type editorCmp struct {
 	textarea           textarea.Model
 	attachments        []message.Attachment
 	deleteMode         bool
+	queueCancelMode    bool
+	editingMessageID   string // ID of the user message being edited, if any
 	readyPlaceholder   string
 	workingPlaceholder string
 
@@ -141,7 +146,7 @@ func (m *editorCmp) send() tea.Cmd {
 	if m.app.CoderAgent == nil {
 		return util.ReportError(fmt.Errorf("coder agent is not initialized"))
 	}
-	if m.app.CoderAgent.IsSessionBusy(m.session.ID) {
+	if m.editingMessageID != "" && m.app.CoderAgent.IsSessionBusy(m.session.ID) {
 		return util.ReportWarn("Agent is working, please wait...")
 	}
 
@@ -156,8 +161,10 @@ func (m *editorCmp) send() tea.Cmd {
 
 	m.textarea.Reset()
 	attachments := m.attachments
+	editingMessageID := m.editingMessageID
 
 	m.attachments = nil
+	m.editingMessageID = ""
 	if value == "" {
 		return nil
 	}
@@ -167,12 +174,93 @@ func (m *editorCmp) send() tea.Cmd {
 
 	return tea.Batch(
 		util.CmdHandler(chat.SendMsg{
-			Text:        value,
-			Attachments: attachments,
+			Text:          value,
+			Attachments:   attachments,
+			EditMessageID: editingMessageID,
 		}),
 	)
 }
 
+// edit loads an earlier user message into the editor.
+func (m *editorCmp) edit(msg message.Message) {
+	m.editingMessageID = msg.ID
+	m.textarea.SetValue(msg.Content().Text)
+	m.textarea.MoveToEnd()
+	m.attachments = nil
+	for _, bc := range msg.BinaryContent() {
+		m.attachments = append(m.attachments, message.Attachment{
+			FilePath: bc.Path,
+			FileName: filepath.Base(bc.Path),
+			MimeType: bc.MIMEType,
+			Content:  bc.Data,
+		})
+	}
+}
+
+func (m *editorCmp) queuedPrompts() []agent.QueuedPrompt {
+	if m.app.CoderAgent == nil || m.session.ID == "" {
+		return nil
+	}
+	return m.app.CoderAgent.QueuedPrompts(m.session.ID)
+}
+
+// editQueued takes a queued prompt out of the queue and into the editor.
+// Sending it queues it again.
+func (m *editorCmp) editQueued(index int) {
+	prompt, ok := m.app.CoderAgent.RemoveQueuedPrompt(m.session.ID, index)
+	if !ok {
+		return
+	}
+	m.textarea.SetValue(prompt.Content)
+	m.textarea.MoveToEnd()
+	m.attachments = prompt.Attachments
+}
+
+// cancelQueued handles the key pressed after the queue cancel key.
+func (m *editorCmp) cancelQueued(msg tea.KeyPressMsg) tea.Cmd {
+	if key.Matches(msg, QueueKeyMaps.CancelAll) {
+		m.app.CoderAgent.ClearQueue(m.session.ID)
+		return util.ReportInfo("Queued prompts cancelled")
+	}
+	if !unicode.IsDigit(msg.Code) {
+		return nil
+	}
+	if _, ok := m.app.CoderAgent.RemoveQueuedPrompt(m.session.ID, int(msg.Code-'1')); ok {
+		return util.ReportInfo("Queued prompt cancelled")
+	}
+	return nil
+}
+
+// QueueView renders the prompts waiting for the current session, if any.
+func (m *editorCmp) QueueView() string {
+	prompts := m.queuedPrompts()
+	if len(prompts) == 0 {
+		return ""
+	}
+	t := styles.CurrentTheme()
+	width := max(m.width-4, 10) // 4 for the padding
+	lines := []string{t.S().Subtle.Render("Queued · ↑ edit last · ctrl+x+{i} cancel · ctrl+x+x cancel all")}
+	for i, prompt := range prompts {
+		text, _, _ := strings.Cut(prompt.Content, "\n")
+		if len(prompt.Attachments) > 0 {
+			text = fmt.Sprintf("%s %s %d", text, styles.DocumentIcon, len(prompt.Attachments))
+		}
+		index := t.S().Muted.Render(fmt.Sprintf("%d.", i+1))
+		if m.queueCancelMode {
+			index = t.S().Base.Foreground(t.Error).Render(fmt.Sprintf("%d.", i+1))
+		}
+		lines = append(lines, ansi.Truncate(index+" "+t.S().Text.Render(text), width, "…"))
+	}
+	return t.S().Base.Width(m.width).Padding(0, 2).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+// cancelEdit drops the message being edited and clears the editor.
+func (m *editorCmp) cancelEdit() {
+	m.editingMessageID = ""
+	m.textarea.Reset()
+	m.attachments = nil
+}
+
 func (m *editorCmp) repositionCompletions() tea.Msg {
 	x, y := m.completionsPosition()
 	return completions.RepositionCompletionsMsg{X: x, Y: y}
@@ -225,6 +313,9 @@ func (m *editorCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case OpenEditorMsg:
 		m.textarea.SetValue(msg.Text)
 		m.textarea.MoveToEnd()
+	case chat.EditMsg:
+		m.edit(msg.Message)
+		return m, nil
 	case tea.PasteMsg:
 		path := strings.ReplaceAll(string(msg), "\\ ", " ")
 		// try to get an image
@@ -278,6 +369,20 @@ func (m *editorCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case m.isCompletionsOpen && curIdx <= m.completionsStartIndex:
 			cmds = append(cmds, util.CmdHandler(completions.CloseCompletionsMsg{}))
 		}
+		if m.queueCancelMode {
+			m.queueCancelMode = false
+			return m, m.cancelQueued(msg)
+		}
+		if key.Matches(msg, QueueKeyMaps.CancelMode) && len(m.queuedPrompts()) > 0 {
+			m.queueCancelMode = true
+			return m, nil
+		}
+		if key.Matches(msg, QueueKeyMaps.EditLast) && m.textarea.Value() == "" && m.editingMessageID == "" {
+			if prompts := m.queuedPrompts(); len(prompts) > 0 {
+				m.editQueued(len(prompts) - 1)
+				return m, nil
+			}
+		}
 		if key.Matches(msg, DeleteKeyMaps.AttachmentDeleteMode) {
 			m.deleteMode = true
 			return m, nil
@@ -307,6 +412,9 @@ func (m *editorCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, m.openEditor(m.textarea.Value())
 		}
 		if key.Matches(msg, DeleteKeyMaps.Escape) {
+			if !m.deleteMode && m.editingMessageID != "" {
+				m.cancelEdit()
+			}
 			m.deleteMode = false
 			return m, nil
 		}
@@ -416,15 +524,27 @@ func (m *editorCmp) View() string {
 	} else {
 		m.textarea.Placeholder = m.readyPlaceholder
 	}
-	if len(m.attachments) == 0 {
+	planMode := m.app.CoderAgent != nil && m.session.ID != "" && m.app.CoderAgent.IsPlanMode(m.session.ID)
+	if len(m.attachments) == 0 && m.editingMessageID == "" && !planMode {
 		content := t.S().Base.Padding(1).Render(
 			m.textarea.View(),
 		)
 		return content
 	}
+	var notes []string
+	if planMode {
+		notes = append(notes, t.S().Base.Foreground(t.Secondary).Render("Plan mode · read-only tools"))
+	}
+	if m.editingMessageID != "" {
+		notes = append(notes, t.S().Muted.Render("Editing message · esc to cancel"))
+	}
+	header := lipgloss.JoinHorizontal(lipgloss.Left,
+		strings.Join(notes, t.S().Muted.Render(" · ")),
+		m.attachmentsContent(),
+	)
 	content := t.S().Base.Padding(0, 1, 1, 1).Render(
 		lipgloss.JoinVertical(lipgloss.Top,
-			m.attachmentsContent(),
+			header,
 			m.textarea.View(),
 		),
 	)
@@ -517,6 +637,9 @@ func (c *editorCmp) Bindings() []key.Binding {
 // TODO: most likely we do not need to have the session here
 // we need to move some functionality to the page level
 func (c *editorCmp) SetSession(session session.Session) tea.Cmd {
+	if c.session.ID != session.ID && c.editingMessageID != "" {
+		c.cancelEdit()
+	}
 	c.session = session
 	return nil
 }
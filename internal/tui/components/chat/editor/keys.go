@@ -45,6 +45,7 @@ func (k EditorKeyMap) KeyBindings() []key.Binding {
 		AttachmentsKeyMaps.AttachmentDeleteMode,
 		AttachmentsKeyMaps.DeleteAllAttachments,
 		AttachmentsKeyMaps.Escape,
+		QueueKeyMaps.CancelMode,
 	}
 }
 
@@ -69,3 +70,24 @@ var AttachmentsKeyMaps = DeleteAttachmentKeyMaps{
 		key.WithHelp("ctrl+r+r", "delete all attachments"),
 	),
 }
+
+type QueuedPromptKeyMaps struct {
+	EditLast   key.Binding
+	CancelMode key.Binding
+	CancelAll  key.Binding
+}
+
+var QueueKeyMaps = QueuedPromptKeyMaps{
+	EditLast: key.NewBinding(
+		key.WithKeys("up"),
+		key.WithHelp("↑", "edit last queued prompt"),
+	),
+	CancelMode: key.NewBinding(
+		key.WithKeys("ctrl+x"),
+		key.WithHelp("ctrl+x+{i}", "cancel queued prompt i"),
+	),
+	CancelAll: key.NewBinding(
+		key.WithKeys("x"),
+		key.WithHelp("ctrl+x+x", "cancel all queued prompts"),
+	),
+}
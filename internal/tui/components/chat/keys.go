@@ -0,0 +1,38 @@
+package chat
+
+import (
+	"github.com/charmbracelet/bubbles/v2/key"
+)
+
+// KeyMap defines the actions available on the selected message.
+type KeyMap struct {
+	Rewind key.Binding
+	Fork   key.Binding
+	Edit   key.Binding
+}
+
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Rewind: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", "rewind to here"),
+		),
+		Fork: key.NewBinding(
+			key.WithKeys("F"),
+			key.WithHelp("F", "fork from here"),
+		),
+		Edit: key.NewBinding(
+			key.WithKeys("e"),
+			key.WithHelp("e", "edit and resend"),
+		),
+	}
+}
+
+// KeyBindings implements layout.KeyMapProvider
+func (k KeyMap) KeyBindings() []key.Binding {
+	return []key.Binding{
+		k.Rewind,
+		k.Fork,
+		k.Edit,
+	}
+}
@@ -1,6 +1,7 @@
 package messages
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -163,10 +164,16 @@ func (br baseRenderer) renderError(v *toolCallCmp, message string) string {
 // Register tool renderers
 func init() {
 	registry.register(tools.BashToolName, func() renderer { return bashRenderer{} })
+	registry.register(tools.JobOutputToolName, func() renderer { return jobRenderer{} })
+	registry.register(tools.JobListToolName, func() renderer { return jobRenderer{} })
+	registry.register(tools.JobKillToolName, func() renderer { return jobRenderer{} })
 	registry.register(tools.DownloadToolName, func() renderer { return downloadRenderer{} })
 	registry.register(tools.ViewToolName, func() renderer { return viewRenderer{} })
 	registry.register(tools.EditToolName, func() renderer { return editRenderer{} })
 	registry.register(tools.MultiEditToolName, func() renderer { return multiEditRenderer{} })
+	registry.register(tools.ApplyPatchToolName, func() renderer { return applyPatchRenderer{} })
+	registry.register(tools.RenameSymbolToolName, func() renderer { return workspaceEditRenderer{} })
+	registry.register(tools.ApplyCodeActionToolName, func() renderer { return workspaceEditRenderer{} })
 	registry.register(tools.WriteToolName, func() renderer { return writeRenderer{} })
 	registry.register(tools.FetchToolName, func() renderer { return fetchRenderer{} })
 	registry.register(tools.GlobToolName, func() renderer { return globRenderer{} })
@@ -174,6 +181,12 @@ func init() {
 	registry.register(tools.LSToolName, func() renderer { return lsRenderer{} })
 	registry.register(tools.SourcegraphToolName, func() renderer { return sourcegraphRenderer{} })
 	registry.register(tools.DiagnosticsToolName, func() renderer { return diagnosticsRenderer{} })
+	registry.register(tools.DefinitionToolName, func() renderer { return navigationRenderer{} })
+	registry.register(tools.ReferencesToolName, func() renderer { return navigationRenderer{} })
+	registry.register(tools.SymbolsToolName, func() renderer { return navigationRenderer{} })
+	registry.register(tools.HoverToolName, func() renderer { return navigationRenderer{} })
+	registry.register(tools.OutlineToolName, func() renderer { return navigationRenderer{} })
+	registry.register(tools.CallHierarchyToolName, func() renderer { return navigationRenderer{} })
 	registry.register(agent.AgentToolName, func() renderer { return agentRenderer{} })
 }
 
@@ -211,7 +224,10 @@ func (br bashRenderer) Render(v *toolCallCmp) string {
 
 	cmd := strings.ReplaceAll(params.Command, "\n", " ")
 	cmd = strings.ReplaceAll(cmd, "\t", "    ")
-	args := newParamBuilder().addMain(cmd).build()
+	args := newParamBuilder().
+		addMain(cmd).
+		addFlag("background", params.RunInBackground).
+		build()
 
 	return br.renderWithParams(v, "Bash", args, func() string {
 		var meta tools.BashResponseMetadata
@@ -230,6 +246,32 @@ func (br bashRenderer) Render(v *toolCallCmp) string {
 	})
 }
 
+// -----------------------------------------------------------------------------
+//  Job renderer
+// -----------------------------------------------------------------------------
+
+// jobRenderer handles the tools that read, list and kill background jobs
+type jobRenderer struct {
+	baseRenderer
+}
+
+// Render displays the job and its output
+func (jr jobRenderer) Render(v *toolCallCmp) string {
+	var params tools.JobOutputParams
+	var args []string
+	if err := jr.unmarshalParams(v.call.Input, &params); err == nil {
+		pb := newParamBuilder().addMain(params.JobID)
+		if params.Wait > 0 {
+			pb.addKeyValue("wait", (time.Duration(params.Wait) * time.Millisecond).String())
+		}
+		args = pb.build()
+	}
+
+	return jr.renderWithParams(v, prettifyToolName(v.call.Name), args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
 // -----------------------------------------------------------------------------
 //  View renderer
 // -----------------------------------------------------------------------------
@@ -368,6 +410,118 @@ func (mer multiEditRenderer) Render(v *toolCallCmp) string {
 	})
 }
 
+// -----------------------------------------------------------------------------
+//  Apply Patch renderer
+// -----------------------------------------------------------------------------
+
+// applyPatchRenderer handles patches to several files with a diff per file
+type applyPatchRenderer struct {
+	baseRenderer
+}
+
+// Render displays the diffs of all the files the patch changed
+func (apr applyPatchRenderer) Render(v *toolCallCmp) string {
+	var meta tools.ApplyPatchResponseMetadata
+	metaErr := apr.unmarshalParams(v.result.Metadata, &meta)
+	var args []string
+	if metaErr == nil && len(meta.Files) > 0 {
+		args = newParamBuilder().
+			addMain(fmt.Sprintf("%d files", len(meta.Files))).
+			addKeyValue("additions", fmt.Sprintf("%d", meta.Additions)).
+			addKeyValue("removals", fmt.Sprintf("%d", meta.Removals)).
+			build()
+	}
+
+	return apr.renderWithParams(v, "Apply Patch", args, func() string {
+		if metaErr != nil {
+			return renderPlainContent(v, v.result.Content)
+		}
+		return renderPatchedFiles(v, meta.Files)
+	})
+}
+
+// renderPatchedFiles renders a diff per file, truncated to the response
+// height
+func renderPatchedFiles(v *toolCallCmp, files []tools.PatchedFile) string {
+	t := styles.CurrentTheme()
+	var diffs []string
+	for _, file := range files {
+		before := fsext.PrettyPath(cmp.Or(file.OldPath, file.Path))
+		if file.Created {
+			before = "/dev/null"
+		}
+		after := fsext.PrettyPath(file.Path)
+		if file.Deleted {
+			after = "/dev/null"
+		}
+		formatter := core.DiffFormatter().
+			Before(before, file.OldContent).
+			After(after, file.NewContent).
+			Width(v.textWidth() - 2) // -2 for padding
+		if v.textWidth() > 120 {
+			formatter = formatter.Split()
+		}
+		diffs = append(diffs, formatter.String())
+	}
+	// add a message to the bottom if the content was truncated
+	formatted := strings.Join(diffs, "\n")
+	if lipgloss.Height(formatted) > responseContextHeight {
+		contentLines := strings.Split(formatted, "\n")
+		truncateMessage := t.S().Muted.
+			Background(t.BgBaseLighter).
+			PaddingLeft(2).
+			Width(v.textWidth() - 4).
+			Render(fmt.Sprintf("… (%d lines)", len(contentLines)-responseContextHeight))
+		formatted = strings.Join(contentLines[:responseContextHeight], "\n") + "\n" + truncateMessage
+	}
+	return formatted
+}
+
+// -----------------------------------------------------------------------------
+//  Workspace Edit renderer
+// -----------------------------------------------------------------------------
+
+// workspaceEditRenderer handles the language server edits of rename_symbol
+// and apply_code_action with a diff per file
+type workspaceEditRenderer struct {
+	baseRenderer
+}
+
+// Render displays the symbol or action and the diffs of the changed files
+func (wer workspaceEditRenderer) Render(v *toolCallCmp) string {
+	// The two tools share the parameters shown in the header.
+	var params struct {
+		FilePath string `json:"file_path"`
+		Line     int    `json:"line"`
+		Symbol   string `json:"symbol"`
+		NewName  string `json:"new_name"`
+		Kind     string `json:"kind"`
+		Title    string `json:"title"`
+	}
+	var args []string
+	if err := wer.unmarshalParams(v.call.Input, &params); err == nil {
+		main := fsext.PrettyPath(params.FilePath)
+		if params.Line > 0 {
+			main = fmt.Sprintf("%s:%d", main, params.Line)
+		}
+		args = newParamBuilder().
+			addMain(main).
+			addKeyValue("symbol", params.Symbol).
+			addKeyValue("new_name", params.NewName).
+			addKeyValue("kind", params.Kind).
+			addKeyValue("title", params.Title).
+			build()
+	}
+
+	return wer.renderWithParams(v, prettifyToolName(v.call.Name), args, func() string {
+		var meta tools.WorkspaceEditResponseMetadata
+		if err := wer.unmarshalParams(v.result.Metadata, &meta); err != nil || len(meta.Files) == 0 {
+			return renderPlainContent(v, v.result.Content)
+		}
+		return renderPatchedFiles(v, meta.Files)
+	})
+}
+
 // -----------------------------------------------------------------------------
 //  Write renderer
 // -----------------------------------------------------------------------------
@@ -589,6 +743,48 @@ func (dr diagnosticsRenderer) Render(v *toolCallCmp) string {
 	})
 }
 
+// -----------------------------------------------------------------------------
+//  Navigation renderer
+// -----------------------------------------------------------------------------
+
+// navigationRenderer handles the LSP code navigation tools
+type navigationRenderer struct {
+	baseRenderer
+}
+
+// Render displays the symbol or file looked up with plain content formatting
+func (nr navigationRenderer) Render(v *toolCallCmp) string {
+	// The navigation tools share their parameter names.
+	var params struct {
+		FilePath  string `json:"file_path"`
+		Line      int    `json:"line"`
+		Symbol    string `json:"symbol"`
+		Query     string `json:"query"`
+		Kind      string `json:"kind"`
+		Direction string `json:"direction"`
+	}
+	var args []string
+	if err := nr.unmarshalParams(v.call.Input, &params); err == nil {
+		main := params.Query
+		if params.FilePath != "" {
+			main = fsext.PrettyPath(params.FilePath)
+			if params.Line > 0 {
+				main = fmt.Sprintf("%s:%d", main, params.Line)
+			}
+		}
+		args = newParamBuilder().
+			addMain(main).
+			addKeyValue("symbol", params.Symbol).
+			addKeyValue("kind", params.Kind).
+			addKeyValue("direction", params.Direction).
+			build()
+	}
+
+	return nr.renderWithParams(v, prettifyToolName(v.call.Name), args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
 // -----------------------------------------------------------------------------
 //  Task renderer
 // -----------------------------------------------------------------------------
@@ -866,22 +1062,46 @@ func prettifyToolName(name string) string {
 		return "Agent"
 	case tools.BashToolName:
 		return "Bash"
+	case tools.CallHierarchyToolName:
+		return "Call Hierarchy"
+	case tools.DefinitionToolName:
+		return "Definition"
 	case tools.DownloadToolName:
 		return "Download"
 	case tools.EditToolName:
 		return "Edit"
 	case tools.MultiEditToolName:
 		return "Multi-Edit"
+	case tools.ApplyPatchToolName:
+		return "Apply Patch"
+	case tools.ApplyCodeActionToolName:
+		return "Code Action"
 	case tools.FetchToolName:
 		return "Fetch"
 	case tools.GlobToolName:
 		return "Glob"
 	case tools.GrepToolName:
 		return "Grep"
+	case tools.HoverToolName:
+		return "Hover"
+	case tools.JobKillToolName:
+		return "Kill Job"
+	case tools.JobListToolName:
+		return "Jobs"
+	case tools.JobOutputToolName:
+		return "Job Output"
 	case tools.LSToolName:
 		return "List"
+	case tools.OutlineToolName:
+		return "Outline"
+	case tools.ReferencesToolName:
+		return "References"
+	case tools.RenameSymbolToolName:
+		return "Rename"
 	case tools.SourcegraphToolName:
 		return "Sourcegraph"
+	case tools.SymbolsToolName:
+		return "Symbols"
 	case tools.ViewToolName:
 		return "View"
 	case tools.WriteToolName:
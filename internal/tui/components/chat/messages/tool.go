@@ -281,6 +281,35 @@ func (m *toolCallCmp) formatParametersForCopy() string {
 		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
 			return fmt.Sprintf("**File:** %s", fsext.PrettyPath(params.FilePath))
 		}
+	case tools.ApplyPatchToolName:
+		var params tools.ApplyPatchParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			return fmt.Sprintf("```diff\n%s\n```", strings.TrimSuffix(params.Patch, "\n"))
+		}
+	case tools.RenameSymbolToolName:
+		var params tools.RenameSymbolParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			var parts []string
+			parts = append(parts, fmt.Sprintf("**File:** %s:%d", fsext.PrettyPath(params.FilePath), params.Line))
+			if params.Symbol != "" {
+				parts = append(parts, fmt.Sprintf("**Symbol:** %s", params.Symbol))
+			}
+			parts = append(parts, fmt.Sprintf("**New Name:** %s", params.NewName))
+			return strings.Join(parts, "\n")
+		}
+	case tools.ApplyCodeActionToolName:
+		var params tools.ApplyCodeActionParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			var parts []string
+			parts = append(parts, fmt.Sprintf("**File:** %s", fsext.PrettyPath(params.FilePath)))
+			if params.Kind != "" {
+				parts = append(parts, fmt.Sprintf("**Kind:** %s", params.Kind))
+			}
+			if params.Title != "" {
+				parts = append(parts, fmt.Sprintf("**Title:** %s", params.Title))
+			}
+			return strings.Join(parts, "\n")
+		}
 	case tools.FetchToolName:
 		var params tools.FetchParams
 		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
@@ -388,13 +417,17 @@ func (m *toolCallCmp) formatResultForCopy() string {
 		return m.formatEditResultForCopy()
 	case tools.MultiEditToolName:
 		return m.formatMultiEditResultForCopy()
+	case tools.ApplyPatchToolName, tools.RenameSymbolToolName, tools.ApplyCodeActionToolName:
+		return m.formatApplyPatchResultForCopy()
 	case tools.WriteToolName:
 		return m.formatWriteResultForCopy()
 	case tools.FetchToolName:
 		return m.formatFetchResultForCopy()
 	case agent.AgentToolName:
 		return m.formatAgentResultForCopy()
-	case tools.DownloadToolName, tools.GrepToolName, tools.GlobToolName, tools.LSToolName, tools.SourcegraphToolName, tools.DiagnosticsToolName:
+	case tools.DownloadToolName, tools.GrepToolName, tools.GlobToolName, tools.LSToolName, tools.SourcegraphToolName, tools.DiagnosticsToolName,
+		tools.DefinitionToolName, tools.ReferencesToolName, tools.SymbolsToolName, tools.HoverToolName, tools.OutlineToolName, tools.CallHierarchyToolName,
+		tools.JobOutputToolName, tools.JobListToolName, tools.JobKillToolName:
 		return fmt.Sprintf("```\n%s\n```", m.result.Content)
 	default:
 		return m.result.Content
@@ -539,6 +572,28 @@ func (m *toolCallCmp) formatMultiEditResultForCopy() string {
 	return result.String()
 }
 
+func (m *toolCallCmp) formatApplyPatchResultForCopy() string {
+	var meta tools.ApplyPatchResponseMetadata
+	if m.result.Metadata == "" {
+		return m.result.Content
+	}
+
+	if json.Unmarshal([]byte(m.result.Metadata), &meta) != nil {
+		return m.result.Content
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Changes: +%d -%d\n", meta.Additions, meta.Removals))
+	for _, file := range meta.Files {
+		diffContent, _, _ := diff.GenerateDiff(file.OldContent, file.NewContent, fsext.PrettyPath(file.Path))
+		result.WriteString("```diff\n")
+		result.WriteString(diffContent)
+		result.WriteString("\n```\n")
+	}
+
+	return strings.TrimSuffix(result.String(), "\n")
+}
+
 func (m *toolCallCmp) formatWriteResultForCopy() string {
 	var params tools.WriteParams
 	if json.Unmarshal([]byte(m.call.Input), &params) != nil {
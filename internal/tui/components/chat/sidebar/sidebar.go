@@ -15,16 +15,19 @@ import (
 	"github.com/charmbracelet/crush/internal/diff"
 	"github.com/charmbracelet/crush/internal/fsext"
 	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/llm/agent"
 	"github.com/charmbracelet/crush/internal/lsp"
 	"github.com/charmbracelet/crush/internal/lsp/protocol"
 	"github.com/charmbracelet/crush/internal/pubsub"
 	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/shell"
 	"github.com/charmbracelet/crush/internal/tui/components/chat"
 	"github.com/charmbracelet/crush/internal/tui/components/core"
 	"github.com/charmbracelet/crush/internal/tui/components/core/layout"
 	"github.com/charmbracelet/crush/internal/tui/components/logo"
 	"github.com/charmbracelet/crush/internal/tui/styles"
 	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/crush/internal/usage"
 	"github.com/charmbracelet/crush/internal/version"
 	"github.com/charmbracelet/lipgloss/v2"
 	"github.com/charmbracelet/x/ansi"
@@ -44,6 +47,7 @@ const (
 	DefaultMaxFilesShown = 10
 	DefaultMaxLSPsShown  = 8
 	DefaultMaxMCPsShown  = 8
+	DefaultMaxJobsShown  = 5
 	MinItemsPerSection   = 2 // Minimum items to show per section
 )
 
@@ -57,6 +61,12 @@ type SessionFilesMsg struct {
 	Files []SessionFile
 }
 
+// SessionUsageMsg carries the usage totals of a session.
+type SessionUsageMsg struct {
+	SessionID string
+	Total     usage.Total
+}
+
 type Sidebar interface {
 	util.Model
 	layout.Sizeable
@@ -73,12 +83,20 @@ type sidebarCmp struct {
 	compactMode   bool
 	history       history.Service
 	files         *csync.Map[string, SessionFile]
+	// Estimated input tokens of the session's next request
+	nextTokens int64
+	ledger     usage.Service
+	// Usage totals of the session, for its prompt cache rates
+	usage usage.Total
+	// Background jobs of the session
+	jobs []shell.Job
 }
 
-func New(history history.Service, lspClients map[string]*lsp.Client, compact bool) Sidebar {
+func New(history history.Service, ledger usage.Service, lspClients map[string]*lsp.Client, compact bool) Sidebar {
 	return &sidebarCmp{
 		lspClients:  lspClients,
 		history:     history,
+		ledger:      ledger,
 		compactMode: compact,
 		files:       csync.NewMap[string, SessionFile](),
 	}
@@ -96,9 +114,28 @@ func (m *sidebarCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.files.Set(file.FilePath, file)
 		}
 		return m, nil
+	case SessionUsageMsg:
+		if msg.SessionID == m.session.ID {
+			m.usage = msg.Total
+		}
+		return m, nil
 
 	case chat.SessionClearedMsg:
 		m.session = session.Session{}
+		m.nextTokens = 0
+		m.usage = usage.Total{}
+		m.jobs = nil
+	case pubsub.Event[shell.Job]:
+		if msg.Payload.SessionID == m.session.ID {
+			m.jobs = shell.BackgroundJobs().List(m.session.ID)
+		}
+	case pubsub.Event[agent.AgentEvent]:
+		switch {
+		case msg.Payload.Type == agent.AgentEventTypeTokens && msg.Payload.SessionID == m.session.ID:
+			m.nextTokens = msg.Payload.Tokens
+		case msg.Payload.Type == agent.AgentEventTypeResponse && msg.Payload.Message.SessionID == m.session.ID:
+			return m, m.loadSessionUsage
+		}
 	case pubsub.Event[history.File]:
 		return m, m.handleFileHistoryEvent(msg)
 	case pubsub.Event[session.Session]:
@@ -162,6 +199,9 @@ func (m *sidebarCmp) View() string {
 		if m.session.ID != "" {
 			parts = append(parts, "", m.filesBlock())
 		}
+		if len(m.jobs) > 0 {
+			parts = append(parts, "", m.jobsBlock())
+		}
 		parts = append(parts,
 			"",
 			m.lspBlock(),
@@ -263,6 +303,30 @@ func (m *sidebarCmp) loadSessionFiles() tea.Msg {
 	}
 }
 
+func (m *sidebarCmp) loadSessionUsage() tea.Msg {
+	sessionID := m.session.ID
+	records, err := m.ledger.ListBySession(context.Background(), sessionID)
+	if err != nil {
+		return util.InfoMsg{
+			Type: util.InfoTypeError,
+			Msg:  err.Error(),
+		}
+	}
+	var total usage.Total
+	for _, record := range records {
+		total.Calls++
+		total.InputTokens += record.InputTokens
+		total.OutputTokens += record.OutputTokens
+		total.CacheCreationTokens += record.CacheCreationTokens
+		total.CacheReadTokens += record.CacheReadTokens
+		total.Cost += record.Cost
+	}
+	return SessionUsageMsg{
+		SessionID: sessionID,
+		Total:     total,
+	}
+}
+
 func (m *sidebarCmp) SetSize(width, height int) tea.Cmd {
 	m.logo = m.logoBlock()
 	m.cwd = cwd()
@@ -318,6 +382,11 @@ func (m *sidebarCmp) calculateAvailableHeight() int {
 
 	usedHeight += 6 // 3 sections × 2 lines each (header + empty line)
 
+	if len(m.jobs) > 0 {
+		usedHeight += 3                                       // Empty line, header and empty line
+		usedHeight += min(len(m.jobs), DefaultMaxJobsShown+1) // Jobs and the "more" line
+	}
+
 	// Base padding
 	usedHeight += 2 // Top and bottom padding
 
@@ -692,6 +761,59 @@ func (m *sidebarCmp) filesBlock() string {
 	)
 }
 
+func (m *sidebarCmp) jobsBlock() string {
+	t := styles.CurrentTheme()
+
+	section := t.S().Subtle.Render(
+		core.Section("Jobs", m.getMaxWidth()),
+	)
+
+	jobList := []string{section, ""}
+
+	// Show the latest jobs first
+	jobs := slices.Clone(m.jobs)
+	slices.Reverse(jobs)
+	maxJobs := min(len(jobs), DefaultMaxJobsShown)
+	for _, job := range jobs[:maxJobs] {
+		iconColor := t.Success
+		description := "running"
+		switch {
+		case job.Status == shell.JobKilled:
+			iconColor = t.FgMuted
+			description = "killed"
+		case job.Status == shell.JobExited && job.ExitCode != 0:
+			iconColor = t.Error
+			description = fmt.Sprintf("exit %d", job.ExitCode)
+		case job.Status == shell.JobExited:
+			iconColor = t.FgMuted
+			description = "done"
+		}
+		jobList = append(jobList,
+			core.Status(
+				core.StatusOpts{
+					IconColor:   iconColor,
+					Title:       job.Command,
+					Description: description,
+				},
+				m.getMaxWidth(),
+			),
+		)
+	}
+
+	// Add indicator if there are more jobs
+	if len(jobs) > maxJobs {
+		remaining := len(jobs) - maxJobs
+		jobList = append(jobList,
+			t.S().Base.Foreground(t.FgSubtle).Render(fmt.Sprintf("…and %d more", remaining)),
+		)
+	}
+
+	return lipgloss.JoinVertical(
+		lipgloss.Left,
+		jobList...,
+	)
+}
+
 func (m *sidebarCmp) lspBlock() string {
 	t := styles.CurrentTheme()
 
@@ -837,9 +959,8 @@ func (m *sidebarCmp) mcpBlock() string {
 	)
 }
 
-func formatTokensAndCost(tokens, contextWindow int64, cost float64) string {
-	t := styles.CurrentTheme()
-	// Format tokens in human-readable format (e.g., 110K, 1.2M)
+// formatTokens formats tokens in human-readable format (e.g., 110K, 1.2M)
+func formatTokens(tokens int64) string {
 	var formattedTokens string
 	switch {
 	case tokens >= 1_000_000:
@@ -857,6 +978,14 @@ func formatTokensAndCost(tokens, contextWindow int64, cost float64) string {
 	if strings.HasSuffix(formattedTokens, ".0M") {
 		formattedTokens = strings.Replace(formattedTokens, ".0M", "M", 1)
 	}
+	return formattedTokens
+}
+
+// formatTokensAndCost formats the tokens and cost of the last request, and on
+// a second line the estimated size of the next one when it is known.
+func formatTokensAndCost(tokens, nextTokens, contextWindow int64, cost float64) string {
+	t := styles.CurrentTheme()
+	formattedTokens := formatTokens(tokens)
 
 	percentage := (float64(tokens) / float64(contextWindow)) * 100
 
@@ -872,7 +1001,30 @@ func formatTokensAndCost(tokens, contextWindow int64, cost float64) string {
 		formattedTokens = fmt.Sprintf("%s %s", styles.WarningIcon, formattedTokens)
 	}
 
-	return fmt.Sprintf("%s %s", formattedTokens, formattedCost)
+	usage := fmt.Sprintf("%s %s", formattedTokens, formattedCost)
+	if nextTokens <= 0 {
+		return usage
+	}
+
+	nextPercentage := (float64(nextTokens) / float64(contextWindow)) * 100
+	next := baseStyle.Foreground(t.FgSubtle).Render(fmt.Sprintf("Next ~%s (%d%%)", formatTokens(nextTokens), int(nextPercentage)))
+	if nextPercentage > 80 {
+		next = fmt.Sprintf("%s %s", styles.WarningIcon, next)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, usage, next)
+}
+
+// formatCacheRates formats the share of the session's input tokens that were
+// read from and written to the prompt cache. It returns an empty string when
+// the session didn't use the cache.
+func formatCacheRates(total usage.Total) string {
+	if total.CacheCreationTokens+total.CacheReadTokens == 0 {
+		return ""
+	}
+	t := styles.CurrentTheme()
+	return t.S().Base.Foreground(t.FgSubtle).Render(
+		fmt.Sprintf("Cache %d%% hit, %d%% miss", int(total.CacheHitRate()*100), int(total.CacheMissRate()*100)),
+	)
 }
 
 func (s *sidebarCmp) currentModelBlock() string {
@@ -914,12 +1066,16 @@ func (s *sidebarCmp) currentModelBlock() string {
 	if s.session.ID != "" {
 		parts = append(
 			parts,
-			"  "+formatTokensAndCost(
+			t.S().Base.PaddingLeft(2).Render(formatTokensAndCost(
 				s.session.CompletionTokens+s.session.PromptTokens,
+				s.nextTokens,
 				model.ContextWindow,
 				s.session.Cost,
-			),
+			)),
 		)
+		if rates := formatCacheRates(s.usage); rates != "" {
+			parts = append(parts, t.S().Base.PaddingLeft(2).Render(rates))
+		}
 	}
 	return lipgloss.JoinVertical(
 		lipgloss.Left,
@@ -929,8 +1085,13 @@ func (s *sidebarCmp) currentModelBlock() string {
 
 // SetSession implements Sidebar.
 func (m *sidebarCmp) SetSession(session session.Session) tea.Cmd {
+	if m.session.ID != session.ID {
+		m.nextTokens = 0
+		m.usage = usage.Total{}
+	}
 	m.session = session
-	return m.loadSessionFiles
+	m.jobs = shell.BackgroundJobs().List(session.ID)
+	return tea.Batch(m.loadSessionFiles, m.loadSessionUsage)
 }
 
 // SetCompactMode sets the compact mode for the sidebar.
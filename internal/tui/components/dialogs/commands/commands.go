@@ -68,10 +68,14 @@ type (
 	ToggleHelpMsg         struct{}
 	ToggleCompactModeMsg  struct{}
 	ToggleThinkingMsg     struct{}
+	TogglePlanModeMsg     struct{}
 	OpenExternalEditorMsg struct{}
 	CompactMsg            struct {
 		SessionID string
 	}
+	SwitchAgentMsg struct {
+		AgentID string
+	}
 )
 
 func NewCommandDialog(sessionID string) CommandsDialog {
@@ -282,6 +286,30 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 		},
 	}
 
+	// Only show agent switching when the user defined their own agents
+	if customAgents := config.Get().CustomAgents(); len(customAgents) > 0 {
+		agents := append([]config.Agent{config.Get().Agents["coder"]}, customAgents...)
+		for _, agent := range agents {
+			commands = append(commands, Command{
+				ID:          "switch_agent_" + agent.ID,
+				Title:       "Switch to " + agent.Name + " Agent",
+				Description: agent.Description,
+				Handler: func(cmd Command) tea.Cmd {
+					return util.CmdHandler(SwitchAgentMsg{AgentID: agent.ID})
+				},
+			})
+		}
+	}
+
+	commands = append(commands, Command{
+		ID:          "toggle_plan_mode",
+		Title:       "Toggle Plan Mode",
+		Description: "Plan with read-only tools and approve the plan before any change is made",
+		Handler: func(cmd Command) tea.Cmd {
+			return util.CmdHandler(TogglePlanModeMsg{})
+		},
+	})
+
 	// Only show compact command if there's an active session
 	if c.sessionID != "" {
 		commands = append(commands, Command{
@@ -1,6 +1,7 @@
 package permissions
 
 import (
+	"cmp"
 	"fmt"
 	"strings"
 
@@ -84,7 +85,12 @@ func (p *permissionDialogCmp) Init() tea.Cmd {
 }
 
 func (p *permissionDialogCmp) supportsDiffView() bool {
-	return p.permission.ToolName == tools.EditToolName || p.permission.ToolName == tools.WriteToolName || p.permission.ToolName == tools.MultiEditToolName
+	switch p.permission.ToolName {
+	case tools.EditToolName, tools.WriteToolName, tools.MultiEditToolName, tools.ApplyPatchToolName,
+		tools.RenameSymbolToolName, tools.ApplyCodeActionToolName:
+		return true
+	}
+	return false
 }
 
 func (p *permissionDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -319,6 +325,70 @@ func (p *permissionDialogCmp) renderHeader() string {
 			),
 			baseStyle.Render(strings.Repeat(" ", p.width)),
 		)
+	case tools.ApplyPatchToolName:
+		params := p.permission.Params.(tools.ApplyPatchPermissionsParams)
+		filesKey := t.S().Muted.Render("Files")
+		fileCount := t.S().Text.
+			Width(p.width - lipgloss.Width(filesKey)).
+			Render(fmt.Sprintf(" %d", len(params.Files)))
+		headerParts = append(headerParts,
+			lipgloss.JoinHorizontal(
+				lipgloss.Left,
+				filesKey,
+				fileCount,
+			),
+			baseStyle.Render(strings.Repeat(" ", p.width)),
+		)
+	case tools.RenameSymbolToolName:
+		params := p.permission.Params.(tools.RenameSymbolPermissionsParams)
+		renameKey := t.S().Muted.Render("Rename")
+		rename := params.NewName
+		if params.Symbol != "" {
+			rename = fmt.Sprintf("%s → %s", params.Symbol, params.NewName)
+		}
+		renameValue := t.S().Text.
+			Width(p.width - lipgloss.Width(renameKey)).
+			Render(" " + rename)
+		filesKey := t.S().Muted.Render("Files")
+		fileCount := t.S().Text.
+			Width(p.width - lipgloss.Width(filesKey)).
+			Render(fmt.Sprintf(" %d", len(params.Files)))
+		headerParts = append(headerParts,
+			lipgloss.JoinHorizontal(
+				lipgloss.Left,
+				renameKey,
+				renameValue,
+			),
+			lipgloss.JoinHorizontal(
+				lipgloss.Left,
+				filesKey,
+				fileCount,
+			),
+			baseStyle.Render(strings.Repeat(" ", p.width)),
+		)
+	case tools.ApplyCodeActionToolName:
+		params := p.permission.Params.(tools.ApplyCodeActionPermissionsParams)
+		actionKey := t.S().Muted.Render("Action")
+		actionValue := t.S().Text.
+			Width(p.width - lipgloss.Width(actionKey)).
+			Render(" " + params.Title)
+		filesKey := t.S().Muted.Render("Files")
+		fileCount := t.S().Text.
+			Width(p.width - lipgloss.Width(filesKey)).
+			Render(fmt.Sprintf(" %d", len(params.Files)))
+		headerParts = append(headerParts,
+			lipgloss.JoinHorizontal(
+				lipgloss.Left,
+				actionKey,
+				actionValue,
+			),
+			lipgloss.JoinHorizontal(
+				lipgloss.Left,
+				filesKey,
+				fileCount,
+			),
+			baseStyle.Render(strings.Repeat(" ", p.width)),
+		)
 	case tools.FetchToolName:
 		headerParts = append(headerParts, t.S().Muted.Width(p.width).Bold(true).Render("URL"))
 	case tools.ViewToolName:
@@ -373,6 +443,16 @@ func (p *permissionDialogCmp) getOrGenerateContent() string {
 		content = p.generateWriteContent()
 	case tools.MultiEditToolName:
 		content = p.generateMultiEditContent()
+	case tools.ApplyPatchToolName:
+		content = p.generateApplyPatchContent()
+	case tools.RenameSymbolToolName:
+		if pr, ok := p.permission.Params.(tools.RenameSymbolPermissionsParams); ok {
+			content = p.generatePatchedFilesContent(pr.Files)
+		}
+	case tools.ApplyCodeActionToolName:
+		if pr, ok := p.permission.Params.(tools.ApplyCodeActionPermissionsParams); ok {
+			content = p.generatePatchedFilesContent(pr.Files)
+		}
 	case tools.FetchToolName:
 		content = p.generateFetchContent()
 	case tools.ViewToolName:
@@ -505,6 +585,49 @@ func (p *permissionDialogCmp) generateMultiEditContent() string {
 	return ""
 }
 
+func (p *permissionDialogCmp) generateApplyPatchContent() string {
+	if pr, ok := p.permission.Params.(tools.ApplyPatchPermissionsParams); ok {
+		return p.generatePatchedFilesContent(pr.Files)
+	}
+	return ""
+}
+
+// generatePatchedFilesContent renders the diffs of all files in full, then
+// scrolls through them together.
+func (p *permissionDialogCmp) generatePatchedFilesContent(files []tools.PatchedFile) string {
+	var diffs []string
+	for _, file := range files {
+		before := fsext.PrettyPath(cmp.Or(file.OldPath, file.Path))
+		if file.Created {
+			before = "/dev/null"
+		}
+		after := fsext.PrettyPath(file.Path)
+		if file.Deleted {
+			after = "/dev/null"
+		}
+		formatter := core.DiffFormatter().
+			Before(before, file.OldContent).
+			After(after, file.NewContent).
+			Width(p.contentViewPort.Width()).
+			XOffset(p.diffXOffset)
+		if p.useDiffSplitMode() {
+			formatter = formatter.Split()
+		} else {
+			formatter = formatter.Unified()
+		}
+		diffs = append(diffs, formatter.String())
+	}
+
+	lines := strings.Split(strings.Join(diffs, "\n"), "\n")
+	height := p.contentViewPort.Height()
+	p.diffYOffset = min(p.diffYOffset, max(0, len(lines)-height))
+	lines = lines[p.diffYOffset:]
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	return strings.Join(lines, "\n")
+}
+
 func (p *permissionDialogCmp) generateFetchContent() string {
 	t := styles.CurrentTheme()
 	baseStyle := t.S().Base.Background(t.BgSubtle)
@@ -691,6 +814,9 @@ func (p *permissionDialogCmp) SetSize() tea.Cmd {
 	case tools.MultiEditToolName:
 		p.width = int(float64(p.wWidth) * 0.8)
 		p.height = int(float64(p.wHeight) * 0.8)
+	case tools.ApplyPatchToolName, tools.RenameSymbolToolName, tools.ApplyCodeActionToolName:
+		p.width = int(float64(p.wWidth) * 0.8)
+		p.height = int(float64(p.wHeight) * 0.8)
 	case tools.FetchToolName:
 		p.width = int(float64(p.wWidth) * 0.8)
 		p.height = int(float64(p.wHeight) * 0.3)
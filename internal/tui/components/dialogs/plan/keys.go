@@ -0,0 +1,111 @@
+package plan
+
+import (
+	"github.com/charmbracelet/bubbles/v2/key"
+)
+
+// KeyMap defines the keyboard bindings for the plan review dialog.
+type KeyMap struct {
+	Left,
+	Right,
+	Tab,
+	Select,
+	Approve,
+	Edit,
+	Reject,
+	Save,
+	Close key.Binding
+}
+
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Left: key.NewBinding(
+			key.WithKeys("left", "shift+tab"),
+			key.WithHelp("←", "previous option"),
+		),
+		Right: key.NewBinding(
+			key.WithKeys("right"),
+			key.WithHelp("→", "next option"),
+		),
+		Tab: key.NewBinding(
+			key.WithKeys("tab"),
+			key.WithHelp("tab", "next option"),
+		),
+		Select: key.NewBinding(
+			key.WithKeys("enter", " "),
+			key.WithHelp("enter", "confirm"),
+		),
+		Approve: key.NewBinding(
+			key.WithKeys("a"),
+			key.WithHelp("a", "approve"),
+		),
+		Edit: key.NewBinding(
+			key.WithKeys("e"),
+			key.WithHelp("e", "edit"),
+		),
+		Reject: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", "reject"),
+		),
+		Save: key.NewBinding(
+			key.WithKeys("ctrl+s"),
+			key.WithHelp("ctrl+s", "approve edited plan"),
+		),
+		Close: key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", "close"),
+		),
+	}
+}
+
+// KeyBindings implements layout.KeyMapProvider
+func (k KeyMap) KeyBindings() []key.Binding {
+	return []key.Binding{
+		k.Tab,
+		k.Select,
+		k.Approve,
+		k.Edit,
+		k.Reject,
+		k.Close,
+	}
+}
+
+// FullHelp implements help.KeyMap.
+func (k KeyMap) FullHelp() [][]key.Binding {
+	m := [][]key.Binding{}
+	slice := k.KeyBindings()
+	for i := 0; i < len(slice); i += 4 {
+		end := min(i+4, len(slice))
+		m = append(m, slice[i:end])
+	}
+	return m
+}
+
+// ShortHelp implements help.KeyMap.
+func (k KeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{
+		k.Approve,
+		k.Edit,
+		k.Reject,
+		k.Close,
+	}
+}
+
+// editKeyMap is the help shown while the plan is being edited.
+type editKeyMap struct {
+	KeyMap
+}
+
+func (k editKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{
+		k.Save,
+		key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", "stop editing"),
+		),
+	}
+}
+
+func (k editKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{k.ShortHelp()}
+}
@@ -0,0 +1,223 @@
+package plan
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	"github.com/charmbracelet/bubbles/v2/textarea"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/tui/components/core"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/lipgloss/v2"
+)
+
+const PlanDialogID dialogs.DialogID = "plan"
+
+const (
+	optionApprove = iota
+	optionEdit
+	optionReject
+	optionCount
+)
+
+// PlanApprovedMsg is sent once the user approved the plan, possibly after
+// editing it.
+type PlanApprovedMsg struct {
+	SessionID string
+	Plan      string
+}
+
+// PlanRejectedMsg is sent when the user rejected the plan.
+type PlanRejectedMsg struct {
+	SessionID string
+}
+
+// PlanDialog represents the dialog used to review a plan made in plan mode.
+type PlanDialog interface {
+	dialogs.DialogModel
+}
+
+type planDialogCmp struct {
+	wWidth  int
+	wHeight int
+	width   int
+
+	sessionID string
+	plan      string
+	selected  int
+	editing   bool
+	textarea  textarea.Model
+	keyMap    KeyMap
+	help      help.Model
+}
+
+// NewPlanDialog creates a dialog to review the given plan.
+func NewPlanDialog(sessionID string, plan agent.Plan) PlanDialog {
+	t := styles.CurrentTheme()
+	ta := textarea.New()
+	ta.SetStyles(t.S().TextArea)
+	ta.ShowLineNumbers = false
+	ta.CharLimit = -1
+	ta.Prompt = ""
+	ta.SetValue(plan.String())
+
+	h := help.New()
+	h.Styles = t.S().Help
+	return &planDialogCmp{
+		sessionID: sessionID,
+		plan:      plan.String(),
+		textarea:  ta,
+		keyMap:    DefaultKeyMap(),
+		help:      h,
+	}
+}
+
+func (p *planDialogCmp) Init() tea.Cmd {
+	return nil
+}
+
+func (p *planDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		p.wWidth = msg.Width
+		p.wHeight = msg.Height
+		p.width = min(100, p.wWidth-8)
+		p.textarea.SetWidth(p.width - 6)
+		p.textarea.SetHeight(p.bodyHeight())
+	case tea.KeyPressMsg:
+		if p.editing {
+			switch {
+			case key.Matches(msg, p.keyMap.Save):
+				p.plan = strings.TrimSpace(p.textarea.Value())
+				return p, p.approve()
+			case key.Matches(msg, p.keyMap.Close):
+				p.plan = strings.TrimSpace(p.textarea.Value())
+				p.editing = false
+				p.textarea.Blur()
+				return p, nil
+			}
+			var cmd tea.Cmd
+			p.textarea, cmd = p.textarea.Update(msg)
+			return p, cmd
+		}
+		switch {
+		case key.Matches(msg, p.keyMap.Left):
+			p.selected = (p.selected + optionCount - 1) % optionCount
+		case key.Matches(msg, p.keyMap.Right, p.keyMap.Tab):
+			p.selected = (p.selected + 1) % optionCount
+		case key.Matches(msg, p.keyMap.Select):
+			return p, p.choose(p.selected)
+		case key.Matches(msg, p.keyMap.Approve):
+			return p, p.choose(optionApprove)
+		case key.Matches(msg, p.keyMap.Edit):
+			return p, p.choose(optionEdit)
+		case key.Matches(msg, p.keyMap.Reject):
+			return p, p.choose(optionReject)
+		case key.Matches(msg, p.keyMap.Close):
+			return p, util.CmdHandler(dialogs.CloseDialogMsg{})
+		}
+	}
+	return p, nil
+}
+
+func (p *planDialogCmp) choose(option int) tea.Cmd {
+	switch option {
+	case optionApprove:
+		return p.approve()
+	case optionEdit:
+		p.editing = true
+		p.textarea.SetValue(p.plan)
+		return p.textarea.Focus()
+	default:
+		return tea.Sequence(
+			util.CmdHandler(dialogs.CloseDialogMsg{}),
+			util.CmdHandler(PlanRejectedMsg{SessionID: p.sessionID}),
+		)
+	}
+}
+
+func (p *planDialogCmp) approve() tea.Cmd {
+	if p.plan == "" {
+		return util.ReportWarn("The plan is empty")
+	}
+	return tea.Sequence(
+		util.CmdHandler(dialogs.CloseDialogMsg{}),
+		util.CmdHandler(PlanApprovedMsg{SessionID: p.sessionID, Plan: p.plan}),
+	)
+}
+
+func (p *planDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	baseStyle := t.S().Base
+
+	var body string
+	if p.editing {
+		body = p.textarea.View()
+	} else {
+		lines := strings.Split(t.S().Text.Width(p.width-6).Render(p.plan), "\n")
+		if len(lines) > p.bodyHeight() {
+			lines = append(lines[:p.bodyHeight()-1], t.S().Muted.Render("… press e to see the whole plan"))
+		}
+		body = strings.Join(lines, "\n")
+	}
+
+	labels := [optionCount]string{"Approve", "Edit", "Reject"}
+	var buttons []string
+	for i, label := range labels {
+		style := t.S().Text.Background(t.BgSubtle)
+		if i == p.selected && !p.editing {
+			style = t.S().Text.Foreground(t.White).Background(t.Secondary)
+		}
+		if i > 0 {
+			buttons = append(buttons, "  ")
+		}
+		buttons = append(buttons, style.Padding(0, 2).Render(label))
+	}
+	buttonsView := baseStyle.Width(p.width - 6).Align(lipgloss.Right).Render(
+		lipgloss.JoinHorizontal(lipgloss.Center, buttons...),
+	)
+
+	var helpView string
+	if p.editing {
+		helpView = p.help.View(editKeyMap{p.keyMap})
+	} else {
+		helpView = p.help.View(p.keyMap)
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		core.Title("Review Plan", p.width-6),
+		"",
+		body,
+		"",
+		buttonsView,
+		"",
+		helpView,
+	)
+	return baseStyle.
+		Width(p.width).
+		Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Render(content)
+}
+
+// bodyHeight is the number of lines available for the plan.
+func (p *planDialogCmp) bodyHeight() int {
+	return max(p.wHeight*2/3-10, 5) // 10 for the border, title, buttons and help
+}
+
+func (p *planDialogCmp) Position() (int, int) {
+	row := p.wHeight/6 - 1
+	col := p.wWidth / 2
+	col -= p.width / 2
+	return row, col
+}
+
+func (p *planDialogCmp) ID() dialogs.DialogID {
+	return PlanDialogID
+}
@@ -0,0 +1,152 @@
+package resend
+
+import (
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/lipgloss/v2"
+)
+
+const (
+	question                        = "Resend the edited message?"
+	warning                         = "Later messages are deleted. File changes can be kept or undone."
+	ResendDialogID dialogs.DialogID = "resend"
+)
+
+const (
+	optionKeepFiles = iota
+	optionRestoreFiles
+	optionCancel
+	optionCount
+)
+
+// ResendMsg is sent once the user confirmed replacing a user message with an
+// edited prompt.
+type ResendMsg struct {
+	SessionID    string
+	MessageID    string
+	Text         string
+	Attachments  []message.Attachment
+	RestoreFiles bool
+}
+
+// ResendDialog represents a confirmation dialog for resending an edited
+// message.
+type ResendDialog interface {
+	dialogs.DialogModel
+}
+
+type resendDialogCmp struct {
+	wWidth  int
+	wHeight int
+
+	msg      ResendMsg
+	selected int
+	keymap   KeyMap
+}
+
+// NewResendDialog creates a new resend confirmation dialog.
+func NewResendDialog(sessionID, messageID, text string, attachments []message.Attachment) ResendDialog {
+	return &resendDialogCmp{
+		msg: ResendMsg{
+			SessionID:   sessionID,
+			MessageID:   messageID,
+			Text:        text,
+			Attachments: attachments,
+		},
+		selected: optionKeepFiles,
+		keymap:   DefaultKeymap(),
+	}
+}
+
+func (r *resendDialogCmp) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles keyboard input for the resend dialog.
+func (r *resendDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		r.wWidth = msg.Width
+		r.wHeight = msg.Height
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, r.keymap.Left):
+			r.selected = (r.selected + optionCount - 1) % optionCount
+			return r, nil
+		case key.Matches(msg, r.keymap.Right, r.keymap.Tab):
+			r.selected = (r.selected + 1) % optionCount
+			return r, nil
+		case key.Matches(msg, r.keymap.EnterSpace):
+			if r.selected == optionCancel {
+				return r, util.CmdHandler(dialogs.CloseDialogMsg{})
+			}
+			r.msg.RestoreFiles = r.selected == optionRestoreFiles
+			return r, tea.Sequence(
+				util.CmdHandler(dialogs.CloseDialogMsg{}),
+				util.CmdHandler(r.msg),
+			)
+		case key.Matches(msg, r.keymap.Close):
+			return r, util.CmdHandler(dialogs.CloseDialogMsg{})
+		}
+	}
+	return r, nil
+}
+
+// View renders the resend dialog with its options.
+func (r *resendDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	baseStyle := t.S().Base
+
+	const horizontalPadding = 2
+	labels := [optionCount]string{"Resend", "Resend & undo files", "Cancel"}
+	var buttons []string
+	for i, label := range labels {
+		style := t.S().Text.Background(t.BgSubtle)
+		if i == r.selected {
+			style = t.S().Text.Foreground(t.White).Background(t.Secondary)
+		}
+		if i > 0 {
+			buttons = append(buttons, "  ")
+		}
+		buttons = append(buttons, style.Padding(0, horizontalPadding).Render(label))
+	}
+
+	width := max(lipgloss.Width(question), lipgloss.Width(warning))
+	buttonsView := baseStyle.Width(width).Align(lipgloss.Right).Render(
+		lipgloss.JoinHorizontal(lipgloss.Center, buttons...),
+	)
+
+	content := baseStyle.Render(
+		lipgloss.JoinVertical(
+			lipgloss.Left,
+			question,
+			t.S().Muted.Render(warning),
+			"",
+			buttonsView,
+		),
+	)
+
+	resendDialogStyle := baseStyle.
+		Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus)
+
+	return resendDialogStyle.Render(content)
+}
+
+func (r *resendDialogCmp) Position() (int, int) {
+	row := r.wHeight / 2
+	row -= 8 / 2
+	col := r.wWidth / 2
+	col -= (max(lipgloss.Width(question), lipgloss.Width(warning)) + 4) / 2
+
+	return row, col
+}
+
+func (r *resendDialogCmp) ID() dialogs.DialogID {
+	return ResendDialogID
+}
@@ -0,0 +1,143 @@
+package rewind
+
+import (
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/lipgloss/v2"
+)
+
+const (
+	question                        = "Rewind the session to this message?"
+	warning                         = "Later messages are deleted and file changes undone."
+	RewindDialogID dialogs.DialogID = "rewind"
+)
+
+// RewindMsg is sent once the user confirmed rewinding the session to the
+// given user message.
+type RewindMsg struct {
+	SessionID string
+	MessageID string
+}
+
+// RewindDialog represents a confirmation dialog for rewinding a session.
+type RewindDialog interface {
+	dialogs.DialogModel
+}
+
+type rewindDialogCmp struct {
+	wWidth  int
+	wHeight int
+
+	sessionID string
+	messageID string
+
+	selectedNo bool // true if "No" button is selected
+	keymap     KeyMap
+}
+
+// NewRewindDialog creates a new rewind confirmation dialog.
+func NewRewindDialog(sessionID, messageID string) RewindDialog {
+	return &rewindDialogCmp{
+		sessionID:  sessionID,
+		messageID:  messageID,
+		selectedNo: true, // Default to "No" for safety
+		keymap:     DefaultKeymap(),
+	}
+}
+
+func (r *rewindDialogCmp) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles keyboard input for the rewind dialog.
+func (r *rewindDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		r.wWidth = msg.Width
+		r.wHeight = msg.Height
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, r.keymap.LeftRight, r.keymap.Tab):
+			r.selectedNo = !r.selectedNo
+			return r, nil
+		case key.Matches(msg, r.keymap.EnterSpace):
+			if !r.selectedNo {
+				return r, r.confirm()
+			}
+			return r, util.CmdHandler(dialogs.CloseDialogMsg{})
+		case key.Matches(msg, r.keymap.Yes):
+			return r, r.confirm()
+		case key.Matches(msg, r.keymap.No, r.keymap.Close):
+			return r, util.CmdHandler(dialogs.CloseDialogMsg{})
+		}
+	}
+	return r, nil
+}
+
+func (r *rewindDialogCmp) confirm() tea.Cmd {
+	return tea.Sequence(
+		util.CmdHandler(dialogs.CloseDialogMsg{}),
+		util.CmdHandler(RewindMsg{
+			SessionID: r.sessionID,
+			MessageID: r.messageID,
+		}),
+	)
+}
+
+// View renders the rewind dialog with Yes/No buttons.
+func (r *rewindDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	baseStyle := t.S().Base
+	yesStyle := t.S().Text
+	noStyle := yesStyle
+
+	if r.selectedNo {
+		noStyle = noStyle.Foreground(t.White).Background(t.Secondary)
+		yesStyle = yesStyle.Background(t.BgSubtle)
+	} else {
+		yesStyle = yesStyle.Foreground(t.White).Background(t.Secondary)
+		noStyle = noStyle.Background(t.BgSubtle)
+	}
+
+	const horizontalPadding = 3
+	yesButton := yesStyle.Padding(0, horizontalPadding).Render("Rewind")
+	noButton := noStyle.Padding(0, horizontalPadding).Render("Cancel")
+
+	width := max(lipgloss.Width(question), lipgloss.Width(warning))
+	buttons := baseStyle.Width(width).Align(lipgloss.Right).Render(
+		lipgloss.JoinHorizontal(lipgloss.Center, yesButton, "  ", noButton),
+	)
+
+	content := baseStyle.Render(
+		lipgloss.JoinVertical(
+			lipgloss.Left,
+			question,
+			t.S().Muted.Render(warning),
+			"",
+			buttons,
+		),
+	)
+
+	rewindDialogStyle := baseStyle.
+		Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus)
+
+	return rewindDialogStyle.Render(content)
+}
+
+func (r *rewindDialogCmp) Position() (int, int) {
+	row := r.wHeight / 2
+	row -= 8 / 2
+	col := r.wWidth / 2
+	col -= (max(lipgloss.Width(question), lipgloss.Width(warning)) + 4) / 2
+
+	return row, col
+}
+
+func (r *rewindDialogCmp) ID() dialogs.DialogID {
+	return RewindDialogID
+}
@@ -0,0 +1,68 @@
+package sessions
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionTree(t *testing.T) {
+	t.Parallel()
+
+	fork := func(id, origin string) session.Session {
+		return session.Session{ID: id, ForkedFromSessionID: origin}
+	}
+
+	tests := []struct {
+		name     string
+		sessions []session.Session
+		// IDs and depths of the nodes, in order.
+		want []string
+	}{
+		{
+			name:     "Empty",
+			sessions: nil,
+			want:     []string{},
+		},
+		{
+			name:     "NoForks",
+			sessions: []session.Session{{ID: "a"}, {ID: "b"}},
+			want:     []string{"a:0", "b:0"},
+		},
+		{
+			name: "NestedForks",
+			sessions: []session.Session{
+				fork("a2", "a1"),
+				{ID: "b"},
+				fork("a1", "a"),
+				{ID: "a"},
+				fork("b1", "b"),
+				fork("a3", "a"),
+			},
+			want: []string{"b:0", "b1:1", "a:0", "a1:1", "a2:2", "a3:1"},
+		},
+		{
+			name: "OrphanedFork",
+			sessions: []session.Session{
+				{ID: "a"},
+				fork("c", "deleted"),
+				fork("c1", "c"),
+			},
+			want: []string{"a:0", "c:0", "c1:1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := []string{}
+			for _, node := range sessionTree(tt.sessions) {
+				got = append(got, fmt.Sprintf("%s:%d", node.session.ID, node.depth))
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -11,10 +11,12 @@ import (
 	"github.com/charmbracelet/crush/internal/app"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/llm/agent"
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/pubsub"
 	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/shell"
 	"github.com/charmbracelet/crush/internal/tui/components/anim"
 	"github.com/charmbracelet/crush/internal/tui/components/chat"
 	"github.com/charmbracelet/crush/internal/tui/components/chat/editor"
@@ -24,9 +26,13 @@ import (
 	"github.com/charmbracelet/crush/internal/tui/components/completions"
 	"github.com/charmbracelet/crush/internal/tui/components/core"
 	"github.com/charmbracelet/crush/internal/tui/components/core/layout"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/commands"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/filepicker"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/models"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/plan"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/resend"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/rewind"
 	"github.com/charmbracelet/crush/internal/tui/page"
 	"github.com/charmbracelet/crush/internal/tui/styles"
 	"github.com/charmbracelet/crush/internal/tui/util"
@@ -41,6 +47,12 @@ type (
 		Focused bool
 	}
 	CancelTimerExpiredMsg struct{}
+	sessionRewoundMsg     struct {
+		sessionID string
+	}
+	messageResentMsg struct {
+		sessionID string
+	}
 )
 
 type PanelType string
@@ -117,7 +129,7 @@ func New(app *app.App) ChatPage {
 		app:         app,
 		keyMap:      DefaultKeyMap(),
 		header:      header.New(app.LSPClients),
-		sidebar:     sidebar.New(app.History, app.LSPClients, false),
+		sidebar:     sidebar.New(app.History, app.Usage, app.LSPClients, false),
 		chat:        chat.New(app),
 		editor:      editor.New(app),
 		splash:      splash.New(),
@@ -183,9 +195,45 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		p.editor = u.(editor.Editor)
 		return p, cmd
 	case chat.SendMsg:
+		if msg.EditMessageID != "" {
+			return p, util.CmdHandler(dialogs.OpenDialogMsg{
+				Model: resend.NewResendDialog(p.session.ID, msg.EditMessageID, msg.Text, msg.Attachments),
+			})
+		}
 		return p, p.sendMessage(msg.Text, msg.Attachments)
+	case chat.EditMsg:
+		if p.focusedPane == PanelTypeChat {
+			p.changeFocus()
+		}
+		u, cmd := p.editor.Update(msg)
+		p.editor = u.(editor.Editor)
+		return p, cmd
+	case resend.ResendMsg:
+		return p, p.resend(msg)
+	case messageResentMsg:
+		if msg.sessionID != p.session.ID {
+			return p, nil
+		}
+		return p, tea.Sequence(
+			p.chat.ReloadSession(),
+			p.sidebar.SetSession(p.session),
+			p.chat.GoToBottom(),
+		)
 	case chat.SessionSelectedMsg:
 		return p, p.setSession(msg)
+	case rewind.RewindMsg:
+		return p, p.rewind(msg)
+	case chat.ForkMsg:
+		return p, p.fork(msg)
+	case sessionRewoundMsg:
+		if msg.sessionID != p.session.ID {
+			return p, nil
+		}
+		return p, tea.Batch(
+			p.chat.ReloadSession(),
+			p.sidebar.SetSession(p.session),
+			util.ReportInfo("Session rewound"),
+		)
 	case splash.SubmitAPIKeyMsg:
 		u, cmd := p.splash.Update(msg)
 		p.splash = u.(splash.Splash)
@@ -204,6 +252,16 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return p, tea.Batch(p.SetSize(p.width, p.height), cmd)
 	case commands.ToggleThinkingMsg:
 		return p, p.toggleThinking()
+	case commands.TogglePlanModeMsg:
+		return p, p.togglePlanMode()
+	case plan.PlanApprovedMsg:
+		return p, p.approvePlan(msg)
+	case plan.PlanRejectedMsg:
+		p.app.CoderAgent.RejectPlan(msg.SessionID)
+		if p.focusedPane == PanelTypeChat {
+			p.changeFocus()
+		}
+		return p, util.ReportInfo("Plan rejected, tell the agent what to change")
 	case commands.OpenExternalEditorMsg:
 		u, cmd := p.editor.Update(msg)
 		p.editor = u.(editor.Editor)
@@ -257,7 +315,11 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		return p, tea.Batch(cmds...)
 
-	case pubsub.Event[history.File], sidebar.SessionFilesMsg:
+	case pubsub.Event[agent.AgentEvent]:
+		u, cmd := p.sidebar.Update(msg)
+		p.sidebar = u.(sidebar.Sidebar)
+		return p, cmd
+	case pubsub.Event[history.File], pubsub.Event[shell.Job], sidebar.SessionFilesMsg, sidebar.SessionUsageMsg:
 		u, cmd := p.sidebar.Update(msg)
 		p.sidebar = u.(sidebar.Sidebar)
 		cmds = append(cmds, cmd)
@@ -431,6 +493,10 @@ func (p *chatPage) View() string {
 		lipgloss.NewLayer(chatView).X(0).Y(0),
 	}
 
+	if queueView := p.editor.QueueView(); queueView != "" && p.session.ID != "" && !p.splashFullScreen {
+		layers = append(layers, lipgloss.NewLayer(queueView).X(0).Y(p.height-EditorHeight-lipgloss.Height(queueView)))
+	}
+
 	if p.showingDetails {
 		style := t.S().Base.
 			Width(p.detailsWidth).
@@ -578,10 +644,33 @@ func (p *chatPage) setSession(session session.Session) tea.Cmd {
 	cmds = append(cmds, p.sidebar.SetSession(session))
 	cmds = append(cmds, p.header.SetSession(session))
 	cmds = append(cmds, p.editor.SetSession(session))
+	cmds = append(cmds, p.estimateTokens(session.ID))
 
 	return tea.Sequence(cmds...)
 }
 
+// estimateTokens estimates the size of the session's next request, which the
+// sidebar shows.
+func (p *chatPage) estimateTokens(sessionID string) tea.Cmd {
+	if sessionID == "" || p.app.CoderAgent == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		tokens, err := p.app.CoderAgent.EstimateTokens(context.Background(), sessionID)
+		if err != nil {
+			return nil
+		}
+		return pubsub.Event[agent.AgentEvent]{
+			Type: pubsub.CreatedEvent,
+			Payload: agent.AgentEvent{
+				Type:      agent.AgentEventTypeTokens,
+				SessionID: sessionID,
+				Tokens:    tokens,
+			},
+		}
+	}
+}
+
 func (p *chatPage) changeFocus() {
 	if p.session.ID == "" {
 		return
@@ -598,6 +687,73 @@ func (p *chatPage) changeFocus() {
 	}
 }
 
+func (p *chatPage) rewind(msg rewind.RewindMsg) tea.Cmd {
+	return func() tea.Msg {
+		if err := p.app.Rewind(context.Background(), msg.SessionID, msg.MessageID); err != nil {
+			return util.InfoMsg{
+				Type: util.InfoTypeError,
+				Msg:  "Failed to rewind session: " + err.Error(),
+			}
+		}
+		return sessionRewoundMsg{sessionID: msg.SessionID}
+	}
+}
+
+func (p *chatPage) resend(msg resend.ResendMsg) tea.Cmd {
+	return func() tea.Msg {
+		_, err := p.app.Resend(context.Background(), msg.SessionID, msg.MessageID, msg.Text, msg.Attachments, msg.RestoreFiles)
+		if err != nil {
+			return util.InfoMsg{
+				Type: util.InfoTypeError,
+				Msg:  "Failed to resend message: " + err.Error(),
+			}
+		}
+		return messageResentMsg{sessionID: msg.SessionID}
+	}
+}
+
+func (p *chatPage) fork(msg chat.ForkMsg) tea.Cmd {
+	return func() tea.Msg {
+		fork, err := p.app.Fork(context.Background(), msg.SessionID, msg.MessageID)
+		if err != nil {
+			return util.InfoMsg{
+				Type: util.InfoTypeError,
+				Msg:  "Failed to fork session: " + err.Error(),
+			}
+		}
+		return chat.SessionSelectedMsg(fork)
+	}
+}
+
+func (p *chatPage) togglePlanMode() tea.Cmd {
+	session := p.session
+	var cmds []tea.Cmd
+	if session.ID == "" {
+		newSession, err := p.app.Sessions.Create(context.Background(), "New Session")
+		if err != nil {
+			return util.ReportError(err)
+		}
+		session = newSession
+		cmds = append(cmds, util.CmdHandler(chat.SessionSelectedMsg(session)))
+	}
+	enabled := !p.app.CoderAgent.IsPlanMode(session.ID)
+	p.app.CoderAgent.SetPlanMode(session.ID, enabled)
+	if enabled {
+		cmds = append(cmds, util.ReportInfo("Plan mode on, the agent will propose a plan before making changes"))
+	} else {
+		cmds = append(cmds, util.ReportInfo("Plan mode off"))
+	}
+	return tea.Batch(cmds...)
+}
+
+func (p *chatPage) approvePlan(msg plan.PlanApprovedMsg) tea.Cmd {
+	_, err := p.app.CoderAgent.ApprovePlan(context.Background(), msg.SessionID, msg.Plan)
+	if err != nil {
+		return util.ReportError(err)
+	}
+	return p.chat.GoToBottom()
+}
+
 func (p *chatPage) cancel() tea.Cmd {
 	if p.isCanceling {
 		p.isCanceling = false
@@ -635,6 +791,10 @@ func (p *chatPage) sendMessage(text string, attachments []message.Attachment) te
 		session = newSession
 		cmds = append(cmds, util.CmdHandler(chat.SessionSelectedMsg(session)))
 	}
+	if p.app.CoderAgent.IsSessionBusy(session.ID) {
+		p.app.CoderAgent.Queue(session.ID, text, attachments...)
+		return util.ReportInfo("Prompt queued")
+	}
 	_, err := p.app.CoderAgent.Run(context.Background(), session.ID, text, attachments...)
 	if err != nil {
 		return util.ReportError(err)
@@ -25,6 +25,7 @@ import (
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/filepicker"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/models"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/permissions"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/plan"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/quit"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/sessions"
 	"github.com/charmbracelet/crush/internal/tui/page"
@@ -196,6 +197,16 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return a, util.ReportInfo(fmt.Sprintf("%s model changed to %s", modelTypeName, msg.Model.Model))
 
+	// Agent Switch
+	case commands.SwitchAgentMsg:
+		if a.app.CoderAgent.IsBusy() {
+			return a, util.ReportWarn("Agent is busy, please wait...")
+		}
+		if err := a.app.SwitchAgent(msg.AgentID); err != nil {
+			return a, util.ReportError(fmt.Errorf("failed to switch agent: %w", err))
+		}
+		return a, util.ReportInfo(fmt.Sprintf("Switched to %s agent", a.app.ActiveAgent().Name))
+
 	// File Picker
 	case commands.OpenFilePickerMsg:
 		if a.dialog.ActiveDialogID() == filepicker.FilePickerID {
@@ -236,6 +247,35 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, dialogCmd)
 		}
 
+		// Ask for review of a plan proposed in plan mode
+		if payload.Type == agent.AgentEventTypePlan && payload.SessionID == a.selectedSessionID {
+			cmds = append(cmds, util.CmdHandler(dialogs.OpenDialogMsg{
+				Model: plan.NewPlanDialog(payload.SessionID, payload.Plan),
+			}))
+		}
+
+		// Tell which model took over when the current one kept failing
+		if payload.Type == agent.AgentEventTypeFallback {
+			cmds = append(cmds, util.ReportWarn(fmt.Sprintf("Switched to %s after an error: %v", payload.Fallback.Model, payload.Error)))
+		}
+
+		// Show that the provider is being retried
+		if payload.Type == agent.AgentEventTypeRetry && payload.SessionID == a.selectedSessionID {
+			cmds = append(cmds, util.ReportWarn(fmt.Sprintf("Retrying in %s (attempt %d/%d)", payload.Retry.Delay.Round(time.Second), payload.Retry.Attempt, payload.Retry.MaxAttempts)))
+		}
+
+		// Tell that old messages were left out of the request
+		if payload.Type == agent.AgentEventTypeContextTrimmed && payload.SessionID == a.selectedSessionID {
+			cmds = append(cmds, util.ReportInfo("Left the oldest messages out of the request to fit the context window"))
+		}
+
+		// Keep the estimate of the next request up to date
+		if payload.Type == agent.AgentEventTypeTokens || (payload.Done && payload.Type == agent.AgentEventTypeResponse) {
+			updated, pageCmd := a.pages[a.currentPage].Update(msg)
+			a.pages[a.currentPage] = updated.(util.Model)
+			cmds = append(cmds, pageCmd)
+		}
+
 		// Handle auto-compact logic
 		if payload.Done && payload.Type == agent.AgentEventTypeResponse && a.selectedSessionID != "" {
 			// Get current session to check token usage
@@ -244,7 +284,8 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				model := a.app.CoderAgent.Model()
 				contextWindow := model.ContextWindow
 				tokens := session.CompletionTokens + session.PromptTokens
-				if (tokens >= int64(float64(contextWindow)*0.95)) && !config.Get().Options.DisableAutoSummarize { // Show compact confirmation dialog
+				opts := config.Get().Options
+				if (tokens >= int64(float64(contextWindow)*opts.Summarize.Threshold)) && !opts.DisableAutoSummarize { // Show compact confirmation dialog
 					cmds = append(cmds, util.CmdHandler(dialogs.OpenDialogMsg{
 						Model: compact.NewCompactDialogCmp(a.app.CoderAgent, a.selectedSessionID, false),
 					}))
@@ -0,0 +1,108 @@
+package usage
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// GroupBy is what records are totaled by in a report.
+type GroupBy string
+
+const (
+	GroupByDay     GroupBy = "day"
+	GroupByProject GroupBy = "project"
+	GroupByModel   GroupBy = "model"
+	GroupBySession GroupBy = "session"
+)
+
+// Total sums up the records that share a key.
+type Total struct {
+	Key string `json:"key"`
+	// Title of the session, when grouping by session.
+	Title               string  `json:"title,omitempty"`
+	Calls               int64   `json:"calls"`
+	InputTokens         int64   `json:"input_tokens"`
+	OutputTokens        int64   `json:"output_tokens"`
+	CacheCreationTokens int64   `json:"cache_creation_tokens"`
+	CacheReadTokens     int64   `json:"cache_read_tokens"`
+	Cost                float64 `json:"cost"`
+	// Average latency of the calls, in milliseconds.
+	AvgLatencyMs int64 `json:"avg_latency_ms"`
+}
+
+// CacheHitRate returns the share of the input tokens that were read from the
+// prompt cache.
+func (t Total) CacheHitRate() float64 {
+	return t.cacheShare(t.CacheReadTokens)
+}
+
+// CacheMissRate returns the share of the input tokens that were written to
+// the prompt cache because they weren't in it yet.
+func (t Total) CacheMissRate() float64 {
+	return t.cacheShare(t.CacheCreationTokens)
+}
+
+func (t Total) cacheShare(tokens int64) float64 {
+	input := t.InputTokens + t.CacheCreationTokens + t.CacheReadTokens
+	if input == 0 {
+		return 0
+	}
+	return float64(tokens) / float64(input)
+}
+
+// Key returns the key of the record when grouping by the given field. Days
+// are in local time.
+func (r Record) Key(by GroupBy) (string, error) {
+	switch by {
+	case GroupByDay:
+		return time.Unix(r.CreatedAt, 0).Format(time.DateOnly), nil
+	case GroupByProject:
+		return r.Project, nil
+	case GroupByModel:
+		return r.Provider + "/" + r.Model, nil
+	case GroupBySession:
+		return r.SessionID, nil
+	default:
+		return "", fmt.Errorf("unknown grouping %q, use one of day, project, model or session", by)
+	}
+}
+
+// Totals groups the records and sums them up. Days come in order, everything
+// else by decreasing cost.
+func Totals(records []Record, by GroupBy) ([]Total, error) {
+	var totals []Total
+	index := map[string]int{}
+	latency := map[string]time.Duration{}
+	for _, r := range records {
+		key, err := r.Key(by)
+		if err != nil {
+			return nil, err
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(totals)
+			index[key] = i
+			totals = append(totals, Total{Key: key})
+		}
+		t := &totals[i]
+		t.Calls++
+		t.InputTokens += r.InputTokens
+		t.OutputTokens += r.OutputTokens
+		t.CacheCreationTokens += r.CacheCreationTokens
+		t.CacheReadTokens += r.CacheReadTokens
+		t.Cost += r.Cost
+		latency[key] += r.Latency
+	}
+	for i := range totals {
+		totals[i].AvgLatencyMs = (latency[totals[i].Key] / time.Duration(totals[i].Calls)).Milliseconds()
+	}
+
+	if by == GroupByDay {
+		slices.SortFunc(totals, func(a, b Total) int { return cmp.Compare(a.Key, b.Key) })
+	} else {
+		slices.SortStableFunc(totals, func(a, b Total) int { return cmp.Compare(b.Cost, a.Cost) })
+	}
+	return totals, nil
+}
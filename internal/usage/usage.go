@@ -0,0 +1,121 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/google/uuid"
+)
+
+// Kinds of provider calls.
+const (
+	KindAgent     = "agent"
+	KindTask      = "task"
+	KindTitle     = "title"
+	KindSummarize = "summarize"
+)
+
+// Record is one call to a provider and what it cost.
+type Record struct {
+	ID                  string
+	SessionID           string
+	MessageID           string
+	Kind                string
+	Project             string
+	Provider            string
+	Model               string
+	InputTokens         int64
+	OutputTokens        int64
+	CacheCreationTokens int64
+	CacheReadTokens     int64
+	Cost                float64
+	// Time until the response was complete, retries included.
+	Latency   time.Duration
+	CreatedAt int64
+}
+
+type Service interface {
+	Create(ctx context.Context, record Record) (Record, error)
+	// List returns the records created in [since, until).
+	List(ctx context.Context, since, until time.Time) ([]Record, error)
+	// ListBySession returns the records of the session, oldest first.
+	ListBySession(ctx context.Context, sessionID string) ([]Record, error)
+}
+
+type service struct {
+	q db.Querier
+}
+
+func NewService(q db.Querier) Service {
+	return &service{q: q}
+}
+
+func (s *service) Create(ctx context.Context, record Record) (Record, error) {
+	dbUsage, err := s.q.CreateUsage(ctx, db.CreateUsageParams{
+		ID:                  uuid.New().String(),
+		SessionID:           record.SessionID,
+		MessageID:           sql.NullString{String: record.MessageID, Valid: record.MessageID != ""},
+		Kind:                record.Kind,
+		Project:             record.Project,
+		Provider:            record.Provider,
+		Model:               record.Model,
+		InputTokens:         record.InputTokens,
+		OutputTokens:        record.OutputTokens,
+		CacheCreationTokens: record.CacheCreationTokens,
+		CacheReadTokens:     record.CacheReadTokens,
+		Cost:                record.Cost,
+		LatencyMs:           record.Latency.Milliseconds(),
+	})
+	if err != nil {
+		return Record{}, err
+	}
+	return fromDBItem(dbUsage), nil
+}
+
+func (s *service) List(ctx context.Context, since, until time.Time) ([]Record, error) {
+	dbUsage, err := s.q.ListUsage(ctx, db.ListUsageParams{
+		Since: since.Unix(),
+		Until: until.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	records := make([]Record, len(dbUsage))
+	for i, item := range dbUsage {
+		records[i] = fromDBItem(item)
+	}
+	return records, nil
+}
+
+func (s *service) ListBySession(ctx context.Context, sessionID string) ([]Record, error) {
+	dbUsage, err := s.q.ListUsageBySession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]Record, len(dbUsage))
+	for i, item := range dbUsage {
+		records[i] = fromDBItem(item)
+	}
+	return records, nil
+}
+
+func fromDBItem(item db.Usage) Record {
+	return Record{
+		ID:                  item.ID,
+		SessionID:           item.SessionID,
+		MessageID:           item.MessageID.String,
+		Kind:                item.Kind,
+		Project:             item.Project,
+		Provider:            item.Provider,
+		Model:               item.Model,
+		InputTokens:         item.InputTokens,
+		OutputTokens:        item.OutputTokens,
+		CacheCreationTokens: item.CacheCreationTokens,
+		CacheReadTokens:     item.CacheReadTokens,
+		Cost:                item.Cost,
+		Latency:             time.Duration(item.LatencyMs) * time.Millisecond,
+		CreatedAt:           item.CreatedAt,
+	}
+}
@@ -0,0 +1,88 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	conn, err := db.Connect(ctx, t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	usage := NewService(db.New(conn))
+	created, err := usage.Create(ctx, Record{
+		SessionID:   "session",
+		MessageID:   "message",
+		Kind:        KindAgent,
+		Project:     "/src/app",
+		Provider:    "anthropic",
+		Model:       "claude-sonnet-4",
+		InputTokens: 1000,
+		Cost:        0.25,
+		Latency:     1500 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, created.ID)
+	require.NotZero(t, created.CreatedAt)
+	_, err = usage.Create(ctx, Record{SessionID: "session", Kind: KindTitle, Project: "/src/app", Provider: "openai", Model: "gpt-4.1-mini"})
+	require.NoError(t, err)
+
+	records, err := usage.List(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, created, records[0])
+	require.Equal(t, "", records[1].MessageID)
+
+	records, err = usage.List(ctx, time.Now().Add(time.Hour), time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, records)
+
+	_, err = usage.Create(ctx, Record{SessionID: "other", Kind: KindAgent, Project: "/src/app", Provider: "openai", Model: "gpt-4.1"})
+	require.NoError(t, err)
+	records, err = usage.ListBySession(ctx, "session")
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, created, records[0])
+}
+
+func TestTotals(t *testing.T) {
+	t.Parallel()
+
+	day := time.Date(2025, 8, 1, 12, 0, 0, 0, time.Local).Unix()
+	records := []Record{
+		{SessionID: "a", Provider: "openai", Model: "gpt-4.1", InputTokens: 100, Cost: 1, Latency: time.Second, CreatedAt: day + 86400},
+		{SessionID: "b", Provider: "anthropic", Model: "claude", OutputTokens: 50, Cost: 3, Latency: 3 * time.Second, CreatedAt: day},
+		{SessionID: "a", Provider: "openai", Model: "gpt-4.1", CacheReadTokens: 20, Cost: 0.5, Latency: 2 * time.Second, CreatedAt: day},
+	}
+
+	totals, err := Totals(records, GroupByDay)
+	require.NoError(t, err)
+	require.Len(t, totals, 2)
+	require.Equal(t, Total{Key: "2025-08-01", Calls: 2, OutputTokens: 50, CacheReadTokens: 20, Cost: 3.5, AvgLatencyMs: 2500}, totals[0])
+	require.Equal(t, "2025-08-02", totals[1].Key)
+
+	// Other groupings put the most expensive first.
+	totals, err = Totals(records, GroupByModel)
+	require.NoError(t, err)
+	require.Equal(t, "anthropic/claude", totals[0].Key)
+	require.Equal(t, Total{Key: "openai/gpt-4.1", Calls: 2, InputTokens: 100, CacheReadTokens: 20, Cost: 1.5, AvgLatencyMs: 1500}, totals[1])
+
+	_, err = Totals(records, "team")
+	require.Error(t, err)
+}
+
+func TestCacheRates(t *testing.T) {
+	t.Parallel()
+
+	total := Total{InputTokens: 100, CacheCreationTokens: 200, CacheReadTokens: 700}
+	require.InDelta(t, 0.7, total.CacheHitRate(), 1e-9)
+	require.InDelta(t, 0.2, total.CacheMissRate(), 1e-9)
+	require.Zero(t, Total{}.CacheHitRate())
+}
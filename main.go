@@ -11,9 +11,14 @@ import (
 
 	"github.com/charmbracelet/crush/internal/cmd"
 	"github.com/charmbracelet/crush/internal/log"
+	"github.com/charmbracelet/crush/internal/shell"
 )
 
 func main() {
+	// Sandboxed commands of the bash tool start crush again to set up their
+	// sandbox.
+	shell.RunSandboxHelper()
+
 	defer log.RecoverPanic("main", func() {
 		slog.Error("Application terminated due to unhandled panic")
 	})